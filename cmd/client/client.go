@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"os"
 	"path"
 
 	"go.uber.org/zap"
@@ -15,8 +17,9 @@ import (
 )
 
 var (
-	fileMode   bool
-	outputFile string
+	fileMode    bool
+	outputFile  string
+	withHistory bool
 )
 
 var rootCmd = &cobra.Command{
@@ -40,10 +43,7 @@ var rootCmd = &cobra.Command{
 			logger.Fatal("LoadConfig", zap.Error(err))
 		}
 
-		db, err := database.GetOrCreateSQLite3DB(path.Join(config.HomeDir, "database.sqlite3"))
-		if err != nil {
-			logger.Fatal("database.GetOrCreateSQLite3DB", zap.Error(err))
-		}
+		db, keyring := mustOpenDatabase(ctx, logger, config)
 
 		openapiClient, err := openapi.NewClientWithResponses(
 			config.Server.URL,
@@ -52,7 +52,7 @@ var rootCmd = &cobra.Command{
 			logger.Fatal("openapi.NewClientWithResponses", zap.Error(err))
 		}
 
-		err = client.InitUI(config, openapiClient, db)
+		err = client.InitUI(config, openapiClient, db, keyring)
 		if err != nil {
 			logger.Fatal("NewUI", zap.Error(err))
 		}
@@ -84,9 +84,11 @@ func mustGetCLIHandler(
 		logger.Fatal("LoadConfig", zap.Error(err))
 	}
 
-	db, err := database.GetOrCreateSQLite3DB(path.Join(config.HomeDir, "database.sqlite3"))
+	db, keyring := mustOpenDatabase(ctx, logger, config)
+
+	history, err := client.NewMessageHistory(keyring, config.Database.EncryptMessages)
 	if err != nil {
-		logger.Fatal("database.GetOrCreateSQLite3DB", zap.Error(err))
+		logger.Fatal("client.NewMessageHistory", zap.Error(err))
 	}
 
 	openapiClient, err := openapi.NewClientWithResponses(
@@ -96,10 +98,33 @@ func mustGetCLIHandler(
 		logger.Fatal("openapi.NewClientWithResponses", zap.Error(err))
 	}
 
-	controller := client.NewController(openapiClient, db)
+	secrets, err := client.NewLocalSecrets(keyring)
+	if err != nil {
+		logger.Fatal("client.NewLocalSecrets", zap.Error(err))
+	}
+
+	controller := client.NewController(openapiClient, db, keyring, history, secrets)
 	return client.NewCLIHandler(logger, controller)
 }
 
+// mustOpenDatabase opens the local database and unlocks its keyring, and exits without touching
+// the database on a wrong passphrase.
+func mustOpenDatabase(
+	ctx context.Context,
+	logger *zap.Logger,
+	config *client.Config,
+) (*sql.DB, *client.Keyring) {
+	db, keyring, err := client.OpenDatabase(ctx, config)
+	if err != nil {
+		if errors.Is(err, client.ErrWrongPassphrase) {
+			fmt.Fprintln(os.Stderr, "Wrong passphrase")
+			os.Exit(1)
+		}
+		logger.Fatal("client.OpenDatabase", zap.Error(err))
+	}
+	return db, keyring
+}
+
 var messageCmd = &cobra.Command{
 	Use:   "message",
 	Short: "Messages commands",
@@ -173,15 +198,222 @@ var createUserCmd = &cobra.Command{
 	},
 }
 
+var linkDeviceCmd = &cobra.Command{
+	Short: "Create a key on this device for an existing user",
+	Use:   "link <username>",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		cliHandler := mustGetCLIHandler(ctx)
+
+		username := args[0]
+		err := cliHandler.LinkDevice(ctx, username)
+		if err != nil {
+			return fmt.Errorf("cliHandler.LinkDevice: %w", err)
+		}
+		return nil
+	},
+}
+
+var addDeviceCmd = &cobra.Command{
+	Short: "Add a linked device's key to a user, from one of its existing devices",
+	Use:   "add-device <username> <public-key>",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		cliHandler := mustGetCLIHandler(ctx)
+
+		username := args[0]
+		publicKey := args[1]
+		err := cliHandler.AddDevice(ctx, username, publicKey)
+		if err != nil {
+			return fmt.Errorf("cliHandler.AddDevice: %w", err)
+		}
+		return nil
+	},
+}
+
+var rotateKeyCmd = &cobra.Command{
+	Short: "Replace a user's key with a new one",
+	Use:   "rotate-key <username>",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		cliHandler := mustGetCLIHandler(ctx)
+
+		username := args[0]
+		err := cliHandler.RotateKey(ctx, username)
+		if err != nil {
+			return fmt.Errorf("cliHandler.RotateKey: %w", err)
+		}
+		return nil
+	},
+}
+
+var readReceiptsCmd = &cobra.Command{
+	Short: "Choose whether a user tells remote users when their messages are read",
+	Use:   "read-receipts <username> <on|off>",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		cliHandler := mustGetCLIHandler(ctx)
+
+		username := args[0]
+		var enabled bool
+		switch args[1] {
+		case "on":
+			enabled = true
+		case "off":
+			enabled = false
+		default:
+			return fmt.Errorf("invalid value %q, expected on or off", args[1])
+		}
+		err := cliHandler.SetReadReceipts(ctx, username, enabled)
+		if err != nil {
+			return fmt.Errorf("cliHandler.SetReadReceipts: %w", err)
+		}
+		return nil
+	},
+}
+
+var verifyContactCmd = &cobra.Command{
+	Short: "Compare the safety number of a local user and a remote user, and approve the remote user's key",
+	Use:   "verify <username> <remote-username>",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		cliHandler := mustGetCLIHandler(ctx)
+
+		username := args[0]
+		remoteUsername := args[1]
+		err := cliHandler.VerifyContact(ctx, username, remoteUsername)
+		if err != nil {
+			return fmt.Errorf("cliHandler.VerifyContact: %w", err)
+		}
+		return nil
+	},
+}
+
+var exportIdentityCmd = &cobra.Command{
+	Short: "Export a user's identity to a passphrase-encrypted file, to move it to another machine",
+	Use:   "export [--history] <username> <file>",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		cliHandler := mustGetCLIHandler(ctx)
+
+		username := args[0]
+		filePath := args[1]
+		err := cliHandler.ExportIdentity(ctx, username, filePath, withHistory)
+		if err != nil {
+			return fmt.Errorf("cliHandler.ExportIdentity: %w", err)
+		}
+		return nil
+	},
+}
+
+var importIdentityCmd = &cobra.Command{
+	Short: "Import a user's identity from a file created by export",
+	Use:   "import <file>",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		cliHandler := mustGetCLIHandler(ctx)
+
+		filePath := args[0]
+		err := cliHandler.ImportIdentity(ctx, filePath)
+		if err != nil {
+			return fmt.Errorf("cliHandler.ImportIdentity: %w", err)
+		}
+		return nil
+	},
+}
+
+var databaseCmd = &cobra.Command{
+	Use:   "database",
+	Short: "Local database commands",
+}
+
+var databaseEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the messages stored in plain text in the local database",
+	Long: `Encrypt the messages stored in plain text in the local database.
+
+Only the content of messages is encrypted. Metadata stays in plain text: contact names,
+conversation partners, and the time, kind, sender and recipient of every message can still be
+read by anyone with access to the database file.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		cliHandler := mustGetCLIHandler(ctx)
+
+		err := cliHandler.EncryptMessageHistory(ctx)
+		if err != nil {
+			return fmt.Errorf("cliHandler.EncryptMessageHistory: %w", err)
+		}
+		return nil
+	},
+}
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Keep the private keys unlocked for the configured time, to avoid typing the passphrase again",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		logger, err := zap.NewDevelopment()
+		if err != nil {
+			return fmt.Errorf("zap.NewDevelopment: %w", err)
+		}
+		config, err := client.LoadConfig(ctx)
+		if err != nil {
+			return fmt.Errorf("client.LoadConfig: %w", err)
+		}
+		db, err := database.GetOrCreateSQLite3DB(path.Join(config.HomeDir, "database.sqlite3"))
+		if err != nil {
+			return fmt.Errorf("database.GetOrCreateSQLite3DB: %w", err)
+		}
+		fmt.Printf("Agent listening on %q for %s\n", config.Agent.Socket, config.Agent.TTL)
+		err = client.RunAgent(ctx, logger, config, db)
+		if err != nil {
+			return fmt.Errorf("client.RunAgent: %w", err)
+		}
+		return nil
+	},
+}
+
 func main() {
 	messageSendCmd.Flags().BoolVar(&fileMode, "file", false, "Send a file instead of a text message")
 	messageReadCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write output to file instead of stdout")
+	exportIdentityCmd.Flags().BoolVar(&withHistory, "history", false, "Include the conversation history")
 	messageCmd.AddCommand(messageSendCmd)
 	messageCmd.AddCommand(messageReadCmd)
 	rootCmd.AddCommand(messageCmd)
 
 	userCmd.AddCommand(createUserCmd)
+	userCmd.AddCommand(linkDeviceCmd)
+	userCmd.AddCommand(addDeviceCmd)
+	userCmd.AddCommand(rotateKeyCmd)
+	userCmd.AddCommand(readReceiptsCmd)
+	userCmd.AddCommand(verifyContactCmd)
+	userCmd.AddCommand(exportIdentityCmd)
+	userCmd.AddCommand(importIdentityCmd)
 	rootCmd.AddCommand(userCmd)
 
+	databaseCmd.AddCommand(databaseEncryptCmd)
+	rootCmd.AddCommand(databaseCmd)
+
+	rootCmd.AddCommand(agentCmd)
+
 	_ = rootCmd.Execute()
 }
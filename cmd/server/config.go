@@ -3,8 +3,14 @@ package main
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sethvargo/go-envconfig"
+	"go.uber.org/zap"
+
+	"github.com/marc921/talk/internal/server/blobstore"
+	"github.com/marc921/talk/internal/server/fanout"
 )
 
 type Config struct {
@@ -14,6 +20,31 @@ type Config struct {
 	AuthTokenSecretKey []byte `env:"AUTH_TOKEN_SECRET_KEY, required"`
 	TLS                bool   `env:"TLS, default=true"`
 	DatabaseURL        string `env:"DATABASE_URL, required"`
+	// Lifetime of the auth tokens, to shorten when refresh tokens are issued
+	AuthTokenExpiration time.Duration `env:"AUTH_TOKEN_EXPIRATION, default=1h"`
+	// Lifetime of the refresh tokens issued along with auth tokens, zero not to issue any
+	RefreshTokenExpiration time.Duration `env:"REFRESH_TOKEN_EXPIRATION, default=0"`
+	// Delay after which messages fetched but not acknowledged by a device are delivered again
+	MessageLeaseTimeout time.Duration `env:"MESSAGE_LEASE_TIMEOUT, default=5m"`
+	// Where the encrypted chunks of file transfers are stored: "filesystem" or "s3"
+	BlobStore string `env:"BLOB_STORE, default=filesystem"`
+	// Directory of the filesystem blob store
+	BlobDir string             `env:"BLOB_DIR, default=blobs"`
+	S3      blobstore.S3Config `env:", prefix=S3_"`
+	// Delay after which transfers are deleted even if not downloaded by all the recipient's devices,
+	// at least UNDELIVERED_MESSAGE_RETENTION for file messages not to outlive their chunks (90 days)
+	TransferRetention time.Duration `env:"TRANSFER_RETENTION, default=2160h"`
+	// Delay after which messages delivered to their recipient are deleted (30 days)
+	DeliveredMessageRetention time.Duration `env:"DELIVERED_MESSAGE_RETENTION, default=720h"`
+	// Delay after which messages never delivered to their recipient are deleted (90 days)
+	UndeliveredMessageRetention time.Duration `env:"UNDELIVERED_MESSAGE_RETENTION, default=2160h"`
+	// Interval between runs of the janitor enforcing the retention periods
+	JanitorInterval time.Duration `env:"JANITOR_INTERVAL, default=1h"`
+	// How websocket events reach the other server replicas: "memory" for a single replica,
+	// or "postgres" for replicas sharing the database
+	HubFanout string `env:"HUB_FANOUT, default=memory"`
+	// Address of the listener serving metrics at /debug/vars, kept off the public one; empty to disable
+	MetricsAddr string `env:"METRICS_ADDR, default=localhost:9090"`
 }
 
 func LoadConfig(ctx context.Context) (*Config, error) {
@@ -21,5 +52,59 @@ func LoadConfig(ctx context.Context) (*Config, error) {
 	if err := envconfig.Process(ctx, cfg); err != nil {
 		return nil, fmt.Errorf("envconfig.Process: %w", err)
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("validate: %w", err)
+	}
 	return cfg, nil
 }
+
+// validate rejects retention periods that would make the janitor delete everything it finds,
+// or delete the chunks of file messages still waiting to be fetched.
+func (c *Config) validate() error {
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"TRANSFER_RETENTION", c.TransferRetention},
+		{"DELIVERED_MESSAGE_RETENTION", c.DeliveredMessageRetention},
+		{"UNDELIVERED_MESSAGE_RETENTION", c.UndeliveredMessageRetention},
+		{"JANITOR_INTERVAL", c.JanitorInterval},
+	}
+	for _, duration := range durations {
+		if duration.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", duration.name, duration.value)
+		}
+	}
+	if c.TransferRetention < c.UndeliveredMessageRetention {
+		return fmt.Errorf(
+			"TRANSFER_RETENTION (%s) must be at least UNDELIVERED_MESSAGE_RETENTION (%s)",
+			c.TransferRetention,
+			c.UndeliveredMessageRetention,
+		)
+	}
+	return nil
+}
+
+// NewBlobStore returns the blob store selected by the configuration.
+func (c *Config) NewBlobStore() (blobstore.Store, error) {
+	switch c.BlobStore {
+	case "filesystem":
+		return blobstore.NewFilesystemStore(c.BlobDir)
+	case "s3":
+		return blobstore.NewS3Store(c.S3)
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", c.BlobStore)
+	}
+}
+
+// NewFanout returns the fan-out of websocket events selected by the configuration.
+func (c *Config) NewFanout(logger *zap.Logger, db *pgxpool.Pool) (fanout.Fanout, error) {
+	switch c.HubFanout {
+	case "memory":
+		return fanout.NewMemory(), nil
+	case "postgres":
+		return fanout.NewPostgres(logger, db), nil
+	default:
+		return nil, fmt.Errorf("unknown hub fanout %q", c.HubFanout)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"embed"
 	"errors"
+	"expvar"
 	"fmt"
 	"io/fs"
 	"net/http"
@@ -55,11 +56,31 @@ func main() {
 		config.AuthTokenSecretKey,
 		64,
 		5*time.Minute,
-		time.Hour,
+		config.AuthTokenExpiration,
+		config.RefreshTokenExpiration,
 	)
 
-	serverController := controller.NewServerController(logger, db)
-	websocketHub := api.NewWebSocketHub(logger)
+	blobs, err := config.NewBlobStore()
+	if err != nil {
+		logger.Fatal("config.NewBlobStore", zap.Error(err))
+	}
+
+	serverController := controller.NewServerController(logger, db, blobs, config.MessageLeaseTimeout)
+	hubFanout, err := config.NewFanout(logger, db)
+	if err != nil {
+		logger.Fatal("config.NewFanout", zap.Error(err))
+	}
+	websocketHub := api.NewWebSocketHub(logger, serverController, hubFanout)
+	janitor := controller.NewJanitor(
+		logger,
+		serverController,
+		controller.RetentionPolicy{
+			DeliveredMessages:   config.DeliveredMessageRetention,
+			UndeliveredMessages: config.UndeliveredMessageRetention,
+			Transfers:           config.TransferRetention,
+		},
+		config.JanitorInterval,
+	)
 
 	api := api.NewAPI(
 		logger,
@@ -87,9 +108,14 @@ func main() {
 	v1 := e.Group("/api/v1")
 	v1.GET("/auth/:username", api.GetAuth)
 	v1.POST("/auth/:username", api.PostAuth)
+	v1.POST("/auth/:username/refresh", api.RefreshAuth)
+	v1.POST("/auth/:username/revoke", api.RevokeRefreshToken)
 
 	v1.GET("/users/:username", api.GetUser)
 	v1.POST("/users", api.AddUser)
+	v1.POST("/users/:username/devices", api.AddDevice)
+	v1.GET("/users/:username/key", api.GetKeyRotations)
+	v1.PUT("/users/:username/key", api.RotateKey)
 
 	v1.GET("/qrcode", api.GenerateQRCode)
 	v1.POST("/compress/image", api.CompressImage)
@@ -100,6 +126,21 @@ func main() {
 	messages.Use(echojwt.JWT([]byte(config.AuthTokenSecretKey)))
 	messages.POST("/:username", api.AddMessage)
 	messages.GET("/:username", api.GetMessages)
+	messages.POST("/:username/ack", api.AckMessages)
+
+	prekeys := v1.Group("/prekeys")
+	prekeys.Use(echojwt.JWT([]byte(config.AuthTokenSecretKey)))
+	prekeys.GET("/:username", api.GetPrekeys)
+	prekeys.PUT("/:username", api.PutPrekeys)
+	prekeys.POST("/:username/claim", api.ClaimPrekeys)
+
+	transfers := v1.Group("/transfers")
+	transfers.Use(echojwt.JWT([]byte(config.AuthTokenSecretKey)))
+	transfers.POST("/:username", api.CreateTransfer)
+	transfers.GET("/:username/:transfer_id", api.GetTransfer)
+	transfers.GET("/:username/:transfer_id/chunks/:index", api.GetTransferChunk)
+	transfers.PUT("/:username/:transfer_id/chunks/:index", api.PutTransferChunk)
+	transfers.POST("/:username/:transfer_id/ack", api.AckTransfer)
 
 	websocket := v1.Group("/ws")
 	websocket.Use(echojwt.JWT([]byte(config.AuthTokenSecretKey)))
@@ -152,6 +193,14 @@ func main() {
 		return nil
 	})
 
+	errGrp.Go(func() error {
+		err := janitor.Run(ctx)
+		if err != nil {
+			return fmt.Errorf("janitor.Run: %w", err)
+		}
+		return nil
+	})
+
 	errGrp.Go(func() error {
 		if config.TLS {
 			err := e.StartAutoTLS(":443")
@@ -167,6 +216,27 @@ func main() {
 		return nil
 	})
 
+	if config.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		metricsServer := &http.Server{Addr: config.MetricsAddr, Handler: mux}
+		errGrp.Go(func() error {
+			err := metricsServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("metricsServer.ListenAndServe: %w", err)
+			}
+			return nil
+		})
+		errGrp.Go(func() error {
+			<-ctx.Done()
+			err := metricsServer.Close()
+			if err != nil {
+				return fmt.Errorf("metricsServer.Close: %w", err)
+			}
+			return nil
+		})
+	}
+
 	errGrp.Go(func() error {
 		<-ctx.Done()
 		gracePeriod := time.Minute
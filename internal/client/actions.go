@@ -74,11 +74,11 @@ func (a *ActionListUsers) Do(ctx context.Context, u *UI) error {
 	}
 	users := make([]*User, 0, len(localUsers))
 	for _, localUser := range localUsers {
-		localUser := localUser
-		user, err := NewUser(localUser, UISingleton.openapiClient, UISingleton.db)
+		privKey, err := u.keyring.PrivateKey(localUser)
 		if err != nil {
-			return fmt.Errorf("NewUser: %w", err)
+			return fmt.Errorf("keyring.PrivateKey: %w", err)
 		}
+		user := NewUser(localUser.Name, privKey, u.history, u.secrets, UISingleton.openapiClient, UISingleton.db)
 		err = user.RegisterWebSocket(ctx)
 		if err != nil {
 			return fmt.Errorf("user.RegisterWebSocket: %w", err)
@@ -123,7 +123,10 @@ func (a *ActionCreateUser) Do(ctx context.Context, u *UI) error {
 	if err != nil {
 		return fmt.Errorf("cryptography.GenerateKey: %w", err)
 	}
-	privKeyBytes := cryptography.MarshalPrivateKey(privKey)
+	privKeyBytes, err := u.keyring.EncryptPrivateKey(a.username, privKey)
+	if err != nil {
+		return fmt.Errorf("keyring.EncryptPrivateKey: %w", err)
+	}
 
 	localUser, err := txQueries.InsertLocalUser(ctx, sqlcgen.InsertLocalUserParams{
 		Name:       a.username,
@@ -133,10 +136,7 @@ func (a *ActionCreateUser) Do(ctx context.Context, u *UI) error {
 		return fmt.Errorf("queries.InsertLocalUser: %w", err)
 	}
 
-	user, err := NewUser(localUser, UISingleton.openapiClient, UISingleton.db)
-	if err != nil {
-		return fmt.Errorf("NewUser: %w", err)
-	}
+	user := NewUser(localUser.Name, privKey, u.history, u.secrets, UISingleton.openapiClient, UISingleton.db)
 
 	// Attempt to register user on distant server, fail if already exists
 	pubKeyBytes := cryptography.MarshalPublicKey(&privKey.PublicKey)
@@ -173,6 +173,18 @@ func (a *ActionFetchMessages) Do(ctx context.Context, u *UI) error {
 		return fmt.Errorf("user.FetchMessages: %w", err)
 	}
 	u.drawer.OnEvent(&EventUpdateUser{user: a.user})
+
+	// Sessions started from our prekeys may have drained the pool
+	err = a.user.RefillPrekeys(ctx)
+	if err != nil {
+		return fmt.Errorf("user.RefillPrekeys: %w", err)
+	}
+
+	// Messages that could not be posted when sent are posted again
+	err = a.user.SendPendingMessages(ctx)
+	if err != nil {
+		return fmt.Errorf("user.SendPendingMessages: %w", err)
+	}
 	return nil
 }
 
@@ -180,6 +192,20 @@ func (a *ActionFetchMessages) String() string {
 	return "FetchMessages"
 }
 
+// ActionUpdateUser notifies the components that a user received messages.
+type ActionUpdateUser struct {
+	user *User
+}
+
+func (a *ActionUpdateUser) Do(ctx context.Context, u *UI) error {
+	u.drawer.OnEvent(&EventUpdateUser{user: a.user})
+	return nil
+}
+
+func (a *ActionUpdateUser) String() string {
+	return "UpdateUser"
+}
+
 type ActionCreateConversation struct {
 	localUser      *User
 	remoteUsername string
@@ -199,11 +225,21 @@ func (a *ActionCreateConversation) String() string {
 }
 
 type ActionSelectConversation struct {
+	localUser    *User
 	conversation *Conversation
 }
 
 func (a *ActionSelectConversation) Do(ctx context.Context, u *UI) error {
-	u.drawer.OnEvent(&EventSelectConversation{conversation: a.conversation})
+	trust, err := a.localUser.ContactTrust(ctx, a.conversation.dbConv.RemoteUserName)
+	// The conversation can still be read without its safety number, e.g. when the server is unreachable
+	u.drawer.OnEvent(&EventSelectConversation{conversation: a.conversation, trust: trust})
+	if err != nil {
+		return fmt.Errorf("localUser.ContactTrust: %w", err)
+	}
+	err = a.localUser.ReadConversation(ctx, a.conversation)
+	if err != nil {
+		return fmt.Errorf("localUser.ReadConversation: %w", err)
+	}
 	return nil
 }
 
@@ -211,6 +247,24 @@ func (a *ActionSelectConversation) String() string {
 	return "SelectConversation"
 }
 
+// ActionReadConversation marks the messages received in the displayed conversation as read.
+type ActionReadConversation struct {
+	localUser    *User
+	conversation *Conversation
+}
+
+func (a *ActionReadConversation) Do(ctx context.Context, u *UI) error {
+	err := a.localUser.ReadConversation(ctx, a.conversation)
+	if err != nil {
+		return fmt.Errorf("localUser.ReadConversation: %w", err)
+	}
+	return nil
+}
+
+func (a *ActionReadConversation) String() string {
+	return "ReadConversation"
+}
+
 type ActionSendMessage struct {
 	localUser      *User
 	remoteUsername string
@@ -229,6 +283,67 @@ func (a *ActionSendMessage) String() string {
 	return "SendMessage"
 }
 
+// ActionSendTyping tells the remote user of a conversation whether the local user is typing.
+type ActionSendTyping struct {
+	localUser    *User
+	conversation *Conversation
+	typing       bool
+}
+
+func (a *ActionSendTyping) Do(ctx context.Context, u *UI) error {
+	a.localUser.SendTyping(a.conversation, a.typing)
+	return nil
+}
+
+func (a *ActionSendTyping) String() string {
+	return "SendTyping"
+}
+
+// ActionSetTyping shows whether a remote user is typing to a local user.
+type ActionSetTyping struct {
+	localUser *User
+	typing    *types.TypingEvent
+}
+
+func (a *ActionSetTyping) Do(ctx context.Context, u *UI) error {
+	a.localUser.setTyping(a.typing)
+	return nil
+}
+
+func (a *ActionSetTyping) String() string {
+	return "SetTyping"
+}
+
+// ActionSetPresence shows whether a correspondent of a local user is online.
+type ActionSetPresence struct {
+	localUser *User
+	presence  *types.PresenceEvent
+}
+
+func (a *ActionSetPresence) Do(ctx context.Context, u *UI) error {
+	a.localUser.setPresence(a.presence)
+	return nil
+}
+
+func (a *ActionSetPresence) String() string {
+	return "SetPresence"
+}
+
+// ActionSetConnectionState shows whether the websocket of a local user is connected.
+type ActionSetConnectionState struct {
+	localUser *User
+	state     ConnectionState
+}
+
+func (a *ActionSetConnectionState) Do(ctx context.Context, u *UI) error {
+	a.localUser.connection = a.state
+	return nil
+}
+
+func (a *ActionSetConnectionState) String() string {
+	return "SetConnectionState"
+}
+
 type ActionSwitchTab struct {
 	tabIndex TabIndex
 }
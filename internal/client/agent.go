@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RunAgent unlocks the keyring and serves its key on a unix socket, so that the TUI and the CLI
+// do not prompt for the passphrase again. The agent stops once the configured TTL expires.
+//
+// The socket is accessible to the user only, and the key is served to any process connecting to it
+// without further checks: processes running as the same user are trusted, as they could read the
+// database and the terminal anyway.
+func RunAgent(ctx context.Context, logger *zap.Logger, config *Config, db *sql.DB) error {
+	key, err := requestAgentKey(config)
+	if err != nil {
+		return fmt.Errorf("requestAgentKey: %w", err)
+	}
+	if key != nil {
+		return errors.New("agent is already running")
+	}
+	key, err = promptKeyringKey(ctx, db)
+	if err != nil {
+		return fmt.Errorf("promptKeyringKey: %w", err)
+	}
+	keyring := &Keyring{
+		db:  db,
+		key: key,
+	}
+	err = keyring.verify(ctx)
+	if err != nil {
+		return fmt.Errorf("keyring.verify: %w", err)
+	}
+
+	// Remove the socket left behind by an agent that did not stop cleanly
+	err = os.Remove(config.Agent.Socket)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("os.Remove: %w", err)
+	}
+	listener, err := listenPrivate(config.Agent.Socket)
+	if err != nil {
+		return fmt.Errorf("listenPrivate: %w", err)
+	}
+	defer listener.Close()
+	defer os.Remove(config.Agent.Socket)
+
+	ctx, cancel := context.WithTimeout(ctx, config.Agent.TTL)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	encodedKey := base64.StdEncoding.EncodeToString(keyring.key)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("listener.Accept: %w", err)
+		}
+		// A client that went away must not stop the agent for the others
+		_, err = fmt.Fprintln(conn, encodedKey)
+		conn.Close()
+		if err != nil {
+			logger.Warn("fmt.Fprintln", zap.Error(err))
+		}
+	}
+}
+
+// listenPrivate listens on a unix socket accessible to the user only. The socket is created in a
+// private directory, for other users not to connect to it before its permissions are set, then
+// moved to socketPath.
+func listenPrivate(socketPath string) (*net.UnixListener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(socketPath), ".agent-")
+	if err != nil {
+		return nil, fmt.Errorf("os.MkdirTemp: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	privatePath := filepath.Join(dir, filepath.Base(socketPath))
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: privatePath, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("net.ListenUnix: %w", err)
+	}
+	// The socket is removed from socketPath by the caller
+	listener.SetUnlinkOnClose(false)
+	err = os.Chmod(privatePath, 0600)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("os.Chmod: %w", err)
+	}
+	err = os.Rename(privatePath, socketPath)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("os.Rename: %w", err)
+	}
+	return listener, nil
+}
+
+// requestAgentKey returns the keyring key held by the agent, or nil if the agent is not running.
+func requestAgentKey(config *Config) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", config.Agent.Socket, time.Second)
+	if err != nil {
+		// No agent listening
+		return nil, nil
+	}
+	defer conn.Close()
+	err = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("conn.SetReadDeadline: %w", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("ReadString: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(line[:len(line)-1])
+	if err != nil {
+		return nil, fmt.Errorf("base64.StdEncoding.DecodeString: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// Auth tokens expiring sooner than this are renewed before sending a request.
+const tokenRenewalMargin = 30 * time.Second
+
+// token returns an auth token, renewed when it expires soon: with the refresh token if the server
+// issued one, otherwise with the challenge-response.
+func (u *User) token(ctx context.Context) (string, error) {
+	u.authMu.Lock()
+	defer u.authMu.Unlock()
+	if u.authToken != "" && (u.authExpiry.IsZero() || time.Until(u.authExpiry) > tokenRenewalMargin) {
+		return u.authToken, nil
+	}
+
+	if u.refreshToken != "" {
+		tokens, err := u.client.RefreshAuth(ctx, u.refreshToken)
+		switch {
+		case err == nil:
+			u.setTokens(tokens)
+			return u.authToken, nil
+		case errors.Is(err, ErrUnauthorized):
+			// The refresh token expired or was revoked
+			u.refreshToken = ""
+		default:
+			return "", fmt.Errorf("client.RefreshAuth: %w", err)
+		}
+	}
+
+	tokens, err := u.authenticate(ctx)
+	if err != nil {
+		return "", fmt.Errorf("authenticate: %w", err)
+	}
+	u.setTokens(tokens)
+	return u.authToken, nil
+}
+
+// withAuth sends a request with an auth token. When the server rejects the token, such as when
+// it was revoked before expiring, the token is renewed and the request sent again, once.
+func (u *User) withAuth(ctx context.Context, request func(token string) error) error {
+	token, err := u.token(ctx)
+	if err != nil {
+		return fmt.Errorf("token: %w", err)
+	}
+	err = request(token)
+	if !errors.Is(err, ErrUnauthorized) {
+		return err
+	}
+
+	u.invalidateToken(token)
+	token, err = u.token(ctx)
+	if err != nil {
+		return fmt.Errorf("token: %w", err)
+	}
+	return request(token)
+}
+
+// setTokens stores the tokens issued by the server. authMu must be held.
+func (u *User) setTokens(tokens *openapi.JWT) {
+	u.authToken = tokens.Token
+	u.authExpiry = tokenExpiry(tokens.Token)
+	u.refreshToken = ""
+	if tokens.RefreshToken != nil {
+		u.refreshToken = *tokens.RefreshToken
+	}
+}
+
+// invalidateToken discards an auth token rejected by the server, unless it was already renewed.
+// The refresh token, if any, is kept to renew it.
+func (u *User) invalidateToken(token string) {
+	u.authMu.Lock()
+	defer u.authMu.Unlock()
+	if u.authToken == token {
+		u.authToken = ""
+	}
+}
+
+// resetAuth discards all tokens, for the next request to authenticate with the challenge-response.
+func (u *User) resetAuth() {
+	u.authMu.Lock()
+	defer u.authMu.Unlock()
+	u.authToken = ""
+	u.authExpiry = time.Time{}
+	u.refreshToken = ""
+}
+
+// tokenExpiry returns when an auth token expires, or the zero time if it cannot tell.
+// The token is not verified: the server does, and only its expiration time is read.
+func tokenExpiry(token string) time.Time {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return time.Time{}
+	}
+	expiration, err := parsed.Claims.GetExpirationTime()
+	if err != nil || expiration == nil {
+		return time.Time{}
+	}
+	return expiration.Time
+}
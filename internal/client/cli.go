@@ -1,12 +1,20 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path"
+	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
 )
 
 type CLIHandler struct {
@@ -41,6 +49,206 @@ func (h *CLIHandler) CreateUser(
 	return nil
 }
 
+func (h *CLIHandler) LinkDevice(
+	ctx context.Context,
+	username string,
+) error {
+	h.logger.Info(
+		"Linking device...",
+		zap.String("username", username),
+	)
+
+	publicKey, err := h.controller.LinkDevice(ctx, username)
+	if err != nil {
+		return fmt.Errorf("LinkDevice: %w", err)
+	}
+	h.logger.Info("Device key created, add it from an existing device with:")
+	fmt.Printf("user add-device %s %s\n", username, base64.StdEncoding.EncodeToString(publicKey))
+	return nil
+}
+
+func (h *CLIHandler) AddDevice(
+	ctx context.Context,
+	username string,
+	publicKeyBase64 string,
+) error {
+	h.logger.Info(
+		"Adding device...",
+		zap.String("username", username),
+	)
+
+	publicKey, err := base64.StdEncoding.DecodeString(publicKeyBase64)
+	if err != nil {
+		return fmt.Errorf("base64.StdEncoding.DecodeString: %w", err)
+	}
+	err = h.controller.AddDevice(ctx, username, publicKey)
+	if err != nil {
+		return fmt.Errorf("AddDevice: %w", err)
+	}
+	h.logger.Info("Device added successfully!")
+	return nil
+}
+
+func (h *CLIHandler) RotateKey(
+	ctx context.Context,
+	username string,
+) error {
+	h.logger.Info(
+		"Rotating key...",
+		zap.String("username", username),
+	)
+
+	err := h.controller.RotateKey(ctx, username)
+	if err != nil {
+		return fmt.Errorf("RotateKey: %w", err)
+	}
+	h.logger.Info("Key rotated successfully! Other devices of the user must be linked again.")
+	return nil
+}
+
+func (h *CLIHandler) SetReadReceipts(
+	ctx context.Context,
+	username string,
+	enabled bool,
+) error {
+	h.logger.Info(
+		"Setting read receipts...",
+		zap.String("username", username),
+		zap.Bool("enabled", enabled),
+	)
+
+	err := h.controller.SetReadReceipts(ctx, username, enabled)
+	if err != nil {
+		return fmt.Errorf("SetReadReceipts: %w", err)
+	}
+	h.logger.Info("Read receipts set successfully!")
+	return nil
+}
+
+func (h *CLIHandler) VerifyContact(
+	ctx context.Context,
+	username string,
+	remoteUsername string,
+) error {
+	h.logger.Info(
+		"Verifying contact...",
+		zap.String("username", username),
+		zap.String("remoteUsername", remoteUsername),
+	)
+
+	user, err := h.controller.GetUser(ctx, username)
+	if err != nil {
+		return fmt.Errorf("GetUser: %w", err)
+	}
+	trust, err := user.ContactTrust(ctx, remoteUsername)
+	if err != nil {
+		return fmt.Errorf("user.ContactTrust: %w", err)
+	}
+
+	fmt.Printf("Safety number of %s and %s:\n%s\n", username, remoteUsername, trust.SafetyNumber)
+	switch {
+	case trust.KeyChanged:
+		fmt.Printf("WARNING: the key of %s changed, messages cannot be sent until the new key is approved.\n", remoteUsername)
+	case trust.VerifiedAt != nil:
+		fmt.Printf("Verified on %s.\n", trust.VerifiedAt.Local().Format(time.DateTime))
+	default:
+		fmt.Println("Never verified.")
+	}
+	fmt.Printf("Compare it with %s through another channel. Does it match? (y/n) ", remoteUsername)
+	var choice string
+	fmt.Scanln(&choice)
+	if choice != "y" {
+		h.logger.Info("Contact left unverified.")
+		return nil
+	}
+
+	err = user.VerifyContact(ctx, remoteUsername, trust)
+	if err != nil {
+		return fmt.Errorf("user.VerifyContact: %w", err)
+	}
+	h.logger.Info("Contact verified successfully!")
+	return nil
+}
+
+func (h *CLIHandler) ExportIdentity(
+	ctx context.Context,
+	username string,
+	filePath string,
+	withHistory bool,
+) error {
+	h.logger.Info(
+		"Exporting identity...",
+		zap.String("username", username),
+		zap.String("filePath", filePath),
+		zap.Bool("withHistory", withHistory),
+	)
+
+	passphrase, err := readPassphrase("Choose a passphrase to encrypt the identity bundle: ")
+	if err != nil {
+		return fmt.Errorf("readPassphrase: %w", err)
+	}
+	if len(passphrase) == 0 {
+		return errors.New("passphrase cannot be empty")
+	}
+	confirmation, err := readPassphrase("Confirm passphrase: ")
+	if err != nil {
+		return fmt.Errorf("readPassphrase: %w", err)
+	}
+	if !bytes.Equal(passphrase, confirmation) {
+		return errors.New("passphrases do not match")
+	}
+
+	bundle, err := h.controller.ExportIdentity(ctx, username, withHistory, passphrase)
+	if err != nil {
+		return fmt.Errorf("ExportIdentity: %w", err)
+	}
+	err = os.WriteFile(filePath, bundle, 0600)
+	if err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	h.logger.Info("Identity exported successfully! Stop using it on this machine once imported elsewhere.")
+	return nil
+}
+
+func (h *CLIHandler) ImportIdentity(
+	ctx context.Context,
+	filePath string,
+) error {
+	h.logger.Info(
+		"Importing identity...",
+		zap.String("filePath", filePath),
+	)
+
+	bundle, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("os.ReadFile: %w", err)
+	}
+	passphrase, err := readPassphrase("Identity bundle passphrase: ")
+	if err != nil {
+		return fmt.Errorf("readPassphrase: %w", err)
+	}
+	username, err := h.controller.ImportIdentity(ctx, bundle, passphrase)
+	if err != nil {
+		return fmt.Errorf("ImportIdentity: %w", err)
+	}
+	h.logger.Info("Identity imported successfully!", zap.String("username", username))
+	return nil
+}
+
+func (h *CLIHandler) EncryptMessageHistory(ctx context.Context) error {
+	h.logger.Info("Encrypting message history...")
+
+	count, err := h.controller.EncryptMessageHistory(ctx)
+	if err != nil {
+		return fmt.Errorf("EncryptMessageHistory: %w", err)
+	}
+	h.logger.Info(
+		"Message history encrypted successfully! Set database.encrypt_messages in the config to encrypt new messages too.",
+		zap.Int("messages", count),
+	)
+	return nil
+}
+
 func (h *CLIHandler) SendFile(
 	ctx context.Context,
 	sender,
@@ -54,24 +262,19 @@ func (h *CLIHandler) SendFile(
 		zap.String("filePath", filePath),
 	)
 
-	// Read file
-	fileBytes, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("os.ReadFile: %w", err)
-	}
-
 	// Get sender's local user
 	user, err := h.controller.GetUser(ctx, sender)
 	if err != nil {
 		return fmt.Errorf("GetUser: %w", err)
 	}
 
-	// Send file as message
-	err = user.SendMessage(ctx, fileBytes, recipient)
+	// Upload file in chunks, then send its manifest as message
+	err = user.SendFile(ctx, filePath, recipient)
 	if err != nil {
-		return fmt.Errorf("SendMessage: %w", err)
+		return fmt.Errorf("SendFile: %w", err)
 	}
 	h.logger.Info("File sent successfully!")
+	h.warnWithoutSession(user, recipient)
 	return nil
 }
 
@@ -92,12 +295,19 @@ func (h *CLIHandler) SendMessage(
 		return fmt.Errorf("GetUser: %w", err)
 	}
 
+	// Messages that could not be posted before go first
+	err = user.SendPendingMessages(ctx)
+	if err != nil {
+		h.logger.Warn("SendPendingMessages", zap.Error(err))
+	}
+
 	// Send message as plaintext
 	err = user.SendMessage(ctx, []byte(message), recipient)
 	if err != nil {
 		return fmt.Errorf("SendMessage: %w", err)
 	}
 	h.logger.Info("Message sent successfully!")
+	h.warnWithoutSession(user, recipient)
 	return nil
 }
 
@@ -122,13 +332,29 @@ func (h *CLIHandler) ReadMessages(
 		return fmt.Errorf("FetchMessages: %w", err)
 	}
 	h.logger.Info("Messages read successfully!")
+	h.refillPrekeys(ctx, user)
 
 	// Print messages
 	for _, message := range messages {
-		fmt.Printf(`From %q:
+		status := ""
+		if !message.Verified {
+			status = " (unverified)"
+		}
+		if message.Kind == string(openapi.MessageKindFile) {
+			fmt.Printf(`From %q%s:
+%s, read messages with -o to download it
+`,
+				message.Sender,
+				status,
+				messageText(message),
+			)
+			continue
+		}
+		fmt.Printf(`From %q%s:
 %s
 `,
 			message.Sender,
+			status,
 			message.Content,
 		)
 	}
@@ -152,14 +378,41 @@ func (h *CLIHandler) ReadMessagesToDir(ctx context.Context, username, outputDir
 		return fmt.Errorf("FetchMessages: %w", err)
 	}
 	h.logger.Info("Messages read successfully!")
+	h.refillPrekeys(ctx, user)
 
 	for _, message := range messages {
+		if !message.Verified {
+			h.logger.Warn(
+				"Message signature could not be verified",
+				zap.String("sender", message.Sender),
+				zap.Int64("id", message.ID),
+			)
+		}
 		senderDir := path.Join(outputDir, string(message.Sender))
 		err := os.MkdirAll(senderDir, 0o755)
 		if err != nil {
 			return fmt.Errorf("failed to create sender dir: %w", err)
 		}
 		filePath := path.Join(senderDir, fmt.Sprintf("%d", message.ID))
+		if message.Kind == string(openapi.MessageKindFile) {
+			var manifest types.FileManifest
+			err = json.Unmarshal(message.Content, &manifest)
+			if err != nil {
+				return fmt.Errorf("json.Unmarshal: %w", err)
+			}
+			filePath = attachmentPath(senderDir, &manifest, fmt.Sprintf("%d", message.ID))
+			err = user.DownloadFile(ctx, &manifest, filePath)
+			if err != nil {
+				return fmt.Errorf("DownloadFile: %w", err)
+			}
+			h.logger.Info(
+				"File downloaded",
+				zap.String("sender", message.Sender),
+				zap.String("filePath", filePath),
+				zap.String("mimeType", manifest.MimeType),
+			)
+			continue
+		}
 		err = os.WriteFile(filePath, message.Content, 0o644)
 		if err != nil {
 			return fmt.Errorf("failed to write message file: %w", err)
@@ -167,3 +420,23 @@ func (h *CLIHandler) ReadMessagesToDir(ctx context.Context, username, outputDir
 	}
 	return nil
 }
+
+// refillPrekeys uploads more prekeys if sessions started from ours drained the pool. Failures are
+// only logged, as the messages were read all the same.
+func (h *CLIHandler) refillPrekeys(ctx context.Context, user *User) {
+	err := user.RefillPrekeys(ctx)
+	if err != nil {
+		h.logger.Warn("RefillPrekeys", zap.Error(err))
+	}
+}
+
+// warnWithoutSession tells when the last message to a recipient was sent without forward secrecy.
+func (h *CLIHandler) warnWithoutSession(user *User, recipient string) {
+	conversation, ok := user.conversations[recipient]
+	if ok && conversation.withoutSession.Load() {
+		h.logger.Warn(
+			"Message sent without forward secrecy, as one of the users has several devices",
+			zap.String("recipient", recipient),
+		)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"golang.org/x/sync/errgroup"
@@ -16,6 +17,21 @@ import (
 	"github.com/marc921/talk/internal/types/openapi"
 )
 
+// Number of times a message with a client ID is posted before giving up, when the server
+// cannot be reached.
+const sendMessageAttempts = 3
+
+// Time allowed between two pings of the server, which sends them every 54 seconds,
+// before the websocket is considered dead.
+const serverPingWait = 90 * time.Second
+
+// ErrResyncRequired is returned by WebSocket when the server evicted the session for falling behind.
+// Messages it did not push are still stored on the server, to be fetched.
+var ErrResyncRequired = errors.New("websocket session evicted, messages must be fetched")
+
+// ErrUnauthorized is returned when the server rejects the auth token, which may have expired.
+var ErrUnauthorized = errors.New("unauthorized")
+
 type Client struct {
 	openapiClient *openapi.ClientWithResponses
 	username      openapi.Username
@@ -56,6 +72,31 @@ func (c *Client) GetPublicUser(
 	}
 }
 
+// GetDevices fetches the public key and the devices of a user, as listed by the server.
+// Neither is trusted: the key must be compared with the pinned one, and device signatures
+// must be checked by the caller.
+func (c *Client) GetDevices(
+	ctx context.Context,
+	username openapi.Username,
+) ([]byte, []openapi.Device, error) {
+	resp, err := c.openapiClient.GetUsersUsernameWithResponse(ctx, username)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetUsersUsernameWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusOK:
+		if resp.JSON200.Devices == nil {
+			// Server without device support
+			return resp.JSON200.PublicKey, nil, nil
+		}
+		return resp.JSON200.PublicKey, *resp.JSON200.Devices, nil
+	case http.StatusNotFound:
+		return nil, nil, errors.New(resp.JSON404.Error)
+	default:
+		return nil, nil, fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
 func (c *Client) RegisterUser(
 	ctx context.Context,
 	publicKey []byte,
@@ -82,6 +123,74 @@ func (c *Client) RegisterUser(
 	}
 }
 
+// AddDevice adds a device, signed by one of our existing devices, to our user.
+func (c *Client) AddDevice(
+	ctx context.Context,
+	device *openapi.Device,
+) error {
+	resp, err := c.openapiClient.PostUsersUsernameDevicesWithResponse(ctx, c.username, *device)
+	if err != nil {
+		return fmt.Errorf("PostUsersUsernameDevicesWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusOK:
+		// Device already added
+		return nil
+	case http.StatusCreated:
+		// Device added
+		return nil
+	case http.StatusBadRequest:
+		return errors.New(resp.JSON400.Error)
+	case http.StatusNotFound:
+		return errors.New(resp.JSON404.Error)
+	default:
+		return fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
+// RotateKey replaces our user's key with a new key signed by the current one.
+func (c *Client) RotateKey(
+	ctx context.Context,
+	rotation *openapi.KeyRotation,
+) error {
+	resp, err := c.openapiClient.PutUsersUsernameKeyWithResponse(ctx, c.username, *rotation)
+	if err != nil {
+		return fmt.Errorf("PutUsersUsernameKeyWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusBadRequest:
+		return errors.New(resp.JSON400.Error)
+	case http.StatusNotFound:
+		return errors.New(resp.JSON404.Error)
+	case http.StatusConflict:
+		return errors.New(resp.JSON409.Error)
+	default:
+		return fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
+// GetKeyRotations fetches the key rotation history of a user, oldest first.
+// The history is not trusted: rotation signatures must be checked by the caller.
+func (c *Client) GetKeyRotations(
+	ctx context.Context,
+	username openapi.Username,
+) ([]openapi.KeyRotation, error) {
+	resp, err := c.openapiClient.GetUsersUsernameKeyWithResponse(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("GetUsersUsernameKeyWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusOK:
+		return *resp.JSON200, nil
+	case http.StatusNotFound:
+		return nil, errors.New(resp.JSON404.Error)
+	default:
+		return nil, fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
 func (c *Client) GetAuth(
 	ctx context.Context,
 ) (*openapi.AuthChallenge, error) {
@@ -115,12 +224,39 @@ func (c *Client) PostAuth(
 	case http.StatusOK:
 		return resp.JSON200, nil
 	case http.StatusUnauthorized:
-		return nil, errors.New(resp.JSON401.Error)
+		return nil, unauthorized(resp.JSON401)
 	default:
 		return nil, fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
 	}
 }
 
+// RefreshAuth exchanges a refresh token for new tokens. Refresh tokens can only be used once.
+func (c *Client) RefreshAuth(ctx context.Context, refreshToken string) (*openapi.JWT, error) {
+	resp, err := c.openapiClient.PostAuthUsernameRefreshWithResponse(ctx, c.username, openapi.PostAuthUsernameRefreshJSONRequestBody{
+		RefreshToken: refreshToken,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("PostAuthUsernameRefreshWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusOK:
+		return resp.JSON200, nil
+	case http.StatusUnauthorized:
+		return nil, unauthorized(resp.JSON401)
+	default:
+		return nil, fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
+// unauthorized returns the error of a response with status 401, for the caller to authenticate again.
+// Tokens rejected by the JWT middleware, such as expired ones, come without an error message.
+func unauthorized(body *openapi.ErrorResponse) error {
+	if body == nil || body.Error == "" {
+		return ErrUnauthorized
+	}
+	return fmt.Errorf("%w: %s", ErrUnauthorized, body.Error)
+}
+
 func WithBearerToken(token string) openapi.RequestEditorFn {
 	return func(ctx context.Context, req *http.Request) error {
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
@@ -128,35 +264,52 @@ func WithBearerToken(token string) openapi.RequestEditorFn {
 	}
 }
 
+// SendMessage posts a message and returns it as stored by the server, with its ID and time.
+// Posts failing before a response are retried: the server stores the message only once,
+// thanks to its client ID.
 func (c *Client) SendMessage(
 	ctx context.Context,
 	token string,
 	message *openapi.Message,
-) error {
+) (*openapi.Message, error) {
 	if message.Sender != c.username {
-		return errors.New("cannot send message on behalf of another user")
+		return nil, errors.New("cannot send message on behalf of another user")
 	}
 	if message.Recipient == c.username {
-		return errors.New("cannot send message to self")
+		return nil, errors.New("cannot send message to self")
 	}
-	resp, err := c.openapiClient.PostMessagesUsernameWithResponse(
-		ctx,
-		c.username,
-		*message,
-		WithBearerToken(token),
+	var (
+		resp *openapi.PostMessagesUsernameResponse
+		err  error
 	)
+	for attempt := 0; attempt < sendMessageAttempts; attempt++ {
+		resp, err = c.openapiClient.PostMessagesUsernameWithResponse(
+			ctx,
+			c.username,
+			*message,
+			WithBearerToken(token),
+		)
+		if err == nil || message.ClientId == nil || ctx.Err() != nil {
+			break
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("PostMessagesUsernameWithResponse: %w", err)
+		return nil, fmt.Errorf("PostMessagesUsernameWithResponse: %w", err)
 	}
 	switch resp.HTTPResponse.StatusCode {
 	case http.StatusCreated:
-		return nil
+		return resp.JSON201, nil
+	case http.StatusOK:
+		// Already stored by a previous attempt
+		return resp.JSON200, nil
+	case http.StatusBadRequest:
+		return nil, errors.New(resp.JSON400.Error)
 	case http.StatusUnauthorized:
-		return errors.New(resp.JSON401.Error)
+		return nil, unauthorized(resp.JSON401)
 	case http.StatusNotFound:
-		return errors.New(resp.JSON404.Error)
+		return nil, errors.New(resp.JSON404.Error)
 	default:
-		return fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+		return nil, fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
 	}
 }
 
@@ -176,7 +329,112 @@ func (c *Client) GetMessages(
 	case http.StatusOK:
 		return *resp.JSON200, nil
 	case http.StatusUnauthorized:
-		return nil, errors.New(resp.JSON401.Error)
+		return nil, unauthorized(resp.JSON401)
+	case http.StatusNotFound:
+		return nil, errors.New(resp.JSON404.Error)
+	default:
+		return nil, fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
+// AckMessages acknowledges messages stored by our device, for the server not to deliver them again.
+func (c *Client) AckMessages(
+	ctx context.Context,
+	token string,
+	ids []string,
+) error {
+	resp, err := c.openapiClient.PostMessagesUsernameAckWithResponse(
+		ctx,
+		c.username,
+		openapi.MessageAck{Ids: ids},
+		WithBearerToken(token),
+	)
+	if err != nil {
+		return fmt.Errorf("PostMessagesUsernameAckWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusBadRequest:
+		return errors.New(resp.JSON400.Error)
+	case http.StatusUnauthorized:
+		return unauthorized(resp.JSON401)
+	default:
+		return fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
+func (c *Client) GetPrekeyStatus(
+	ctx context.Context,
+	token string,
+) (*openapi.PrekeyStatus, error) {
+	resp, err := c.openapiClient.GetPrekeysUsernameWithResponse(
+		ctx,
+		c.username,
+		WithBearerToken(token),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("GetPrekeysUsernameWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusOK:
+		return resp.JSON200, nil
+	case http.StatusUnauthorized:
+		return nil, unauthorized(resp.JSON401)
+	default:
+		return nil, fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
+func (c *Client) UploadPrekeys(
+	ctx context.Context,
+	token string,
+	upload *openapi.PrekeyUpload,
+) (*openapi.PrekeyStatus, error) {
+	resp, err := c.openapiClient.PutPrekeysUsernameWithResponse(
+		ctx,
+		c.username,
+		*upload,
+		WithBearerToken(token),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("PutPrekeysUsernameWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusOK:
+		return resp.JSON200, nil
+	case http.StatusBadRequest:
+		return nil, errors.New(resp.JSON400.Error)
+	case http.StatusUnauthorized:
+		return nil, unauthorized(resp.JSON401)
+	default:
+		return nil, fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
+// CreateTransfer starts uploading a file of chunkCount encrypted chunks to another user.
+func (c *Client) CreateTransfer(
+	ctx context.Context,
+	token string,
+	recipient openapi.Username,
+	chunkCount int,
+) (*openapi.Transfer, error) {
+	resp, err := c.openapiClient.PostTransfersUsernameWithResponse(
+		ctx,
+		c.username,
+		openapi.TransferRequest{Recipient: recipient, ChunkCount: chunkCount},
+		WithBearerToken(token),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("PostTransfersUsernameWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusCreated:
+		return resp.JSON201, nil
+	case http.StatusBadRequest:
+		return nil, errors.New(resp.JSON400.Error)
+	case http.StatusUnauthorized:
+		return nil, unauthorized(resp.JSON401)
 	case http.StatusNotFound:
 		return nil, errors.New(resp.JSON404.Error)
 	default:
@@ -184,11 +442,163 @@ func (c *Client) GetMessages(
 	}
 }
 
+// GetTransfer returns a transfer with the indexes of the chunks uploaded so far.
+// It returns types.ErrNotFound if the server does not know the transfer.
+func (c *Client) GetTransfer(
+	ctx context.Context,
+	token string,
+	transferID string,
+) (*openapi.Transfer, error) {
+	resp, err := c.openapiClient.GetTransfersUsernameTransferIdWithResponse(
+		ctx,
+		c.username,
+		transferID,
+		WithBearerToken(token),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("GetTransfersUsernameTransferIdWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusOK:
+		return resp.JSON200, nil
+	case http.StatusUnauthorized:
+		return nil, unauthorized(resp.JSON401)
+	case http.StatusNotFound:
+		return nil, types.ErrNotFound
+	default:
+		return nil, fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
+// PutTransferChunk uploads an encrypted chunk of a transfer, replacing it if it was already uploaded.
+// It returns types.ErrNotFound if the server does not know the transfer.
+func (c *Client) PutTransferChunk(
+	ctx context.Context,
+	token string,
+	transferID string,
+	index int,
+	data []byte,
+) error {
+	resp, err := c.openapiClient.PutTransfersUsernameTransferIdChunksIndexWithResponse(
+		ctx,
+		c.username,
+		transferID,
+		index,
+		openapi.TransferChunk{Data: data},
+		WithBearerToken(token),
+	)
+	if err != nil {
+		return fmt.Errorf("PutTransfersUsernameTransferIdChunksIndexWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusBadRequest:
+		return errors.New(resp.JSON400.Error)
+	case http.StatusUnauthorized:
+		return unauthorized(resp.JSON401)
+	case http.StatusNotFound:
+		return types.ErrNotFound
+	default:
+		return fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
+// GetTransferChunk downloads an encrypted chunk of a transfer.
+func (c *Client) GetTransferChunk(
+	ctx context.Context,
+	token string,
+	transferID string,
+	index int,
+) ([]byte, error) {
+	resp, err := c.openapiClient.GetTransfersUsernameTransferIdChunksIndexWithResponse(
+		ctx,
+		c.username,
+		transferID,
+		index,
+		WithBearerToken(token),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("GetTransfersUsernameTransferIdChunksIndexWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusOK:
+		return resp.JSON200.Data, nil
+	case http.StatusUnauthorized:
+		return nil, unauthorized(resp.JSON401)
+	case http.StatusNotFound:
+		return nil, errors.New(resp.JSON404.Error)
+	default:
+		return nil, fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
+// AckTransfer tells the server that this device downloaded a transfer.
+func (c *Client) AckTransfer(
+	ctx context.Context,
+	token string,
+	transferID string,
+) error {
+	resp, err := c.openapiClient.PostTransfersUsernameTransferIdAckWithResponse(
+		ctx,
+		c.username,
+		transferID,
+		WithBearerToken(token),
+	)
+	if err != nil {
+		return fmt.Errorf("PostTransfersUsernameTransferIdAckWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusUnauthorized:
+		return unauthorized(resp.JSON401)
+	case http.StatusNotFound:
+		return errors.New(resp.JSON404.Error)
+	default:
+		return fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
+// ClaimPrekeyBundle claims a prekey bundle of another user.
+// It returns types.ErrNotFound if the user has not uploaded any prekeys, and types.ErrRateLimited
+// if the local user claimed too many bundles recently.
+func (c *Client) ClaimPrekeyBundle(
+	ctx context.Context,
+	token string,
+	username openapi.Username,
+) (*openapi.PrekeyBundle, error) {
+	resp, err := c.openapiClient.PostPrekeysUsernameClaimWithResponse(
+		ctx,
+		username,
+		WithBearerToken(token),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("PostPrekeysUsernameClaimWithResponse: %w", err)
+	}
+	switch resp.HTTPResponse.StatusCode {
+	case http.StatusOK:
+		return resp.JSON200, nil
+	case http.StatusUnauthorized:
+		return nil, unauthorized(resp.JSON401)
+	case http.StatusNotFound:
+		return nil, types.ErrNotFound
+	case http.StatusTooManyRequests:
+		return nil, types.ErrRateLimited
+	default:
+		return nil, fmt.Errorf("received unexpected status code: %d", resp.HTTPResponse.StatusCode)
+	}
+}
+
+// WebSocket exchanges events with the server until the connection fails or ctx is done.
+// onConnected is called once the connection is established.
+// Servers that do not speak types.WebSocketProtocol only exchange messages.
 func (c *Client) WebSocket(
 	ctx context.Context,
 	token string,
-	readChan chan<- *openapi.Message,
-	writeChan <-chan *openapi.Message,
+	readChan chan<- *types.WebSocketEvent,
+	writeChan <-chan *types.WebSocketEvent,
+	onConnected func(),
 ) error {
 	serverUrl, err := url.ParseRequestURI(UISingleton.config.Server.URL)
 	if err != nil {
@@ -199,18 +609,44 @@ func (c *Client) WebSocket(
 
 	header := http.Header{}
 	header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	wsConn, _, err := websocket.DefaultDialer.DialContext(ctx, serverUrl.String(), header)
+	dialer := *websocket.DefaultDialer
+	dialer.Subprotocols = []string{types.WebSocketProtocol}
+	wsConn, resp, err := dialer.DialContext(ctx, serverUrl.String(), header)
 	if err != nil {
-		return fmt.Errorf("websocket.DefaultDialer.Dial: %w", err)
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+		}
+		return fmt.Errorf("dialer.Dial: %w", err)
 	}
 	defer wsConn.Close()
+	events := wsConn.Subprotocol() == types.WebSocketProtocol
+	onConnected()
 
 	wsConn.SetReadLimit(1 << 20) // 1 MiB	// TODO: chunk messages, match size with server 512
+	// A connection silently dropped by the network is only noticed when the server's pings stop
+	wsConn.SetReadDeadline(time.Now().Add(serverPingWait))
+	wsConn.SetPingHandler(func(data string) error {
+		wsConn.SetReadDeadline(time.Now().Add(serverPingWait))
+		err := wsConn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		if err != nil && !errors.Is(err, websocket.ErrCloseSent) {
+			return err
+		}
+		return nil
+	})
 
 	errGrp, ctx := errgroup.WithContext(ctx)
+	writerDone := make(chan struct{})
+
+	// The reader is blocked in ReadMessage until the connection is closed, once the writer sent
+	// the close message or failed
+	errGrp.Go(func() error {
+		<-ctx.Done()
+		<-writerDone
+		wsConn.Close()
+		return nil
+	})
 
 	errGrp.Go(func() error {
-		defer close(readChan)
 		for {
 			select {
 			case <-ctx.Done():
@@ -218,36 +654,68 @@ func (c *Client) WebSocket(
 			default:
 				msgType, message, err := wsConn.ReadMessage()
 				if err != nil {
+					if websocket.IsCloseError(err, types.WebSocketCloseResync) {
+						return ErrResyncRequired
+					}
 					return fmt.Errorf("wsConn.ReadMessage: %w", err)
 				}
 				if msgType != websocket.TextMessage {
 					// TODO: handle ping/pong messages ?
 					return fmt.Errorf("unexpected message type: %d", msgType)
 				}
-				var msg *openapi.Message
-				err = json.Unmarshal(message, &msg)
+				if !events {
+					var msg *openapi.Message
+					err = json.Unmarshal(message, &msg)
+					if err != nil {
+						return fmt.Errorf("json.Unmarshal: %w", err)
+					}
+					select {
+					case readChan <- types.NewMessageEvent(msg):
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+					continue
+				}
+				var event *types.WebSocketEvent
+				err = json.Unmarshal(message, &event)
 				if err != nil {
 					return fmt.Errorf("json.Unmarshal: %w", err)
 				}
-				readChan <- msg
+				select {
+				case readChan <- event:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 			}
 		}
 	})
 
 	errGrp.Go(func() error {
+		defer close(writerDone)
 		for {
 			select {
 			case <-ctx.Done():
-				err := wsConn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				err := wsConn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+					time.Now().Add(time.Second),
+				)
 				if err != nil {
-					return fmt.Errorf("wsConn.WriteMessage(Close): %w", err)
+					return fmt.Errorf("wsConn.WriteControl(Close): %w", err)
 				}
 				return ctx.Err()
-			case message, ok := <-writeChan:
+			case event, ok := <-writeChan:
 				if !ok {
 					return errors.New("writeChan closed")
 				}
-				msgBytes, err := json.Marshal(message)
+				var payload any = event
+				if !events {
+					if event.Message == nil {
+						continue
+					}
+					payload = event.Message
+				}
+				msgBytes, err := json.Marshal(payload)
 				if err != nil {
 					return fmt.Errorf("json.Marshal: %w", err)
 				}
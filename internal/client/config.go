@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -19,14 +20,31 @@ var defaultConfig []byte
 
 type Config struct {
 	// The root directory of the config file. This field is not in the config file, but is set by LoadConfig.
-	HomeDir string       `yaml:"-"`
-	Server  ServerConfig `yaml:"server"`
+	HomeDir  string         `yaml:"-"`
+	Server   ServerConfig   `yaml:"server"`
+	Agent    AgentConfig    `yaml:"agent"`
+	Database DatabaseConfig `yaml:"database"`
 }
 
 type ServerConfig struct {
 	URL string `yaml:"url"`
 }
 
+type DatabaseConfig struct {
+	// EncryptMessages encrypts the content of new messages stored in the local database.
+	// Messages stored before are encrypted by the `database encrypt` command.
+	// Metadata stays in plain text: contact names, conversation partners, and the time, kind,
+	// sender and recipient of every message can be read by anyone with access to the database file.
+	EncryptMessages bool `yaml:"encrypt_messages"`
+}
+
+type AgentConfig struct {
+	// Socket is the path of the agent's unix socket, defaults to agent.sock in the home directory
+	Socket string `yaml:"socket"`
+	// TTL is how long the agent keeps the keys unlocked
+	TTL time.Duration `yaml:"ttl"`
+}
+
 func LoadConfig(ctx context.Context) (*Config, error) {
 	args := getArgs()
 	configPath := path.Join(args.homeDir, "config.yaml")
@@ -74,6 +92,7 @@ func readConfigFile(homeDir string) (*Config, error) {
 	configPath := path.Join(homeDir, "config.yaml")
 	cfg := new(Config)
 	cfg.HomeDir = homeDir
+	cfg.Agent.TTL = 15 * time.Minute
 	// Read the config file
 	content, err := os.ReadFile(configPath)
 	if err != nil {
@@ -84,6 +103,9 @@ func readConfigFile(homeDir string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("yaml.Unmarshal: %w", err)
 	}
+	if cfg.Agent.Socket == "" {
+		cfg.Agent.Socket = path.Join(homeDir, "agent.sock")
+	}
 	return cfg, nil
 }
 
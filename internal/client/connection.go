@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ConnectionState tells whether the websocket of a local user is connected to the server.
+type ConnectionState string
+
+const (
+	ConnectionConnected ConnectionState = "connected"
+	// ConnectionReconnecting is the state of a websocket that was lost and is being established again.
+	ConnectionReconnecting ConnectionState = "reconnecting"
+	// ConnectionOffline is the state of a websocket that could not be established for several attempts.
+	ConnectionOffline ConnectionState = "offline"
+)
+
+const (
+	// Delays between attempts to reconnect the websocket, doubled on each failed attempt.
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = time.Minute
+	// Failed attempts after which the websocket is considered offline rather than reconnecting.
+	offlineAttempts = 3
+	// Connections that lasted this long reset the delay between attempts.
+	stableConnection = time.Minute
+)
+
+// superviseWebSocket keeps the websocket of the user connected until ctx is done,
+// reconnecting with a jittered exponential backoff. Messages missed while disconnected are fetched
+// once reconnected.
+func (u *User) superviseWebSocket(ctx context.Context) {
+	defer close(u.inboundEvents)
+	failures := 0
+	reconnected := false
+	var shown ConnectionState
+	for {
+		var connectedAt time.Time
+		err := u.connectWebSocket(ctx, func() {
+			connectedAt = time.Now()
+			if reconnected {
+				UISingleton.actions <- &ActionFetchMessages{user: u}
+			}
+			shown = ConnectionConnected
+			u.setConnectionState(shown)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !connectedAt.IsZero() {
+			reconnected = true
+			if time.Since(connectedAt) > stableConnection {
+				failures = 0
+			}
+		}
+		failures++
+		state := ConnectionReconnecting
+		if failures >= offlineAttempts {
+			state = ConnectionOffline
+		}
+		if state != shown {
+			if state == ConnectionOffline {
+				UISingleton.actions <- &ActionSetError{err: fmt.Errorf("connectWebSocket: %w", err)}
+			}
+			shown = state
+			u.setConnectionState(shown)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay(failures)):
+		}
+	}
+}
+
+// connectWebSocket exchanges events with the server until the connection fails or ctx is done.
+// An auth token rejected when connecting is renewed.
+func (u *User) connectWebSocket(ctx context.Context, onConnected func()) error {
+	err := u.withAuth(ctx, func(token string) error {
+		return u.client.WebSocket(ctx, token, u.inboundEvents, u.outboundEvents, onConnected)
+	})
+	if err != nil {
+		return fmt.Errorf("client.WebSocket: %w", err)
+	}
+	return nil
+}
+
+// setConnectionState shows the state of the websocket of the user.
+func (u *User) setConnectionState(state ConnectionState) {
+	UISingleton.actions <- &ActionSetConnectionState{localUser: u, state: state}
+	// Wake the UI up from waiting for terminal events, for the action to run
+	UISingleton.drawer.screen.PostEvent(tcell.NewEventInterrupt(nil))
+}
+
+// reconnectDelay returns the delay before the next attempt to reconnect, after a number of
+// consecutive failures: exponential, with a random half for clients disconnected together not
+// to reconnect together.
+func reconnectDelay(failures int) time.Duration {
+	delay := reconnectMaxDelay
+	if failures <= 6 {
+		delay = min(reconnectMinDelay<<(failures-1), reconnectMaxDelay)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/marc921/talk/internal/client/database"
 	"github.com/marc921/talk/internal/client/database/sqlcgen"
 	"github.com/marc921/talk/internal/cryptography"
 	"github.com/marc921/talk/internal/types/openapi"
@@ -13,15 +14,24 @@ import (
 type Controller struct {
 	openapiClient *openapi.ClientWithResponses
 	db            *sql.DB
+	keyring       *Keyring
+	history       *MessageHistory
+	secrets       *LocalSecrets
 }
 
 func NewController(
 	openapiClient *openapi.ClientWithResponses,
 	db *sql.DB,
+	keyring *Keyring,
+	history *MessageHistory,
+	secrets *LocalSecrets,
 ) *Controller {
 	return &Controller{
 		openapiClient: openapiClient,
 		db:            db,
+		keyring:       keyring,
+		history:       history,
+		secrets:       secrets,
 	}
 }
 
@@ -41,7 +51,10 @@ func (c *Controller) CreateUser(
 	if err != nil {
 		return fmt.Errorf("cryptography.GenerateKey: %w", err)
 	}
-	privKeyBytes := cryptography.MarshalPrivateKey(privKey)
+	privKeyBytes, err := c.keyring.EncryptPrivateKey(username, privKey)
+	if err != nil {
+		return fmt.Errorf("keyring.EncryptPrivateKey: %w", err)
+	}
 
 	localUser, err := txQueries.InsertLocalUser(ctx, sqlcgen.InsertLocalUserParams{
 		Name:       username,
@@ -51,10 +64,7 @@ func (c *Controller) CreateUser(
 		return fmt.Errorf("queries.InsertLocalUser: %w", err)
 	}
 
-	user, err := NewUser(localUser, c.openapiClient, c.db)
-	if err != nil {
-		return fmt.Errorf("NewUser: %w", err)
-	}
+	user := NewUser(localUser.Name, privKey, c.history, c.secrets, c.openapiClient, c.db)
 
 	// Attempt to register user on distant server, fail if already exists
 	pubKeyBytes := cryptography.MarshalPublicKey(&privKey.PublicKey)
@@ -68,9 +78,130 @@ func (c *Controller) CreateUser(
 		return fmt.Errorf("tx.Commit: %w", err)
 	}
 
+	// Let other users start sessions with us while we are offline
+	_, err = user.UploadPrekeys(ctx, true)
+	if err != nil {
+		return fmt.Errorf("user.UploadPrekeys: %w", err)
+	}
+
+	return nil
+}
+
+// LinkDevice creates a local user on this device for an existing user, with a new key, and returns the
+// PEM encoded public key. The key must be added from one of the user's existing devices before use.
+func (c *Controller) LinkDevice(
+	ctx context.Context,
+	username string,
+) ([]byte, error) {
+	privKey, err := cryptography.GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.GenerateKey: %w", err)
+	}
+
+	privKeyBytes, err := c.keyring.EncryptPrivateKey(username, privKey)
+	if err != nil {
+		return nil, fmt.Errorf("keyring.EncryptPrivateKey: %w", err)
+	}
+
+	queries := sqlcgen.New(c.db)
+	_, err = queries.InsertLocalUser(ctx, sqlcgen.InsertLocalUserParams{
+		Name:       username,
+		PrivateKey: privKeyBytes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("queries.InsertLocalUser: %w", err)
+	}
+
+	return cryptography.MarshalPublicKey(&privKey.PublicKey), nil
+}
+
+// AddDevice adds the public key of a new device to an existing local user.
+func (c *Controller) AddDevice(
+	ctx context.Context,
+	username openapi.Username,
+	publicKey []byte,
+) error {
+	user, err := c.GetUser(ctx, username)
+	if err != nil {
+		return fmt.Errorf("GetUser: %w", err)
+	}
+	err = user.AddDevice(ctx, publicKey)
+	if err != nil {
+		return fmt.Errorf("user.AddDevice: %w", err)
+	}
+	return nil
+}
+
+// RotateKey replaces the key of a local user with a new one.
+func (c *Controller) RotateKey(
+	ctx context.Context,
+	username openapi.Username,
+) error {
+	user, err := c.GetUser(ctx, username)
+	if err != nil {
+		return fmt.Errorf("GetUser: %w", err)
+	}
+	err = user.RotateKey(ctx, c.keyring)
+	if err != nil {
+		return fmt.Errorf("user.RotateKey: %w", err)
+	}
+	return nil
+}
+
+// SetReadReceipts enables or disables the read receipts sent by a local user.
+// Delivery receipts are always sent.
+func (c *Controller) SetReadReceipts(
+	ctx context.Context,
+	username openapi.Username,
+	enabled bool,
+) error {
+	queries := sqlcgen.New(c.db)
+	_, err := queries.GetLocalUserByName(ctx, username)
+	if err != nil {
+		return fmt.Errorf("GetLocalUserByName: %w", err)
+	}
+	err = queries.SetLocalUserReadReceipts(ctx, sqlcgen.SetLocalUserReadReceiptsParams{
+		ReadReceipts: enabled,
+		Name:         username,
+	})
+	if err != nil {
+		return fmt.Errorf("SetLocalUserReadReceipts: %w", err)
+	}
 	return nil
 }
 
+// EncryptMessageHistory encrypts the content of the messages stored in plain text in the local database,
+// and returns how many were encrypted.
+func (c *Controller) EncryptMessageHistory(ctx context.Context) (int, error) {
+	history, err := NewMessageHistory(c.keyring, true)
+	if err != nil {
+		return 0, fmt.Errorf("NewMessageHistory: %w", err)
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	txQueries := sqlcgen.New(c.db).WithTx(tx)
+
+	count, err := history.EncryptStoredMessages(ctx, txQueries)
+	if err != nil {
+		return 0, fmt.Errorf("history.EncryptStoredMessages: %w", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("tx.Commit: %w", err)
+	}
+
+	// The plain text contents would otherwise remain in the free pages of the database file
+	err = database.Vacuum(ctx, c.db)
+	if err != nil {
+		return 0, fmt.Errorf("database.Vacuum: %w", err)
+	}
+	return count, nil
+}
+
 func (c *Controller) GetUser(
 	ctx context.Context,
 	username openapi.Username,
@@ -80,10 +211,10 @@ func (c *Controller) GetUser(
 	if err != nil {
 		return nil, fmt.Errorf("GetLocalUserByName: %w", err)
 	}
-	user, err := NewUser(localUser, c.openapiClient, c.db)
+	privKey, err := c.keyring.PrivateKey(localUser)
 	if err != nil {
-		return nil, fmt.Errorf("NewUser: %w", err)
+		return nil, fmt.Errorf("keyring.PrivateKey: %w", err)
 	}
 
-	return user, nil
+	return NewUser(localUser.Name, privKey, c.history, c.secrets, c.openapiClient, c.db), nil
 }
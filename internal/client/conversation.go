@@ -1,18 +1,52 @@
 package client
 
 import (
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/marc921/talk/internal/client/database/sqlcgen"
 )
 
 type Conversation struct {
+	// dbConv.Session is the session as stored, encrypted by secrets.
 	dbConv   *sqlcgen.Conversation
+	secrets  *LocalSecrets
 	messages []*sqlcgen.Message
+	// sessionMu serializes the use of the session, whose ratchet advances with every message.
+	sessionMu    sync.Mutex
+	sessionState *Session
+	// Whether the last message was sent without the session, as one of the users has several devices,
+	// hence without forward secrecy. Set when sending from any goroutine, read by the UI.
+	withoutSession atomic.Bool
+	// When the remote user was last told that we are typing, zero once told that we stopped.
+	// Only used by the UI goroutine, like typingUntil.
+	typingSentAt time.Time
+	// Until when the remote user is shown typing.
+	typingUntil time.Time
 }
 
 func NewConversation(
 	dbConv *sqlcgen.Conversation,
+	secrets *LocalSecrets,
 ) *Conversation {
 	return &Conversation{
-		dbConv: dbConv,
+		dbConv:  dbConv,
+		secrets: secrets,
+	}
+}
+
+// message returns the cached message with the given local ID, if loaded.
+func (c *Conversation) message(id int64) *sqlcgen.Message {
+	for _, message := range c.messages {
+		if message.ID == id {
+			return message
+		}
 	}
+	return nil
+}
+
+// remoteTyping reports whether the remote user is typing.
+func (c *Conversation) remoteTyping() bool {
+	return time.Now().Before(c.typingUntil)
 }
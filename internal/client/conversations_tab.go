@@ -76,6 +76,7 @@ func (c *ConversationsTab) OnEvent(event any) {
 			} else {
 				remoteUsernames := c.GetSortedRemoteUsernames()
 				UISingleton.actions <- &ActionSelectConversation{
+					localUser:    c.localUser,
 					conversation: c.localUser.conversations[remoteUsernames[c.hovered]],
 				}
 			}
@@ -115,6 +116,9 @@ func (c *ConversationsTab) Render() {
 	remoteUsernames := c.GetSortedRemoteUsernames()
 	for i, remoteUsername := range remoteUsernames {
 		line := fmt.Sprintf(" %d. "+remoteUsername, i+1)
+		if c.localUser.online[remoteUsername] {
+			line += " ●"
+		}
 		style := tcell.StyleDefault
 		if remoteUsername == c.selected {
 			style = style.Foreground(tcell.ColorGreen).Bold(true)
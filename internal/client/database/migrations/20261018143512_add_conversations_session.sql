@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE conversations ADD COLUMN session BLOB;
+
+-- migrate:down
+ALTER TABLE conversations DROP COLUMN session;
@@ -0,0 +1,11 @@
+-- migrate:up
+CREATE TABLE prekeys (
+	local_user_name TEXT REFERENCES local_users(name) NOT NULL,
+	key_id INTEGER NOT NULL,
+	one_time BOOLEAN NOT NULL,
+	private_key BLOB NOT NULL,
+	PRIMARY KEY (local_user_name, one_time, key_id)
+);
+
+-- migrate:down
+DROP TABLE prekeys;
@@ -0,0 +1,7 @@
+-- migrate:up
+ALTER TABLE public_users ADD COLUMN verified_at DATETIME;
+ALTER TABLE public_users ADD COLUMN pending_public_key BLOB;
+
+-- migrate:down
+ALTER TABLE public_users DROP COLUMN pending_public_key;
+ALTER TABLE public_users DROP COLUMN verified_at;
@@ -0,0 +1,12 @@
+-- migrate:up
+CREATE TABLE key_encryption (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	salt BLOB NOT NULL,
+	time INTEGER NOT NULL,
+	memory INTEGER NOT NULL,
+	threads INTEGER NOT NULL,
+	verifier BLOB NOT NULL
+);
+
+-- migrate:down
+DROP TABLE key_encryption;
@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE messages ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- migrate:down
+ALTER TABLE messages DROP COLUMN encrypted;
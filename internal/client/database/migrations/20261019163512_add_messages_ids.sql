@@ -0,0 +1,9 @@
+-- migrate:up
+ALTER TABLE messages ADD COLUMN client_id TEXT;
+ALTER TABLE messages ADD COLUMN server_id TEXT;
+CREATE UNIQUE INDEX messages_server_id ON messages(server_id);
+
+-- migrate:down
+DROP INDEX messages_server_id;
+ALTER TABLE messages DROP COLUMN server_id;
+ALTER TABLE messages DROP COLUMN client_id;
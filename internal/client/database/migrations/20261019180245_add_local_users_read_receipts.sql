@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE local_users ADD COLUMN read_receipts BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- migrate:down
+ALTER TABLE local_users DROP COLUMN read_receipts;
@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE messages ADD COLUMN kind TEXT NOT NULL DEFAULT 'message';
+
+-- migrate:down
+ALTER TABLE messages DROP COLUMN kind;
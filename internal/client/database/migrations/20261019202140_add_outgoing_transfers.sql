@@ -0,0 +1,13 @@
+-- migrate:up
+-- Files being uploaded, for an interrupted upload to resume when the same file is sent again
+CREATE TABLE outgoing_transfers (
+	id TEXT PRIMARY KEY,
+	local_user_name TEXT REFERENCES local_users(name) NOT NULL,
+	recipient TEXT NOT NULL,
+	hash BLOB NOT NULL,
+	key BLOB NOT NULL,
+	UNIQUE (local_user_name, recipient, hash)
+);
+
+-- migrate:down
+DROP TABLE outgoing_transfers;
@@ -0,0 +1,9 @@
+-- migrate:up
+-- Local users sharing the database store their own copy of the messages they exchange,
+-- under the same server ID.
+DROP INDEX messages_server_id;
+CREATE UNIQUE INDEX messages_server_id ON messages(conversation_id, server_id);
+
+-- migrate:down
+DROP INDEX messages_server_id;
+CREATE UNIQUE INDEX messages_server_id ON messages(server_id);
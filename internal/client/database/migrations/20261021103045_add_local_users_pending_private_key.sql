@@ -0,0 +1,6 @@
+-- migrate:up
+-- Key being rotated to, kept until the server switched to it.
+ALTER TABLE local_users ADD COLUMN pending_private_key BLOB;
+
+-- migrate:down
+ALTER TABLE local_users DROP COLUMN pending_private_key;
@@ -0,0 +1,6 @@
+-- migrate:up
+-- Whether the sessions, prekeys and transfer keys stored before were encrypted.
+ALTER TABLE key_encryption ADD COLUMN secrets_encrypted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- migrate:down
+ALTER TABLE key_encryption DROP COLUMN secrets_encrypted;
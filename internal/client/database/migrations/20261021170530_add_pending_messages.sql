@@ -0,0 +1,10 @@
+-- migrate:up
+-- Envelopes of the messages stored locally but not yet accepted by the server, posted again with the
+-- same client ID until they are.
+CREATE TABLE pending_messages (
+	message_id INTEGER PRIMARY KEY REFERENCES messages(id),
+	envelope BLOB NOT NULL
+);
+
+-- migrate:down
+DROP TABLE pending_messages;
@@ -9,4 +9,7 @@ INSERT INTO conversations (local_user_name, remote_user_name)
 VALUES (?, ?) 
 ON CONFLICT (local_user_name, remote_user_name) 
 DO UPDATE SET local_user_name = EXCLUDED.local_user_name
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: UpdateConversationSession :exec
+UPDATE conversations SET session = ? WHERE id = ?;
\ No newline at end of file
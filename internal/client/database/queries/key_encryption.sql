@@ -0,0 +1,8 @@
+-- name: GetKeyEncryption :one
+SELECT * FROM key_encryption WHERE id = 1;
+
+-- name: InsertKeyEncryption :exec
+INSERT INTO key_encryption (id, salt, time, memory, threads, verifier) VALUES (1, ?, ?, ?, ?, ?);
+
+-- name: SetSecretsEncrypted :exec
+UPDATE key_encryption SET secrets_encrypted = TRUE WHERE id = 1;
\ No newline at end of file
@@ -5,4 +5,18 @@ SELECT * FROM local_users;
 SELECT * FROM local_users WHERE name = ?;
 
 -- name: InsertLocalUser :one
-INSERT INTO local_users (name, private_key) VALUES (?, ?) RETURNING *;
\ No newline at end of file
+INSERT INTO local_users (name, private_key) VALUES (?, ?) RETURNING *;
+
+-- name: UpdateLocalUserPrivateKey :exec
+UPDATE local_users SET private_key = ? WHERE name = ?;
+
+-- name: SetLocalUserPendingKey :exec
+UPDATE local_users SET pending_private_key = ? WHERE name = ?;
+
+-- name: ApplyLocalUserPendingKey :exec
+-- The pending key replaces the key once the server switched to it.
+UPDATE local_users SET private_key = pending_private_key, pending_private_key = NULL
+WHERE name = ? AND pending_private_key IS NOT NULL;
+
+-- name: SetLocalUserReadReceipts :exec
+UPDATE local_users SET read_receipts = ? WHERE name = ?;
\ No newline at end of file
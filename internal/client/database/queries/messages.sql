@@ -1,19 +1,34 @@
 -- name: ListMessages :many
-SELECT * FROM messages WHERE conversation_id = ?;
+SELECT * FROM messages WHERE conversation_id = ? ORDER BY sent_at, id;
+
+-- name: GetMessageByServerID :one
+SELECT * FROM messages WHERE conversation_id = ? AND server_id = ?;
+
+-- name: ListUnencryptedMessages :many
+SELECT * FROM messages WHERE encrypted = FALSE;
 
 -- name: InsertMessage :one
 INSERT INTO messages (
 	conversation_id,
 	sender,
 	receiver,
-	content
-) VALUES (?, ?, ?, ?) RETURNING *;
+	content,
+	verified,
+	encrypted,
+	client_id,
+	server_id,
+	sent_at,
+	kind
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
 
--- name: MarkMessageSent :one
-UPDATE messages SET sent_at = ? WHERE id = ? RETURNING *;
+-- name: MarkMessageSent :exec
+UPDATE messages SET server_id = ?, sent_at = ? WHERE id = ?;
 
 -- name: MarkMessageDelivered :one
 UPDATE messages SET delivered_at = ? WHERE id = ? RETURNING *;
 
 -- name: MarkMessageRead :one
-UPDATE messages SET read_at = ? WHERE id = ? RETURNING *;
\ No newline at end of file
+UPDATE messages SET read_at = ? WHERE id = ? RETURNING *;
+
+-- name: UpdateMessageContent :exec
+UPDATE messages SET content = ?, encrypted = ? WHERE id = ?;
\ No newline at end of file
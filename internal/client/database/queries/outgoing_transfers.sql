@@ -0,0 +1,14 @@
+-- name: InsertOutgoingTransfer :exec
+INSERT INTO outgoing_transfers (id, local_user_name, recipient, hash, key) VALUES (?, ?, ?, ?, ?);
+
+-- name: GetOutgoingTransfer :one
+SELECT * FROM outgoing_transfers WHERE local_user_name = ? AND recipient = ? AND hash = ?;
+
+-- name: DeleteOutgoingTransfer :exec
+DELETE FROM outgoing_transfers WHERE id = ?;
+
+-- name: ListOutgoingTransfers :many
+SELECT * FROM outgoing_transfers;
+
+-- name: UpdateOutgoingTransferKey :exec
+UPDATE outgoing_transfers SET key = ? WHERE id = ?;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- name: InsertPendingMessage :exec
+INSERT INTO pending_messages (message_id, envelope) VALUES (?, ?);
+
+-- name: ListPendingMessages :many
+SELECT pending_messages.*, messages.conversation_id FROM pending_messages
+JOIN messages ON messages.id = pending_messages.message_id
+WHERE messages.sender = ?
+ORDER BY messages.id;
+
+-- name: DeletePendingMessage :exec
+DELETE FROM pending_messages WHERE message_id = ?;
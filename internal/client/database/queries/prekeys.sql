@@ -0,0 +1,17 @@
+-- name: InsertPrekey :exec
+INSERT INTO prekeys (local_user_name, key_id, one_time, private_key) VALUES (?, ?, ?, ?);
+
+-- name: GetPrekey :one
+SELECT * FROM prekeys WHERE local_user_name = ? AND one_time = ? AND key_id = ?;
+
+-- name: DeletePrekey :exec
+DELETE FROM prekeys WHERE local_user_name = ? AND one_time = ? AND key_id = ?;
+
+-- name: ListPrekeys :many
+SELECT * FROM prekeys WHERE local_user_name = ?;
+
+-- name: CountOneTimePrekeys :one
+SELECT COUNT(*) FROM prekeys WHERE local_user_name = ? AND one_time = TRUE;
+
+-- name: UpdatePrekeyPrivateKey :exec
+UPDATE prekeys SET private_key = ? WHERE local_user_name = ? AND one_time = ? AND key_id = ?;
\ No newline at end of file
@@ -5,4 +5,17 @@ SELECT * FROM public_users;
 SELECT * FROM public_users WHERE name = ?;
 
 -- name: InsertPublicUser :one
-INSERT INTO public_users (name, public_key) VALUES (?, ?) RETURNING *;
\ No newline at end of file
+INSERT INTO public_users (name, public_key) VALUES (?, ?) RETURNING *;
+
+-- name: UpdatePublicUserKey :exec
+-- The safety number changes along with the key, so the key must be verified again.
+UPDATE public_users SET public_key = ?, verified_at = NULL WHERE name = ?;
+
+-- name: SetPublicUserPendingKey :exec
+UPDATE public_users SET pending_public_key = ? WHERE name = ?;
+
+-- name: VerifyPublicUserKey :exec
+UPDATE public_users SET public_key = ?, pending_public_key = NULL, verified_at = CURRENT_TIMESTAMP WHERE name = ?;
+
+-- name: ImportPublicUser :exec
+INSERT INTO public_users (name, public_key, verified_at) VALUES (?, ?, ?);
\ No newline at end of file
@@ -2,15 +2,15 @@ CREATE TABLE IF NOT EXISTS "schema_migrations" (version varchar(128) primary key
 CREATE TABLE local_users (
 	name TEXT PRIMARY KEY,
 	private_key BLOB
-);
+, read_receipts BOOLEAN NOT NULL DEFAULT TRUE, pending_private_key BLOB);
 CREATE TABLE public_users (
 	name TEXT PRIMARY KEY,
 	public_key BLOB
-);
+, verified_at DATETIME, pending_public_key BLOB);
 CREATE TABLE conversations (
 	id INTEGER PRIMARY KEY,
 	local_user_name TEXT REFERENCES local_users(name) NOT NULL,
-	remote_user_name TEXT REFERENCES public_users(name) NOT NULL,
+	remote_user_name TEXT REFERENCES public_users(name) NOT NULL, session BLOB,
 	UNIQUE (local_user_name, remote_user_name)
 );
 CREATE TABLE messages (
@@ -22,10 +22,52 @@ CREATE TABLE messages (
 	sent_at DATETIME,
 	delivered_at DATETIME,
 	read_at DATETIME
+, verified BOOLEAN NOT NULL DEFAULT FALSE, encrypted BOOLEAN NOT NULL DEFAULT FALSE, client_id TEXT, server_id TEXT, kind TEXT NOT NULL DEFAULT 'message');
+CREATE TABLE prekeys (
+	local_user_name TEXT REFERENCES local_users(name) NOT NULL,
+	key_id INTEGER NOT NULL,
+	one_time BOOLEAN NOT NULL,
+	private_key BLOB NOT NULL,
+	PRIMARY KEY (local_user_name, one_time, key_id)
+);
+CREATE TABLE key_encryption (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	salt BLOB NOT NULL,
+	time INTEGER NOT NULL,
+	memory INTEGER NOT NULL,
+	threads INTEGER NOT NULL,
+	verifier BLOB NOT NULL
+, secrets_encrypted BOOLEAN NOT NULL DEFAULT FALSE);
+CREATE UNIQUE INDEX messages_server_id ON messages(conversation_id, server_id);
+CREATE TABLE outgoing_transfers (
+	id TEXT PRIMARY KEY,
+	local_user_name TEXT REFERENCES local_users(name) NOT NULL,
+	recipient TEXT NOT NULL,
+	hash BLOB NOT NULL,
+	key BLOB NOT NULL,
+	UNIQUE (local_user_name, recipient, hash)
+);
+CREATE TABLE pending_messages (
+	message_id INTEGER PRIMARY KEY REFERENCES messages(id),
+	envelope BLOB NOT NULL
 );
 -- Dbmate schema migrations
 INSERT INTO "schema_migrations" (version) VALUES
   ('20241105135553'),
   ('20241105140048'),
   ('20241105142502'),
-  ('20241110121425');
+  ('20241110121425'),
+  ('20261018093012'),
+  ('20261018143512'),
+  ('20261018163020'),
+  ('20261018211204'),
+  ('20261019090512'),
+  ('20261019113045'),
+  ('20261019163512'),
+  ('20261019180245'),
+  ('20261019202015'),
+  ('20261019202140'),
+  ('20261021091530'),
+  ('20261021103045'),
+  ('20261021141530'),
+  ('20261021170530');
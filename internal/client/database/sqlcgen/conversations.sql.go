@@ -10,7 +10,7 @@ import (
 )
 
 const getConversation = `-- name: GetConversation :one
-SELECT id, local_user_name, remote_user_name FROM conversations WHERE local_user_name = ? AND remote_user_name = ?
+SELECT id, local_user_name, remote_user_name, session FROM conversations WHERE local_user_name = ? AND remote_user_name = ?
 `
 
 type GetConversationParams struct {
@@ -21,7 +21,7 @@ type GetConversationParams struct {
 func (q *Queries) GetConversation(ctx context.Context, arg GetConversationParams) (*Conversation, error) {
 	row := q.db.QueryRowContext(ctx, getConversation, arg.LocalUserName, arg.RemoteUserName)
 	var i Conversation
-	err := row.Scan(&i.ID, &i.LocalUserName, &i.RemoteUserName)
+	err := row.Scan(&i.ID, &i.LocalUserName, &i.RemoteUserName, &i.Session)
 	return &i, err
 }
 
@@ -30,7 +30,7 @@ INSERT INTO conversations (local_user_name, remote_user_name)
 VALUES (?, ?) 
 ON CONFLICT (local_user_name, remote_user_name) 
 DO UPDATE SET local_user_name = EXCLUDED.local_user_name
-RETURNING id, local_user_name, remote_user_name
+RETURNING id, local_user_name, remote_user_name, session
 `
 
 type InsertConversationParams struct {
@@ -41,12 +41,12 @@ type InsertConversationParams struct {
 func (q *Queries) InsertConversation(ctx context.Context, arg InsertConversationParams) (*Conversation, error) {
 	row := q.db.QueryRowContext(ctx, insertConversation, arg.LocalUserName, arg.RemoteUserName)
 	var i Conversation
-	err := row.Scan(&i.ID, &i.LocalUserName, &i.RemoteUserName)
+	err := row.Scan(&i.ID, &i.LocalUserName, &i.RemoteUserName, &i.Session)
 	return &i, err
 }
 
 const listConversations = `-- name: ListConversations :many
-SELECT id, local_user_name, remote_user_name FROM conversations WHERE local_user_name = ?
+SELECT id, local_user_name, remote_user_name, session FROM conversations WHERE local_user_name = ?
 `
 
 func (q *Queries) ListConversations(ctx context.Context, localUserName string) ([]*Conversation, error) {
@@ -58,7 +58,7 @@ func (q *Queries) ListConversations(ctx context.Context, localUserName string) (
 	var items []*Conversation
 	for rows.Next() {
 		var i Conversation
-		if err := rows.Scan(&i.ID, &i.LocalUserName, &i.RemoteUserName); err != nil {
+		if err := rows.Scan(&i.ID, &i.LocalUserName, &i.RemoteUserName, &i.Session); err != nil {
 			return nil, err
 		}
 		items = append(items, &i)
@@ -71,3 +71,17 @@ func (q *Queries) ListConversations(ctx context.Context, localUserName string) (
 	}
 	return items, nil
 }
+
+const updateConversationSession = `-- name: UpdateConversationSession :exec
+UPDATE conversations SET session = ? WHERE id = ?
+`
+
+type UpdateConversationSessionParams struct {
+	Session []byte
+	ID      int64
+}
+
+func (q *Queries) UpdateConversationSession(ctx context.Context, arg UpdateConversationSessionParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationSession, arg.Session, arg.ID)
+	return err
+}
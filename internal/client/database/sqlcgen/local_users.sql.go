@@ -9,19 +9,35 @@ import (
 	"context"
 )
 
+const applyLocalUserPendingKey = `-- name: ApplyLocalUserPendingKey :exec
+UPDATE local_users SET private_key = pending_private_key, pending_private_key = NULL
+WHERE name = ? AND pending_private_key IS NOT NULL
+`
+
+// The pending key replaces the key once the server switched to it.
+func (q *Queries) ApplyLocalUserPendingKey(ctx context.Context, name string) error {
+	_, err := q.db.ExecContext(ctx, applyLocalUserPendingKey, name)
+	return err
+}
+
 const getLocalUserByName = `-- name: GetLocalUserByName :one
-SELECT name, private_key FROM local_users WHERE name = ?
+SELECT name, private_key, read_receipts, pending_private_key FROM local_users WHERE name = ?
 `
 
 func (q *Queries) GetLocalUserByName(ctx context.Context, name string) (*LocalUser, error) {
 	row := q.db.QueryRowContext(ctx, getLocalUserByName, name)
 	var i LocalUser
-	err := row.Scan(&i.Name, &i.PrivateKey)
+	err := row.Scan(
+		&i.Name,
+		&i.PrivateKey,
+		&i.ReadReceipts,
+		&i.PendingPrivateKey,
+	)
 	return &i, err
 }
 
 const insertLocalUser = `-- name: InsertLocalUser :one
-INSERT INTO local_users (name, private_key) VALUES (?, ?) RETURNING name, private_key
+INSERT INTO local_users (name, private_key) VALUES (?, ?) RETURNING name, private_key, read_receipts, pending_private_key
 `
 
 type InsertLocalUserParams struct {
@@ -32,12 +48,17 @@ type InsertLocalUserParams struct {
 func (q *Queries) InsertLocalUser(ctx context.Context, arg InsertLocalUserParams) (*LocalUser, error) {
 	row := q.db.QueryRowContext(ctx, insertLocalUser, arg.Name, arg.PrivateKey)
 	var i LocalUser
-	err := row.Scan(&i.Name, &i.PrivateKey)
+	err := row.Scan(
+		&i.Name,
+		&i.PrivateKey,
+		&i.ReadReceipts,
+		&i.PendingPrivateKey,
+	)
 	return &i, err
 }
 
 const listLocalUsers = `-- name: ListLocalUsers :many
-SELECT name, private_key FROM local_users
+SELECT name, private_key, read_receipts, pending_private_key FROM local_users
 `
 
 func (q *Queries) ListLocalUsers(ctx context.Context) ([]*LocalUser, error) {
@@ -49,7 +70,12 @@ func (q *Queries) ListLocalUsers(ctx context.Context) ([]*LocalUser, error) {
 	var items []*LocalUser
 	for rows.Next() {
 		var i LocalUser
-		if err := rows.Scan(&i.Name, &i.PrivateKey); err != nil {
+		if err := rows.Scan(
+			&i.Name,
+			&i.PrivateKey,
+			&i.ReadReceipts,
+			&i.PendingPrivateKey,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, &i)
@@ -62,3 +88,45 @@ func (q *Queries) ListLocalUsers(ctx context.Context) ([]*LocalUser, error) {
 	}
 	return items, nil
 }
+
+const setLocalUserPendingKey = `-- name: SetLocalUserPendingKey :exec
+UPDATE local_users SET pending_private_key = ? WHERE name = ?
+`
+
+type SetLocalUserPendingKeyParams struct {
+	PendingPrivateKey []byte
+	Name              string
+}
+
+func (q *Queries) SetLocalUserPendingKey(ctx context.Context, arg SetLocalUserPendingKeyParams) error {
+	_, err := q.db.ExecContext(ctx, setLocalUserPendingKey, arg.PendingPrivateKey, arg.Name)
+	return err
+}
+
+const setLocalUserReadReceipts = `-- name: SetLocalUserReadReceipts :exec
+UPDATE local_users SET read_receipts = ? WHERE name = ?
+`
+
+type SetLocalUserReadReceiptsParams struct {
+	ReadReceipts bool
+	Name         string
+}
+
+func (q *Queries) SetLocalUserReadReceipts(ctx context.Context, arg SetLocalUserReadReceiptsParams) error {
+	_, err := q.db.ExecContext(ctx, setLocalUserReadReceipts, arg.ReadReceipts, arg.Name)
+	return err
+}
+
+const updateLocalUserPrivateKey = `-- name: UpdateLocalUserPrivateKey :exec
+UPDATE local_users SET private_key = ? WHERE name = ?
+`
+
+type UpdateLocalUserPrivateKeyParams struct {
+	PrivateKey []byte
+	Name       string
+}
+
+func (q *Queries) UpdateLocalUserPrivateKey(ctx context.Context, arg UpdateLocalUserPrivateKeyParams) error {
+	_, err := q.db.ExecContext(ctx, updateLocalUserPrivateKey, arg.PrivateKey, arg.Name)
+	return err
+}
@@ -10,13 +10,49 @@ import (
 	"database/sql"
 )
 
+const getMessageByServerID = `-- name: GetMessageByServerID :one
+SELECT id, conversation_id, sender, receiver, content, sent_at, delivered_at, read_at, verified, encrypted, client_id, server_id, kind FROM messages WHERE conversation_id = ? AND server_id = ?
+`
+
+type GetMessageByServerIDParams struct {
+	ConversationID int64
+	ServerID       sql.NullString
+}
+
+func (q *Queries) GetMessageByServerID(ctx context.Context, arg GetMessageByServerIDParams) (*Message, error) {
+	row := q.db.QueryRowContext(ctx, getMessageByServerID, arg.ConversationID, arg.ServerID)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.ConversationID,
+		&i.Sender,
+		&i.Receiver,
+		&i.Content,
+		&i.SentAt,
+		&i.DeliveredAt,
+		&i.ReadAt,
+		&i.Verified,
+		&i.Encrypted,
+		&i.ClientID,
+		&i.ServerID,
+		&i.Kind,
+	)
+	return &i, err
+}
+
 const insertMessage = `-- name: InsertMessage :one
 INSERT INTO messages (
 	conversation_id,
 	sender,
 	receiver,
-	content
-) VALUES (?, ?, ?, ?) RETURNING id, conversation_id, sender, receiver, content, sent_at, delivered_at, read_at
+	content,
+	verified,
+	encrypted,
+	client_id,
+	server_id,
+	sent_at,
+	kind
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id, conversation_id, sender, receiver, content, sent_at, delivered_at, read_at, verified, encrypted, client_id, server_id, kind
 `
 
 type InsertMessageParams struct {
@@ -24,6 +60,12 @@ type InsertMessageParams struct {
 	Sender         string
 	Receiver       string
 	Content        []byte
+	Verified       bool
+	Encrypted      bool
+	ClientID       sql.NullString
+	ServerID       sql.NullString
+	SentAt         sql.NullTime
+	Kind           string
 }
 
 func (q *Queries) InsertMessage(ctx context.Context, arg InsertMessageParams) (*Message, error) {
@@ -32,6 +74,12 @@ func (q *Queries) InsertMessage(ctx context.Context, arg InsertMessageParams) (*
 		arg.Sender,
 		arg.Receiver,
 		arg.Content,
+		arg.Verified,
+		arg.Encrypted,
+		arg.ClientID,
+		arg.ServerID,
+		arg.SentAt,
+		arg.Kind,
 	)
 	var i Message
 	err := row.Scan(
@@ -43,12 +91,17 @@ func (q *Queries) InsertMessage(ctx context.Context, arg InsertMessageParams) (*
 		&i.SentAt,
 		&i.DeliveredAt,
 		&i.ReadAt,
+		&i.Verified,
+		&i.Encrypted,
+		&i.ClientID,
+		&i.ServerID,
+		&i.Kind,
 	)
 	return &i, err
 }
 
 const listMessages = `-- name: ListMessages :many
-SELECT id, conversation_id, sender, receiver, content, sent_at, delivered_at, read_at FROM messages WHERE conversation_id = ?
+SELECT id, conversation_id, sender, receiver, content, sent_at, delivered_at, read_at, verified, encrypted, client_id, server_id, kind FROM messages WHERE conversation_id = ? ORDER BY sent_at, id
 `
 
 func (q *Queries) ListMessages(ctx context.Context, conversationID int64) ([]*Message, error) {
@@ -69,6 +122,52 @@ func (q *Queries) ListMessages(ctx context.Context, conversationID int64) ([]*Me
 			&i.SentAt,
 			&i.DeliveredAt,
 			&i.ReadAt,
+			&i.Verified,
+			&i.Encrypted,
+			&i.ClientID,
+			&i.ServerID,
+			&i.Kind,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnencryptedMessages = `-- name: ListUnencryptedMessages :many
+SELECT id, conversation_id, sender, receiver, content, sent_at, delivered_at, read_at, verified, encrypted, client_id, server_id, kind FROM messages WHERE encrypted = FALSE
+`
+
+func (q *Queries) ListUnencryptedMessages(ctx context.Context) ([]*Message, error) {
+	rows, err := q.db.QueryContext(ctx, listUnencryptedMessages)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.Sender,
+			&i.Receiver,
+			&i.Content,
+			&i.SentAt,
+			&i.DeliveredAt,
+			&i.ReadAt,
+			&i.Verified,
+			&i.Encrypted,
+			&i.ClientID,
+			&i.ServerID,
+			&i.Kind,
 		); err != nil {
 			return nil, err
 		}
@@ -84,7 +183,7 @@ func (q *Queries) ListMessages(ctx context.Context, conversationID int64) ([]*Me
 }
 
 const markMessageDelivered = `-- name: MarkMessageDelivered :one
-UPDATE messages SET delivered_at = ? WHERE id = ? RETURNING id, conversation_id, sender, receiver, content, sent_at, delivered_at, read_at
+UPDATE messages SET delivered_at = ? WHERE id = ? RETURNING id, conversation_id, sender, receiver, content, sent_at, delivered_at, read_at, verified, encrypted, client_id, server_id, kind
 `
 
 type MarkMessageDeliveredParams struct {
@@ -104,12 +203,17 @@ func (q *Queries) MarkMessageDelivered(ctx context.Context, arg MarkMessageDeliv
 		&i.SentAt,
 		&i.DeliveredAt,
 		&i.ReadAt,
+		&i.Verified,
+		&i.Encrypted,
+		&i.ClientID,
+		&i.ServerID,
+		&i.Kind,
 	)
 	return &i, err
 }
 
 const markMessageRead = `-- name: MarkMessageRead :one
-UPDATE messages SET read_at = ? WHERE id = ? RETURNING id, conversation_id, sender, receiver, content, sent_at, delivered_at, read_at
+UPDATE messages SET read_at = ? WHERE id = ? RETURNING id, conversation_id, sender, receiver, content, sent_at, delivered_at, read_at, verified, encrypted, client_id, server_id, kind
 `
 
 type MarkMessageReadParams struct {
@@ -129,31 +233,41 @@ func (q *Queries) MarkMessageRead(ctx context.Context, arg MarkMessageReadParams
 		&i.SentAt,
 		&i.DeliveredAt,
 		&i.ReadAt,
+		&i.Verified,
+		&i.Encrypted,
+		&i.ClientID,
+		&i.ServerID,
+		&i.Kind,
 	)
 	return &i, err
 }
 
-const markMessageSent = `-- name: MarkMessageSent :one
-UPDATE messages SET sent_at = ? WHERE id = ? RETURNING id, conversation_id, sender, receiver, content, sent_at, delivered_at, read_at
+const markMessageSent = `-- name: MarkMessageSent :exec
+UPDATE messages SET server_id = ?, sent_at = ? WHERE id = ?
 `
 
 type MarkMessageSentParams struct {
-	SentAt sql.NullTime
-	ID     int64
+	ServerID sql.NullString
+	SentAt   sql.NullTime
+	ID       int64
 }
 
-func (q *Queries) MarkMessageSent(ctx context.Context, arg MarkMessageSentParams) (*Message, error) {
-	row := q.db.QueryRowContext(ctx, markMessageSent, arg.SentAt, arg.ID)
-	var i Message
-	err := row.Scan(
-		&i.ID,
-		&i.ConversationID,
-		&i.Sender,
-		&i.Receiver,
-		&i.Content,
-		&i.SentAt,
-		&i.DeliveredAt,
-		&i.ReadAt,
-	)
-	return &i, err
+func (q *Queries) MarkMessageSent(ctx context.Context, arg MarkMessageSentParams) error {
+	_, err := q.db.ExecContext(ctx, markMessageSent, arg.ServerID, arg.SentAt, arg.ID)
+	return err
+}
+
+const updateMessageContent = `-- name: UpdateMessageContent :exec
+UPDATE messages SET content = ?, encrypted = ? WHERE id = ?
+`
+
+type UpdateMessageContentParams struct {
+	Content   []byte
+	Encrypted bool
+	ID        int64
+}
+
+func (q *Queries) UpdateMessageContent(ctx context.Context, arg UpdateMessageContentParams) error {
+	_, err := q.db.ExecContext(ctx, updateMessageContent, arg.Content, arg.Encrypted, arg.ID)
+	return err
 }
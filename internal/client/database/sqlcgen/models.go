@@ -12,11 +12,24 @@ type Conversation struct {
 	ID             int64
 	LocalUserName  string
 	RemoteUserName string
+	Session        []byte
+}
+
+type KeyEncryption struct {
+	ID               int64
+	Salt             []byte
+	Time             int64
+	Memory           int64
+	Threads          int64
+	Verifier         []byte
+	SecretsEncrypted bool
 }
 
 type LocalUser struct {
-	Name       string
-	PrivateKey []byte
+	Name              string
+	PrivateKey        []byte
+	ReadReceipts      bool
+	PendingPrivateKey []byte
 }
 
 type Message struct {
@@ -28,11 +41,38 @@ type Message struct {
 	SentAt         sql.NullTime
 	DeliveredAt    sql.NullTime
 	ReadAt         sql.NullTime
+	Verified       bool
+	Encrypted      bool
+	ClientID       sql.NullString
+	ServerID       sql.NullString
+	Kind           string
+}
+
+type OutgoingTransfer struct {
+	ID            string
+	LocalUserName string
+	Recipient     string
+	Hash          []byte
+	Key           []byte
+}
+
+type PendingMessage struct {
+	MessageID int64
+	Envelope  []byte
+}
+
+type Prekey struct {
+	LocalUserName string
+	KeyID         int64
+	OneTime       bool
+	PrivateKey    []byte
 }
 
 type PublicUser struct {
-	Name      string
-	PublicKey []byte
+	Name             string
+	PublicKey        []byte
+	VerifiedAt       sql.NullTime
+	PendingPublicKey []byte
 }
 
 type SchemaMigration struct {
@@ -7,21 +7,42 @@ package sqlcgen
 
 import (
 	"context"
+	"database/sql"
 )
 
 const getPublicUserByName = `-- name: GetPublicUserByName :one
-SELECT name, public_key FROM public_users WHERE name = ?
+SELECT name, public_key, verified_at, pending_public_key FROM public_users WHERE name = ?
 `
 
 func (q *Queries) GetPublicUserByName(ctx context.Context, name string) (*PublicUser, error) {
 	row := q.db.QueryRowContext(ctx, getPublicUserByName, name)
 	var i PublicUser
-	err := row.Scan(&i.Name, &i.PublicKey)
+	err := row.Scan(
+		&i.Name,
+		&i.PublicKey,
+		&i.VerifiedAt,
+		&i.PendingPublicKey,
+	)
 	return &i, err
 }
 
+const importPublicUser = `-- name: ImportPublicUser :exec
+INSERT INTO public_users (name, public_key, verified_at) VALUES (?, ?, ?)
+`
+
+type ImportPublicUserParams struct {
+	Name       string
+	PublicKey  []byte
+	VerifiedAt sql.NullTime
+}
+
+func (q *Queries) ImportPublicUser(ctx context.Context, arg ImportPublicUserParams) error {
+	_, err := q.db.ExecContext(ctx, importPublicUser, arg.Name, arg.PublicKey, arg.VerifiedAt)
+	return err
+}
+
 const insertPublicUser = `-- name: InsertPublicUser :one
-INSERT INTO public_users (name, public_key) VALUES (?, ?) RETURNING name, public_key
+INSERT INTO public_users (name, public_key) VALUES (?, ?) RETURNING name, public_key, verified_at, pending_public_key
 `
 
 type InsertPublicUserParams struct {
@@ -32,12 +53,17 @@ type InsertPublicUserParams struct {
 func (q *Queries) InsertPublicUser(ctx context.Context, arg InsertPublicUserParams) (*PublicUser, error) {
 	row := q.db.QueryRowContext(ctx, insertPublicUser, arg.Name, arg.PublicKey)
 	var i PublicUser
-	err := row.Scan(&i.Name, &i.PublicKey)
+	err := row.Scan(
+		&i.Name,
+		&i.PublicKey,
+		&i.VerifiedAt,
+		&i.PendingPublicKey,
+	)
 	return &i, err
 }
 
 const listPublicUsers = `-- name: ListPublicUsers :many
-SELECT name, public_key FROM public_users
+SELECT name, public_key, verified_at, pending_public_key FROM public_users
 `
 
 func (q *Queries) ListPublicUsers(ctx context.Context) ([]*PublicUser, error) {
@@ -49,7 +75,12 @@ func (q *Queries) ListPublicUsers(ctx context.Context) ([]*PublicUser, error) {
 	var items []*PublicUser
 	for rows.Next() {
 		var i PublicUser
-		if err := rows.Scan(&i.Name, &i.PublicKey); err != nil {
+		if err := rows.Scan(
+			&i.Name,
+			&i.PublicKey,
+			&i.VerifiedAt,
+			&i.PendingPublicKey,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, &i)
@@ -62,3 +93,46 @@ func (q *Queries) ListPublicUsers(ctx context.Context) ([]*PublicUser, error) {
 	}
 	return items, nil
 }
+
+const setPublicUserPendingKey = `-- name: SetPublicUserPendingKey :exec
+UPDATE public_users SET pending_public_key = ? WHERE name = ?
+`
+
+type SetPublicUserPendingKeyParams struct {
+	PendingPublicKey []byte
+	Name             string
+}
+
+func (q *Queries) SetPublicUserPendingKey(ctx context.Context, arg SetPublicUserPendingKeyParams) error {
+	_, err := q.db.ExecContext(ctx, setPublicUserPendingKey, arg.PendingPublicKey, arg.Name)
+	return err
+}
+
+const updatePublicUserKey = `-- name: UpdatePublicUserKey :exec
+UPDATE public_users SET public_key = ?, verified_at = NULL WHERE name = ?
+`
+
+type UpdatePublicUserKeyParams struct {
+	PublicKey []byte
+	Name      string
+}
+
+// The safety number changes along with the key, so the key must be verified again.
+func (q *Queries) UpdatePublicUserKey(ctx context.Context, arg UpdatePublicUserKeyParams) error {
+	_, err := q.db.ExecContext(ctx, updatePublicUserKey, arg.PublicKey, arg.Name)
+	return err
+}
+
+const verifyPublicUserKey = `-- name: VerifyPublicUserKey :exec
+UPDATE public_users SET public_key = ?, pending_public_key = NULL, verified_at = CURRENT_TIMESTAMP WHERE name = ?
+`
+
+type VerifyPublicUserKeyParams struct {
+	PublicKey []byte
+	Name      string
+}
+
+func (q *Queries) VerifyPublicUserKey(ctx context.Context, arg VerifyPublicUserKeyParams) error {
+	_, err := q.db.ExecContext(ctx, verifyPublicUserKey, arg.PublicKey, arg.Name)
+	return err
+}
@@ -3,6 +3,7 @@ package database
 //go:generate sqlc generate
 
 import (
+	"context"
 	"database/sql"
 	_ "embed"
 	"errors"
@@ -84,3 +85,12 @@ func GetOrCreateSQLite3DB(dbPath string) (*sql.DB, error) {
 	}
 	return db, nil
 }
+
+// Vacuum rebuilds the database file, so that overwritten contents do not remain in its free pages.
+func Vacuum(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "VACUUM")
+	if err != nil {
+		return fmt.Errorf("db.ExecContext: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// errNotForThisDevice is returned when decrypting a message that has no key wrapped for our device,
+// such as a message sent before our device was added.
+var errNotForThisDevice = errors.New("message not encrypted for this device")
+
+// Device lists are fetched again after this long, for devices added meanwhile to be used.
+const deviceListTTL = 5 * time.Minute
+
+// deviceList is the cached list of the trusted devices of a user.
+type deviceList struct {
+	devices   []types.Device
+	fetchedAt time.Time
+}
+
+// GetDevices returns the devices of a user whose signatures chain back to the user's public key,
+// as pinned in our database. Devices the server lists without a valid chain are ignored.
+// The first device returned is always the one holding the user's public key.
+// If the server lists another key for the user, ErrKeyChanged is returned until the user approves it.
+// Lists are cached until deviceListTTL, or until we learn that the user's key or devices changed.
+func (u *User) GetDevices(ctx context.Context, name openapi.Username) ([]types.Device, error) {
+	u.devicesMu.Lock()
+	cached, ok := u.devices[name]
+	u.devicesMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < deviceListTTL {
+		return cached.devices, nil
+	}
+
+	devices, err := u.fetchDevices(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("fetchDevices: %w", err)
+	}
+	u.devicesMu.Lock()
+	u.devices[name] = &deviceList{devices: devices, fetchedAt: time.Now()}
+	u.devicesMu.Unlock()
+	return devices, nil
+}
+
+// invalidateDevices drops the cached device list of a user, whose key or devices changed.
+func (u *User) invalidateDevices(name openapi.Username) {
+	u.devicesMu.Lock()
+	defer u.devicesMu.Unlock()
+	delete(u.devices, name)
+}
+
+// fetchDevices lists the devices of a user from the server, see GetDevices.
+func (u *User) fetchDevices(ctx context.Context, name openapi.Username) ([]types.Device, error) {
+	serverKey, listed, err := u.client.GetDevices(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("client.GetDevices: %w", err)
+	}
+	err = u.checkPinnedKey(ctx, name, serverKey)
+	if err != nil {
+		return nil, fmt.Errorf("checkPinnedKey: %w", err)
+	}
+	// Fetched after the check, which may have refreshed the pinned key through a signed rotation
+	publicUser, err := u.GetPublicUser(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("GetPublicUser: %w", err)
+	}
+	primary := types.Device{
+		ID:        cryptography.Fingerprint(publicUser.PublicKey),
+		PublicKey: publicUser.PublicKey,
+	}
+
+	trusted := map[string]*rsa.PublicKey{primary.ID: primary.PublicKey}
+	devices := []types.Device{primary}
+	// Devices are listed in creation order, but keep going until no more device can be trusted
+	for progress := true; progress; {
+		progress = false
+		for _, device := range listed {
+			if _, ok := trusted[device.Id]; ok || device.SignedBy == nil || device.Signature == nil {
+				continue
+			}
+			signerKey, ok := trusted[*device.SignedBy]
+			if !ok {
+				continue
+			}
+			publicKey, err := cryptography.UnmarshalPublicKey(device.PublicKey)
+			if err != nil || cryptography.Fingerprint(publicKey) != device.Id {
+				continue
+			}
+			err = cryptography.Verify(signerKey, types.DeviceSignaturePayload(name, device.PublicKey), *device.Signature)
+			if err != nil {
+				continue
+			}
+			trusted[device.Id] = publicKey
+			devices = append(devices, types.Device{
+				ID:        device.Id,
+				PublicKey: publicKey,
+			})
+			progress = true
+		}
+	}
+	return devices, nil
+}
+
+// deviceKey returns the public key of one of a user's trusted devices,
+// or types.ErrNotFound if the device is unknown.
+func (u *User) deviceKey(ctx context.Context, name openapi.Username, deviceID string) (*rsa.PublicKey, error) {
+	publicUser, err := u.GetPublicUser(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("GetPublicUser: %w", err)
+	}
+	// Avoid fetching the device list for the user's first device
+	if cryptography.Fingerprint(publicUser.PublicKey) == deviceID {
+		return publicUser.PublicKey, nil
+	}
+	for _, cached := range []bool{true, false} {
+		if !cached {
+			// The device may have been added since the list was cached
+			u.invalidateDevices(name)
+		}
+		devices, err := u.GetDevices(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("GetDevices: %w", err)
+		}
+		for _, device := range devices {
+			if device.ID == deviceID {
+				return device.PublicKey, nil
+			}
+		}
+	}
+	return nil, types.ErrNotFound
+}
+
+// isPrimaryDevice reports whether our device holds the user's public key. Only the first device
+// manages prekeys, as sessions are only used between users with a single device.
+func (u *User) isPrimaryDevice(ctx context.Context) (bool, error) {
+	publicUser, err := u.GetPublicUser(ctx, u.name)
+	if err != nil {
+		return false, fmt.Errorf("GetPublicUser: %w", err)
+	}
+	return cryptography.Fingerprint(publicUser.PublicKey) == u.deviceID, nil
+}
+
+// AddDevice signs the public key of a new device with our device's key, and adds it to our user.
+func (u *User) AddDevice(ctx context.Context, publicKeyBytes []byte) error {
+	publicKey, err := cryptography.UnmarshalPublicKey(publicKeyBytes)
+	if err != nil {
+		return fmt.Errorf("cryptography.UnmarshalPublicKey: %w", err)
+	}
+	// Re-encode the key, so that the signature covers the exact bytes the server stores
+	publicKeyBytes = cryptography.MarshalPublicKey(publicKey)
+	signature, err := cryptography.Sign(u.key, types.DeviceSignaturePayload(u.name, publicKeyBytes))
+	if err != nil {
+		return fmt.Errorf("cryptography.Sign: %w", err)
+	}
+	err = u.client.AddDevice(ctx, &openapi.Device{
+		Id:        cryptography.Fingerprint(publicKey),
+		PublicKey: publicKeyBytes,
+		SignedBy:  &u.deviceID,
+		Signature: &signature,
+	})
+	if err != nil {
+		return fmt.Errorf("client.AddDevice: %w", err)
+	}
+	u.invalidateDevices(u.name)
+	return nil
+}
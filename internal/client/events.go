@@ -29,6 +29,7 @@ type EventSetError struct {
 
 type EventSelectConversation struct {
 	conversation *Conversation
+	trust        *ContactTrust
 }
 
 type EventSwitchTab struct {
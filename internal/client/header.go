@@ -8,8 +8,10 @@ import (
 
 type Header struct {
 	*BaseComponent
-	mode            Mode
-	currentUsername *string
+	mode         Mode
+	currentUser  *User
+	conversation *Conversation
+	trust        *ContactTrust
 }
 
 func NewHeader(base *BaseComponent) *Header {
@@ -32,6 +34,13 @@ func (c *Header) OnEvent(event any) {
 	switch event := event.(type) {
 	case *EventSetMode:
 		c.mode = event.mode
+	case *EventSelectUser:
+		c.currentUser = event.user
+		c.conversation = nil
+		c.trust = nil
+	case *EventSelectConversation:
+		c.conversation = event.conversation
+		c.trust = event.trust
 	case *tcell.EventKey:
 		switch event.Key() {
 		case tcell.KeyEscape:
@@ -58,16 +67,45 @@ func (c *Header) Render() {
 	headerParts := []string{
 		"│ Mode: " + string(c.mode),
 	}
-	if c.currentUsername != nil {
-		headerParts = append(headerParts, "User: "+*c.currentUsername)
+	if c.currentUser != nil {
+		headerParts = append(headerParts, "User: "+c.currentUser.name)
+	}
+	if c.conversation != nil {
+		headerParts = append(headerParts, "Contact: "+c.conversation.dbConv.RemoteUserName)
+	}
+	if c.trust != nil {
+		headerParts = append(headerParts, "Safety number: "+c.trust.SafetyNumber)
 	}
 	c.PrintText(strings.Join(headerParts, " │ "))
+	if c.trust != nil {
+		switch {
+		case c.trust.KeyChanged:
+			c.PrintTextStyle(" │ KEY CHANGED, verify it", UnverifiedStyle)
+		case c.trust.VerifiedAt != nil:
+			c.PrintTextStyle(" │ Verified", tcell.StyleDefault.Foreground(tcell.ColorGreen))
+		default:
+			c.PrintText(" │ Unverified")
+		}
+	}
+	if c.conversation != nil && c.conversation.withoutSession.Load() {
+		// Messages to several devices are RSA envelopes, which the safety number alone does not tell
+		c.PrintTextStyle(" │ NOT FORWARD SECRET: several devices", UnverifiedStyle)
+	}
 
 	// Print the right part of the header
-	c.PrintTextRightAlign("│ [Q]uit ")
+	right := "│ [Q]uit "
+	if c.currentUser != nil && c.currentUser.connection != "" {
+		right = "│ " + connectionLabel(c.currentUser.connection) + " " + right
+	}
+	c.PrintTextRightAlign(right)
 
 	// Print the line separator
 	c.drawCursor.Newline()
 	c.PrintText(strings.Repeat("-", width))
 	c.drawCursor.Newline()
 }
+
+// connectionLabel capitalizes a connection state for the header.
+func connectionLabel(state ConnectionState) string {
+	return strings.ToUpper(string(state[:1])) + string(state[1:])
+}
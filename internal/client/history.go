@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/cryptography"
+)
+
+// MessageHistory encrypts the contents of the messages stored in the local database, with a key
+// derived from the keyring key. Encrypted messages are always decrypted when read, so that the
+// encryption can be turned on without migrating the messages stored before.
+type MessageHistory struct {
+	aesCipher *cryptography.AESCipher
+	encrypt   bool
+}
+
+func NewMessageHistory(keyring *Keyring, encrypt bool) (*MessageHistory, error) {
+	key, err := cryptography.DeriveSubkey(keyring.key, "talk message history")
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.DeriveSubkey: %w", err)
+	}
+	aesCipher, err := cryptography.NewAESCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.NewAESCipher: %w", err)
+	}
+	return &MessageHistory{
+		aesCipher: aesCipher,
+		encrypt:   encrypt,
+	}, nil
+}
+
+// insertMessage stores a message, its content encrypted if enabled, and returns it with its
+// content in plain text.
+func (h *MessageHistory) insertMessage(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	params sqlcgen.InsertMessageParams,
+) (*sqlcgen.Message, error) {
+	plaintext := params.Content
+	if h.encrypt {
+		var err error
+		params.Content, err = h.seal(params.ConversationID, plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("seal: %w", err)
+		}
+		params.Encrypted = true
+	}
+	dbMessage, err := queries.InsertMessage(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("queries.InsertMessage: %w", err)
+	}
+	dbMessage.Content = plaintext
+	return dbMessage, nil
+}
+
+// listMessages returns the messages of a conversation with their content in plain text.
+func (h *MessageHistory) listMessages(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	conversationID int64,
+) ([]*sqlcgen.Message, error) {
+	dbMessages, err := queries.ListMessages(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("queries.ListMessages: %w", err)
+	}
+	for _, dbMessage := range dbMessages {
+		if !dbMessage.Encrypted {
+			continue
+		}
+		dbMessage.Content, err = h.open(dbMessage.ConversationID, dbMessage.Content)
+		if err != nil {
+			return nil, fmt.Errorf("open message %d: %w", dbMessage.ID, err)
+		}
+	}
+	return dbMessages, nil
+}
+
+// EncryptStoredMessages encrypts the content of every message stored in plain text, and returns
+// how many were encrypted.
+func (h *MessageHistory) EncryptStoredMessages(ctx context.Context, queries *sqlcgen.Queries) (int, error) {
+	dbMessages, err := queries.ListUnencryptedMessages(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("queries.ListUnencryptedMessages: %w", err)
+	}
+	for _, dbMessage := range dbMessages {
+		content, err := h.seal(dbMessage.ConversationID, dbMessage.Content)
+		if err != nil {
+			return 0, fmt.Errorf("seal: %w", err)
+		}
+		err = queries.UpdateMessageContent(ctx, sqlcgen.UpdateMessageContentParams{
+			Content:   content,
+			Encrypted: true,
+			ID:        dbMessage.ID,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("queries.UpdateMessageContent: %w", err)
+		}
+	}
+	return len(dbMessages), nil
+}
+
+// The conversation is authenticated along with the content, so that messages cannot be moved
+// from one conversation to another.
+func (h *MessageHistory) seal(conversationID int64, plaintext []byte) ([]byte, error) {
+	return h.aesCipher.EncryptWithAD(plaintext, []byte(strconv.FormatInt(conversationID, 10)))
+}
+
+func (h *MessageHistory) open(conversationID int64, ciphertext []byte) ([]byte, error) {
+	return h.aesCipher.DecryptWithAD(ciphertext, []byte(strconv.FormatInt(conversationID, 10)))
+}
@@ -0,0 +1,381 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// identityBundleVersion is the version of the identity bundle file format.
+const identityBundleVersion = 1
+
+// identityBundleAD is authenticated along with the bundle, so that no other ciphertext
+// encrypted with the same passphrase can be passed as a bundle.
+var identityBundleAD = []byte("talk identity bundle")
+
+// identityBundleFile is the format of an exported identity file.
+type identityBundleFile struct {
+	Version    int                    `json:"version"`
+	KDF        cryptography.KDFParams `json:"kdf"`
+	Ciphertext []byte                 `json:"ciphertext"`
+}
+
+// identityBundle is what an identity bundle holds, encrypted with the bundle passphrase.
+// Conversation sessions and prekeys are part of the identity, as they are needed to read
+// the messages sent to it once moved.
+type identityBundle struct {
+	Name          openapi.Username     `json:"name"`
+	PrivateKey    []byte               `json:"private_key"`
+	Contacts      []bundleContact      `json:"contacts"`
+	Conversations []bundleConversation `json:"conversations"`
+	Prekeys       []bundlePrekey       `json:"prekeys"`
+}
+
+type bundleContact struct {
+	Name       openapi.Username `json:"name"`
+	PublicKey  []byte           `json:"public_key"`
+	VerifiedAt *time.Time       `json:"verified_at,omitempty"`
+}
+
+type bundleConversation struct {
+	RemoteUserName openapi.Username `json:"remote_user_name"`
+	Session        []byte           `json:"session,omitempty"`
+	// Messages are only exported along with the conversation history
+	Messages []bundleMessage `json:"messages,omitempty"`
+}
+
+type bundleMessage struct {
+	Sender   openapi.Username `json:"sender"`
+	Receiver openapi.Username `json:"receiver"`
+	// Kind is missing from bundles exported before file transfers, which only held text messages
+	Kind     string     `json:"kind,omitempty"`
+	Content  []byte     `json:"content"`
+	Verified bool       `json:"verified"`
+	ClientID string     `json:"client_id,omitempty"`
+	ServerID string     `json:"server_id,omitempty"`
+	SentAt   *time.Time `json:"sent_at,omitempty"`
+}
+
+type bundlePrekey struct {
+	KeyID      int64  `json:"key_id"`
+	OneTime    bool   `json:"one_time"`
+	PrivateKey []byte `json:"private_key"`
+}
+
+// ExportIdentity returns the identity of a local user, with its pinned contact keys and
+// optionally its conversation history, encrypted with a key derived from the passphrase.
+// The identity should not be used from both machines afterwards, as their sessions would diverge.
+func (c *Controller) ExportIdentity(
+	ctx context.Context,
+	username openapi.Username,
+	withHistory bool,
+	passphrase []byte,
+) ([]byte, error) {
+	user, err := c.GetUser(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("GetUser: %w", err)
+	}
+	bundle, err := user.exportIdentity(ctx, withHistory)
+	if err != nil {
+		return nil, fmt.Errorf("user.exportIdentity: %w", err)
+	}
+	plaintext, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	params, err := cryptography.NewKDFParams()
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.NewKDFParams: %w", err)
+	}
+	aesCipher, err := cryptography.NewAESCipher(cryptography.DeriveKey(passphrase, params))
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.NewAESCipher: %w", err)
+	}
+	ciphertext, err := aesCipher.EncryptWithAD(plaintext, identityBundleAD)
+	if err != nil {
+		return nil, fmt.Errorf("aesCipher.EncryptWithAD: %w", err)
+	}
+	bundleFile, err := json.Marshal(identityBundleFile{
+		Version:    identityBundleVersion,
+		KDF:        *params,
+		Ciphertext: ciphertext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	return bundleFile, nil
+}
+
+// ImportIdentity decrypts an identity bundle and adds it to the local database as a new local user.
+// Contacts already pinned are kept: if the bundle holds another key for one of them, that key must be
+// verified before sending to them.
+func (c *Controller) ImportIdentity(
+	ctx context.Context,
+	bundleFile []byte,
+	passphrase []byte,
+) (openapi.Username, error) {
+	var file identityBundleFile
+	err := json.Unmarshal(bundleFile, &file)
+	if err != nil {
+		return "", fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	if file.Version != identityBundleVersion {
+		return "", fmt.Errorf("unsupported identity bundle version %d", file.Version)
+	}
+	aesCipher, err := cryptography.NewAESCipher(cryptography.DeriveKey(passphrase, &file.KDF))
+	if err != nil {
+		return "", fmt.Errorf("cryptography.NewAESCipher: %w", err)
+	}
+	plaintext, err := aesCipher.DecryptWithAD(file.Ciphertext, identityBundleAD)
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+	var bundle identityBundle
+	err = json.Unmarshal(plaintext, &bundle)
+	if err != nil {
+		return "", fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	// Start transaction to import the whole identity or nothing
+	tx, err := c.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	txQueries := sqlcgen.New(c.db).WithTx(tx)
+
+	err = c.importIdentity(ctx, txQueries, &bundle)
+	if err != nil {
+		return "", fmt.Errorf("importIdentity: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", fmt.Errorf("tx.Commit: %w", err)
+	}
+	return bundle.Name, nil
+}
+
+func (u *User) exportIdentity(ctx context.Context, withHistory bool) (*identityBundle, error) {
+	queries := sqlcgen.New(u.db)
+	bundle := &identityBundle{
+		Name:       u.name,
+		PrivateKey: cryptography.MarshalPrivateKey(u.key),
+	}
+
+	contactNames := []openapi.Username{u.name}
+	dbConvs, err := queries.ListConversations(ctx, u.name)
+	if err != nil {
+		return nil, fmt.Errorf("queries.ListConversations: %w", err)
+	}
+	for _, dbConv := range dbConvs {
+		contactNames = append(contactNames, dbConv.RemoteUserName)
+		conversation := bundleConversation{
+			RemoteUserName: dbConv.RemoteUserName,
+		}
+		if dbConv.Session != nil {
+			conversation.Session, err = u.secrets.openSession(dbConv.ID, dbConv.Session)
+			if err != nil {
+				return nil, fmt.Errorf("secrets.openSession: %w", err)
+			}
+		}
+		if withHistory {
+			dbMessages, err := u.history.listMessages(ctx, queries, dbConv.ID)
+			if err != nil {
+				return nil, fmt.Errorf("history.listMessages: %w", err)
+			}
+			for _, dbMessage := range dbMessages {
+				message := bundleMessage{
+					Sender:   dbMessage.Sender,
+					Receiver: dbMessage.Receiver,
+					Kind:     dbMessage.Kind,
+					Content:  dbMessage.Content,
+					Verified: dbMessage.Verified,
+					ClientID: dbMessage.ClientID.String,
+					ServerID: dbMessage.ServerID.String,
+				}
+				if dbMessage.SentAt.Valid {
+					message.SentAt = &dbMessage.SentAt.Time
+				}
+				conversation.Messages = append(conversation.Messages, message)
+			}
+		}
+		bundle.Conversations = append(bundle.Conversations, conversation)
+	}
+
+	for _, name := range contactNames {
+		publicUser, err := queries.GetPublicUserByName(ctx, name)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return nil, fmt.Errorf("queries.GetPublicUserByName: %w", err)
+		}
+		contact := bundleContact{
+			Name:      publicUser.Name,
+			PublicKey: publicUser.PublicKey,
+		}
+		if publicUser.VerifiedAt.Valid {
+			contact.VerifiedAt = &publicUser.VerifiedAt.Time
+		}
+		bundle.Contacts = append(bundle.Contacts, contact)
+	}
+
+	dbPrekeys, err := queries.ListPrekeys(ctx, u.name)
+	if err != nil {
+		return nil, fmt.Errorf("queries.ListPrekeys: %w", err)
+	}
+	for _, dbPrekey := range dbPrekeys {
+		privateKey, err := u.secrets.openPrekey(dbPrekey)
+		if err != nil {
+			return nil, fmt.Errorf("secrets.openPrekey: %w", err)
+		}
+		bundle.Prekeys = append(bundle.Prekeys, bundlePrekey{
+			KeyID:      dbPrekey.KeyID,
+			OneTime:    dbPrekey.OneTime,
+			PrivateKey: privateKey,
+		})
+	}
+	return bundle, nil
+}
+
+func (c *Controller) importIdentity(ctx context.Context, queries *sqlcgen.Queries, bundle *identityBundle) error {
+	_, err := queries.GetLocalUserByName(ctx, bundle.Name)
+	if err == nil {
+		return fmt.Errorf("local user %q already exists", bundle.Name)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("queries.GetLocalUserByName: %w", err)
+	}
+
+	privateKey, err := cryptography.UnmarshalPrivateKey(bundle.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("cryptography.UnmarshalPrivateKey: %w", err)
+	}
+	privateKeyBytes, err := c.keyring.EncryptPrivateKey(bundle.Name, privateKey)
+	if err != nil {
+		return fmt.Errorf("keyring.EncryptPrivateKey: %w", err)
+	}
+	_, err = queries.InsertLocalUser(ctx, sqlcgen.InsertLocalUserParams{
+		Name:       bundle.Name,
+		PrivateKey: privateKeyBytes,
+	})
+	if err != nil {
+		return fmt.Errorf("queries.InsertLocalUser: %w", err)
+	}
+
+	for _, contact := range bundle.Contacts {
+		err = importContact(ctx, queries, contact)
+		if err != nil {
+			return fmt.Errorf("importContact: %w", err)
+		}
+	}
+
+	for _, conversation := range bundle.Conversations {
+		dbConv, err := queries.InsertConversation(ctx, sqlcgen.InsertConversationParams{
+			LocalUserName:  bundle.Name,
+			RemoteUserName: conversation.RemoteUserName,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.InsertConversation: %w", err)
+		}
+		if conversation.Session != nil {
+			session, err := c.secrets.sealSession(dbConv.ID, conversation.Session)
+			if err != nil {
+				return fmt.Errorf("secrets.sealSession: %w", err)
+			}
+			err = queries.UpdateConversationSession(ctx, sqlcgen.UpdateConversationSessionParams{
+				Session: session,
+				ID:      dbConv.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("queries.UpdateConversationSession: %w", err)
+			}
+		}
+		for _, message := range conversation.Messages {
+			var sentAt sql.NullTime
+			if message.SentAt != nil {
+				sentAt = sql.NullTime{Time: *message.SentAt, Valid: true}
+			}
+			kind := message.Kind
+			if kind == "" {
+				kind = string(openapi.MessageKindMessage)
+			}
+			_, err = c.history.insertMessage(ctx, queries, sqlcgen.InsertMessageParams{
+				ConversationID: dbConv.ID,
+				Sender:         message.Sender,
+				Receiver:       message.Receiver,
+				Kind:           kind,
+				Content:        message.Content,
+				Verified:       message.Verified,
+				ClientID:       sql.NullString{String: message.ClientID, Valid: message.ClientID != ""},
+				ServerID:       sql.NullString{String: message.ServerID, Valid: message.ServerID != ""},
+				SentAt:         sentAt,
+			})
+			if err != nil {
+				return fmt.Errorf("history.insertMessage: %w", err)
+			}
+		}
+	}
+
+	for _, prekey := range bundle.Prekeys {
+		privateKey, err := c.secrets.sealPrekey(bundle.Name, prekey.OneTime, prekey.KeyID, prekey.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("secrets.sealPrekey: %w", err)
+		}
+		err = queries.InsertPrekey(ctx, sqlcgen.InsertPrekeyParams{
+			LocalUserName: bundle.Name,
+			KeyID:         prekey.KeyID,
+			OneTime:       prekey.OneTime,
+			PrivateKey:    privateKey,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.InsertPrekey: %w", err)
+		}
+	}
+	return nil
+}
+
+// importContact pins the key of a contact we never saw. The key already pinned for a known contact
+// is kept, and a different key from the bundle must be verified.
+func importContact(ctx context.Context, queries *sqlcgen.Queries, contact bundleContact) error {
+	publicUser, err := queries.GetPublicUserByName(ctx, contact.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		verifiedAt := sql.NullTime{}
+		if contact.VerifiedAt != nil {
+			verifiedAt = sql.NullTime{Time: *contact.VerifiedAt, Valid: true}
+		}
+		err = queries.ImportPublicUser(ctx, sqlcgen.ImportPublicUserParams{
+			Name:       contact.Name,
+			PublicKey:  contact.PublicKey,
+			VerifiedAt: verifiedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.ImportPublicUser: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("queries.GetPublicUserByName: %w", err)
+	}
+	if bytes.Equal(publicUser.PublicKey, contact.PublicKey) {
+		return nil
+	}
+	err = queries.SetPublicUserPendingKey(ctx, sqlcgen.SetPublicUserPendingKeyParams{
+		PendingPublicKey: contact.PublicKey,
+		Name:             contact.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("queries.SetPublicUserPendingKey: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"golang.org/x/term"
+
+	"github.com/marc921/talk/internal/client/database"
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// keyringVerifier is encrypted with the key derived from the passphrase, to detect a wrong passphrase
+// even when there is no private key to decrypt yet.
+var keyringVerifier = []byte("talk keyring")
+
+// Keyring encrypts and decrypts the private keys of local users with a key derived from a passphrase.
+type Keyring struct {
+	db  *sql.DB
+	key []byte
+}
+
+// OpenDatabase opens the local database, creating it if the user agrees, and unlocks its keyring.
+// The database is closed if the keyring cannot be unlocked, e.g. with ErrWrongPassphrase, so that
+// nothing is read from or written to it with the wrong key.
+func OpenDatabase(ctx context.Context, config *Config) (*sql.DB, *Keyring, error) {
+	db, err := database.GetOrCreateSQLite3DB(path.Join(config.HomeDir, "database.sqlite3"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("database.GetOrCreateSQLite3DB: %w", err)
+	}
+	keyring, err := UnlockKeyring(ctx, config, db)
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("UnlockKeyring: %w", err)
+	}
+	return db, keyring, nil
+}
+
+// UnlockKeyring gets the key from the agent if it is running, otherwise prompts for the passphrase.
+// A new passphrase is asked for on first use. Private keys stored in plain text by previous versions
+// are encrypted once the keyring is unlocked, as are the sessions, prekeys and transfer keys.
+func UnlockKeyring(ctx context.Context, config *Config, db *sql.DB) (*Keyring, error) {
+	key, err := requestAgentKey(config)
+	if err != nil {
+		return nil, fmt.Errorf("requestAgentKey: %w", err)
+	}
+	if key == nil {
+		key, err = promptKeyringKey(ctx, db)
+		if err != nil {
+			return nil, fmt.Errorf("promptKeyringKey: %w", err)
+		}
+	}
+	keyring := &Keyring{
+		db:  db,
+		key: key,
+	}
+	err = keyring.verify(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("keyring.verify: %w", err)
+	}
+	err = keyring.encryptPlaintextKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("keyring.encryptPlaintextKeys: %w", err)
+	}
+	err = keyring.encryptPlaintextSecrets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("keyring.encryptPlaintextSecrets: %w", err)
+	}
+	return keyring, nil
+}
+
+// PrivateKey decrypts the private key of a local user.
+func (k *Keyring) PrivateKey(localUser *sqlcgen.LocalUser) (*rsa.PrivateKey, error) {
+	privateKey, err := cryptography.DecryptPrivateKey(k.key, localUser.PrivateKey, localUser.Name)
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.DecryptPrivateKey: %w", err)
+	}
+	return privateKey, nil
+}
+
+// PendingPrivateKey decrypts the key a local user is rotating to, or returns nil if there is none.
+func (k *Keyring) PendingPrivateKey(localUser *sqlcgen.LocalUser) (*rsa.PrivateKey, error) {
+	if localUser.PendingPrivateKey == nil {
+		return nil, nil
+	}
+	privateKey, err := cryptography.DecryptPrivateKey(k.key, localUser.PendingPrivateKey, localUser.Name)
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.DecryptPrivateKey: %w", err)
+	}
+	return privateKey, nil
+}
+
+// EncryptPrivateKey encrypts the private key of a local user, to be stored in the database.
+func (k *Keyring) EncryptPrivateKey(name openapi.Username, privateKey *rsa.PrivateKey) ([]byte, error) {
+	privateKeyBytes, err := cryptography.EncryptPrivateKey(k.key, privateKey, name)
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.EncryptPrivateKey: %w", err)
+	}
+	return privateKeyBytes, nil
+}
+
+func (k *Keyring) verify(ctx context.Context) error {
+	keyEncryption, err := sqlcgen.New(k.db).GetKeyEncryption(ctx)
+	if err != nil {
+		return fmt.Errorf("queries.GetKeyEncryption: %w", err)
+	}
+	aesCipher, err := cryptography.NewAESCipher(k.key)
+	if err != nil {
+		return fmt.Errorf("cryptography.NewAESCipher: %w", err)
+	}
+	verifier, err := aesCipher.Decrypt(keyEncryption.Verifier)
+	if err != nil || !bytes.Equal(verifier, keyringVerifier) {
+		return ErrWrongPassphrase
+	}
+	return nil
+}
+
+func (k *Keyring) encryptPlaintextKeys(ctx context.Context) error {
+	queries := sqlcgen.New(k.db)
+	localUsers, err := queries.ListLocalUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("queries.ListLocalUsers: %w", err)
+	}
+	for _, localUser := range localUsers {
+		if cryptography.IsEncryptedPrivateKey(localUser.PrivateKey) {
+			continue
+		}
+		privateKey, err := cryptography.UnmarshalPrivateKey(localUser.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("cryptography.UnmarshalPrivateKey: %w", err)
+		}
+		privateKeyBytes, err := k.EncryptPrivateKey(localUser.Name, privateKey)
+		if err != nil {
+			return fmt.Errorf("EncryptPrivateKey: %w", err)
+		}
+		err = queries.UpdateLocalUserPrivateKey(ctx, sqlcgen.UpdateLocalUserPrivateKeyParams{
+			PrivateKey: privateKeyBytes,
+			Name:       localUser.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.UpdateLocalUserPrivateKey: %w", err)
+		}
+	}
+	return nil
+}
+
+// encryptPlaintextSecrets encrypts, once, the secrets stored in plain text before LocalSecrets.
+func (k *Keyring) encryptPlaintextSecrets(ctx context.Context) error {
+	keyEncryption, err := sqlcgen.New(k.db).GetKeyEncryption(ctx)
+	if err != nil {
+		return fmt.Errorf("queries.GetKeyEncryption: %w", err)
+	}
+	if keyEncryption.SecretsEncrypted {
+		return nil
+	}
+	secrets, err := NewLocalSecrets(k)
+	if err != nil {
+		return fmt.Errorf("NewLocalSecrets: %w", err)
+	}
+	tx, err := k.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("db.BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+	txQueries := sqlcgen.New(k.db).WithTx(tx)
+	err = secrets.encryptStored(ctx, txQueries)
+	if err != nil {
+		return fmt.Errorf("secrets.encryptStored: %w", err)
+	}
+	err = txQueries.SetSecretsEncrypted(ctx)
+	if err != nil {
+		return fmt.Errorf("queries.SetSecretsEncrypted: %w", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+	// The plain text secrets must not remain in the free pages of the database file
+	err = database.Vacuum(ctx, k.db)
+	if err != nil {
+		return fmt.Errorf("database.Vacuum: %w", err)
+	}
+	return nil
+}
+
+// promptKeyringKey prompts for the passphrase and derives the keyring key from it.
+// On first use, the passphrase is chosen and the key derivation parameters are stored.
+func promptKeyringKey(ctx context.Context, db *sql.DB) ([]byte, error) {
+	queries := sqlcgen.New(db)
+	keyEncryption, err := queries.GetKeyEncryption(ctx)
+	if err == nil {
+		passphrase, err := readPassphrase("Passphrase: ")
+		if err != nil {
+			return nil, fmt.Errorf("readPassphrase: %w", err)
+		}
+		return cryptography.DeriveKey(passphrase, &cryptography.KDFParams{
+			Salt:    keyEncryption.Salt,
+			Time:    uint32(keyEncryption.Time),
+			Memory:  uint32(keyEncryption.Memory),
+			Threads: uint8(keyEncryption.Threads),
+		}), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("queries.GetKeyEncryption: %w", err)
+	}
+
+	passphrase, err := readPassphrase("Choose a passphrase to encrypt your private keys: ")
+	if err != nil {
+		return nil, fmt.Errorf("readPassphrase: %w", err)
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase cannot be empty")
+	}
+	confirmation, err := readPassphrase("Confirm passphrase: ")
+	if err != nil {
+		return nil, fmt.Errorf("readPassphrase: %w", err)
+	}
+	if !bytes.Equal(passphrase, confirmation) {
+		return nil, errors.New("passphrases do not match")
+	}
+
+	params, err := cryptography.NewKDFParams()
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.NewKDFParams: %w", err)
+	}
+	key := cryptography.DeriveKey(passphrase, params)
+	aesCipher, err := cryptography.NewAESCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.NewAESCipher: %w", err)
+	}
+	verifier, err := aesCipher.Encrypt(keyringVerifier)
+	if err != nil {
+		return nil, fmt.Errorf("aesCipher.Encrypt: %w", err)
+	}
+	err = queries.InsertKeyEncryption(ctx, sqlcgen.InsertKeyEncryptionParams{
+		Salt:     params.Salt,
+		Time:     int64(params.Time),
+		Memory:   int64(params.Memory),
+		Threads:  int64(params.Threads),
+		Verifier: verifier,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("queries.InsertKeyEncryption: %w", err)
+	}
+	return key, nil
+}
+
+func readPassphrase(prompt string) ([]byte, error) {
+	fmt.Print(prompt)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return nil, fmt.Errorf("term.ReadPassword: %w", err)
+	}
+	return passphrase, nil
+}
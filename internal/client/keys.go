@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// RotateKey replaces our user's key with a new one, signed by the current key.
+// Pending messages are fetched first, as they are encrypted for the current key.
+// Only the device holding the user's key can rotate it; the user's other devices are revoked
+// by the server and must be linked again.
+// The new key is stored as pending before being sent to the server, and replaces the current key
+// once the server switched to it. A rotation interrupted in between is resumed on the next call.
+func (u *User) RotateKey(ctx context.Context, keyring *Keyring) error {
+	queries := sqlcgen.New(u.db)
+	localUser, err := queries.GetLocalUserByName(ctx, u.name)
+	if err != nil {
+		return fmt.Errorf("queries.GetLocalUserByName: %w", err)
+	}
+	newKey, err := keyring.PendingPrivateKey(localUser)
+	if err != nil {
+		return fmt.Errorf("keyring.PendingPrivateKey: %w", err)
+	}
+	if newKey != nil {
+		// The server may have switched to the pending key before the rotation was interrupted
+		serverUser, err := u.client.GetPublicUser(ctx, u.name)
+		if err != nil {
+			return fmt.Errorf("client.GetPublicUser: %w", err)
+		}
+		if serverUser.PublicKey.Equal(&newKey.PublicKey) {
+			return u.applyRotatedKey(ctx, newKey)
+		}
+	}
+
+	primary, err := u.isPrimaryDevice(ctx)
+	if err != nil {
+		return fmt.Errorf("isPrimaryDevice: %w", err)
+	}
+	if !primary {
+		return errors.New("only the user's first device can rotate its key")
+	}
+	_, err = u.FetchMessages(ctx)
+	if err != nil {
+		return fmt.Errorf("FetchMessages: %w", err)
+	}
+
+	if newKey == nil {
+		newKey, err = cryptography.GenerateKey()
+		if err != nil {
+			return fmt.Errorf("cryptography.GenerateKey: %w", err)
+		}
+		newKeyBytes, err := keyring.EncryptPrivateKey(u.name, newKey)
+		if err != nil {
+			return fmt.Errorf("keyring.EncryptPrivateKey: %w", err)
+		}
+		// Stored before the server switches to it, for the key not to be lost if storing fails
+		err = queries.SetLocalUserPendingKey(ctx, sqlcgen.SetLocalUserPendingKeyParams{
+			PendingPrivateKey: newKeyBytes,
+			Name:              u.name,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.SetLocalUserPendingKey: %w", err)
+		}
+	}
+	newPublicKey := cryptography.MarshalPublicKey(&newKey.PublicKey)
+	signature, err := cryptography.Sign(u.key, types.KeyRotationPayload(u.name, newPublicKey))
+	if err != nil {
+		return fmt.Errorf("cryptography.Sign: %w", err)
+	}
+
+	err = u.client.RotateKey(ctx, &openapi.KeyRotation{
+		OldPublicKey: cryptography.MarshalPublicKey(&u.key.PublicKey),
+		PublicKey:    newPublicKey,
+		Signature:    signature,
+	})
+	if err != nil {
+		return fmt.Errorf("client.RotateKey: %w", err)
+	}
+	return u.applyRotatedKey(ctx, newKey)
+}
+
+// applyRotatedKey replaces our user's key with the pending key, once the server switched to it.
+func (u *User) applyRotatedKey(ctx context.Context, newKey *rsa.PrivateKey) error {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("db.BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+	txQueries := sqlcgen.New(u.db).WithTx(tx)
+
+	err = txQueries.ApplyLocalUserPendingKey(ctx, u.name)
+	if err != nil {
+		return fmt.Errorf("txQueries.ApplyLocalUserPendingKey: %w", err)
+	}
+	err = txQueries.UpdatePublicUserKey(ctx, sqlcgen.UpdatePublicUserKeyParams{
+		PublicKey: cryptography.MarshalPublicKey(&newKey.PublicKey),
+		Name:      u.name,
+	})
+	if err != nil {
+		return fmt.Errorf("txQueries.UpdatePublicUserKey: %w", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+
+	u.key = newKey
+	u.deviceID = cryptography.Fingerprint(&newKey.PublicKey)
+	u.invalidateDevices(u.name)
+	// The previous tokens were issued to the revoked device
+	u.resetAuth()
+
+	// The signed prekey must be signed by the new key
+	_, err = u.UploadPrekeys(ctx, true)
+	if err != nil {
+		return fmt.Errorf("UploadPrekeys: %w", err)
+	}
+	return nil
+}
+
+// verifySignature checks the signature of a message against the key of the sender's device.
+// If it does not match, the sender may have rotated their key since we pinned it: the pinned key
+// is refreshed and the signature checked again.
+func (u *User) verifySignature(
+	ctx context.Context,
+	message *openapi.Message,
+	verify func(*rsa.PublicKey, []byte, []byte) error,
+) (bool, error) {
+	verified, err := u.verifyWithPinnedKey(ctx, message, verify)
+	if err != nil || verified {
+		return verified, err
+	}
+	refreshed, err := u.refreshPublicUser(ctx, message.Sender)
+	if err != nil {
+		return false, fmt.Errorf("refreshPublicUser: %w", err)
+	}
+	if !refreshed {
+		return false, nil
+	}
+	return u.verifyWithPinnedKey(ctx, message, verify)
+}
+
+func (u *User) verifyWithPinnedKey(
+	ctx context.Context,
+	message *openapi.Message,
+	verify func(*rsa.PublicKey, []byte, []byte) error,
+) (bool, error) {
+	var senderKey *rsa.PublicKey
+	if message.SenderDevice != nil {
+		var err error
+		senderKey, err = u.deviceKey(ctx, message.Sender, *message.SenderDevice)
+		if err != nil {
+			// Devices of a user whose key changed are not trusted until the new key is approved
+			if errors.Is(err, types.ErrNotFound) || errors.Is(err, ErrKeyChanged) {
+				return false, nil
+			}
+			return false, fmt.Errorf("deviceKey: %w", err)
+		}
+	} else {
+		// Envelopes from clients without device support are signed with the user's key
+		sender, err := u.GetPublicUser(ctx, message.Sender)
+		if err != nil {
+			return false, fmt.Errorf("GetPublicUser: %w", err)
+		}
+		senderKey = sender.PublicKey
+	}
+	return verify(senderKey, signaturePayload(message), *message.Signature) == nil, nil
+}
+
+// refreshPublicUser follows the key rotations of a user from the key pinned in our database,
+// and pins the last key reached through valid rotation signatures.
+// It reports whether the pinned key changed.
+func (u *User) refreshPublicUser(ctx context.Context, name openapi.Username) (bool, error) {
+	publicUser, err := u.GetPublicUser(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("GetPublicUser: %w", err)
+	}
+	rotations, err := u.client.GetKeyRotations(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("client.GetKeyRotations: %w", err)
+	}
+
+	current := publicUser.PublicKey
+	for _, rotation := range rotations {
+		oldKey, err := cryptography.UnmarshalPublicKey(rotation.OldPublicKey)
+		if err != nil || !oldKey.Equal(current) {
+			continue
+		}
+		newKey, err := cryptography.UnmarshalPublicKey(rotation.PublicKey)
+		if err != nil {
+			continue
+		}
+		err = cryptography.Verify(current, types.KeyRotationPayload(name, rotation.PublicKey), rotation.Signature)
+		if err != nil {
+			continue
+		}
+		current = newKey
+	}
+	if current.Equal(publicUser.PublicKey) {
+		return false, nil
+	}
+
+	err = sqlcgen.New(u.db).UpdatePublicUserKey(ctx, sqlcgen.UpdatePublicUserKeyParams{
+		PublicKey: cryptography.MarshalPublicKey(current),
+		Name:      name,
+	})
+	if err != nil {
+		return false, fmt.Errorf("queries.UpdatePublicUserKey: %w", err)
+	}
+	u.invalidateDevices(name)
+	return true, nil
+}
@@ -1,9 +1,19 @@
 package client
 
 import (
+	"encoding/json"
+	"unicode/utf8"
+
 	"github.com/gdamore/tcell/v2"
+
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
 )
 
+// UnverifiedStyle highlights messages whose signature could not be verified.
+var UnverifiedStyle = tcell.StyleDefault.Bold(true).Foreground(tcell.ColorOrange)
+
 type MessagesTab struct {
 	*BaseComponent
 	localUser        *User
@@ -43,6 +53,14 @@ func (c *MessagesTab) OnEvent(event any) {
 		c.conversation = nil
 	case *EventSelectConversation:
 		c.conversation = event.conversation
+	case *EventUpdateUser:
+		// New messages of the displayed conversation are read
+		if event.user == c.localUser && c.conversation != nil {
+			UISingleton.actions <- &ActionReadConversation{
+				localUser:    c.localUser,
+				conversation: c.conversation,
+			}
+		}
 	case *EventFocus:
 		c.hasFocus = true
 	case *tcell.EventKey:
@@ -52,6 +70,7 @@ func (c *MessagesTab) OnEvent(event any) {
 		switch event.Key() {
 		case tcell.KeyEnter:
 			if c.mode == ModeInsert {
+				c.sendTyping(false)
 				UISingleton.actions <- &ActionSendMessage{
 					localUser:      c.localUser,
 					remoteUsername: c.conversation.dbConv.RemoteUserName,
@@ -62,22 +81,41 @@ func (c *MessagesTab) OnEvent(event any) {
 		case tcell.KeyBackspace, tcell.KeyBackspace2:
 			if c.mode == ModeInsert && len(c.newMessageBuffer) > 0 {
 				c.newMessageBuffer = c.newMessageBuffer[:len(c.newMessageBuffer)-1]
+				c.sendTyping(c.newMessageBuffer != "")
 			}
 		case tcell.KeyRune:
 			if c.mode == ModeInsert {
 				c.newMessageBuffer += string(event.Rune())
+				c.sendTyping(true)
 			}
 		}
 	}
 }
 
+// sendTyping tells the remote user whether the message being written is still being edited.
+func (c *MessagesTab) sendTyping(typing bool) {
+	if c.localUser == nil || c.conversation == nil {
+		return
+	}
+	UISingleton.actions <- &ActionSendTyping{
+		localUser:    c.localUser,
+		conversation: c.conversation,
+		typing:       typing,
+	}
+}
+
 func (c *MessagesTab) Render() {
 	if c.localUser == nil || c.conversation == nil {
 		return
 	}
 	c.drawCursor.Reset()
-	// Scroll effect (2 lines for "Messages" and " + New")
-	c.drawCursor.Y += c.bounds.Height - len(c.conversation.messages) - 2
+	// Scroll effect (2 lines for "Messages" and " + New", and 1 when the remote user is typing)
+	typing := c.conversation.remoteTyping()
+	lines := len(c.conversation.messages) + 2
+	if typing {
+		lines++
+	}
+	c.drawCursor.Y += c.bounds.Height - lines
 	style := tcell.StyleDefault.Bold(true).Underline(true)
 	if c.hasFocus {
 		style = style.Foreground(tcell.ColorDeepSkyBlue)
@@ -87,12 +125,19 @@ func (c *MessagesTab) Render() {
 	c.drawCursor.Newline()
 	for _, message := range c.conversation.messages {
 		if message.Sender == c.localUser.name {
-			c.PrintTextRightAlign(string(message.Content))
+			c.PrintTextRightAlign(messageText(message) + receiptMarker(message))
 		} else {
-			c.PrintText(string(message.Content))
+			if !message.Verified {
+				c.PrintTextStyle("[unverified] ", UnverifiedStyle)
+			}
+			c.PrintText(messageText(message))
 		}
 		c.drawCursor.Newline()
 	}
+	if typing {
+		c.PrintTextStyle(c.conversation.dbConv.RemoteUserName+" is typing…", tcell.StyleDefault.Italic(true).Dim(true))
+		c.drawCursor.Newline()
+	}
 	if c.hasFocus {
 		style = tcell.StyleDefault.Italic(true)
 		c.PrintTextStyle(" + New", style)
@@ -103,3 +148,33 @@ func (c *MessagesTab) Render() {
 		c.drawCursor.Newline()
 	}
 }
+
+// messageText returns the text a message is displayed with, files being shown by their name
+// and size rather than their manifest.
+func messageText(message *sqlcgen.Message) string {
+	if message.Kind != string(openapi.MessageKindFile) {
+		// Files were sent as text messages before transfers
+		if !utf8.Valid(message.Content) {
+			return "[binary content]"
+		}
+		return string(message.Content)
+	}
+	var manifest types.FileManifest
+	err := json.Unmarshal(message.Content, &manifest)
+	if err != nil {
+		return "[invalid file]"
+	}
+	return attachmentLabel(&manifest)
+}
+
+// receiptMarker returns ✓ for our messages delivered to the remote user, and ✓✓ for those they read.
+func receiptMarker(message *sqlcgen.Message) string {
+	switch {
+	case message.ReadAt.Valid:
+		return " ✓✓"
+	case message.DeliveredAt.Valid:
+		return " ✓"
+	default:
+		return ""
+	}
+}
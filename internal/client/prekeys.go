@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// OneTimePrekeyBatchSize is the number of one-time prekeys uploaded at once.
+const OneTimePrekeyBatchSize = 50
+
+// lowOneTimePrekeys is the number of one-time prekeys stored locally under which the server is asked
+// whether to upload more. Prekeys claimed from the server stay stored until a session is started
+// from them, so it is above the server's own threshold.
+const lowOneTimePrekeys = OneTimePrekeyBatchSize / 2
+
+// UploadPrekeys generates and uploads a batch of one-time prekeys, along with a new signed prekey if
+// withSignedPrekey is set. Private keys are stored before uploading, so that any session started from
+// an uploaded prekey can be answered.
+func (u *User) UploadPrekeys(ctx context.Context, withSignedPrekey bool) (*openapi.PrekeyStatus, error) {
+	queries := sqlcgen.New(u.db)
+	upload := &openapi.PrekeyUpload{}
+	if withSignedPrekey {
+		prekey, err := u.generatePrekey(ctx, queries, false)
+		if err != nil {
+			return nil, fmt.Errorf("generatePrekey: %w", err)
+		}
+		signature, err := cryptography.Sign(u.key, prekey.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("cryptography.Sign: %w", err)
+		}
+		prekey.Signature = &signature
+		upload.SignedPrekey = prekey
+	}
+	oneTimePrekeys := make([]openapi.Prekey, 0, OneTimePrekeyBatchSize)
+	for range OneTimePrekeyBatchSize {
+		prekey, err := u.generatePrekey(ctx, queries, true)
+		if err != nil {
+			return nil, fmt.Errorf("generatePrekey: %w", err)
+		}
+		oneTimePrekeys = append(oneTimePrekeys, *prekey)
+	}
+	upload.OneTimePrekeys = &oneTimePrekeys
+
+	var status *openapi.PrekeyStatus
+	err := u.withAuth(ctx, func(token string) error {
+		var err error
+		status, err = u.client.UploadPrekeys(ctx, token, upload)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("client.UploadPrekeys: %w", err)
+	}
+	return status, nil
+}
+
+// RefillPrekeys uploads more one-time prekeys if the server reports the pool is running low,
+// and a signed prekey if the server has none. The server is only asked once sessions were started
+// from enough of our prekeys. Only the user's first device manages prekeys.
+func (u *User) RefillPrekeys(ctx context.Context) error {
+	count, err := sqlcgen.New(u.db).CountOneTimePrekeys(ctx, u.name)
+	if err != nil {
+		return fmt.Errorf("queries.CountOneTimePrekeys: %w", err)
+	}
+	if count >= lowOneTimePrekeys {
+		return nil
+	}
+	primary, err := u.isPrimaryDevice(ctx)
+	if err != nil {
+		return fmt.Errorf("isPrimaryDevice: %w", err)
+	}
+	if !primary {
+		return nil
+	}
+	var status *openapi.PrekeyStatus
+	err = u.withAuth(ctx, func(token string) error {
+		var err error
+		status, err = u.client.GetPrekeyStatus(ctx, token)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("client.GetPrekeyStatus: %w", err)
+	}
+	if !status.Low && status.SignedPrekeyId != nil {
+		return nil
+	}
+	_, err = u.UploadPrekeys(ctx, status.SignedPrekeyId == nil)
+	if err != nil {
+		return fmt.Errorf("UploadPrekeys: %w", err)
+	}
+	return nil
+}
+
+// generatePrekey generates a prekey and stores its private key, encrypted.
+func (u *User) generatePrekey(ctx context.Context, queries *sqlcgen.Queries, oneTime bool) (*openapi.Prekey, error) {
+	privateKey, err := cryptography.GenerateX25519Key()
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.GenerateX25519Key: %w", err)
+	}
+	id, err := newPrekeyID()
+	if err != nil {
+		return nil, fmt.Errorf("newPrekeyID: %w", err)
+	}
+	privateKeyBytes, err := u.secrets.sealPrekey(u.name, oneTime, int64(id), privateKey.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("secrets.sealPrekey: %w", err)
+	}
+	err = queries.InsertPrekey(ctx, sqlcgen.InsertPrekeyParams{
+		LocalUserName: u.name,
+		KeyID:         int64(id),
+		OneTime:       oneTime,
+		PrivateKey:    privateKeyBytes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("queries.InsertPrekey: %w", err)
+	}
+	return &openapi.Prekey{
+		Id:        id,
+		PublicKey: privateKey.PublicKey().Bytes(),
+	}, nil
+}
+
+// prekeyResponderRatchet starts a ratchet for a session the remote user started from our prekeys.
+func (u *User) prekeyResponderRatchet(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	header *openapi.SessionHeader,
+) (*cryptography.Ratchet, error) {
+	if header.SignedPrekeyId == nil {
+		return nil, errors.New("missing signed prekey id")
+	}
+	signedPrekey, err := queries.GetPrekey(ctx, sqlcgen.GetPrekeyParams{
+		LocalUserName: u.name,
+		OneTime:       false,
+		KeyID:         int64(*header.SignedPrekeyId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("queries.GetPrekey: %w", err)
+	}
+	signedPrekeyBytes, err := u.secrets.openPrekey(signedPrekey)
+	if err != nil {
+		return nil, fmt.Errorf("secrets.openPrekey: %w", err)
+	}
+	var oneTimePrekey []byte
+	if header.OneTimePrekeyId != nil {
+		dbPrekey, err := queries.GetPrekey(ctx, sqlcgen.GetPrekeyParams{
+			LocalUserName: u.name,
+			OneTime:       true,
+			KeyID:         int64(*header.OneTimePrekeyId),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.GetPrekey: %w", err)
+		}
+		oneTimePrekey, err = u.secrets.openPrekey(dbPrekey)
+		if err != nil {
+			return nil, fmt.Errorf("secrets.openPrekey: %w", err)
+		}
+	}
+	sharedSecret, err := cryptography.RespondPrekeySecret(signedPrekeyBytes, oneTimePrekey, *header.EphemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.RespondPrekeySecret: %w", err)
+	}
+	return cryptography.NewResponderRatchet(sharedSecret, signedPrekeyBytes), nil
+}
+
+// deleteOneTimePrekey forgets a one-time prekey once a session was started from it.
+func (u *User) deleteOneTimePrekey(ctx context.Context, queries *sqlcgen.Queries, id int) error {
+	err := queries.DeletePrekey(ctx, sqlcgen.DeletePrekeyParams{
+		LocalUserName: u.name,
+		OneTime:       true,
+		KeyID:         int64(id),
+	})
+	if err != nil {
+		return fmt.Errorf("queries.DeletePrekey: %w", err)
+	}
+	return nil
+}
+
+// newPrekeyID returns a random positive 31-bit prekey ID.
+func newPrekeyID() (int, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return 0, fmt.Errorf("rand.Read: %w", err)
+	}
+	return int(binary.BigEndian.Uint32(b) >> 1), nil
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/marc921/talk/internal/client/database"
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// newTestPrekeyBundle generates the prekeys of a user as UploadPrekeys does, and returns them as claimed.
+func newTestPrekeyBundle(t *testing.T, user *User, withOneTime bool) *openapi.PrekeyBundle {
+	t.Helper()
+	ctx := context.Background()
+	queries := sqlcgen.New(user.db)
+	signedPrekey, err := user.generatePrekey(ctx, queries, false)
+	if err != nil {
+		t.Fatalf("generatePrekey: %v", err)
+	}
+	signature, err := cryptography.Sign(user.key, signedPrekey.PublicKey)
+	if err != nil {
+		t.Fatalf("cryptography.Sign: %v", err)
+	}
+	signedPrekey.Signature = &signature
+	bundle := &openapi.PrekeyBundle{SignedPrekey: *signedPrekey}
+	if withOneTime {
+		bundle.OneTimePrekey, err = user.generatePrekey(ctx, queries, true)
+		if err != nil {
+			t.Fatalf("generatePrekey: %v", err)
+		}
+	}
+	return bundle
+}
+
+func TestPrekeySession(t *testing.T) {
+	for _, withOneTime := range []bool{false, true} {
+		name := "signed prekey only"
+		if withOneTime {
+			name = "with one-time prekey"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			db, err := database.CreateSQLite3DB(filepath.Join(t.TempDir(), "talk.db"))
+			if err != nil {
+				t.Fatalf("database.CreateSQLite3DB: %v", err)
+			}
+			defer db.Close()
+			bob := newTestUser(t, db, "bob")
+			bundle := newTestPrekeyBundle(t, bob, withOneTime)
+
+			session := new(Session)
+			err = session.startFromPrekeys(&types.PublicUser{Name: bob.name, PublicKey: &bob.key.PublicKey}, bundle)
+			if err != nil {
+				t.Fatalf("startFromPrekeys: %v", err)
+			}
+			message := &openapi.Message{}
+			err = session.encrypt([]byte("hello"), message)
+			if err != nil {
+				t.Fatalf("session.encrypt: %v", err)
+			}
+			if withOneTime != (message.Session.OneTimePrekeyId != nil) {
+				t.Fatalf("one-time prekey ID sent: %t, want %t", message.Session.OneTimePrekeyId != nil, withOneTime)
+			}
+
+			// Bob derives the same root from his stored prekeys, and decrypts the first message
+			ratchet, err := bob.prekeyResponderRatchet(ctx, sqlcgen.New(db), message.Session)
+			if err != nil {
+				t.Fatalf("prekeyResponderRatchet: %v", err)
+			}
+			plaintext, err := ratchet.Decrypt(&cryptography.RatchetHeader{
+				PublicKey:           *message.Session.RatchetKey,
+				PreviousChainLength: uint32(*message.Session.PreviousChainLength),
+				MessageNumber:       uint32(*message.Session.MessageNumber),
+			}, message.Ciphertext)
+			if err != nil {
+				t.Fatalf("ratchet.Decrypt: %v", err)
+			}
+			if !bytes.Equal(plaintext, []byte("hello")) {
+				t.Errorf("decrypted %q, want %q", plaintext, "hello")
+			}
+		})
+	}
+}
+
+func TestPrekeySignature(t *testing.T) {
+	db, err := database.CreateSQLite3DB(filepath.Join(t.TempDir(), "talk.db"))
+	if err != nil {
+		t.Fatalf("database.CreateSQLite3DB: %v", err)
+	}
+	defer db.Close()
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+	bobPublic := &types.PublicUser{Name: bob.name, PublicKey: &bob.key.PublicKey}
+
+	tests := []struct {
+		name   string
+		tamper func(bundle *openapi.PrekeyBundle)
+	}{
+		{
+			name: "unsigned",
+			tamper: func(bundle *openapi.PrekeyBundle) {
+				bundle.SignedPrekey.Signature = nil
+			},
+		},
+		{
+			name: "signed by another user",
+			tamper: func(bundle *openapi.PrekeyBundle) {
+				signature, err := cryptography.Sign(alice.key, bundle.SignedPrekey.PublicKey)
+				if err != nil {
+					t.Fatalf("cryptography.Sign: %v", err)
+				}
+				bundle.SignedPrekey.Signature = &signature
+			},
+		},
+		{
+			name: "substituted prekey",
+			tamper: func(bundle *openapi.PrekeyBundle) {
+				key, err := cryptography.GenerateX25519Key()
+				if err != nil {
+					t.Fatalf("cryptography.GenerateX25519Key: %v", err)
+				}
+				bundle.SignedPrekey.PublicKey = key.PublicKey().Bytes()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle := newTestPrekeyBundle(t, bob, true)
+			tt.tamper(bundle)
+			session := new(Session)
+			err := session.startFromPrekeys(bobPublic, bundle)
+			if err == nil {
+				t.Fatal("startFromPrekeys accepted a bundle without a valid signature")
+			}
+			if session.Ratchet != nil || session.PrekeyInit != nil {
+				t.Error("startFromPrekeys started a session from a bundle without a valid signature")
+			}
+		})
+	}
+}
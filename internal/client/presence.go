@@ -0,0 +1,70 @@
+package client
+
+import (
+	"time"
+
+	"github.com/marc921/talk/internal/types"
+)
+
+const (
+	// Typing events are sent again at this interval while the local user keeps typing.
+	typingInterval = 3 * time.Second
+	// Remote users are shown typing until they stop, or for this long after their last typing event,
+	// in case their stop event was lost.
+	typingTimeout = 2 * typingInterval
+	// Events waiting to be written to the websocket, beyond which typing events are dropped.
+	outboundEventsBuffer = 16
+)
+
+// SendTyping tells the remote user of a conversation that the local user started or stopped typing.
+// Typing events are best effort: they are dropped when the websocket lags behind.
+func (u *User) SendTyping(conversation *Conversation, typing bool) {
+	now := time.Now()
+	if typing && now.Sub(conversation.typingSentAt) < typingInterval {
+		return
+	}
+	// The remote user was not told that we were typing
+	if !typing && conversation.typingSentAt.IsZero() {
+		return
+	}
+
+	event := &types.WebSocketEvent{
+		Type: types.WebSocketEventTyping,
+		Typing: &types.TypingEvent{
+			To:     conversation.dbConv.RemoteUserName,
+			Typing: typing,
+		},
+	}
+	select {
+	case u.outboundEvents <- event:
+	default:
+		return
+	}
+	if typing {
+		conversation.typingSentAt = now
+	} else {
+		conversation.typingSentAt = time.Time{}
+	}
+}
+
+// setTyping records that the remote user of a conversation started or stopped typing.
+func (u *User) setTyping(typing *types.TypingEvent) {
+	conversation, ok := u.conversations[typing.From]
+	if !ok {
+		return
+	}
+	if typing.Typing {
+		conversation.typingUntil = time.Now().Add(typingTimeout)
+	} else {
+		conversation.typingUntil = time.Time{}
+	}
+}
+
+// setPresence records that a remote user came online or went offline.
+func (u *User) setPresence(presence *types.PresenceEvent) {
+	if presence.Online {
+		u.online[presence.Username] = true
+	} else {
+		delete(u.online, presence.Username)
+	}
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// errInvalidReceipt is returned for receipts that cannot be read or trusted.
+// They are acknowledged anyway: a lost receipt only leaves a message unmarked.
+var errInvalidReceipt = errors.New("invalid receipt")
+
+// sendReceipt tells the remote user of a conversation that messages were delivered or read,
+// in a message encrypted like any other so that the server cannot tell what was read.
+func (u *User) sendReceipt(
+	ctx context.Context,
+	conversation *Conversation,
+	receiptType types.ReceiptType,
+	messageIDs []string,
+) error {
+	receipt, err := json.Marshal(types.Receipt{Type: receiptType, MessageIDs: messageIDs})
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	encryptedMsg, err := u.encryptMessage(ctx, conversation, openapi.MessageKindReceipt, receipt)
+	if err != nil {
+		return fmt.Errorf("encryptMessage: %w", err)
+	}
+	clientID := uuid.NewString()
+	encryptedMsg.ClientId = &clientID
+
+	err = u.withAuth(ctx, func(token string) error {
+		_, err := u.client.SendMessage(ctx, token, encryptedMsg)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("client.SendMessage: %w", err)
+	}
+	return nil
+}
+
+// sendDeliveryReceipts sends a delivery receipt to each remote user for the messages received from them.
+func (u *User) sendDeliveryReceipts(ctx context.Context, dbMessages []*sqlcgen.Message) error {
+	idsBySender := make(map[string][]string)
+	for _, dbMessage := range dbMessages {
+		// Messages relayed without being stored by the server cannot be referred to
+		if dbMessage.Sender == u.name || !dbMessage.ServerID.Valid {
+			continue
+		}
+		idsBySender[dbMessage.Sender] = append(idsBySender[dbMessage.Sender], dbMessage.ServerID.String)
+	}
+
+	for sender, ids := range idsBySender {
+		conversation, ok := u.conversations[sender]
+		if !ok {
+			continue
+		}
+		err := u.sendReceipt(ctx, conversation, types.ReceiptDelivered, ids)
+		if err != nil {
+			return fmt.Errorf("sendReceipt: %w", err)
+		}
+	}
+	return nil
+}
+
+// receiveReceipt marks the messages we sent, that a receipt refers to, as delivered or read.
+func (u *User) receiveReceipt(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	conversation *Conversation,
+	message openapi.Message,
+) error {
+	decryptedMsg, err := u.decryptMessage(ctx, queries, conversation, message)
+	if err != nil {
+		return fmt.Errorf("%w: decryptMessage: %w", errInvalidReceipt, err)
+	}
+	// Anyone could otherwise mark our messages as read
+	if !decryptedMsg.Verified {
+		return fmt.Errorf("%w: unverified signature", errInvalidReceipt)
+	}
+	// Receipts sent by our other devices are for messages they received, not ours
+	if message.Sender == u.name {
+		return nil
+	}
+
+	var receipt types.Receipt
+	err = json.Unmarshal(decryptedMsg.Content, &receipt)
+	if err != nil {
+		return fmt.Errorf("%w: json.Unmarshal: %w", errInvalidReceipt, err)
+	}
+	at := time.Now()
+	if message.SentAt != nil {
+		at = *message.SentAt
+	}
+
+	for _, id := range receipt.MessageIDs {
+		dbMessage, err := queries.GetMessageByServerID(ctx, sqlcgen.GetMessageByServerIDParams{
+			ConversationID: conversation.dbConv.ID,
+			ServerID:       sql.NullString{String: id, Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("GetMessageByServerID: %w", err)
+		}
+		// Only the recipient of a message can report it
+		if dbMessage.Sender != u.name || dbMessage.Receiver != message.Sender {
+			continue
+		}
+
+		// The cached message holds the decrypted content, only its timestamps are updated
+		cached := conversation.message(dbMessage.ID)
+		switch receipt.Type {
+		case types.ReceiptDelivered:
+			if dbMessage.DeliveredAt.Valid {
+				continue
+			}
+			deliveredAt := sql.NullTime{Time: at, Valid: true}
+			_, err = queries.MarkMessageDelivered(ctx, sqlcgen.MarkMessageDeliveredParams{
+				DeliveredAt: deliveredAt,
+				ID:          dbMessage.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("MarkMessageDelivered: %w", err)
+			}
+			if cached != nil {
+				cached.DeliveredAt = deliveredAt
+			}
+		case types.ReceiptRead:
+			if dbMessage.ReadAt.Valid {
+				continue
+			}
+			readAt := sql.NullTime{Time: at, Valid: true}
+			_, err = queries.MarkMessageRead(ctx, sqlcgen.MarkMessageReadParams{
+				ReadAt: readAt,
+				ID:     dbMessage.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("MarkMessageRead: %w", err)
+			}
+			if cached != nil {
+				cached.ReadAt = readAt
+			}
+		default:
+			return fmt.Errorf("%w: unknown type %q", errInvalidReceipt, receipt.Type)
+		}
+	}
+	return nil
+}
+
+// ReadConversation marks the messages received in a conversation as read,
+// and tells the remote user unless the local user opted out of read receipts.
+func (u *User) ReadConversation(ctx context.Context, conversation *Conversation) error {
+	queries := sqlcgen.New(u.db)
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	var ids []string
+	for _, dbMessage := range conversation.messages {
+		if dbMessage.Sender == u.name || dbMessage.ReadAt.Valid {
+			continue
+		}
+		_, err := queries.MarkMessageRead(ctx, sqlcgen.MarkMessageReadParams{
+			ReadAt: now,
+			ID:     dbMessage.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("MarkMessageRead: %w", err)
+		}
+		dbMessage.ReadAt = now
+		if dbMessage.ServerID.Valid {
+			ids = append(ids, dbMessage.ServerID.String)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	localUser, err := queries.GetLocalUserByName(ctx, u.name)
+	if err != nil {
+		return fmt.Errorf("GetLocalUserByName: %w", err)
+	}
+	if !localUser.ReadReceipts {
+		return nil
+	}
+	err = u.sendReceipt(ctx, conversation, types.ReceiptRead, ids)
+	if err != nil {
+		return fmt.Errorf("sendReceipt: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// LocalSecrets encrypts the secrets stored in the local database besides private keys: the session
+// of each conversation, prekey private keys and the keys of outgoing transfers. They are encrypted
+// with a key derived from the keyring key, and each is bound to the row that holds it.
+type LocalSecrets struct {
+	aesCipher *cryptography.AESCipher
+}
+
+func NewLocalSecrets(keyring *Keyring) (*LocalSecrets, error) {
+	key, err := cryptography.DeriveSubkey(keyring.key, "talk local secrets")
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.DeriveSubkey: %w", err)
+	}
+	aesCipher, err := cryptography.NewAESCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.NewAESCipher: %w", err)
+	}
+	return &LocalSecrets{
+		aesCipher: aesCipher,
+	}, nil
+}
+
+func (s *LocalSecrets) sealSession(conversationID int64, session []byte) ([]byte, error) {
+	return s.aesCipher.EncryptWithAD(session, sessionAD(conversationID))
+}
+
+func (s *LocalSecrets) openSession(conversationID int64, ciphertext []byte) ([]byte, error) {
+	return s.aesCipher.DecryptWithAD(ciphertext, sessionAD(conversationID))
+}
+
+func (s *LocalSecrets) sealPrekey(localUserName openapi.Username, oneTime bool, keyID int64, privateKey []byte) ([]byte, error) {
+	return s.aesCipher.EncryptWithAD(privateKey, prekeyAD(localUserName, oneTime, keyID))
+}
+
+func (s *LocalSecrets) openPrekey(dbPrekey *sqlcgen.Prekey) ([]byte, error) {
+	return s.aesCipher.DecryptWithAD(
+		dbPrekey.PrivateKey,
+		prekeyAD(dbPrekey.LocalUserName, dbPrekey.OneTime, dbPrekey.KeyID),
+	)
+}
+
+func (s *LocalSecrets) sealTransferKey(transferID string, key []byte) ([]byte, error) {
+	return s.aesCipher.EncryptWithAD(key, transferKeyAD(transferID))
+}
+
+func (s *LocalSecrets) openTransferKey(outgoing *sqlcgen.OutgoingTransfer) ([]byte, error) {
+	return s.aesCipher.DecryptWithAD(outgoing.Key, transferKeyAD(outgoing.ID))
+}
+
+func sessionAD(conversationID int64) []byte {
+	return []byte("session " + strconv.FormatInt(conversationID, 10))
+}
+
+func prekeyAD(localUserName openapi.Username, oneTime bool, keyID int64) []byte {
+	return []byte("prekey " + localUserName + " " + strconv.FormatBool(oneTime) + " " + strconv.FormatInt(keyID, 10))
+}
+
+func transferKeyAD(transferID string) []byte {
+	return []byte("transfer " + transferID)
+}
+
+// encryptStored encrypts the secrets stored in plain text by previous versions.
+func (s *LocalSecrets) encryptStored(ctx context.Context, queries *sqlcgen.Queries) error {
+	localUsers, err := queries.ListLocalUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("queries.ListLocalUsers: %w", err)
+	}
+	for _, localUser := range localUsers {
+		dbConvs, err := queries.ListConversations(ctx, localUser.Name)
+		if err != nil {
+			return fmt.Errorf("queries.ListConversations: %w", err)
+		}
+		for _, dbConv := range dbConvs {
+			if dbConv.Session == nil {
+				continue
+			}
+			session, err := s.sealSession(dbConv.ID, dbConv.Session)
+			if err != nil {
+				return fmt.Errorf("sealSession: %w", err)
+			}
+			err = queries.UpdateConversationSession(ctx, sqlcgen.UpdateConversationSessionParams{
+				Session: session,
+				ID:      dbConv.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("queries.UpdateConversationSession: %w", err)
+			}
+		}
+		dbPrekeys, err := queries.ListPrekeys(ctx, localUser.Name)
+		if err != nil {
+			return fmt.Errorf("queries.ListPrekeys: %w", err)
+		}
+		for _, dbPrekey := range dbPrekeys {
+			privateKey, err := s.sealPrekey(dbPrekey.LocalUserName, dbPrekey.OneTime, dbPrekey.KeyID, dbPrekey.PrivateKey)
+			if err != nil {
+				return fmt.Errorf("sealPrekey: %w", err)
+			}
+			err = queries.UpdatePrekeyPrivateKey(ctx, sqlcgen.UpdatePrekeyPrivateKeyParams{
+				PrivateKey:    privateKey,
+				LocalUserName: dbPrekey.LocalUserName,
+				OneTime:       dbPrekey.OneTime,
+				KeyID:         dbPrekey.KeyID,
+			})
+			if err != nil {
+				return fmt.Errorf("queries.UpdatePrekeyPrivateKey: %w", err)
+			}
+		}
+	}
+	outgoingTransfers, err := queries.ListOutgoingTransfers(ctx)
+	if err != nil {
+		return fmt.Errorf("queries.ListOutgoingTransfers: %w", err)
+	}
+	for _, outgoing := range outgoingTransfers {
+		key, err := s.sealTransferKey(outgoing.ID, outgoing.Key)
+		if err != nil {
+			return fmt.Errorf("sealTransferKey: %w", err)
+		}
+		err = queries.UpdateOutgoingTransferKey(ctx, sqlcgen.UpdateOutgoingTransferKeyParams{
+			Key: key,
+			ID:  outgoing.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.UpdateOutgoingTransferKey: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// Session is the forward-secret session of a conversation, persisted as JSON in the conversations table.
+//
+// Sessions are preferably started from the remote user's prekeys, claimed from the server: the prekey
+// fields are sent along with our messages until the remote user replies. If both users start a session at
+// the same time, the session started by the user whose name sorts first wins.
+//
+// Otherwise, sessions are negotiated in-band, so that clients without session support keep receiving RSA
+// envelopes: while no session exists, the user whose name sorts last advertises an X25519 offer key along
+// with its messages. When the other user receives a signed offer, it starts a ratchet from it and a random
+// shared secret, which it sends wrapped with the offerer's RSA key until the offerer replies.
+type Session struct {
+	// OfferKey is the X25519 private key we offered to the remote user, until they start a session with it.
+	OfferKey []byte `json:"offer_key,omitempty"`
+	// RemoteOfferKey is the remote user's offer our ratchet was started from.
+	RemoteOfferKey []byte `json:"remote_offer_key,omitempty"`
+	// WrappedSecret is the shared secret wrapped for the remote user, until they reply.
+	WrappedSecret []byte `json:"wrapped_secret,omitempty"`
+	// PrekeyInit holds the prekey fields of the session we started from the remote user's prekeys,
+	// until they reply.
+	PrekeyInit *openapi.SessionHeader `json:"prekey_init,omitempty"`
+	// RemoteEphemeralKey is the ephemeral key of the session the remote user started from our prekeys.
+	RemoteEphemeralKey []byte                `json:"remote_ephemeral_key,omitempty"`
+	Ratchet            *cryptography.Ratchet `json:"ratchet,omitempty"`
+}
+
+// isSessionOfferer tells whether the local user offers sessions to the remote user, or starts them.
+func isSessionOfferer(localName, remoteName openapi.Username) bool {
+	return localName > remoteName
+}
+
+// session returns the conversation session, loading it from the database row on first use.
+// The caller must hold sessionMu.
+func (c *Conversation) session() (*Session, error) {
+	if c.sessionState != nil {
+		return c.sessionState, nil
+	}
+	session := new(Session)
+	if c.dbConv.Session != nil {
+		sessionBytes, err := c.secrets.openSession(c.dbConv.ID, c.dbConv.Session)
+		if err != nil {
+			return nil, fmt.Errorf("secrets.openSession: %w", err)
+		}
+		err = json.Unmarshal(sessionBytes, session)
+		if err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+	}
+	c.sessionState = session
+	return session, nil
+}
+
+// saveSession persists the conversation session. The caller must hold sessionMu.
+func (c *Conversation) saveSession(ctx context.Context, queries *sqlcgen.Queries) error {
+	sessionBytes, err := json.Marshal(c.sessionState)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	sessionBytes, err = c.secrets.sealSession(c.dbConv.ID, sessionBytes)
+	if err != nil {
+		return fmt.Errorf("secrets.sealSession: %w", err)
+	}
+	err = queries.UpdateConversationSession(ctx, sqlcgen.UpdateConversationSessionParams{
+		Session: sessionBytes,
+		ID:      c.dbConv.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("queries.UpdateConversationSession: %w", err)
+	}
+	c.dbConv.Session = sessionBytes
+	return nil
+}
+
+// resetSession drops the session state, for it to be loaded again as saved before a transaction
+// that was rolled back. The caller must hold sessionMu.
+func (c *Conversation) resetSession(saved []byte) {
+	c.sessionState = nil
+	c.dbConv.Session = saved
+}
+
+// encrypt encrypts plaintext with the conversation ratchet into message.
+func (s *Session) encrypt(plaintext types.PlainText, message *openapi.Message) error {
+	header, ciphertext, err := s.Ratchet.Encrypt(plaintext)
+	if err != nil {
+		return fmt.Errorf("Ratchet.Encrypt: %w", err)
+	}
+	version := types.MessageVersionSession
+	previousChainLength := int(header.PreviousChainLength)
+	messageNumber := int(header.MessageNumber)
+	message.Version = &version
+	message.Ciphertext = ciphertext
+	message.CipherSymKey = []byte{}
+	if s.WrappedSecret != nil {
+		message.CipherSymKey = s.WrappedSecret
+	}
+	message.Session = &openapi.SessionHeader{
+		RatchetKey:          &header.PublicKey,
+		PreviousChainLength: &previousChainLength,
+		MessageNumber:       &messageNumber,
+	}
+	if s.PrekeyInit != nil {
+		message.Session.EphemeralKey = s.PrekeyInit.EphemeralKey
+		message.Session.SignedPrekeyId = s.PrekeyInit.SignedPrekeyId
+		message.Session.OneTimePrekeyId = s.PrekeyInit.OneTimePrekeyId
+	}
+	return nil
+}
+
+// startFromPrekeys starts a ratchet from a prekey bundle of the remote user,
+// whose signed prekey must be signed by their private key.
+func (s *Session) startFromPrekeys(remote *types.PublicUser, bundle *openapi.PrekeyBundle) error {
+	signedPrekey := bundle.SignedPrekey
+	if signedPrekey.Signature == nil {
+		return errors.New("unsigned prekey")
+	}
+	err := cryptography.Verify(remote.PublicKey, signedPrekey.PublicKey, *signedPrekey.Signature)
+	if err != nil {
+		return fmt.Errorf("cryptography.Verify: %w", err)
+	}
+
+	ephemeralKey, err := cryptography.GenerateX25519Key()
+	if err != nil {
+		return fmt.Errorf("cryptography.GenerateX25519Key: %w", err)
+	}
+	var oneTimePrekey []byte
+	var oneTimePrekeyID *int
+	if bundle.OneTimePrekey != nil {
+		oneTimePrekey = bundle.OneTimePrekey.PublicKey
+		oneTimePrekeyID = &bundle.OneTimePrekey.Id
+	}
+	sharedSecret, err := cryptography.InitiatePrekeySecret(ephemeralKey.Bytes(), signedPrekey.PublicKey, oneTimePrekey)
+	if err != nil {
+		return fmt.Errorf("cryptography.InitiatePrekeySecret: %w", err)
+	}
+	ratchet, err := cryptography.NewInitiatorRatchet(sharedSecret, signedPrekey.PublicKey)
+	if err != nil {
+		return fmt.Errorf("cryptography.NewInitiatorRatchet: %w", err)
+	}
+
+	ephemeralPublicKey := ephemeralKey.PublicKey().Bytes()
+	s.Ratchet = ratchet
+	s.PrekeyInit = &openapi.SessionHeader{
+		EphemeralKey:    &ephemeralPublicKey,
+		SignedPrekeyId:  &signedPrekey.Id,
+		OneTimePrekeyId: oneTimePrekeyID,
+	}
+	s.OfferKey = nil
+	s.RemoteOfferKey = nil
+	s.WrappedSecret = nil
+	return nil
+}
+
+// offer attaches our offer key to an RSA envelope, generating it if needed.
+func (s *Session) offer(message *openapi.Message) error {
+	if s.OfferKey == nil {
+		offerKey, err := cryptography.GenerateX25519Key()
+		if err != nil {
+			return fmt.Errorf("cryptography.GenerateX25519Key: %w", err)
+		}
+		s.OfferKey = offerKey.Bytes()
+	}
+	offerPublicKey, err := cryptography.X25519PublicKey(s.OfferKey)
+	if err != nil {
+		return fmt.Errorf("cryptography.X25519PublicKey: %w", err)
+	}
+	message.Session = &openapi.SessionHeader{
+		OfferKey: &offerPublicKey,
+	}
+	return nil
+}
+
+// acceptOffer starts a ratchet from the remote user's offer key, unless one was already started from
+// the same offer, or we started one from their prekeys that they will pick up instead.
+func (s *Session) acceptOffer(remote *types.PublicUser, offerKey []byte) error {
+	if s.Ratchet != nil && bytes.Equal(s.RemoteOfferKey, offerKey) {
+		return nil
+	}
+	if s.PrekeyInit != nil {
+		return nil
+	}
+	sharedSecret, err := cryptography.GenerateAESKey()
+	if err != nil {
+		return fmt.Errorf("cryptography.GenerateAESKey: %w", err)
+	}
+	wrappedSecret, err := cryptography.EncryptKey(remote.PublicKey, sharedSecret)
+	if err != nil {
+		return fmt.Errorf("cryptography.EncryptKey: %w", err)
+	}
+	ratchet, err := cryptography.NewInitiatorRatchet(sharedSecret, offerKey)
+	if err != nil {
+		return fmt.Errorf("cryptography.NewInitiatorRatchet: %w", err)
+	}
+	s.Ratchet = ratchet
+	s.RemoteOfferKey = offerKey
+	s.WrappedSecret = wrappedSecret
+	return nil
+}
+
+// decrypt decrypts a session message, starting a new ratchet if the message was sent from one the remote
+// user started, from our prekeys or our offer. Only verified messages may start a session.
+func (s *Session) decrypt(
+	ctx context.Context,
+	u *User,
+	queries *sqlcgen.Queries,
+	message *openapi.Message,
+	verified bool,
+) (types.PlainText, error) {
+	header := message.Session
+	if header == nil || header.RatchetKey == nil || header.PreviousChainLength == nil || header.MessageNumber == nil {
+		return nil, errors.New("missing ratchet header")
+	}
+	ratchet := s.Ratchet
+	// Whether a ratchet started by the remote user replaces ours
+	adopt := false
+	switch {
+	case header.EphemeralKey != nil && !bytes.Equal(*header.EphemeralKey, s.RemoteEphemeralKey):
+		if !verified {
+			return nil, errors.New("refusing to start a session from an unverified message")
+		}
+		var err error
+		ratchet, err = u.prekeyResponderRatchet(ctx, queries, header)
+		if err != nil {
+			return nil, fmt.Errorf("prekeyResponderRatchet: %w", err)
+		}
+		// If we both started a session, keep the one started by the user whose name sorts first,
+		// the remote user will pick it up when receiving our messages
+		adopt = s.PrekeyInit == nil || isSessionOfferer(u.name, message.Sender)
+	case len(message.CipherSymKey) > 0 && (ratchet == nil || s.OfferKey != nil):
+		if !verified {
+			return nil, errors.New("refusing to start a session from an unverified message")
+		}
+		if s.OfferKey == nil {
+			return nil, errors.New("no session offered to the sender")
+		}
+		sharedSecret, err := cryptography.DecryptKey(u.key, message.CipherSymKey)
+		if err != nil {
+			return nil, fmt.Errorf("cryptography.DecryptKey: %w", err)
+		}
+		ratchet = cryptography.NewResponderRatchet(sharedSecret, s.OfferKey)
+		adopt = true
+	}
+	if ratchet == nil {
+		return nil, errors.New("no session with the sender")
+	}
+
+	plaintext, err := ratchet.Decrypt(
+		&cryptography.RatchetHeader{
+			PublicKey:           *header.RatchetKey,
+			PreviousChainLength: uint32(*header.PreviousChainLength),
+			MessageNumber:       uint32(*header.MessageNumber),
+		},
+		message.Ciphertext,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ratchet.Decrypt: %w", err)
+	}
+	switch {
+	case adopt:
+		// Our offer and prekeys must not be reused
+		s.Ratchet = ratchet
+		s.OfferKey = nil
+		s.PrekeyInit = nil
+		s.WrappedSecret = nil
+		if header.EphemeralKey != nil {
+			s.RemoteEphemeralKey = *header.EphemeralKey
+			if header.OneTimePrekeyId != nil {
+				err = u.deleteOneTimePrekey(ctx, queries, *header.OneTimePrekeyId)
+				if err != nil {
+					return nil, fmt.Errorf("deleteOneTimePrekey: %w", err)
+				}
+			}
+		}
+	case ratchet == s.Ratchet:
+		// The remote user replied, so they hold the shared secret or picked up our prekeys
+		s.WrappedSecret = nil
+		s.PrekeyInit = nil
+	}
+	return plaintext, nil
+}
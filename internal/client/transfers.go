@@ -0,0 +1,342 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// SendFile uploads a file to a remote user in encrypted chunks, then sends them the manifest
+// to download it. Only one chunk is held in memory at a time.
+// An interrupted upload of the same file to the same user resumes with the chunks the server is missing.
+func (u *User) SendFile(ctx context.Context, filePath string, recipientName openapi.Username) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return fmt.Errorf("io.Copy: %w", err)
+	}
+	mimeType, err := detectMimeType(file, filePath)
+	if err != nil {
+		return fmt.Errorf("detectMimeType: %w", err)
+	}
+	manifest := &types.FileManifest{
+		Name:       filepath.Base(filePath),
+		MimeType:   mimeType,
+		Size:       size,
+		Hash:       hash.Sum(nil),
+		ChunkSize:  types.TransferChunkSize,
+		ChunkCount: chunkCount(size, types.TransferChunkSize),
+	}
+	if manifest.ChunkCount > types.MaxTransferChunks {
+		return fmt.Errorf("file too large: %d bytes", size)
+	}
+
+	queries := sqlcgen.New(u.db)
+	transfer, err := u.resumeTransfer(ctx, queries, recipientName, manifest)
+	if err != nil {
+		return fmt.Errorf("resumeTransfer: %w", err)
+	}
+	manifest.TransferID = transfer.Id
+
+	cipher, err := cryptography.NewAESCipher(manifest.Key)
+	if err != nil {
+		return fmt.Errorf("cryptography.NewAESCipher: %w", err)
+	}
+	buf := make([]byte, manifest.ChunkSize)
+	for index := 0; index < manifest.ChunkCount; index++ {
+		if slices.Contains(transfer.Received, index) {
+			continue
+		}
+		n, err := file.ReadAt(buf, int64(index)*int64(manifest.ChunkSize))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("file.ReadAt: %w", err)
+		}
+		chunk, err := cipher.EncryptWithAD(buf[:n], chunkAD(manifest.TransferID, index))
+		if err != nil {
+			return fmt.Errorf("cipher.EncryptWithAD: %w", err)
+		}
+		err = u.withAuth(ctx, func(token string) error {
+			return u.client.PutTransferChunk(ctx, token, manifest.TransferID, index, chunk)
+		})
+		if err != nil {
+			return fmt.Errorf("client.PutTransferChunk: %w", err)
+		}
+	}
+
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	err = u.sendMessage(ctx, openapi.MessageKindFile, content, recipientName)
+	if err != nil {
+		return fmt.Errorf("sendMessage: %w", err)
+	}
+
+	err = queries.DeleteOutgoingTransfer(ctx, manifest.TransferID)
+	if err != nil {
+		return fmt.Errorf("queries.DeleteOutgoingTransfer: %w", err)
+	}
+	return nil
+}
+
+// resumeTransfer returns the transfer of an interrupted upload of the same file to the same user,
+// or else creates one, and sets the key of the manifest accordingly.
+func (u *User) resumeTransfer(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	recipientName openapi.Username,
+	manifest *types.FileManifest,
+) (*openapi.Transfer, error) {
+	outgoing, err := queries.GetOutgoingTransfer(ctx, sqlcgen.GetOutgoingTransferParams{
+		LocalUserName: u.name,
+		Recipient:     recipientName,
+		Hash:          manifest.Hash,
+	})
+	switch {
+	case err == nil:
+		var transfer *openapi.Transfer
+		err = u.withAuth(ctx, func(token string) error {
+			var err error
+			transfer, err = u.client.GetTransfer(ctx, token, outgoing.ID)
+			return err
+		})
+		if err == nil {
+			manifest.Key, err = u.secrets.openTransferKey(outgoing)
+			if err != nil {
+				return nil, fmt.Errorf("secrets.openTransferKey: %w", err)
+			}
+			return transfer, nil
+		}
+		if !errors.Is(err, types.ErrNotFound) {
+			return nil, fmt.Errorf("client.GetTransfer: %w", err)
+		}
+		// The server no longer has the transfer, start over
+		err = queries.DeleteOutgoingTransfer(ctx, outgoing.ID)
+		if err != nil {
+			return nil, fmt.Errorf("queries.DeleteOutgoingTransfer: %w", err)
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("queries.GetOutgoingTransfer: %w", err)
+	}
+
+	key, err := cryptography.GenerateAESKey()
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.GenerateAESKey: %w", err)
+	}
+	var transfer *openapi.Transfer
+	err = u.withAuth(ctx, func(token string) error {
+		var err error
+		transfer, err = u.client.CreateTransfer(ctx, token, recipientName, manifest.ChunkCount)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("client.CreateTransfer: %w", err)
+	}
+	storedKey, err := u.secrets.sealTransferKey(transfer.Id, key)
+	if err != nil {
+		return nil, fmt.Errorf("secrets.sealTransferKey: %w", err)
+	}
+	err = queries.InsertOutgoingTransfer(ctx, sqlcgen.InsertOutgoingTransferParams{
+		ID:            transfer.Id,
+		LocalUserName: u.name,
+		Recipient:     recipientName,
+		Hash:          manifest.Hash,
+		Key:           storedKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("queries.InsertOutgoingTransfer: %w", err)
+	}
+	manifest.Key = key
+	return transfer, nil
+}
+
+// DownloadFile downloads and decrypts the chunks of a file described by a manifest to filePath.
+// Chunks are written to filePath with a ".part" suffix first, so that an interrupted download
+// resumes after the chunks already written, and the file is only renamed once its hash is checked.
+func (u *User) DownloadFile(ctx context.Context, manifest *types.FileManifest, filePath string) error {
+	err := validateManifest(manifest)
+	if err != nil {
+		return fmt.Errorf("validateManifest: %w", err)
+	}
+	cipher, err := cryptography.NewAESCipher(manifest.Key)
+	if err != nil {
+		return fmt.Errorf("cryptography.NewAESCipher: %w", err)
+	}
+
+	partPath := filePath + ".part"
+	file, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile: %w", err)
+	}
+	defer file.Close()
+
+	// Only whole chunks are kept from a previous download, and hashed again
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("file.Stat: %w", err)
+	}
+	start := min(int(info.Size()/int64(manifest.ChunkSize)), manifest.ChunkCount-1)
+	offset := int64(start) * int64(manifest.ChunkSize)
+	err = file.Truncate(offset)
+	if err != nil {
+		return fmt.Errorf("file.Truncate: %w", err)
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, io.NewSectionReader(file, 0, offset))
+	if err != nil {
+		return fmt.Errorf("io.Copy: %w", err)
+	}
+
+	for index := start; index < manifest.ChunkCount; index++ {
+		var chunk []byte
+		err := u.withAuth(ctx, func(token string) error {
+			var err error
+			chunk, err = u.client.GetTransferChunk(ctx, token, manifest.TransferID, index)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("client.GetTransferChunk: %w", err)
+		}
+		plaintext, err := cipher.DecryptWithAD(chunk, chunkAD(manifest.TransferID, index))
+		if err != nil {
+			return fmt.Errorf("%w: cipher.DecryptWithAD: %w", types.ErrInvalidChunk, err)
+		}
+		offset := int64(index) * int64(manifest.ChunkSize)
+		if int64(len(plaintext)) != min(manifest.Size-offset, int64(manifest.ChunkSize)) {
+			return fmt.Errorf("%w: chunk %d is %d bytes long", types.ErrInvalidChunk, index, len(plaintext))
+		}
+		_, err = file.WriteAt(plaintext, offset)
+		if err != nil {
+			return fmt.Errorf("file.WriteAt: %w", err)
+		}
+		hash.Write(plaintext)
+	}
+
+	if !bytes.Equal(hash.Sum(nil), manifest.Hash) {
+		// Chunks are authenticated, so the sender sent a wrong hash: retrying would not help
+		os.Remove(partPath)
+		return fmt.Errorf("%w: file hash mismatch", types.ErrInvalidChunk)
+	}
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("file.Close: %w", err)
+	}
+	err = os.Rename(partPath, filePath)
+	if err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+
+	// The server deletes the chunks once all our devices have downloaded them
+	err = u.withAuth(ctx, func(token string) error {
+		return u.client.AckTransfer(ctx, token, manifest.TransferID)
+	})
+	if err != nil {
+		return fmt.Errorf("client.AckTransfer: %w", err)
+	}
+	return nil
+}
+
+// detectMimeType returns the MIME type of a file from its extension, or else from its first bytes.
+func detectMimeType(file *os.File, filePath string) (string, error) {
+	mimeType := mime.TypeByExtension(filepath.Ext(filePath))
+	if mimeType != "" {
+		return mimeType, nil
+	}
+	head := make([]byte, 512)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("file.ReadAt: %w", err)
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// attachmentPath returns where to save a received file in dir, under the name it was sent with
+// unless that name is unusable, without overwriting any existing file.
+func attachmentPath(dir string, manifest *types.FileManifest, fallback string) string {
+	// Names are chosen by the sender, who must not be able to write outside of dir
+	name := filepath.Base(filepath.Clean("/" + manifest.Name))
+	if name == "/" || name == "." {
+		name = fallback
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	filePath := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		_, err := os.Stat(filePath)
+		if errors.Is(err, os.ErrNotExist) {
+			return filePath
+		}
+		filePath = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+	}
+}
+
+// attachmentLabel describes a file message for display, such as "📎 report.pdf (2.3 MB)".
+func attachmentLabel(manifest *types.FileManifest) string {
+	return fmt.Sprintf("📎 %s (%s)", manifest.Name, formatSize(manifest.Size))
+}
+
+// formatSize formats a size in bytes with decimal units.
+func formatSize(size int64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	const prefixes = "kMGT"
+	value := float64(size) / unit
+	i := 0
+	for value >= unit && i < len(prefixes)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %cB", value, prefixes[i])
+}
+
+// validateManifest checks that a received manifest is consistent before any chunk is downloaded.
+func validateManifest(manifest *types.FileManifest) error {
+	if manifest.ChunkSize <= 0 || manifest.ChunkSize > types.MaxTransferChunkSize {
+		return fmt.Errorf("invalid chunk size %d", manifest.ChunkSize)
+	}
+	if manifest.Size < 0 || manifest.ChunkCount != chunkCount(manifest.Size, manifest.ChunkSize) {
+		return fmt.Errorf("%d chunks do not match a size of %d bytes", manifest.ChunkCount, manifest.Size)
+	}
+	if manifest.ChunkCount > types.MaxTransferChunks {
+		return fmt.Errorf("too many chunks: %d", manifest.ChunkCount)
+	}
+	if len(manifest.Hash) != sha256.Size {
+		return errors.New("invalid hash")
+	}
+	return nil
+}
+
+// chunkCount returns the number of chunks a file is split into, an empty file still having one.
+func chunkCount(size int64, chunkSize int) int {
+	return max(1, int((size+int64(chunkSize)-1)/int64(chunkSize)))
+}
+
+// chunkAD returns the additional data a chunk is encrypted with, binding it to its transfer and index.
+func chunkAD(transferID string, index int) []byte {
+	return binary.BigEndian.AppendUint32([]byte(transferID), uint32(index))
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// ErrKeyChanged is returned when the server lists a key for a user that differs from the key we pinned,
+// and that is not reachable through signed key rotations. Messages cannot be sent to the user
+// until the new key is approved.
+var ErrKeyChanged = errors.New("public key changed")
+
+// ContactTrust describes how much we trust the key we hold for a remote user.
+type ContactTrust struct {
+	// SafetyNumber is computed from our key and the remote user's key, the new one if it changed
+	SafetyNumber string
+	// VerifiedAt is the time the user approved the remote user's key, nil if never or if the key changed since
+	VerifiedAt *time.Time
+	// KeyChanged is true when the server lists another key than the pinned one
+	KeyChanged bool
+	publicKey  []byte
+}
+
+// checkPinnedKey compares the key listed by the server for a user with the key pinned in our database,
+// pinning it if we never saw the user. A different key is first looked up in the user's signed key
+// rotations; otherwise it is kept aside until approved, and ErrKeyChanged is returned.
+func (u *User) checkPinnedKey(ctx context.Context, name openapi.Username, serverKeyBytes []byte) error {
+	serverKey, err := cryptography.UnmarshalPublicKey(serverKeyBytes)
+	if err != nil {
+		return fmt.Errorf("cryptography.UnmarshalPublicKey: %w", err)
+	}
+	publicUser, err := u.GetPublicUser(ctx, name)
+	if err != nil {
+		return fmt.Errorf("GetPublicUser: %w", err)
+	}
+	if !publicUser.PublicKey.Equal(serverKey) {
+		refreshed, err := u.refreshPublicUser(ctx, name)
+		if err != nil {
+			return fmt.Errorf("refreshPublicUser: %w", err)
+		}
+		if refreshed {
+			publicUser, err = u.GetPublicUser(ctx, name)
+			if err != nil {
+				return fmt.Errorf("GetPublicUser: %w", err)
+			}
+		}
+	}
+
+	queries := sqlcgen.New(u.db)
+	if publicUser.PublicKey.Equal(serverKey) {
+		err = queries.SetPublicUserPendingKey(ctx, sqlcgen.SetPublicUserPendingKeyParams{
+			PendingPublicKey: nil,
+			Name:             name,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.SetPublicUserPendingKey: %w", err)
+		}
+		return nil
+	}
+	err = queries.SetPublicUserPendingKey(ctx, sqlcgen.SetPublicUserPendingKeyParams{
+		PendingPublicKey: cryptography.MarshalPublicKey(serverKey),
+		Name:             name,
+	})
+	if err != nil {
+		return fmt.Errorf("queries.SetPublicUserPendingKey: %w", err)
+	}
+	return fmt.Errorf(
+		"%w: the server returned a new key for %q, compare safety numbers with `user verify %s %s` before sending again",
+		ErrKeyChanged, name, u.name, name,
+	)
+}
+
+// ContactTrust checks the key of a remote user against the server and returns its safety number.
+func (u *User) ContactTrust(ctx context.Context, remoteName openapi.Username) (*ContactTrust, error) {
+	// Listing the devices checks the pinned key against the one on the server
+	u.invalidateDevices(remoteName)
+	_, err := u.GetDevices(ctx, remoteName)
+	if err != nil && !errors.Is(err, ErrKeyChanged) {
+		return nil, fmt.Errorf("GetDevices: %w", err)
+	}
+	local, err := u.GetPublicUser(ctx, u.name)
+	if err != nil {
+		return nil, fmt.Errorf("GetPublicUser: %w", err)
+	}
+	remote, err := sqlcgen.New(u.db).GetPublicUserByName(ctx, remoteName)
+	if err != nil {
+		return nil, fmt.Errorf("queries.GetPublicUserByName: %w", err)
+	}
+
+	trust := &ContactTrust{
+		KeyChanged: remote.PendingPublicKey != nil,
+		publicKey:  remote.PublicKey,
+	}
+	if trust.KeyChanged {
+		trust.publicKey = remote.PendingPublicKey
+	} else if remote.VerifiedAt.Valid {
+		trust.VerifiedAt = &remote.VerifiedAt.Time
+	}
+	remoteKey, err := cryptography.UnmarshalPublicKey(trust.publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.UnmarshalPublicKey: %w", err)
+	}
+	trust.SafetyNumber = cryptography.SafetyNumber(u.name, local.PublicKey, remoteName, remoteKey)
+	return trust, nil
+}
+
+// VerifyContact pins the key whose safety number the user compared, and marks it as verified.
+func (u *User) VerifyContact(ctx context.Context, remoteName openapi.Username, trust *ContactTrust) error {
+	err := sqlcgen.New(u.db).VerifyPublicUserKey(ctx, sqlcgen.VerifyPublicUserKeyParams{
+		PublicKey: trust.publicKey,
+		Name:      remoteName,
+	})
+	if err != nil {
+		return fmt.Errorf("queries.VerifyPublicUserKey: %w", err)
+	}
+	u.invalidateDevices(remoteName)
+	return nil
+}
@@ -16,6 +16,9 @@ type UI struct {
 	db            *sql.DB
 	openapiClient *openapi.ClientWithResponses
 	config        *Config
+	keyring       *Keyring
+	history       *MessageHistory
+	secrets       *LocalSecrets
 }
 
 type Mode string
@@ -31,12 +34,24 @@ func InitUI(
 	config *Config,
 	openapiClient *openapi.ClientWithResponses,
 	db *sql.DB,
+	keyring *Keyring,
 ) error {
+	history, err := NewMessageHistory(keyring, config.Database.EncryptMessages)
+	if err != nil {
+		return fmt.Errorf("NewMessageHistory: %w", err)
+	}
+	secrets, err := NewLocalSecrets(keyring)
+	if err != nil {
+		return fmt.Errorf("NewLocalSecrets: %w", err)
+	}
 	UISingleton = &UI{
 		actions:       make(chan Action, 100),
 		db:            db,
 		openapiClient: openapiClient,
 		config:        config,
+		keyring:       keyring,
+		history:       history,
+		secrets:       secrets,
 	}
 
 	drawer, err := NewDrawer()
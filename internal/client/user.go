@@ -2,12 +2,18 @@ package client
 
 import (
 	"context"
-	"crypto/rand"
 	"crypto/rsa"
 	"database/sql"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/google/uuid"
 
 	"github.com/marc921/talk/internal/client/database/sqlcgen"
 	"github.com/marc921/talk/internal/cryptography"
@@ -15,92 +21,155 @@ import (
 	"github.com/marc921/talk/internal/types/openapi"
 )
 
+// errAlreadyReceived is returned when receiving a message already stored, such as a message
+// delivered again because its acknowledgement was lost.
+var errAlreadyReceived = errors.New("message already received")
+
+// acknowledgeable reports whether a message that could not be received should be acknowledged
+// all the same, as receiving it again would not succeed either.
+func acknowledgeable(err error) bool {
+	return errors.Is(err, errNotForThisDevice) ||
+		errors.Is(err, errAlreadyReceived) ||
+		errors.Is(err, errInvalidReceipt)
+}
+
 type User struct {
-	name             openapi.Username
-	key              *rsa.PrivateKey
-	client           *Client
-	authToken        *string
-	db               *sql.DB
-	conversations    map[openapi.Username]*Conversation
-	inboundMessages  chan *openapi.Message
-	outboundMessages chan *openapi.Message
+	name           openapi.Username
+	key            *rsa.PrivateKey
+	deviceID       string
+	client         *Client
+	db             *sql.DB
+	history        *MessageHistory
+	secrets        *LocalSecrets
+	conversations  map[openapi.Username]*Conversation
+	inboundEvents  chan *types.WebSocketEvent
+	outboundEvents chan *types.WebSocketEvent
+	// Remote users currently online, as told by the server. Only used by the UI goroutine.
+	online map[openapi.Username]bool
+	// State of the websocket, as told by superviseWebSocket. Only used by the UI goroutine.
+	connection ConnectionState
+	// Device lists of users, see GetDevices. Guarded by devicesMu, as messages are sent and received
+	// from several goroutines.
+	devicesMu sync.Mutex
+	devices   map[openapi.Username]*deviceList
+	// Auth tokens, renewed by token. Guarded by authMu, as requests are sent from several goroutines.
+	authMu       sync.Mutex
+	authToken    string
+	authExpiry   time.Time
+	refreshToken string
 }
 
+// NewUser returns a local user, whose private key was unlocked from the keyring.
 func NewUser(
-	localUser *sqlcgen.LocalUser,
+	name openapi.Username,
+	privKey *rsa.PrivateKey,
+	history *MessageHistory,
+	secrets *LocalSecrets,
 	openapiClient *openapi.ClientWithResponses,
 	db *sql.DB,
-) (*User, error) {
-	privKey, err := cryptography.UnmarshalPrivateKey(localUser.PrivateKey)
-	if err != nil {
-		return nil, fmt.Errorf("cryptography.UnmarshalPrivateKey: %w", err)
-	}
-	user := &User{
-		name:             localUser.Name,
-		key:              privKey,
-		client:           NewClient(openapiClient, localUser.Name),
-		db:               db,
-		conversations:    make(map[openapi.Username]*Conversation),
-		inboundMessages:  make(chan *openapi.Message),
-		outboundMessages: make(chan *openapi.Message),
+) *User {
+	return &User{
+		name:           name,
+		key:            privKey,
+		deviceID:       cryptography.Fingerprint(&privKey.PublicKey),
+		client:         NewClient(openapiClient, name),
+		db:             db,
+		history:        history,
+		secrets:        secrets,
+		conversations:  make(map[openapi.Username]*Conversation),
+		inboundEvents:  make(chan *types.WebSocketEvent),
+		outboundEvents: make(chan *types.WebSocketEvent, outboundEventsBuffer),
+		online:         make(map[openapi.Username]bool),
+		devices:        make(map[openapi.Username]*deviceList),
 	}
-	return user, nil
 }
 
-func (u *User) Authenticate(ctx context.Context) error {
+// authenticate obtains new tokens from the server by signing its challenge with the user's key.
+func (u *User) authenticate(ctx context.Context) (*openapi.JWT, error) {
 	// Get the nonce from the server
 	challenge, err := u.client.GetAuth(ctx)
 	if err != nil {
-		return fmt.Errorf("GetAuth: %w", err)
+		return nil, fmt.Errorf("GetAuth: %w", err)
 	}
 	nonceBytes, err := base64.URLEncoding.DecodeString(challenge.Nonce)
 	if err != nil {
-		return fmt.Errorf("base64.URLEncoding.DecodeString: %w", err)
+		return nil, fmt.Errorf("base64.URLEncoding.DecodeString: %w", err)
 	}
 
 	// Sign the nonce with the user's private key
-	signedNonceBytes, err := rsa.SignPKCS1v15(rand.Reader, u.key, 0, nonceBytes)
+	signedNonceBytes, err := cryptography.Sign(u.key, nonceBytes)
 	if err != nil {
-		return fmt.Errorf("rsa.SignPKCS1v15: %w", err)
+		return nil, fmt.Errorf("cryptography.Sign: %w", err)
 	}
 	signedNonce := base64.URLEncoding.EncodeToString(signedNonceBytes)
 
 	// Send the signed nonce to the server
-	authResp, err := u.client.PostAuth(ctx, challenge, signedNonce)
+	tokens, err := u.client.PostAuth(ctx, challenge, signedNonce)
 	if err != nil {
-		return fmt.Errorf("client.PostAuth: %w", err)
+		return nil, fmt.Errorf("client.PostAuth: %w", err)
 	}
-	u.authToken = &authResp.Token
-	return nil
+	return tokens, nil
 }
 
 func (u *User) RegisterWebSocket(ctx context.Context) error {
-	if u.authToken == nil {
-		err := u.Authenticate(ctx)
-		if err != nil {
-			return fmt.Errorf("Authenticate: %w", err)
-		}
+	_, err := u.token(ctx)
+	if err != nil {
+		return fmt.Errorf("token: %w", err)
 	}
-	go func() {
-		err := u.client.WebSocket(ctx, *u.authToken, u.inboundMessages, u.outboundMessages)
-		if err != nil {
-			UISingleton.actions <- &ActionSetError{err: fmt.Errorf("client.WebSocket: %w", err)}
-		}
-	}()
+	go u.superviseWebSocket(ctx)
 
 	go func() {
 		queries := sqlcgen.New(u.db)
-		for message := range u.inboundMessages {
-			_, err := u.receiveMessage(ctx, queries, *message)
-			if err != nil {
-				UISingleton.actions <- &ActionSetError{err: fmt.Errorf("receiveMessage: %w", err)}
+		for event := range u.inboundEvents {
+			switch {
+			case event.Message != nil:
+				err := u.receiveWebSocketMessage(ctx, queries, event.Message)
+				if err != nil {
+					UISingleton.actions <- &ActionSetError{err: err}
+				}
+				// The conversation may be displayed, and its new messages read
+				UISingleton.actions <- &ActionUpdateUser{user: u}
+			case event.Type == types.WebSocketEventTyping && event.Typing != nil:
+				UISingleton.actions <- &ActionSetTyping{localUser: u, typing: event.Typing}
+			case event.Type == types.WebSocketEventPresence && event.Presence != nil:
+				UISingleton.actions <- &ActionSetPresence{localUser: u, presence: event.Presence}
+			default:
+				continue
 			}
-			UISingleton.drawer.Draw()
+			// Wake the UI up from waiting for terminal events, for the action to run
+			UISingleton.drawer.screen.PostEvent(tcell.NewEventInterrupt(nil))
 		}
 	}()
 	return nil
 }
 
+// receiveWebSocketMessage receives a message pushed by the server, acknowledges it,
+// and sends a delivery receipt for it.
+func (u *User) receiveWebSocketMessage(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	message *openapi.Message,
+) error {
+	dbMsg, err := u.receiveMessage(ctx, queries, *message)
+	if err != nil && !acknowledgeable(err) {
+		return fmt.Errorf("receiveMessage: %w", err)
+	}
+	// Messages relayed without being stored by the server have no ID to acknowledge
+	if message.Id != nil {
+		err = u.ackMessages(ctx, []string{*message.Id})
+		if err != nil {
+			return fmt.Errorf("ackMessages: %w", err)
+		}
+	}
+	if dbMsg != nil {
+		err = u.sendDeliveryReceipts(ctx, []*sqlcgen.Message{dbMsg})
+		if err != nil {
+			return fmt.Errorf("sendDeliveryReceipts: %w", err)
+		}
+	}
+	return nil
+}
+
 func (u *User) GetPublicUser(ctx context.Context, name openapi.Username) (*types.PublicUser, error) {
 	queries := sqlcgen.New(u.db)
 	// Check if the public user is already in the database
@@ -120,7 +189,9 @@ func (u *User) GetPublicUser(ctx context.Context, name openapi.Username) (*types
 			Name:      resp.Name,
 			PublicKey: cryptography.MarshalPublicKey(resp.PublicKey),
 		})
-
+		if err != nil {
+			return nil, fmt.Errorf("queries.InsertPublicUser: %w", err)
+		}
 	}
 
 	pubKey, err := cryptography.UnmarshalPublicKey(publicUser.PublicKey)
@@ -143,10 +214,10 @@ func (u *User) FetchConversationsFromDB(ctx context.Context) error {
 	}
 	for _, dbConv := range dbConvs {
 		dbConv := dbConv
-		u.conversations[dbConv.RemoteUserName] = NewConversation(dbConv)
-		dbMessages, err := queries.ListMessages(ctx, dbConv.ID)
+		u.conversations[dbConv.RemoteUserName] = NewConversation(dbConv, u.secrets)
+		dbMessages, err := u.history.listMessages(ctx, queries, dbConv.ID)
 		if err != nil {
-			return fmt.Errorf("queries.ListMessages: %w", err)
+			return fmt.Errorf("history.listMessages: %w", err)
 		}
 		u.conversations[dbConv.RemoteUserName].messages = dbMessages
 	}
@@ -162,11 +233,21 @@ func (u *User) CreateConversation(ctx context.Context, remoteUsername openapi.Us
 	if err != nil {
 		return fmt.Errorf("queries.InsertConversation: %w", err)
 	}
-	u.conversations[remoteUsername] = NewConversation(dbConv)
+	u.conversations[remoteUsername] = NewConversation(dbConv, u.secrets)
 	return nil
 }
 
 func (u *User) SendMessage(ctx context.Context, plaintext types.PlainText, recipientName openapi.Username) error {
+	return u.sendMessage(ctx, openapi.MessageKindMessage, plaintext, recipientName)
+}
+
+// sendMessage sends a message of any kind that is kept in the conversation history.
+func (u *User) sendMessage(
+	ctx context.Context,
+	kind openapi.MessageKind,
+	plaintext types.PlainText,
+	recipientName openapi.Username,
+) error {
 	conversation, ok := u.conversations[recipientName]
 	if !ok {
 		err := u.CreateConversation(ctx, recipientName)
@@ -176,19 +257,20 @@ func (u *User) SendMessage(ctx context.Context, plaintext types.PlainText, recip
 		conversation = u.conversations[recipientName]
 	}
 
-	encryptedMsg, err := u.encryptMessage(ctx, plaintext, recipientName)
+	encryptedMsg, err := u.encryptMessage(ctx, conversation, kind, plaintext)
 	if err != nil {
 		return fmt.Errorf("encryptMessage: %w", err)
 	}
-
-	if u.authToken == nil {
-		err := u.Authenticate(ctx)
-		if err != nil {
-			return fmt.Errorf("Authenticate: %w", err)
-		}
+	clientID := uuid.NewString()
+	encryptedMsg.ClientId = &clientID
+	envelope, err := json.Marshal(encryptedMsg)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
 	}
 
-	// Start transaction to insert message in database and send it to remote user, rollback if any error occurs
+	// Store the message as pending before posting it, so that it is not lost if posting fails, even
+	// though the server may have stored it: it is posted again with the same client ID, which the
+	// server does not store twice. No transaction is held while posting.
 	tx, err := u.db.Begin()
 	if err != nil {
 		return err
@@ -196,33 +278,118 @@ func (u *User) SendMessage(ctx context.Context, plaintext types.PlainText, recip
 	defer tx.Rollback()
 	txQueries := sqlcgen.New(u.db).WithTx(tx)
 
-	// Insert message in database
-	dbMessage, err := txQueries.InsertMessage(ctx, sqlcgen.InsertMessageParams{
+	dbMessage, err := u.history.insertMessage(ctx, txQueries, sqlcgen.InsertMessageParams{
 		ConversationID: conversation.dbConv.ID,
 		Sender:         u.name,
 		Receiver:       recipientName,
+		Kind:           string(kind),
 		Content:        plaintext,
+		Verified:       true,
+		ClientID:       sql.NullString{String: clientID, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("history.insertMessage: %w", err)
+	}
+	err = txQueries.InsertPendingMessage(ctx, sqlcgen.InsertPendingMessageParams{
+		MessageID: dbMessage.ID,
+		Envelope:  envelope,
 	})
 	if err != nil {
-		return fmt.Errorf("txQueries.InsertMessage: %w", err)
+		return fmt.Errorf("txQueries.InsertPendingMessage: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
 	}
 
 	// Add message to conversation local cache
 	conversation.messages = append(conversation.messages, dbMessage)
 
-	// Send message to remote user
-	err = u.client.SendMessage(ctx, *u.authToken, encryptedMsg)
+	err = u.postMessage(ctx, dbMessage, encryptedMsg)
+	if err != nil {
+		return fmt.Errorf("postMessage: %w", err)
+	}
+	return nil
+}
+
+// postMessage posts a pending message to the server, and marks it sent with the server's ID and time,
+// for ordering and receipts. The message stays pending if posting fails.
+func (u *User) postMessage(ctx context.Context, dbMessage *sqlcgen.Message, envelope *openapi.Message) error {
+	var stored *openapi.Message
+	err := u.withAuth(ctx, func(token string) error {
+		var err error
+		stored, err = u.client.SendMessage(ctx, token, envelope)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("client.SendMessage: %w", err)
 	}
-	// u.outboundMessages <- encryptedMsg
+	// u.outboundEvents <- types.NewMessageEvent(envelope)
+
+	sent := sqlcgen.MarkMessageSentParams{
+		ID: dbMessage.ID,
+	}
+	if stored.Id != nil {
+		sent.ServerID = sql.NullString{String: *stored.Id, Valid: true}
+	}
+	if stored.SentAt != nil {
+		sent.SentAt = sql.NullTime{Time: *stored.SentAt, Valid: true}
+	}
+
+	tx, err := u.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	txQueries := sqlcgen.New(u.db).WithTx(tx)
+
+	err = txQueries.MarkMessageSent(ctx, sent)
+	if err != nil {
+		return fmt.Errorf("txQueries.MarkMessageSent: %w", err)
+	}
+	err = txQueries.DeletePendingMessage(ctx, dbMessage.ID)
+	if err != nil {
+		return fmt.Errorf("txQueries.DeletePendingMessage: %w", err)
+	}
 
-	// Commit transaction
 	err = tx.Commit()
 	if err != nil {
 		return fmt.Errorf("tx.Commit: %w", err)
 	}
+	dbMessage.ServerID = sent.ServerID
+	dbMessage.SentAt = sent.SentAt
+	return nil
+}
 
+// SendPendingMessages posts again the messages that could not be posted when sent, oldest first.
+// It stops at the first failure, for messages to reach the server in order.
+func (u *User) SendPendingMessages(ctx context.Context) error {
+	pendingMessages, err := sqlcgen.New(u.db).ListPendingMessages(ctx, u.name)
+	if err != nil {
+		return fmt.Errorf("queries.ListPendingMessages: %w", err)
+	}
+	for _, pending := range pendingMessages {
+		var envelope *openapi.Message
+		err = json.Unmarshal(pending.Envelope, &envelope)
+		if err != nil {
+			return fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		// Update the cached message, if loaded
+		dbMessage := &sqlcgen.Message{ID: pending.MessageID}
+		for _, conversation := range u.conversations {
+			if conversation.dbConv.ID != pending.ConversationID {
+				continue
+			}
+			if cached := conversation.message(pending.MessageID); cached != nil {
+				dbMessage = cached
+			}
+		}
+		err = u.postMessage(ctx, dbMessage, envelope)
+		if err != nil {
+			return fmt.Errorf("postMessage: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -236,120 +403,542 @@ func (u *User) FetchMessages(
 	}
 
 	// Fetch additional messages from the server
-	if u.authToken == nil {
-		err := u.Authenticate(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("Authenticate: %w", err)
-		}
-	}
-
-	messages, err := u.client.GetMessages(ctx, *u.authToken)
+	var messages []openapi.Message
+	err = u.withAuth(ctx, func(token string) error {
+		var err error
+		messages, err = u.client.GetMessages(ctx, token)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("client.GetMessages: %w", err)
 	}
 
+	// Only messages stored, now or before, or that we can never read, are acknowledged:
+	// the server delivers the others again once their lease expires
 	dbMessages := make([]*sqlcgen.Message, 0, len(messages))
+	acked := make([]string, 0, len(messages))
+	var receiveErr error
 	queries := sqlcgen.New(u.db)
 	for _, message := range messages {
 		dbMsg, err := u.receiveMessage(ctx, queries, message)
-		if err != nil {
-			return nil, fmt.Errorf("receiveMessage: %w", err)
+		if err != nil && !acknowledgeable(err) {
+			if receiveErr == nil {
+				receiveErr = fmt.Errorf("receiveMessage: %w", err)
+			}
+			continue
+		}
+		if message.Id != nil {
+			acked = append(acked, *message.Id)
+		}
+		if dbMsg != nil {
+			dbMessages = append(dbMessages, dbMsg)
 		}
-		dbMessages = append(dbMessages, dbMsg)
+	}
+	err = u.ackMessages(ctx, acked)
+	if err != nil {
+		return nil, fmt.Errorf("ackMessages: %w", err)
+	}
+	if receiveErr != nil {
+		return nil, receiveErr
+	}
+
+	err = u.sendDeliveryReceipts(ctx, dbMessages)
+	if err != nil {
+		return nil, fmt.Errorf("sendDeliveryReceipts: %w", err)
 	}
 	return dbMessages, nil
 }
 
+// ackMessages acknowledges messages stored in our database.
+func (u *User) ackMessages(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := u.withAuth(ctx, func(token string) error {
+		return u.client.AckMessages(ctx, token, ids)
+	})
+	if err != nil {
+		return fmt.Errorf("client.AckMessages: %w", err)
+	}
+	return nil
+}
+
+// receiveMessage decrypts a message and stores it in its conversation.
+// Receipts are applied to the messages they refer to instead, and nil is returned.
 func (u *User) receiveMessage(
 	ctx context.Context,
 	queries *sqlcgen.Queries,
 	message openapi.Message,
 ) (*sqlcgen.Message, error) {
-	conv, ok := u.conversations[message.Sender]
+	// Messages we sent from another device belong to the conversation with their recipient
+	remoteName := message.Sender
+	if message.Sender == u.name {
+		remoteName = message.Recipient
+	}
+	conv, ok := u.conversations[remoteName]
 	if !ok {
-		err := u.CreateConversation(ctx, message.Sender)
+		err := u.CreateConversation(ctx, remoteName)
 		if err != nil {
 			return nil, fmt.Errorf("CreateConversation: %w", err)
 		}
-		conv = u.conversations[message.Sender]
+		conv = u.conversations[remoteName]
+	}
+
+	// Decrypting a message twice would fail once the session moved on. Local users sharing the
+	// database each store the messages they exchange, so the lookup is scoped to the conversation.
+	var serverID sql.NullString
+	if message.Id != nil {
+		serverID = sql.NullString{String: *message.Id, Valid: true}
+		_, err := queries.GetMessageByServerID(ctx, sqlcgen.GetMessageByServerIDParams{
+			ConversationID: conv.dbConv.ID,
+			ServerID:       serverID,
+		})
+		if err == nil {
+			return nil, errAlreadyReceived
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("queries.GetMessageByServerID: %w", err)
+		}
 	}
 
-	decryptedMsg, err := u.decryptMessage(message)
+	conv.sessionMu.Lock()
+	defer conv.sessionMu.Unlock()
+	savedSession := conv.dbConv.Session
+	dbMessage, err := u.storeMessage(ctx, queries, conv, message, serverID)
+	if err != nil {
+		// The session must not move past the key of a message that was not stored, for the message
+		// to be decrypted again when delivered again
+		conv.resetSession(savedSession)
+		return nil, fmt.Errorf("storeMessage: %w", err)
+	}
+	if dbMessage != nil {
+		conv.messages = append(conv.messages, dbMessage)
+	}
+	return dbMessage, nil
+}
+
+// storeMessage decrypts a message and stores it in its conversation, in a transaction along with the
+// session it advanced. Receipts are applied to the messages they refer to instead, and nil is returned.
+// The caller must hold conversation.sessionMu.
+func (u *User) storeMessage(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	conv *Conversation,
+	message openapi.Message,
+	serverID sql.NullString,
+) (*sqlcgen.Message, error) {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("db.BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+	txQueries := queries.WithTx(tx)
+
+	if message.Kind != nil && *message.Kind == openapi.MessageKindReceipt {
+		err = u.receiveReceipt(ctx, txQueries, conv, message)
+		if err != nil {
+			return nil, fmt.Errorf("receiveReceipt: %w", err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			return nil, fmt.Errorf("tx.Commit: %w", err)
+		}
+		return nil, nil
+	}
+
+	decryptedMsg, err := u.decryptMessage(ctx, txQueries, conv, message)
 	if err != nil {
 		return nil, fmt.Errorf("decryptMessage: %w", err)
 	}
 	decryptedMsg.ConversationID = conv.dbConv.ID
+	decryptedMsg.ServerID = serverID
+	if message.ClientId != nil {
+		decryptedMsg.ClientID = sql.NullString{String: *message.ClientId, Valid: true}
+	}
+	if message.SentAt != nil {
+		decryptedMsg.SentAt = sql.NullTime{Time: *message.SentAt, Valid: true}
+	}
 
 	// Insert message in database
-	dbMessage, err := queries.InsertMessage(ctx, *decryptedMsg)
+	dbMessage, err := u.history.insertMessage(ctx, txQueries, *decryptedMsg)
+	if err != nil {
+		return nil, fmt.Errorf("history.insertMessage: %w", err)
+	}
+	err = tx.Commit()
 	if err != nil {
-		return nil, fmt.Errorf("queries.InsertMessage: %w", err)
+		return nil, fmt.Errorf("tx.Commit: %w", err)
 	}
-	conv.messages = append(conv.messages, dbMessage)
 	return dbMessage, nil
 }
 
-func (u *User) decryptMessage(message openapi.Message) (*sqlcgen.InsertMessageParams, error) {
-	// TODO: sign and verify messages
-	// sender, err := u.GetPublicUser(ctx, message.Sender)
-	// if err != nil {
-	// 	return fmt.Errorf("GetPublicUser: %w", err)
-	// }
-	// Decrypt symmetric key with private key
-	symKey, err := rsa.DecryptPKCS1v15(rand.Reader, u.key, message.CipherSymKey)
+// decryptMessage decrypts a message and checks its signature against the public key of the sender's device.
+// Messages whose signature is missing or invalid are still decrypted, but flagged as unverified.
+// The conversation session is started or advanced as needed, and persisted with queries.
+// The caller must hold conversation.sessionMu.
+func (u *User) decryptMessage(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	conversation *Conversation,
+	message openapi.Message,
+) (*sqlcgen.InsertMessageParams, error) {
+	// Pick the primitives matching the envelope version
+	var (
+		verify     func(*rsa.PublicKey, []byte, []byte) error
+		decryptKey func(*rsa.PrivateKey, []byte) ([]byte, error)
+	)
+	switch types.MessageVersion(&message) {
+	case types.MessageVersionPKCS1v15:
+		verify = cryptography.VerifyPKCS1v15
+		decryptKey = cryptography.DecryptKeyPKCS1v15
+	case types.MessageVersionOAEP:
+		verify = cryptography.Verify
+		decryptKey = cryptography.DecryptKey
+	case types.MessageVersionSession:
+		verify = cryptography.Verify
+	default:
+		return nil, fmt.Errorf("unsupported message version %d", types.MessageVersion(&message))
+	}
+
+	verified := false
+	if message.Signature != nil {
+		var err error
+		verified, err = u.verifySignature(ctx, &message, verify)
+		if err != nil {
+			return nil, fmt.Errorf("verifySignature: %w", err)
+		}
+	}
+	// Fetched after verification, which may have refreshed the sender's key
+	sender, err := u.GetPublicUser(ctx, message.Sender)
 	if err != nil {
-		return nil, fmt.Errorf("rsa.DecryptPKCS1v15: %w", err)
+		return nil, fmt.Errorf("GetPublicUser: %w", err)
 	}
-	cipher, err := cryptography.NewAESCipher(symKey)
+
+	// Envelopes for several devices hold a key wrapped for each of them
+	cipherSymKey := message.CipherSymKey
+	if message.Keys != nil {
+		cipherSymKey = nil
+		for _, key := range *message.Keys {
+			if key.Device == u.deviceID {
+				cipherSymKey = key.CipherSymKey
+				break
+			}
+		}
+		if cipherSymKey == nil {
+			return nil, errNotForThisDevice
+		}
+	}
+
+	session, err := conversation.session()
 	if err != nil {
-		return nil, fmt.Errorf("cryptography.NewAESCipher: %w", err)
+		return nil, fmt.Errorf("conversation.session: %w", err)
+	}
+
+	var plaintext types.PlainText
+	if decryptKey == nil {
+		plaintext, err = session.decrypt(ctx, u, queries, &message, verified)
+		if err != nil {
+			return nil, fmt.Errorf("session.decrypt: %w", err)
+		}
+	} else {
+		// Decrypt symmetric key with private key
+		symKey, err := decryptKey(u.key, cipherSymKey)
+		if err != nil {
+			return nil, fmt.Errorf("decryptKey: %w", err)
+		}
+		cipher, err := cryptography.NewAESCipher(symKey)
+		if err != nil {
+			return nil, fmt.Errorf("cryptography.NewAESCipher: %w", err)
+		}
+		plaintext, err = cipher.Decrypt(message.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("cipher.Decrypt: %w", err)
+		}
+
+		// Start a session if the sender offered one, only trusting signed offers
+		if message.Session != nil && message.Session.OfferKey != nil &&
+			verified && !isSessionOfferer(u.name, message.Sender) {
+			err = session.acceptOffer(sender, *message.Session.OfferKey)
+			if err != nil {
+				return nil, fmt.Errorf("session.acceptOffer: %w", err)
+			}
+		}
 	}
-	plaintext, err := cipher.Decrypt(message.Ciphertext)
+
+	err = conversation.saveSession(ctx, queries)
 	if err != nil {
-		return nil, fmt.Errorf("cipher.Decrypt: %w", err)
+		return nil, fmt.Errorf("conversation.saveSession: %w", err)
 	}
 
+	kind := openapi.MessageKindMessage
+	if message.Kind != nil {
+		kind = *message.Kind
+	}
 	return &sqlcgen.InsertMessageParams{
 		Sender:   message.Sender,
 		Receiver: message.Recipient,
+		Kind:     string(kind),
 		Content:  plaintext,
+		Verified: verified,
 	}, nil
 }
 
+// encryptMessage encrypts a message for the remote user of a conversation, with the conversation
+// session if one was started or can be started from their prekeys, or else with an RSA envelope
+// offering a session if it is our turn.
+// If either user has several devices, the message is sent as an RSA envelope with the symmetric key
+// wrapped for each of the remote user's devices and each of our other devices, so that they all can read it.
+// Such messages are not forward secret, which the conversation records for the user to be told.
 func (u *User) encryptMessage(
 	ctx context.Context,
+	conversation *Conversation,
+	kind openapi.MessageKind,
 	plaintext types.PlainText,
-	recipientName openapi.Username,
 ) (*openapi.Message, error) {
-	recipient, err := u.GetPublicUser(ctx, recipientName)
+	recipientName := conversation.dbConv.RemoteUserName
+	message := &openapi.Message{
+		Sender:       u.name,
+		Recipient:    recipientName,
+		SenderDevice: &u.deviceID,
+	}
+	// Plain messages are sent without kind, for clients that do not know about kinds
+	if kind != openapi.MessageKindMessage {
+		message.Kind = &kind
+	}
+
+	recipientDevices, err := u.GetDevices(ctx, recipientName)
 	if err != nil {
-		return nil, fmt.Errorf("GetPublicUser: %w", err)
+		return nil, fmt.Errorf("GetDevices: %w", err)
+	}
+	ownDevices, err := u.GetDevices(ctx, u.name)
+	if err != nil {
+		return nil, fmt.Errorf("GetDevices: %w", err)
 	}
+	multiDevice := len(recipientDevices) > 1 || len(ownDevices) > 1
+	conversation.withoutSession.Store(multiDevice)
+	if multiDevice {
+		err = u.encryptForDevices(ctx, message, plaintext, recipientDevices, ownDevices)
+		if err != nil {
+			return nil, fmt.Errorf("encryptForDevices: %w", err)
+		}
+		return message, nil
+	}
+
+	conversation.sessionMu.Lock()
+	defer conversation.sessionMu.Unlock()
+	session, err := conversation.session()
+	if err != nil {
+		return nil, fmt.Errorf("conversation.session: %w", err)
+	}
+
+	if session.Ratchet == nil {
+		err = u.startSessionFromPrekeys(ctx, session, recipientName)
+		if err != nil {
+			return nil, fmt.Errorf("startSessionFromPrekeys: %w", err)
+		}
+	}
+	if session.Ratchet != nil {
+		err = session.encrypt(plaintext, message)
+		if err != nil {
+			return nil, fmt.Errorf("session.encrypt: %w", err)
+		}
+	} else {
+		recipient, err := u.GetPublicUser(ctx, recipientName)
+		if err != nil {
+			return nil, fmt.Errorf("GetPublicUser: %w", err)
+		}
 
-	// Encrypt plaintext with new unique symmetric key
+		// Encrypt plaintext with new unique symmetric key
+		symKey, err := cryptography.GenerateAESKey()
+		if err != nil {
+			return nil, fmt.Errorf("cryptography.GenerateAESKey: %w", err)
+		}
+		cipher, err := cryptography.NewAESCipher(symKey)
+		if err != nil {
+			return nil, fmt.Errorf("cryptography.NewAESCipher: %w", err)
+		}
+		ciphertext, err := cipher.Encrypt(plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("cipher.Encrypt: %w", err)
+		}
+
+		// Encrypt symmetric key with recipient's public key
+		cipheredSymKey, err := cryptography.EncryptKey(recipient.PublicKey, symKey)
+		if err != nil {
+			return nil, fmt.Errorf("cryptography.EncryptKey: %w", err)
+		}
+
+		version := types.MessageVersionOAEP
+		message.Version = &version
+		message.CipherSymKey = cipheredSymKey
+		message.Ciphertext = ciphertext
+
+		if isSessionOfferer(u.name, recipientName) {
+			err = session.offer(message)
+			if err != nil {
+				return nil, fmt.Errorf("session.offer: %w", err)
+			}
+		}
+	}
+
+	// Persist the session before sending, so that a message key is never used twice
+	err = conversation.saveSession(ctx, sqlcgen.New(u.db))
+	if err != nil {
+		return nil, fmt.Errorf("conversation.saveSession: %w", err)
+	}
+
+	// Sign the envelope with the sender's private key
+	signature, err := cryptography.Sign(u.key, signaturePayload(message))
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.Sign: %w", err)
+	}
+	message.Signature = &signature
+
+	return message, nil
+}
+
+// encryptForDevices encrypts plaintext into a signed RSA envelope readable by each of the recipient's
+// devices and each of our other devices. The key is also wrapped for the recipient's public key, as
+// clients without device support only read CipherSymKey.
+func (u *User) encryptForDevices(
+	ctx context.Context,
+	message *openapi.Message,
+	plaintext types.PlainText,
+	recipientDevices []types.Device,
+	ownDevices []types.Device,
+) error {
 	symKey, err := cryptography.GenerateAESKey()
 	if err != nil {
-		return nil, fmt.Errorf("cryptography.GenerateAESKey: %w", err)
+		return fmt.Errorf("cryptography.GenerateAESKey: %w", err)
 	}
 	cipher, err := cryptography.NewAESCipher(symKey)
 	if err != nil {
-		return nil, fmt.Errorf("cryptography.NewAESCipher: %w", err)
+		return fmt.Errorf("cryptography.NewAESCipher: %w", err)
 	}
 	ciphertext, err := cipher.Encrypt(plaintext)
 	if err != nil {
-		return nil, fmt.Errorf("cipher.Encrypt: %w", err)
+		return fmt.Errorf("cipher.Encrypt: %w", err)
 	}
 
-	// Encrypt symmetric key with recipient's public key
-	cipheredSymKey, err := rsa.EncryptPKCS1v15(rand.Reader, recipient.PublicKey, symKey)
+	// The recipient's first device holds their public key
+	cipheredSymKey, err := cryptography.EncryptKey(recipientDevices[0].PublicKey, symKey)
 	if err != nil {
-		return nil, fmt.Errorf("rsa.EncryptPKCS1v15: %w", err)
+		return fmt.Errorf("cryptography.EncryptKey: %w", err)
+	}
+	keys := make([]openapi.DeviceKey, 0, len(recipientDevices)+len(ownDevices)-1)
+	for _, devices := range [][]types.Device{recipientDevices, ownDevices} {
+		for _, device := range devices {
+			if device.ID == u.deviceID {
+				continue
+			}
+			cipheredKey, err := cryptography.EncryptKey(device.PublicKey, symKey)
+			if err != nil {
+				return fmt.Errorf("cryptography.EncryptKey: %w", err)
+			}
+			keys = append(keys, openapi.DeviceKey{
+				Device:       device.ID,
+				CipherSymKey: cipheredKey,
+			})
+		}
 	}
 
-	return &openapi.Message{
-		Sender:       u.name,
-		Recipient:    recipientName,
-		CipherSymKey: cipheredSymKey,
-		Ciphertext:   ciphertext,
-	}, nil
+	version := types.MessageVersionOAEP
+	message.Version = &version
+	message.CipherSymKey = cipheredSymKey
+	message.Ciphertext = ciphertext
+	message.Keys = &keys
+
+	signature, err := cryptography.Sign(u.key, signaturePayload(message))
+	if err != nil {
+		return fmt.Errorf("cryptography.Sign: %w", err)
+	}
+	message.Signature = &signature
+	return nil
+}
+
+// startSessionFromPrekeys starts a session from a prekey bundle of the remote user,
+// unless they have not uploaded any prekeys or the server refuses more claims for now,
+// in which case the session is started by an offer.
+func (u *User) startSessionFromPrekeys(
+	ctx context.Context,
+	session *Session,
+	remoteName openapi.Username,
+) error {
+	var bundle *openapi.PrekeyBundle
+	err := u.withAuth(ctx, func(token string) error {
+		var err error
+		bundle, err = u.client.ClaimPrekeyBundle(ctx, token, remoteName)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) || errors.Is(err, types.ErrRateLimited) {
+			return nil
+		}
+		return fmt.Errorf("client.ClaimPrekeyBundle: %w", err)
+	}
+	remote, err := u.GetPublicUser(ctx, remoteName)
+	if err != nil {
+		return fmt.Errorf("GetPublicUser: %w", err)
+	}
+	err = session.startFromPrekeys(remote, bundle)
+	if err != nil {
+		return fmt.Errorf("session.startFromPrekeys: %w", err)
+	}
+	return nil
+}
+
+// signaturePayload returns the bytes covered by a message signature.
+// Sender and recipient are included so that a signed envelope cannot be replayed to another user,
+// the version (from MessageVersionOAEP on) so that it cannot be downgraded,
+// the session header, if any, so that offers, prekeys and ratchet keys cannot be swapped,
+// the sender device and per-device keys, if any, and the kind of content, unless a plain message.
+func signaturePayload(message *openapi.Message) []byte {
+	var payload []byte
+	if version := types.MessageVersion(message); version != types.MessageVersionPKCS1v15 {
+		payload = binary.BigEndian.AppendUint32(payload, uint32(version))
+	}
+	fields := [][]byte{
+		[]byte(message.Sender),
+		[]byte(message.Recipient),
+		message.CipherSymKey,
+		message.Ciphertext,
+	}
+	if header := message.Session; header != nil {
+		if header.OfferKey != nil {
+			fields = append(fields, []byte("offer"), *header.OfferKey)
+		}
+		if header.RatchetKey != nil {
+			fields = append(fields, []byte("ratchet"), *header.RatchetKey)
+		}
+		if header.PreviousChainLength != nil && header.MessageNumber != nil {
+			fields = append(fields,
+				binary.BigEndian.AppendUint32(nil, uint32(*header.PreviousChainLength)),
+				binary.BigEndian.AppendUint32(nil, uint32(*header.MessageNumber)),
+			)
+		}
+		if header.EphemeralKey != nil {
+			fields = append(fields, []byte("ephemeral"), *header.EphemeralKey)
+		}
+		if header.SignedPrekeyId != nil {
+			fields = append(fields, []byte("signed_prekey"), binary.BigEndian.AppendUint32(nil, uint32(*header.SignedPrekeyId)))
+		}
+		if header.OneTimePrekeyId != nil {
+			fields = append(fields, []byte("one_time_prekey"), binary.BigEndian.AppendUint32(nil, uint32(*header.OneTimePrekeyId)))
+		}
+	}
+	if message.SenderDevice != nil {
+		fields = append(fields, []byte("sender_device"), []byte(*message.SenderDevice))
+	}
+	if message.Keys != nil {
+		for _, key := range *message.Keys {
+			fields = append(fields, []byte("key"), []byte(key.Device), key.CipherSymKey)
+		}
+	}
+	if message.Kind != nil && *message.Kind != openapi.MessageKindMessage {
+		fields = append(fields, []byte("kind"), []byte(*message.Kind))
+	}
+	for _, field := range fields {
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(field)))
+		payload = append(payload, field...)
+	}
+	return payload
 }
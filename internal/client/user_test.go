@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marc921/talk/internal/client/database"
+	"github.com/marc921/talk/internal/client/database/sqlcgen"
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// newTestUser adds a local user to the database, known to itself and others as a public user.
+func newTestUser(t *testing.T, db *sql.DB, name openapi.Username) *User {
+	t.Helper()
+	ctx := context.Background()
+	key, err := cryptography.GenerateKey()
+	if err != nil {
+		t.Fatalf("cryptography.GenerateKey: %v", err)
+	}
+	queries := sqlcgen.New(db)
+	_, err = queries.InsertLocalUser(ctx, sqlcgen.InsertLocalUserParams{
+		Name:       name,
+		PrivateKey: cryptography.MarshalPrivateKey(key),
+	})
+	if err != nil {
+		t.Fatalf("queries.InsertLocalUser: %v", err)
+	}
+	_, err = queries.InsertPublicUser(ctx, sqlcgen.InsertPublicUserParams{
+		Name:      name,
+		PublicKey: cryptography.MarshalPublicKey(&key.PublicKey),
+	})
+	if err != nil {
+		t.Fatalf("queries.InsertPublicUser: %v", err)
+	}
+	secrets, err := NewLocalSecrets(&Keyring{key: make([]byte, 32)})
+	if err != nil {
+		t.Fatalf("NewLocalSecrets: %v", err)
+	}
+	return NewUser(name, key, &MessageHistory{}, secrets, nil, db)
+}
+
+// encryptForTest wraps a message in an RSA-OAEP envelope for the recipient's key.
+func encryptForTest(t *testing.T, recipientKey *rsa.PublicKey, plaintext []byte) ([]byte, []byte) {
+	t.Helper()
+	symKey, err := cryptography.GenerateAESKey()
+	if err != nil {
+		t.Fatalf("cryptography.GenerateAESKey: %v", err)
+	}
+	cipher, err := cryptography.NewAESCipher(symKey)
+	if err != nil {
+		t.Fatalf("cryptography.NewAESCipher: %v", err)
+	}
+	ciphertext, err := cipher.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("cipher.Encrypt: %v", err)
+	}
+	cipherSymKey, err := cryptography.EncryptKey(recipientKey, symKey)
+	if err != nil {
+		t.Fatalf("cryptography.EncryptKey: %v", err)
+	}
+	return ciphertext, cipherSymKey
+}
+
+func TestReceiveMessageBetweenLocalUsers(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.CreateSQLite3DB(filepath.Join(t.TempDir(), "talk.db"))
+	if err != nil {
+		t.Fatalf("database.CreateSQLite3DB: %v", err)
+	}
+	defer db.Close()
+	alice := newTestUser(t, db, "alice")
+	bob := newTestUser(t, db, "bob")
+	queries := sqlcgen.New(db)
+
+	// Alice sent the message, and stored her copy under the ID given by the server
+	serverID := "5b6f1a0e-8a34-4a35-9f4e-2f1f0d7f3c21"
+	err = alice.CreateConversation(ctx, bob.name)
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	_, err = alice.history.insertMessage(ctx, queries, sqlcgen.InsertMessageParams{
+		ConversationID: alice.conversations[bob.name].dbConv.ID,
+		Sender:         alice.name,
+		Receiver:       bob.name,
+		Content:        []byte("hello"),
+		ServerID:       sql.NullString{String: serverID, Valid: true},
+		SentAt:         sql.NullTime{Time: time.Now(), Valid: true},
+		Kind:           string(openapi.MessageKindMessage),
+	})
+	if err != nil {
+		t.Fatalf("history.insertMessage: %v", err)
+	}
+
+	ciphertext, cipherSymKey := encryptForTest(t, &bob.key.PublicKey, []byte("hello"))
+	version := types.MessageVersionOAEP
+	message := openapi.Message{
+		Id:           &serverID,
+		Sender:       alice.name,
+		Recipient:    bob.name,
+		Version:      &version,
+		Ciphertext:   ciphertext,
+		CipherSymKey: cipherSymKey,
+	}
+	dbMessage, err := bob.receiveMessage(ctx, queries, message)
+	if err != nil {
+		t.Fatalf("receiveMessage: %v", err)
+	}
+	if string(dbMessage.Content) != "hello" {
+		t.Errorf("received %q, want %q", dbMessage.Content, "hello")
+	}
+	if dbMessage.ConversationID != bob.conversations[alice.name].dbConv.ID {
+		t.Errorf("received in conversation %d, want %d", dbMessage.ConversationID, bob.conversations[alice.name].dbConv.ID)
+	}
+
+	// The message delivered again is recognized, in Bob's conversation only
+	_, err = bob.receiveMessage(ctx, queries, message)
+	if !errors.Is(err, errAlreadyReceived) {
+		t.Errorf("receiveMessage again: got %v, want %v", err, errAlreadyReceived)
+	}
+	for _, user := range []*User{alice, bob} {
+		for _, conversation := range user.conversations {
+			messages, err := queries.ListMessages(ctx, conversation.dbConv.ID)
+			if err != nil {
+				t.Fatalf("queries.ListMessages: %v", err)
+			}
+			if len(messages) != 1 {
+				t.Errorf("%s has %d messages, want 1", user.name, len(messages))
+			}
+		}
+	}
+}
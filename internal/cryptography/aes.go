@@ -41,22 +41,33 @@ func NewAESCipher(key []byte) (*AESCipher, error) {
 }
 
 func (c *AESCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	return c.EncryptWithAD(plaintext, nil)
+}
+
+func (c *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	return c.DecryptWithAD(ciphertext, nil)
+}
+
+// EncryptWithAD encrypts plaintext and authenticates it along with the additional data,
+// which is not included in the ciphertext.
+func (c *AESCipher) EncryptWithAD(plaintext []byte, additionalData []byte) ([]byte, error) {
 	nonce := make([]byte, c.gcm.NonceSize())
 	if _, err := rand.Read(nonce); err != nil {
 		return nil, fmt.Errorf("rand.Read: %w", err)
 	}
 
-	return c.gcm.Seal(nonce, nonce, plaintext, nil), nil
+	return c.gcm.Seal(nonce, nonce, plaintext, additionalData), nil
 }
 
-func (c *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+// DecryptWithAD decrypts a ciphertext produced by EncryptWithAD with the same additional data.
+func (c *AESCipher) DecryptWithAD(ciphertext []byte, additionalData []byte) ([]byte, error) {
 	nonceSize := c.gcm.NonceSize()
 	if len(ciphertext) < nonceSize {
 		return nil, fmt.Errorf("ciphertext too short")
 	}
 
 	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	plaintext, err := c.gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := c.gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, fmt.Errorf("gcm.Open: %w", err)
 	}
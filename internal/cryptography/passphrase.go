@@ -0,0 +1,100 @@
+package cryptography
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+const encryptedPrivateKeyType = "ENCRYPTED RSA PRIVATE KEY"
+
+// KDFParams are the Argon2id parameters used to derive a key from a passphrase.
+type KDFParams struct {
+	Salt    []byte
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// NewKDFParams returns parameters with a random salt, following the RFC 9106 recommendations
+// for memory-constrained environments.
+func NewKDFParams() (*KDFParams, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("rand.Read: %w", err)
+	}
+	return &KDFParams{
+		Salt:    salt,
+		Time:    3,
+		Memory:  64 * 1024,
+		Threads: 4,
+	}, nil
+}
+
+// DeriveKey derives an AES key from a passphrase with Argon2id.
+func DeriveKey(passphrase []byte, params *KDFParams) []byte {
+	return argon2.IDKey(passphrase, params.Salt, params.Time, params.Memory, params.Threads, AESKeySize)
+}
+
+// DeriveSubkey derives an AES key for a given purpose from a key derived with DeriveKey,
+// so that the passphrase key is never used for more than one purpose.
+func DeriveSubkey(key []byte, purpose string) ([]byte, error) {
+	reader := hkdf.New(sha256.New, key, nil, []byte(purpose))
+	subkey := make([]byte, AESKeySize)
+	if _, err := io.ReadFull(reader, subkey); err != nil {
+		return nil, fmt.Errorf("io.ReadFull: %w", err)
+	}
+	return subkey, nil
+}
+
+// EncryptPrivateKey encrypts a private key with AES-GCM, bound to the name of its owner,
+// and returns it PEM encoded.
+func EncryptPrivateKey(key []byte, privateKey *rsa.PrivateKey, name string) ([]byte, error) {
+	aesCipher, err := NewAESCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("NewAESCipher: %w", err)
+	}
+	ciphertext, err := aesCipher.EncryptWithAD(x509.MarshalPKCS1PrivateKey(privateKey), []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("aesCipher.EncryptWithAD: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  encryptedPrivateKeyType,
+		Bytes: ciphertext,
+	}), nil
+}
+
+// DecryptPrivateKey decrypts a private key produced by EncryptPrivateKey.
+func DecryptPrivateKey(key []byte, pemBytes []byte, name string) (*rsa.PrivateKey, error) {
+	privateKeyBlock, _ := pem.Decode(pemBytes)
+	if privateKeyBlock == nil || privateKeyBlock.Type != encryptedPrivateKeyType {
+		return nil, fmt.Errorf("pem.Decode: no encrypted key found")
+	}
+	aesCipher, err := NewAESCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("NewAESCipher: %w", err)
+	}
+	privateKeyBytes, err := aesCipher.DecryptWithAD(privateKeyBlock.Bytes, []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("aesCipher.DecryptWithAD: %w", err)
+	}
+	privateKey, err := x509.ParsePKCS1PrivateKey(privateKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("x509.ParsePKCS1PrivateKey: %w", err)
+	}
+	return privateKey, nil
+}
+
+// IsEncryptedPrivateKey reports whether a PEM encoded private key was produced by EncryptPrivateKey,
+// rather than MarshalPrivateKey.
+func IsEncryptedPrivateKey(pemBytes []byte) bool {
+	privateKeyBlock, _ := pem.Decode(pemBytes)
+	return privateKeyBlock != nil && privateKeyBlock.Type == encryptedPrivateKeyType
+}
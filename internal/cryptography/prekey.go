@@ -0,0 +1,70 @@
+package cryptography
+
+import (
+	"crypto/ecdh"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// ValidateX25519PublicKey checks that a raw encoded X25519 public key is well-formed.
+func ValidateX25519PublicKey(publicKeyBytes []byte) error {
+	_, err := ecdh.X25519().NewPublicKey(publicKeyBytes)
+	if err != nil {
+		return fmt.Errorf("ecdh.X25519().NewPublicKey: %w", err)
+	}
+	return nil
+}
+
+// InitiatePrekeySecret derives the shared secret of a session started from the remote user's prekeys,
+// from our ephemeral private key, their signed prekey and their one-time prekey, which may be nil.
+func InitiatePrekeySecret(ephemeralKey []byte, signedPrekey []byte, oneTimePrekey []byte) ([]byte, error) {
+	dhSigned, err := X25519(ephemeralKey, signedPrekey)
+	if err != nil {
+		return nil, fmt.Errorf("X25519: %w", err)
+	}
+	var dhOneTime []byte
+	if oneTimePrekey != nil {
+		dhOneTime, err = X25519(ephemeralKey, oneTimePrekey)
+		if err != nil {
+			return nil, fmt.Errorf("X25519: %w", err)
+		}
+	}
+	return kdfPrekeySecret(dhSigned, dhOneTime)
+}
+
+// RespondPrekeySecret derives the shared secret of a session the remote user started from our prekeys,
+// from our signed prekey and one-time prekey private keys, the latter possibly nil, and their ephemeral key.
+func RespondPrekeySecret(signedPrekey []byte, oneTimePrekey []byte, ephemeralKey []byte) ([]byte, error) {
+	dhSigned, err := X25519(signedPrekey, ephemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("X25519: %w", err)
+	}
+	var dhOneTime []byte
+	if oneTimePrekey != nil {
+		dhOneTime, err = X25519(oneTimePrekey, ephemeralKey)
+		if err != nil {
+			return nil, fmt.Errorf("X25519: %w", err)
+		}
+	}
+	return kdfPrekeySecret(dhSigned, dhOneTime)
+}
+
+// kdfPrekeySecret derives the session shared secret from the prekey DH outputs.
+//
+// Unlike X3DH, the secret mixes no DH with either user's identity key: identity keys are RSA keys,
+// which cannot take part in a DH. The secret alone thus authenticates neither user. The responder is
+// authenticated by the RSA signature of its signed prekey, checked by the initiator before deriving the
+// secret, and the initiator by the RSA signature of the message carrying the ephemeral key, without
+// which the responder refuses to start the session. A forged or unsigned first message must therefore
+// never start a session.
+func kdfPrekeySecret(dhSigned []byte, dhOneTime []byte) ([]byte, error) {
+	reader := hkdf.New(sha256.New, append(dhSigned, dhOneTime...), nil, []byte("talk prekey"))
+	secret := make([]byte, AESKeySize)
+	if _, err := io.ReadFull(reader, secret); err != nil {
+		return nil, fmt.Errorf("io.ReadFull: %w", err)
+	}
+	return secret, nil
+}
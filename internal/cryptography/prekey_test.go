@@ -0,0 +1,69 @@
+package cryptography
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrekeySecretAgreement(t *testing.T) {
+	tests := []struct {
+		name    string
+		oneTime bool
+	}{
+		{name: "signed prekey only", oneTime: false},
+		{name: "with one-time prekey", oneTime: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signedPrekey, err := GenerateX25519Key()
+			if err != nil {
+				t.Fatalf("GenerateX25519Key: %v", err)
+			}
+			ephemeralKey, err := GenerateX25519Key()
+			if err != nil {
+				t.Fatalf("GenerateX25519Key: %v", err)
+			}
+			var oneTimePrivate, oneTimePublic []byte
+			if tt.oneTime {
+				oneTimePrekey, err := GenerateX25519Key()
+				if err != nil {
+					t.Fatalf("GenerateX25519Key: %v", err)
+				}
+				oneTimePrivate, oneTimePublic = oneTimePrekey.Bytes(), oneTimePrekey.PublicKey().Bytes()
+			}
+
+			initiated, err := InitiatePrekeySecret(ephemeralKey.Bytes(), signedPrekey.PublicKey().Bytes(), oneTimePublic)
+			if err != nil {
+				t.Fatalf("InitiatePrekeySecret: %v", err)
+			}
+			responded, err := RespondPrekeySecret(signedPrekey.Bytes(), oneTimePrivate, ephemeralKey.PublicKey().Bytes())
+			if err != nil {
+				t.Fatalf("RespondPrekeySecret: %v", err)
+			}
+			if !bytes.Equal(initiated, responded) {
+				t.Fatal("initiator and responder derived different secrets")
+			}
+			if len(initiated) != AESKeySize {
+				t.Errorf("len(secret) = %d, want %d", len(initiated), AESKeySize)
+			}
+
+			// A responder missing the one-time prekey, or using another one, derives another secret
+			otherOneTime, err := GenerateX25519Key()
+			if err != nil {
+				t.Fatalf("GenerateX25519Key: %v", err)
+			}
+			for _, wrongOneTime := range [][]byte{nil, otherOneTime.Bytes()} {
+				if wrongOneTime == nil && !tt.oneTime {
+					continue
+				}
+				wrong, err := RespondPrekeySecret(signedPrekey.Bytes(), wrongOneTime, ephemeralKey.PublicKey().Bytes())
+				if err != nil {
+					t.Fatalf("RespondPrekeySecret: %v", err)
+				}
+				if bytes.Equal(initiated, wrong) {
+					t.Error("responder derived the same secret with the wrong one-time prekey")
+				}
+			}
+		})
+	}
+}
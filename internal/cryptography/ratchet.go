@@ -0,0 +1,323 @@
+package cryptography
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// MaxSkippedMessageKeys bounds the number of message keys kept for out-of-order messages,
+// so that a malicious peer cannot make us derive an unbounded number of keys. Beyond it, the keys
+// of the messages skipped first are dropped, and those messages can no longer be decrypted.
+const MaxSkippedMessageKeys = 1000
+
+var ErrTooManySkippedMessages = errors.New("too many skipped messages")
+
+// GenerateX25519Key generates a new X25519 key pair.
+func GenerateX25519Key() (*ecdh.PrivateKey, error) {
+	privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("ecdh.X25519().GenerateKey: %w", err)
+	}
+	return privateKey, nil
+}
+
+// X25519 computes the shared secret between a private key and a public key, both raw encoded.
+func X25519(privateKeyBytes []byte, publicKeyBytes []byte) ([]byte, error) {
+	privateKey, err := ecdh.X25519().NewPrivateKey(privateKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("ecdh.X25519().NewPrivateKey: %w", err)
+	}
+	publicKey, err := ecdh.X25519().NewPublicKey(publicKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("ecdh.X25519().NewPublicKey: %w", err)
+	}
+	secret, err := privateKey.ECDH(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("privateKey.ECDH: %w", err)
+	}
+	return secret, nil
+}
+
+// RatchetHeader is sent in clear along with every ratchet message.
+type RatchetHeader struct {
+	// PublicKey is the sender's current ratchet public key.
+	PublicKey []byte
+	// PreviousChainLength is the number of messages in the sender's previous sending chain.
+	PreviousChainLength uint32
+	// MessageNumber is the index of the message in the current sending chain.
+	MessageNumber uint32
+}
+
+// Bytes encodes the header, to be authenticated as additional data.
+func (h *RatchetHeader) Bytes() []byte {
+	b := make([]byte, 0, len(h.PublicKey)+8)
+	b = append(b, h.PublicKey...)
+	b = binary.BigEndian.AppendUint32(b, h.PreviousChainLength)
+	b = binary.BigEndian.AppendUint32(b, h.MessageNumber)
+	return b
+}
+
+// Ratchet implements the Double Ratchet algorithm over X25519, HKDF-SHA256 and AES-GCM.
+// Every message is encrypted with a fresh key, and keys are erased once used, so that
+// compromising the current state does not reveal past messages.
+//
+// Fields are exported so that the state can be persisted as JSON between messages.
+type Ratchet struct {
+	// DHPrivateKey is our current ratchet private key.
+	DHPrivateKey []byte
+	// DHRemoteKey is the remote user's current ratchet public key, nil until known.
+	DHRemoteKey       []byte
+	RootKey           []byte
+	SendingChainKey   []byte
+	ReceivingChainKey []byte
+	SendingCount      uint32
+	ReceivingCount    uint32
+	// PreviousSendingCount is the number of messages sent in the previous sending chain.
+	PreviousSendingCount uint32
+	// SkippedKeys holds the message keys of messages not received yet, indexed by skippedKeyID.
+	SkippedKeys map[string][]byte
+	// SkippedKeyOrder lists the IDs of SkippedKeys from the oldest, to drop the oldest first.
+	SkippedKeyOrder []string
+}
+
+// NewInitiatorRatchet starts a ratchet with a shared secret agreed with the remote user,
+// and the remote user's ratchet public key. The initiator is the first to send.
+func NewInitiatorRatchet(sharedSecret []byte, remotePublicKey []byte) (*Ratchet, error) {
+	dhKey, err := GenerateX25519Key()
+	if err != nil {
+		return nil, fmt.Errorf("GenerateX25519Key: %w", err)
+	}
+	r := &Ratchet{
+		DHPrivateKey: dhKey.Bytes(),
+		DHRemoteKey:  remotePublicKey,
+		SkippedKeys:  make(map[string][]byte),
+	}
+	dhOutput, err := X25519(r.DHPrivateKey, r.DHRemoteKey)
+	if err != nil {
+		return nil, fmt.Errorf("X25519: %w", err)
+	}
+	r.RootKey, r.SendingChainKey, err = kdfRootKey(sharedSecret, dhOutput)
+	if err != nil {
+		return nil, fmt.Errorf("kdfRootKey: %w", err)
+	}
+	return r, nil
+}
+
+// NewResponderRatchet starts a ratchet with a shared secret agreed with the remote user,
+// and the private key matching the ratchet public key the initiator was given.
+func NewResponderRatchet(sharedSecret []byte, localPrivateKey []byte) *Ratchet {
+	return &Ratchet{
+		DHPrivateKey: localPrivateKey,
+		RootKey:      sharedSecret,
+		SkippedKeys:  make(map[string][]byte),
+	}
+}
+
+// Encrypt encrypts plaintext with the next sending message key.
+func (r *Ratchet) Encrypt(plaintext []byte) (*RatchetHeader, []byte, error) {
+	if r.SendingChainKey == nil {
+		// We have not received anything since our last ratchet step, start a new sending chain
+		err := r.stepSendingChain()
+		if err != nil {
+			return nil, nil, fmt.Errorf("stepSendingChain: %w", err)
+		}
+	}
+	publicKey, err := X25519PublicKey(r.DHPrivateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("X25519PublicKey: %w", err)
+	}
+	var messageKey []byte
+	r.SendingChainKey, messageKey = kdfChainKey(r.SendingChainKey)
+	header := &RatchetHeader{
+		PublicKey:           publicKey,
+		PreviousChainLength: r.PreviousSendingCount,
+		MessageNumber:       r.SendingCount,
+	}
+	r.SendingCount++
+
+	cipher, err := NewAESCipher(messageKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("NewAESCipher: %w", err)
+	}
+	ciphertext, err := cipher.EncryptWithAD(plaintext, header.Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("cipher.EncryptWithAD: %w", err)
+	}
+	return header, ciphertext, nil
+}
+
+// Decrypt decrypts a message sent by the remote ratchet.
+// The state is left untouched if the message cannot be decrypted.
+func (r *Ratchet) Decrypt(header *RatchetHeader, ciphertext []byte) ([]byte, error) {
+	next := r.clone()
+	messageKey, err := next.receivingMessageKey(header)
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := NewAESCipher(messageKey)
+	if err != nil {
+		return nil, fmt.Errorf("NewAESCipher: %w", err)
+	}
+	plaintext, err := cipher.DecryptWithAD(ciphertext, header.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("cipher.DecryptWithAD: %w", err)
+	}
+	*r = *next
+	return plaintext, nil
+}
+
+func (r *Ratchet) receivingMessageKey(header *RatchetHeader) ([]byte, error) {
+	id := skippedKeyID(header.PublicKey, header.MessageNumber)
+	if messageKey, ok := r.SkippedKeys[id]; ok {
+		delete(r.SkippedKeys, id)
+		r.SkippedKeyOrder = slices.DeleteFunc(r.SkippedKeyOrder, func(skipped string) bool {
+			return skipped == id
+		})
+		return messageKey, nil
+	}
+	if !bytes.Equal(header.PublicKey, r.DHRemoteKey) {
+		// The remote user started a new sending chain
+		err := r.skipMessageKeys(header.PreviousChainLength)
+		if err != nil {
+			return nil, fmt.Errorf("skipMessageKeys: %w", err)
+		}
+		err = r.stepReceivingChain(header.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("stepReceivingChain: %w", err)
+		}
+	}
+	err := r.skipMessageKeys(header.MessageNumber)
+	if err != nil {
+		return nil, fmt.Errorf("skipMessageKeys: %w", err)
+	}
+	var messageKey []byte
+	r.ReceivingChainKey, messageKey = kdfChainKey(r.ReceivingChainKey)
+	r.ReceivingCount++
+	return messageKey, nil
+}
+
+// skipMessageKeys stores the receiving message keys up to (excluding) until, dropping the oldest
+// keys stored beyond MaxSkippedMessageKeys.
+func (r *Ratchet) skipMessageKeys(until uint32) error {
+	if r.ReceivingChainKey == nil {
+		return nil
+	}
+	if until > r.ReceivingCount+MaxSkippedMessageKeys {
+		return ErrTooManySkippedMessages
+	}
+	for r.ReceivingCount < until {
+		var messageKey []byte
+		r.ReceivingChainKey, messageKey = kdfChainKey(r.ReceivingChainKey)
+		id := skippedKeyID(r.DHRemoteKey, r.ReceivingCount)
+		r.SkippedKeys[id] = messageKey
+		r.SkippedKeyOrder = append(r.SkippedKeyOrder, id)
+		r.ReceivingCount++
+	}
+	for len(r.SkippedKeys) > MaxSkippedMessageKeys && len(r.SkippedKeyOrder) > 0 {
+		delete(r.SkippedKeys, r.SkippedKeyOrder[0])
+		r.SkippedKeyOrder = r.SkippedKeyOrder[1:]
+	}
+	// Keys stored before SkippedKeyOrder was persisted are of unknown age
+	for id := range r.SkippedKeys {
+		if len(r.SkippedKeys) <= MaxSkippedMessageKeys {
+			break
+		}
+		delete(r.SkippedKeys, id)
+	}
+	return nil
+}
+
+// stepReceivingChain derives the receiving chain for a new remote ratchet key.
+// Our own sending chain is reset and will be derived from a new key pair on the next send.
+func (r *Ratchet) stepReceivingChain(remotePublicKey []byte) error {
+	r.DHRemoteKey = remotePublicKey
+	dhOutput, err := X25519(r.DHPrivateKey, r.DHRemoteKey)
+	if err != nil {
+		return fmt.Errorf("X25519: %w", err)
+	}
+	r.RootKey, r.ReceivingChainKey, err = kdfRootKey(r.RootKey, dhOutput)
+	if err != nil {
+		return fmt.Errorf("kdfRootKey: %w", err)
+	}
+	r.ReceivingCount = 0
+	r.PreviousSendingCount = r.SendingCount
+	r.SendingCount = 0
+	r.SendingChainKey = nil
+	return nil
+}
+
+// stepSendingChain generates a new ratchet key pair and derives a new sending chain from it.
+func (r *Ratchet) stepSendingChain() error {
+	if r.DHRemoteKey == nil {
+		return errors.New("remote ratchet key unknown")
+	}
+	dhKey, err := GenerateX25519Key()
+	if err != nil {
+		return fmt.Errorf("GenerateX25519Key: %w", err)
+	}
+	r.DHPrivateKey = dhKey.Bytes()
+	dhOutput, err := X25519(r.DHPrivateKey, r.DHRemoteKey)
+	if err != nil {
+		return fmt.Errorf("X25519: %w", err)
+	}
+	r.RootKey, r.SendingChainKey, err = kdfRootKey(r.RootKey, dhOutput)
+	if err != nil {
+		return fmt.Errorf("kdfRootKey: %w", err)
+	}
+	return nil
+}
+
+func (r *Ratchet) clone() *Ratchet {
+	c := *r
+	c.SkippedKeys = make(map[string][]byte, len(r.SkippedKeys))
+	for id, key := range r.SkippedKeys {
+		c.SkippedKeys[id] = key
+	}
+	c.SkippedKeyOrder = slices.Clone(r.SkippedKeyOrder)
+	return &c
+}
+
+// X25519PublicKey returns the raw public key matching a raw X25519 private key.
+func X25519PublicKey(privateKeyBytes []byte) ([]byte, error) {
+	privateKey, err := ecdh.X25519().NewPrivateKey(privateKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("ecdh.X25519().NewPrivateKey: %w", err)
+	}
+	return privateKey.PublicKey().Bytes(), nil
+}
+
+func skippedKeyID(publicKey []byte, messageNumber uint32) string {
+	return fmt.Sprintf("%s:%d", base64.StdEncoding.EncodeToString(publicKey), messageNumber)
+}
+
+// kdfRootKey derives a new root key and chain key from the current root key and a DH output.
+func kdfRootKey(rootKey []byte, dhOutput []byte) ([]byte, []byte, error) {
+	reader := hkdf.New(sha256.New, dhOutput, rootKey, []byte("talk ratchet root"))
+	keys := make([]byte, 2*AESKeySize)
+	if _, err := io.ReadFull(reader, keys); err != nil {
+		return nil, nil, fmt.Errorf("io.ReadFull: %w", err)
+	}
+	return keys[:AESKeySize], keys[AESKeySize:], nil
+}
+
+// kdfChainKey derives the next chain key and a message key from the current chain key.
+func kdfChainKey(chainKey []byte) ([]byte, []byte) {
+	mac := hmac.New(sha256.New, chainKey)
+	mac.Write([]byte{0x02})
+	nextChainKey := mac.Sum(nil)
+	mac = hmac.New(sha256.New, chainKey)
+	mac.Write([]byte{0x01})
+	messageKey := mac.Sum(nil)
+	return nextChainKey, messageKey
+}
@@ -0,0 +1,290 @@
+package cryptography
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// newTestRatchets starts the ratchets of an initiator and a responder sharing a secret.
+func newTestRatchets(t *testing.T) (*Ratchet, *Ratchet) {
+	t.Helper()
+	sharedSecret := make([]byte, 32)
+	_, err := rand.Read(sharedSecret)
+	if err != nil {
+		t.Fatalf("rand.Read: %v", err)
+	}
+	responderKey, err := GenerateX25519Key()
+	if err != nil {
+		t.Fatalf("GenerateX25519Key: %v", err)
+	}
+	initiator, err := NewInitiatorRatchet(sharedSecret, responderKey.PublicKey().Bytes())
+	if err != nil {
+		t.Fatalf("NewInitiatorRatchet: %v", err)
+	}
+	return initiator, NewResponderRatchet(sharedSecret, responderKey.Bytes())
+}
+
+// persist round trips a ratchet through JSON, as done between messages.
+func persist(t *testing.T, r *Ratchet) *Ratchet {
+	t.Helper()
+	state, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	var persisted Ratchet
+	err = json.Unmarshal(state, &persisted)
+	if err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	return &persisted
+}
+
+type ratchetMessage struct {
+	header     *RatchetHeader
+	ciphertext []byte
+	plaintext  []byte
+}
+
+func encryptTestMessage(t *testing.T, r *Ratchet, plaintext string) ratchetMessage {
+	t.Helper()
+	header, ciphertext, err := r.Encrypt([]byte(plaintext))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	return ratchetMessage{header: header, ciphertext: ciphertext, plaintext: []byte(plaintext)}
+}
+
+func decryptTestMessage(t *testing.T, r *Ratchet, message ratchetMessage) {
+	t.Helper()
+	plaintext, err := r.Decrypt(message.header, message.ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt(%q): %v", message.plaintext, err)
+	}
+	if !bytes.Equal(plaintext, message.plaintext) {
+		t.Fatalf("Decrypt = %q, want %q", plaintext, message.plaintext)
+	}
+}
+
+// ratchetEvent either sends the message numbered msg from one side, or delivers it to the other.
+type ratchetEvent struct {
+	fromInitiator bool
+	send          bool
+	msg           int
+}
+
+func send(fromInitiator bool, msg int) ratchetEvent {
+	return ratchetEvent{fromInitiator: fromInitiator, send: true, msg: msg}
+}
+
+func deliver(fromInitiator bool, msg int) ratchetEvent {
+	return ratchetEvent{fromInitiator: fromInitiator, msg: msg}
+}
+
+func TestRatchetConversation(t *testing.T) {
+	const initiator, responder = true, false
+	tests := []struct {
+		name   string
+		events []ratchetEvent
+	}{
+		{
+			name: "in order",
+			events: []ratchetEvent{
+				send(initiator, 0), deliver(initiator, 0),
+				send(initiator, 1), deliver(initiator, 1),
+				send(responder, 2), deliver(responder, 2),
+				send(initiator, 3), deliver(initiator, 3),
+				send(responder, 4), send(responder, 5), deliver(responder, 4), deliver(responder, 5),
+			},
+		},
+		{
+			name: "out of order within a chain",
+			events: []ratchetEvent{
+				send(initiator, 0), send(initiator, 1), send(initiator, 2), send(initiator, 3),
+				deliver(initiator, 2), deliver(initiator, 0), deliver(initiator, 3), deliver(initiator, 1),
+			},
+		},
+		{
+			name: "out of order across a DH step",
+			events: []ratchetEvent{
+				send(initiator, 0), send(initiator, 1), send(initiator, 2),
+				deliver(initiator, 0),
+				send(responder, 3), deliver(responder, 3),
+				send(initiator, 4), send(initiator, 5),
+				deliver(initiator, 5), deliver(initiator, 2), deliver(initiator, 4), deliver(initiator, 1),
+			},
+		},
+		{
+			name: "both sides sending at once",
+			events: []ratchetEvent{
+				send(initiator, 0), deliver(initiator, 0),
+				send(initiator, 1), send(responder, 2),
+				deliver(responder, 2), deliver(initiator, 1),
+				send(initiator, 3), send(responder, 4),
+				deliver(initiator, 3), deliver(responder, 4),
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, persisted := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/persisted=%t", tt.name, persisted), func(t *testing.T) {
+				initiatorRatchet, responderRatchet := newTestRatchets(t)
+				messages := make(map[int]ratchetMessage)
+				for _, event := range tt.events {
+					sender, recipient := &initiatorRatchet, &responderRatchet
+					if !event.fromInitiator {
+						sender, recipient = recipient, sender
+					}
+					if event.send {
+						messages[event.msg] = encryptTestMessage(t, *sender, fmt.Sprintf("message %d", event.msg))
+					} else {
+						decryptTestMessage(t, *recipient, messages[event.msg])
+					}
+					if persisted {
+						initiatorRatchet = persist(t, initiatorRatchet)
+						responderRatchet = persist(t, responderRatchet)
+					}
+				}
+				if len(initiatorRatchet.SkippedKeys) != 0 || len(responderRatchet.SkippedKeys) != 0 {
+					t.Errorf("skipped keys left after delivering every message")
+				}
+			})
+		}
+	}
+}
+
+func TestRatchetReplay(t *testing.T) {
+	initiator, responder := newTestRatchets(t)
+	first := encryptTestMessage(t, initiator, "first")
+	second := encryptTestMessage(t, initiator, "second")
+	decryptTestMessage(t, responder, second)
+	decryptTestMessage(t, responder, first)
+	for _, message := range []ratchetMessage{first, second} {
+		_, err := responder.Decrypt(message.header, message.ciphertext)
+		if err == nil {
+			t.Errorf("Decrypt(%q) replayed without error", message.plaintext)
+		}
+	}
+}
+
+func TestRatchetTampered(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(message *ratchetMessage)
+	}{
+		{
+			name: "ciphertext",
+			tamper: func(message *ratchetMessage) {
+				message.ciphertext[len(message.ciphertext)-1] ^= 1
+			},
+		},
+		{
+			name: "message number",
+			tamper: func(message *ratchetMessage) {
+				message.header.MessageNumber += 5
+			},
+		},
+		{
+			name: "previous chain length",
+			tamper: func(message *ratchetMessage) {
+				message.header.PreviousChainLength++
+			},
+		},
+		{
+			name: "public key",
+			tamper: func(message *ratchetMessage) {
+				key, err := GenerateX25519Key()
+				if err != nil {
+					t.Fatalf("GenerateX25519Key: %v", err)
+				}
+				message.header.PublicKey = key.PublicKey().Bytes()
+			},
+		},
+		{
+			name: "too many skipped messages",
+			tamper: func(message *ratchetMessage) {
+				message.header.MessageNumber = MaxSkippedMessageKeys + 10
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initiator, responder := newTestRatchets(t)
+			// Go through a DH step, so that the responder has a receiving chain and skipped keys
+			decryptTestMessage(t, responder, encryptTestMessage(t, initiator, "hello"))
+			decryptTestMessage(t, initiator, encryptTestMessage(t, responder, "hi"))
+			skipped := encryptTestMessage(t, initiator, "skipped")
+			decryptTestMessage(t, responder, encryptTestMessage(t, initiator, "after skipped"))
+
+			message := encryptTestMessage(t, initiator, "genuine")
+			tampered := ratchetMessage{
+				header: &RatchetHeader{
+					PublicKey:           bytes.Clone(message.header.PublicKey),
+					PreviousChainLength: message.header.PreviousChainLength,
+					MessageNumber:       message.header.MessageNumber,
+				},
+				ciphertext: bytes.Clone(message.ciphertext),
+			}
+			tt.tamper(&tampered)
+
+			before, err := json.Marshal(responder)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			_, err = responder.Decrypt(tampered.header, tampered.ciphertext)
+			if err == nil {
+				t.Fatal("Decrypt of a tampered message succeeded")
+			}
+			after, err := json.Marshal(responder)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			if !bytes.Equal(before, after) {
+				t.Fatal("Decrypt of a tampered message changed the state")
+			}
+			decryptTestMessage(t, responder, message)
+			decryptTestMessage(t, responder, skipped)
+		})
+	}
+}
+
+func TestRatchetSkippedKeysBound(t *testing.T) {
+	initiator, responder := newTestRatchets(t)
+	messages := make([]ratchetMessage, MaxSkippedMessageKeys+20)
+	for i := range messages {
+		messages[i] = encryptTestMessage(t, initiator, fmt.Sprintf("message %d", i))
+	}
+
+	_, err := responder.Decrypt(messages[MaxSkippedMessageKeys+1].header, messages[MaxSkippedMessageKeys+1].ciphertext)
+	if !errors.Is(err, ErrTooManySkippedMessages) {
+		t.Fatalf("Decrypt beyond MaxSkippedMessageKeys: got %v, want %v", err, ErrTooManySkippedMessages)
+	}
+
+	// Skip exactly MaxSkippedMessageKeys messages, then 9 more: the 9 oldest keys are dropped
+	decryptTestMessage(t, responder, messages[MaxSkippedMessageKeys])
+	decryptTestMessage(t, responder, messages[MaxSkippedMessageKeys+10])
+	if len(responder.SkippedKeys) != MaxSkippedMessageKeys {
+		t.Fatalf("len(SkippedKeys) = %d, want %d", len(responder.SkippedKeys), MaxSkippedMessageKeys)
+	}
+	if len(responder.SkippedKeyOrder) != len(responder.SkippedKeys) {
+		t.Fatalf("len(SkippedKeyOrder) = %d, want %d", len(responder.SkippedKeyOrder), len(responder.SkippedKeys))
+	}
+	for i := range 9 {
+		_, err := responder.Decrypt(messages[i].header, messages[i].ciphertext)
+		if err == nil {
+			t.Fatalf("Decrypt(%q) succeeded with an evicted key", messages[i].plaintext)
+		}
+	}
+
+	// The session is not wedged: remaining skipped and new messages still decrypt
+	decryptTestMessage(t, responder, messages[9])
+	decryptTestMessage(t, responder, messages[MaxSkippedMessageKeys+19])
+	decryptTestMessage(t, responder, messages[MaxSkippedMessageKeys+11])
+	decryptTestMessage(t, responder, messages[MaxSkippedMessageKeys-1])
+	if len(responder.SkippedKeys) > MaxSkippedMessageKeys {
+		t.Fatalf("len(SkippedKeys) = %d, want at most %d", len(responder.SkippedKeys), MaxSkippedMessageKeys)
+	}
+}
@@ -1,9 +1,12 @@
 package cryptography
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
 )
@@ -26,6 +29,12 @@ func MarshalPublicKey(publicKey *rsa.PublicKey) []byte {
 	return publicKeyPEM
 }
 
+// Fingerprint returns the hex encoded SHA-256 digest of a public key, in its PEM encoding.
+func Fingerprint(publicKey *rsa.PublicKey) string {
+	digest := sha256.Sum256(MarshalPublicKey(publicKey))
+	return hex.EncodeToString(digest[:])
+}
+
 func UnmarshalPublicKey(pemBytes []byte) (*rsa.PublicKey, error) {
 	publicKeyBlock, _ := pem.Decode(pemBytes)
 	if publicKeyBlock == nil {
@@ -59,3 +68,62 @@ func UnmarshalPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
 	}
 	return privateKey, nil
 }
+
+// EncryptKey wraps a symmetric key for the owner of the public key, using RSA-OAEP with SHA-256.
+func EncryptKey(publicKey *rsa.PublicKey, key []byte) ([]byte, error) {
+	cipheredKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rsa.EncryptOAEP: %w", err)
+	}
+	return cipheredKey, nil
+}
+
+// DecryptKey unwraps a symmetric key produced by EncryptKey.
+func DecryptKey(privateKey *rsa.PrivateKey, cipheredKey []byte) ([]byte, error) {
+	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, cipheredKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rsa.DecryptOAEP: %w", err)
+	}
+	return key, nil
+}
+
+// DecryptKeyPKCS1v15 unwraps a symmetric key from a legacy envelope.
+// Only kept to read messages sent before RSA-OAEP was introduced.
+func DecryptKeyPKCS1v15(privateKey *rsa.PrivateKey, cipheredKey []byte) ([]byte, error) {
+	key, err := rsa.DecryptPKCS1v15(rand.Reader, privateKey, cipheredKey)
+	if err != nil {
+		return nil, fmt.Errorf("rsa.DecryptPKCS1v15: %w", err)
+	}
+	return key, nil
+}
+
+// Sign signs the SHA-256 digest of data with the private key, using RSA-PSS.
+func Sign(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	signature, err := rsa.SignPSS(rand.Reader, privateKey, crypto.SHA256, digest[:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("rsa.SignPSS: %w", err)
+	}
+	return signature, nil
+}
+
+// Verify checks a signature produced by Sign.
+func Verify(publicKey *rsa.PublicKey, data []byte, signature []byte) error {
+	digest := sha256.Sum256(data)
+	err := rsa.VerifyPSS(publicKey, crypto.SHA256, digest[:], signature, nil)
+	if err != nil {
+		return fmt.Errorf("rsa.VerifyPSS: %w", err)
+	}
+	return nil
+}
+
+// VerifyPKCS1v15 checks a legacy PKCS#1 v1.5 signature over the SHA-256 digest of data.
+// Only kept to verify messages sent before RSA-PSS was introduced.
+func VerifyPKCS1v15(publicKey *rsa.PublicKey, data []byte, signature []byte) error {
+	digest := sha256.Sum256(data)
+	err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature)
+	if err != nil {
+		return fmt.Errorf("rsa.VerifyPKCS1v15: %w", err)
+	}
+	return nil
+}
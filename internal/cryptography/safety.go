@@ -0,0 +1,39 @@
+package cryptography
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// SafetyNumber returns a number two users can compare out of band to make sure they see each other's keys.
+// Each user contributes 30 digits derived from their name and public key; the halves are ordered by name,
+// so both users compute the same number.
+func SafetyNumber(localName string, localKey *rsa.PublicKey, remoteName string, remoteKey *rsa.PublicKey) string {
+	local := safetyNumberHalf(localName, localKey)
+	remote := safetyNumberHalf(remoteName, remoteKey)
+	if remoteName < localName {
+		local, remote = remote, local
+	}
+	digits := local + remote
+
+	groups := make([]string, 0, len(digits)/5)
+	for i := 0; i < len(digits); i += 5 {
+		groups = append(groups, digits[i:i+5])
+	}
+	return strings.Join(groups, " ")
+}
+
+func safetyNumberHalf(name string, publicKey *rsa.PublicKey) string {
+	digest := sha256.Sum256(append([]byte(name+"\n"), MarshalPublicKey(publicKey)...))
+	var half strings.Builder
+	for i := 0; i < 6; i++ {
+		// 5 bytes per chunk leave no noticeable bias once reduced to 5 digits
+		chunk := make([]byte, 8)
+		copy(chunk[3:], digest[i*5:i*5+5])
+		fmt.Fprintf(&half, "%05d", binary.BigEndian.Uint64(chunk)%100000)
+	}
+	return half.String()
+}
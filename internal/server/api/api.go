@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"image/color"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 	"github.com/skip2/go-qrcode"
@@ -54,8 +55,9 @@ func (a *API) PostAuth(c echo.Context) error {
 			WithInternal(fmt.Errorf("c.Bind: %w", err))
 	}
 
-	username, err := a.Authenticator.VerifyAuthChallenge(
+	username, deviceID, err := a.Authenticator.VerifyAuthChallenge(
 		c.Request().Context(),
+		c.Param("username"),
 		signedAuthChallenge,
 		a.Controller,
 	)
@@ -65,10 +67,82 @@ func (a *API) PostAuth(c echo.Context) error {
 			WithInternal(fmt.Errorf("Authenticator.VerifyAuthChallenge: %w", err))
 	}
 
-	authToken, err := a.Authenticator.GenerateAuthJWT(username)
-	return c.JSON(http.StatusOK, openapi.JWT{
-		Token: authToken,
-	})
+	tokens, err := a.issueTokens(c, username, deviceID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to issue tokens").
+			WithInternal(fmt.Errorf("issueTokens: %w", err))
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// RefreshAuth exchanges a refresh token for new tokens, without redoing the challenge-response.
+func (a *API) RefreshAuth(c echo.Context) error {
+	username := c.Param("username")
+	var request *openapi.RefreshRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	deviceID, err := a.Controller.UseRefreshToken(c.Request().Context(), username, request.RefreshToken)
+	if err != nil {
+		if errors.Is(err, types.ErrInvalidToken) {
+			return echo.NewHTTPError(http.StatusUnauthorized, openapi.ErrorResponse{
+				Error: types.ErrInvalidToken.Error(),
+			}).
+				WithInternal(fmt.Errorf("Controller.UseRefreshToken: %w", err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to refresh token").
+			WithInternal(fmt.Errorf("Controller.UseRefreshToken: %w", err))
+	}
+
+	tokens, err := a.issueTokens(c, username, deviceID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to issue tokens").
+			WithInternal(fmt.Errorf("issueTokens: %w", err))
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeRefreshToken revokes a refresh token, such as when signing out.
+func (a *API) RevokeRefreshToken(c echo.Context) error {
+	username := c.Param("username")
+	var request *openapi.RefreshRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	err := a.Controller.RevokeRefreshToken(c.Request().Context(), username, request.RefreshToken)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke token").
+			WithInternal(fmt.Errorf("Controller.RevokeRefreshToken: %w", err))
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// issueTokens generates an auth token for one of the user's devices, along with a refresh token
+// if they are enabled.
+func (a *API) issueTokens(c echo.Context, username openapi.Username, deviceID string) (*openapi.JWT, error) {
+	authToken, err := a.Authenticator.GenerateAuthJWT(username, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("Authenticator.GenerateAuthJWT: %w", err)
+	}
+	tokens := &openapi.JWT{Token: authToken}
+	if !a.Authenticator.RefreshEnabled() {
+		return tokens, nil
+	}
+
+	refreshToken, expiresAt, err := a.Authenticator.GenerateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("Authenticator.GenerateRefreshToken: %w", err)
+	}
+	err = a.Controller.AddRefreshToken(c.Request().Context(), username, deviceID, refreshToken, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("Controller.AddRefreshToken: %w", err)
+	}
+	tokens.RefreshToken = &refreshToken
+	return tokens, nil
 }
 
 func (a *API) GetUser(c echo.Context) error {
@@ -91,9 +165,19 @@ func (a *API) GetUser(c echo.Context) error {
 
 	publicKeyPem := cryptography.MarshalPublicKey(publicKey)
 
+	devices, err := a.Controller.ListDevices(
+		c.Request().Context(),
+		username,
+	)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user").
+			WithInternal(fmt.Errorf("Controller.ListDevices: %w", err))
+	}
+
 	return c.JSON(http.StatusOK, openapi.PublicUser{
 		Name:      username,
 		PublicKey: publicKeyPem,
+		Devices:   &devices,
 	})
 }
 
@@ -124,18 +208,132 @@ func (a *API) AddUser(c echo.Context) error {
 	return c.JSON(http.StatusCreated, nil)
 }
 
+// AddDevice adds a device to a user. No authentication is needed, as the device must be
+// signed by one of the user's existing devices.
+func (a *API) AddDevice(c echo.Context) error {
+	username := c.Param("username")
+
+	var device *openapi.Device
+	if err := c.Bind(&device); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	alreadyExists, err := a.Controller.AddDevice(
+		c.Request().Context(),
+		username,
+		device,
+	)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, openapi.ErrorResponse{
+				Error: "user not found",
+			}).
+				WithInternal(fmt.Errorf("Controller.AddDevice: %w", err))
+		}
+		if errors.Is(err, types.ErrInvalidDevice) {
+			return echo.NewHTTPError(http.StatusBadRequest, openapi.ErrorResponse{
+				Error: types.ErrInvalidDevice.Error(),
+			}).
+				WithInternal(fmt.Errorf("Controller.AddDevice: %w", err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to add device").
+			WithInternal(fmt.Errorf("Controller.AddDevice: %w", err))
+	}
+	if alreadyExists {
+		return c.JSON(http.StatusOK, nil)
+	}
+
+	return c.JSON(http.StatusCreated, nil)
+}
+
+// GetKeyRotations returns the key rotation history of a user, for contacts to follow the chain of
+// signatures from the key they know to the current one.
+func (a *API) GetKeyRotations(c echo.Context) error {
+	username := c.Param("username")
+
+	rotations, err := a.Controller.ListKeyRotations(
+		c.Request().Context(),
+		username,
+	)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, openapi.ErrorResponse{
+				Error: "user not found",
+			}).
+				WithInternal(fmt.Errorf("Controller.ListKeyRotations: %w", err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get key rotations").
+			WithInternal(fmt.Errorf("Controller.ListKeyRotations: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, rotations)
+}
+
+// RotateKey replaces the key of a user. No authentication is needed, as the new key must be signed
+// by the current one.
+func (a *API) RotateKey(c echo.Context) error {
+	username := c.Param("username")
+
+	var rotation *openapi.KeyRotation
+	if err := c.Bind(&rotation); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	err := a.Controller.RotateUserKey(
+		c.Request().Context(),
+		username,
+		rotation,
+	)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, openapi.ErrorResponse{
+				Error: "user not found",
+			}).
+				WithInternal(fmt.Errorf("Controller.RotateUserKey: %w", err))
+		}
+		if errors.Is(err, types.ErrInvalidKey) {
+			return echo.NewHTTPError(http.StatusBadRequest, openapi.ErrorResponse{
+				Error: types.ErrInvalidKey.Error(),
+			}).
+				WithInternal(fmt.Errorf("Controller.RotateUserKey: %w", err))
+		}
+		if errors.Is(err, types.ErrStaleKey) {
+			return echo.NewHTTPError(http.StatusConflict, openapi.ErrorResponse{
+				Error: types.ErrStaleKey.Error(),
+			}).
+				WithInternal(fmt.Errorf("Controller.RotateUserKey: %w", err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to rotate key").
+			WithInternal(fmt.Errorf("Controller.RotateUserKey: %w", err))
+	}
+
+	// Connections authenticated with the revoked devices are closed
+	a.WebsocketHub.DisconnectUser(c.Request().Context(), username)
+
+	return c.JSON(http.StatusOK, nil)
+}
+
 func (a *API) GetMessages(c echo.Context) error {
 	username := c.Param("username")
 
-	err := a.Authenticator.VerifyAuthJWT(c, username)
+	err := a.Authenticator.VerifyAuthJWT(c, username, a.Controller)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
 			WithInternal(fmt.Errorf("Authenticator.VerifyAuthJWT: %w", err))
 	}
 
+	deviceID, err := a.Authenticator.AuthDevice(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.AuthDevice: %w", err))
+	}
+
 	messages, err := a.Controller.GetMessages(
 		c.Request().Context(),
 		username,
+		deviceID,
 	)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "could not get messages").
@@ -145,10 +343,53 @@ func (a *API) GetMessages(c echo.Context) error {
 	return c.JSON(http.StatusOK, messages)
 }
 
+// AckMessages marks messages as delivered to the authenticated device, which has stored them.
+// Messages fetched but not acknowledged are fetched again once their lease expires.
+func (a *API) AckMessages(c echo.Context) error {
+	username := c.Param("username")
+
+	err := a.Authenticator.VerifyAuthJWT(c, username, a.Controller)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.VerifyAuthJWT: %w", err))
+	}
+
+	deviceID, err := a.Authenticator.AuthDevice(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.AuthDevice: %w", err))
+	}
+
+	var ack *openapi.MessageAck
+	if err := c.Bind(&ack); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	err = a.Controller.AckMessages(
+		c.Request().Context(),
+		username,
+		deviceID,
+		ack.Ids,
+	)
+	if err != nil {
+		if errors.Is(err, types.ErrInvalidMessageID) {
+			return echo.NewHTTPError(http.StatusBadRequest, openapi.ErrorResponse{
+				Error: types.ErrInvalidMessageID.Error(),
+			}).
+				WithInternal(fmt.Errorf("Controller.AckMessages: %w", err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not acknowledge messages").
+			WithInternal(fmt.Errorf("Controller.AckMessages: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
 func (a *API) AddMessage(c echo.Context) error {
 	username := c.Param("username")
 
-	err := a.Authenticator.VerifyAuthJWT(c, username)
+	err := a.Authenticator.VerifyAuthJWT(c, username, a.Controller)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
 			WithInternal(fmt.Errorf("Authenticator.VerifyAuthJWT: %w", err))
@@ -164,29 +405,353 @@ func (a *API) AddMessage(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, "Forbidden")
 	}
 
-	err = a.Controller.AddMessage(
+	deviceID, err := a.Authenticator.AuthDevice(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.AuthDevice: %w", err))
+	}
+	if message.SenderDevice != nil && *message.SenderDevice != deviceID {
+		return echo.NewHTTPError(http.StatusForbidden, "Forbidden")
+	}
+
+	stored, alreadyExists, err := a.Controller.AddMessage(
 		c.Request().Context(),
 		message,
 	)
 	if err != nil {
+		if errors.Is(err, types.ErrInvalidMessageID) {
+			return echo.NewHTTPError(http.StatusBadRequest, openapi.ErrorResponse{
+				Error: types.ErrInvalidMessageID.Error(),
+			}).
+				WithInternal(fmt.Errorf("Controller.AddMessage: %w", err))
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "could not add message").
 			WithInternal(fmt.Errorf("Controller.AddMessage: %w", err))
 	}
+	if alreadyExists {
+		return c.JSON(http.StatusOK, stored)
+	}
 
-	return c.JSON(http.StatusCreated, nil)
+	// Connected recipients get the message right away
+	a.WebsocketHub.Broadcast(c.Request().Context(), stored)
+
+	return c.JSON(http.StatusCreated, stored)
+}
+
+func (a *API) GetPrekeys(c echo.Context) error {
+	username := c.Param("username")
+
+	err := a.Authenticator.VerifyAuthJWT(c, username, a.Controller)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.VerifyAuthJWT: %w", err))
+	}
+
+	status, err := a.Controller.GetPrekeyStatus(
+		c.Request().Context(),
+		username,
+	)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not get prekeys").
+			WithInternal(fmt.Errorf("Controller.GetPrekeyStatus: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, status)
+}
+
+func (a *API) PutPrekeys(c echo.Context) error {
+	username := c.Param("username")
+
+	err := a.Authenticator.VerifyAuthJWT(c, username, a.Controller)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.VerifyAuthJWT: %w", err))
+	}
+
+	var upload *openapi.PrekeyUpload
+	if err := c.Bind(&upload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	status, err := a.Controller.UploadPrekeys(
+		c.Request().Context(),
+		username,
+		upload,
+	)
+	if err != nil {
+		if errors.Is(err, types.ErrInvalidPrekey) {
+			return echo.NewHTTPError(http.StatusBadRequest, openapi.ErrorResponse{
+				Error: types.ErrInvalidPrekey.Error(),
+			}).
+				WithInternal(fmt.Errorf("Controller.UploadPrekeys: %w", err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not upload prekeys").
+			WithInternal(fmt.Errorf("Controller.UploadPrekeys: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, status)
+}
+
+// ClaimPrekeys hands out a prekey bundle of a user to any authenticated user, within the limits
+// of the claims recorded for the caller and for the user.
+func (a *API) ClaimPrekeys(c echo.Context) error {
+	username := c.Param("username")
+
+	claimer, err := a.Authenticator.AuthSubject(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.AuthSubject: %w", err))
+	}
+	err = a.Authenticator.VerifyAuthJWT(c, claimer, a.Controller)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.VerifyAuthJWT: %w", err))
+	}
+
+	bundle, err := a.Controller.ClaimPrekeyBundle(
+		c.Request().Context(),
+		claimer,
+		username,
+	)
+	if err != nil {
+		if errors.Is(err, types.ErrRateLimited) {
+			return echo.NewHTTPError(http.StatusTooManyRequests, openapi.ErrorResponse{
+				Error: "too many prekey claims",
+			}).
+				WithInternal(fmt.Errorf("Controller.ClaimPrekeyBundle: %w", err))
+		}
+		if errors.Is(err, types.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, openapi.ErrorResponse{
+				Error: "no prekeys for user",
+			}).
+				WithInternal(fmt.Errorf("Controller.ClaimPrekeyBundle: %w", err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not claim prekeys").
+			WithInternal(fmt.Errorf("Controller.ClaimPrekeyBundle: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, bundle)
+}
+
+func (a *API) CreateTransfer(c echo.Context) error {
+	username := c.Param("username")
+
+	err := a.Authenticator.VerifyAuthJWT(c, username, a.Controller)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.VerifyAuthJWT: %w", err))
+	}
+
+	var request *openapi.TransferRequest
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	transfer, err := a.Controller.CreateTransfer(
+		c.Request().Context(),
+		username,
+		request.Recipient,
+		request.ChunkCount,
+	)
+	if err != nil {
+		if errors.Is(err, types.ErrInvalidChunk) {
+			return echo.NewHTTPError(http.StatusBadRequest, openapi.ErrorResponse{
+				Error: types.ErrInvalidChunk.Error(),
+			}).
+				WithInternal(fmt.Errorf("Controller.CreateTransfer: %w", err))
+		}
+		if errors.Is(err, types.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, openapi.ErrorResponse{
+				Error: "recipient not found",
+			}).
+				WithInternal(fmt.Errorf("Controller.CreateTransfer: %w", err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not create transfer").
+			WithInternal(fmt.Errorf("Controller.CreateTransfer: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, transfer)
+}
+
+func (a *API) GetTransfer(c echo.Context) error {
+	username := c.Param("username")
+
+	err := a.Authenticator.VerifyAuthJWT(c, username, a.Controller)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.VerifyAuthJWT: %w", err))
+	}
+
+	transfer, err := a.Controller.GetTransfer(
+		c.Request().Context(),
+		username,
+		c.Param("transfer_id"),
+	)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, openapi.ErrorResponse{
+				Error: "transfer not found",
+			}).
+				WithInternal(fmt.Errorf("Controller.GetTransfer: %w", err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not get transfer").
+			WithInternal(fmt.Errorf("Controller.GetTransfer: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, transfer)
+}
+
+func (a *API) AckTransfer(c echo.Context) error {
+	username := c.Param("username")
+
+	err := a.Authenticator.VerifyAuthJWT(c, username, a.Controller)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.VerifyAuthJWT: %w", err))
+	}
+
+	deviceID, err := a.Authenticator.AuthDevice(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.AuthDevice: %w", err))
+	}
+
+	err = a.Controller.AckTransfer(
+		c.Request().Context(),
+		username,
+		deviceID,
+		c.Param("transfer_id"),
+	)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, openapi.ErrorResponse{
+				Error: "transfer not found",
+			}).
+				WithInternal(fmt.Errorf("Controller.AckTransfer: %w", err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not acknowledge transfer").
+			WithInternal(fmt.Errorf("Controller.AckTransfer: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (a *API) GetTransferChunk(c echo.Context) error {
+	username := c.Param("username")
+
+	err := a.Authenticator.VerifyAuthJWT(c, username, a.Controller)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.VerifyAuthJWT: %w", err))
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, openapi.ErrorResponse{
+			Error: "chunk not found",
+		}).
+			WithInternal(fmt.Errorf("strconv.Atoi: %w", err))
+	}
+
+	data, err := a.Controller.GetTransferChunk(
+		c.Request().Context(),
+		username,
+		c.Param("transfer_id"),
+		index,
+	)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, openapi.ErrorResponse{
+				Error: "chunk not found",
+			}).
+				WithInternal(fmt.Errorf("Controller.GetTransferChunk: %w", err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not get chunk").
+			WithInternal(fmt.Errorf("Controller.GetTransferChunk: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, openapi.TransferChunk{Data: data})
+}
+
+func (a *API) PutTransferChunk(c echo.Context) error {
+	username := c.Param("username")
+
+	err := a.Authenticator.VerifyAuthJWT(c, username, a.Controller)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.VerifyAuthJWT: %w", err))
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, openapi.ErrorResponse{
+			Error: types.ErrInvalidChunk.Error(),
+		}).
+			WithInternal(fmt.Errorf("strconv.Atoi: %w", err))
+	}
+
+	var chunk *openapi.TransferChunk
+	if err := c.Bind(&chunk); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	err = a.Controller.PutTransferChunk(
+		c.Request().Context(),
+		username,
+		c.Param("transfer_id"),
+		index,
+		chunk.Data,
+	)
+	if err != nil {
+		if errors.Is(err, types.ErrInvalidChunk) {
+			return echo.NewHTTPError(http.StatusBadRequest, openapi.ErrorResponse{
+				Error: types.ErrInvalidChunk.Error(),
+			}).
+				WithInternal(fmt.Errorf("Controller.PutTransferChunk: %w", err))
+		}
+		if errors.Is(err, types.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, openapi.ErrorResponse{
+				Error: "transfer not found",
+			}).
+				WithInternal(fmt.Errorf("Controller.PutTransferChunk: %w", err))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not store chunk").
+			WithInternal(fmt.Errorf("Controller.PutTransferChunk: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
 }
 
 // serveWs handles websocket requests from the peer.
 func (a *API) RegisterWebsocketClient(c echo.Context) error {
 	username := c.Param("username")
 
-	err := a.Authenticator.VerifyAuthJWT(c, username)
+	err := a.Authenticator.VerifyAuthJWT(c, username, a.Controller)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
 			WithInternal(fmt.Errorf("Authenticator.VerifyAuthJWT: %w", err))
 	}
 
-	err = a.WebsocketHub.RegisterClient(c, username)
+	deviceID, err := a.Authenticator.AuthDevice(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized").
+			WithInternal(fmt.Errorf("Authenticator.AuthDevice: %w", err))
+	}
+
+	device, err := a.Controller.GetDevice(
+		c.Request().Context(),
+		username,
+		deviceID,
+	)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError).
+			WithInternal(fmt.Errorf("Controller.GetDevice: %w", err))
+	}
+
+	err = a.WebsocketHub.RegisterClient(c, username, deviceID, device.SignedBy == nil)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError).
 			WithInternal(fmt.Errorf("WebsocketHub.RegisterClient: %w", err))
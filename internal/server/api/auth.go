@@ -3,13 +3,13 @@ package api
 import (
 	"context"
 	"crypto/rand"
-	"crypto/rsa"
 	"encoding/base64"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
+	"github.com/marc921/talk/internal/cryptography"
 	"github.com/marc921/talk/internal/server/controller"
 	"github.com/marc921/talk/internal/types/openapi"
 )
@@ -20,6 +20,8 @@ type Authenticator struct {
 	nonceLen            int
 	challengeExpiration time.Duration
 	authExpiration      time.Duration
+	// Zero when refresh tokens are not issued.
+	refreshExpiration time.Duration
 }
 
 func NewAuthenticator(
@@ -28,6 +30,7 @@ func NewAuthenticator(
 	nonceLen int,
 	challengeExpiration time.Duration,
 	authExpiration time.Duration,
+	refreshExpiration time.Duration,
 ) *Authenticator {
 	return &Authenticator{
 		challengeSecretKey:  challengeSecretKey,
@@ -35,6 +38,7 @@ func NewAuthenticator(
 		nonceLen:            nonceLen,
 		challengeExpiration: challengeExpiration,
 		authExpiration:      authExpiration,
+		refreshExpiration:   refreshExpiration,
 	}
 }
 
@@ -78,11 +82,14 @@ func (a *Authenticator) GenerateAuthChallenge(username openapi.Username) (*opena
 	}, nil
 }
 
+// VerifyAuthChallenge checks that the challenge was issued to the user and signed by one of their
+// devices, and returns the user and that device's ID. Each challenge is accepted once.
 func (a *Authenticator) VerifyAuthChallenge(
 	ctx context.Context,
+	username openapi.Username,
 	signedAuthChallenge *openapi.AuthChallengeSigned,
 	controller *controller.ServerController,
-) (openapi.Username, error) {
+) (openapi.Username, string, error) {
 	token, err := jwt.Parse(
 		signedAuthChallenge.Token,
 		func(token *jwt.Token) (any, error) {
@@ -91,60 +98,74 @@ func (a *Authenticator) VerifyAuthChallenge(
 		jwt.WithValidMethods([]string{"HS256"}),
 	)
 	if err != nil {
-		return "", fmt.Errorf("jwt.Parse: %w", err)
+		return "", "", fmt.Errorf("jwt.Parse: %w", err)
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return "", fmt.Errorf("invalid token")
+		return "", "", fmt.Errorf("invalid token")
 	}
 
 	expiration, err := claims.GetExpirationTime()
 	if err != nil {
-		return "", fmt.Errorf("missing expiration time")
+		return "", "", fmt.Errorf("missing expiration time")
 	}
 	if time.Now().After(expiration.Time) {
-		return "", fmt.Errorf("token expired")
+		return "", "", fmt.Errorf("token expired")
 	}
 
 	nonce, ok := claims["nonce"].(string)
 	if !ok {
-		return "", fmt.Errorf("missing nonce")
+		return "", "", fmt.Errorf("missing nonce")
 	}
 
-	username, ok := claims["sub"].(string)
+	sub, ok := claims["sub"].(string)
 	if !ok {
-		return "", fmt.Errorf("missing subject")
+		return "", "", fmt.Errorf("missing subject")
 	}
-	publicKey, err := controller.GetUserPublicKey(
-		ctx,
-		openapi.Username(username),
-	)
+	if sub != username {
+		return "", "", fmt.Errorf("wrong subject")
+	}
+	devices, err := controller.ListDevices(ctx, username)
 	if err != nil {
-		return "", fmt.Errorf("controller.GetUserPublicKey: %w", err)
+		return "", "", fmt.Errorf("controller.ListDevices: %w", err)
 	}
 
 	nonceBytes, err := base64.URLEncoding.DecodeString(nonce)
 	if err != nil {
-		return "", fmt.Errorf("base64.URLEncoding.DecodeString: %w", err)
+		return "", "", fmt.Errorf("base64.URLEncoding.DecodeString: %w", err)
 	}
 	signedNonceBytes, err := base64.URLEncoding.DecodeString(signedAuthChallenge.SignedNonce)
 	if err != nil {
-		return "", fmt.Errorf("base64.URLEncoding.DecodeString: %w", err)
+		return "", "", fmt.Errorf("base64.URLEncoding.DecodeString: %w", err)
 	}
-	err = rsa.VerifyPKCS1v15(publicKey, 0, nonceBytes, signedNonceBytes)
-	if err != nil {
-		return "", fmt.Errorf("rsa.VerifyPKCS1v15: %w", err)
+	// The nonce is signed with RSA-PSS over its SHA-256 digest, by any of the user's devices
+	for _, device := range devices {
+		publicKey, err := cryptography.UnmarshalPublicKey(device.PublicKey)
+		if err != nil {
+			return "", "", fmt.Errorf("cryptography.UnmarshalPublicKey: %w", err)
+		}
+		err = cryptography.Verify(publicKey, nonceBytes, signedNonceBytes)
+		if err != nil {
+			continue
+		}
+		// Only once the signature is verified, for others not to burn the user's challenges
+		err = controller.UseAuthChallenge(ctx, nonce, expiration.Time)
+		if err != nil {
+			return "", "", fmt.Errorf("controller.UseAuthChallenge: %w", err)
+		}
+		return username, device.Id, nil
 	}
 
-	return username, nil
+	return "", "", fmt.Errorf("no device key matches the signature")
 }
 
-// GenerateJWT generates a JWT that authenticates the user
-func (a *Authenticator) GenerateAuthJWT(username openapi.Username) (string, error) {
+// GenerateJWT generates a JWT that authenticates the user on one of their devices
+func (a *Authenticator) GenerateAuthJWT(username openapi.Username, deviceID string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": username,
-		"exp": time.Now().Add(a.authExpiration).Unix(),
+		"sub":    username,
+		"device": deviceID,
+		"exp":    time.Now().Add(a.authExpiration).Unix(),
 	})
 
 	tokenString, err := token.SignedString(a.authSecretKey)
@@ -155,7 +176,26 @@ func (a *Authenticator) GenerateAuthJWT(username openapi.Username) (string, erro
 	return tokenString, nil
 }
 
-func (a *Authenticator) VerifyAuthJWT(c echo.Context, username string) error {
+// RefreshEnabled reports whether refresh tokens are issued along with auth tokens.
+func (a *Authenticator) RefreshEnabled() bool {
+	return a.refreshExpiration > 0
+}
+
+// GenerateRefreshToken generates an opaque refresh token, and returns when it expires.
+func (a *Authenticator) GenerateRefreshToken() (string, time.Time, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", time.Time{}, fmt.Errorf("rand.Read: %w", err)
+	}
+	return base64.URLEncoding.EncodeToString(bytes), time.Now().Add(a.refreshExpiration), nil
+}
+
+// VerifyAuthJWT checks that the JWT was issued to the user, on a device that was not revoked since.
+func (a *Authenticator) VerifyAuthJWT(
+	c echo.Context,
+	username string,
+	controller *controller.ServerController,
+) error {
 	token, ok := c.Get("user").(*jwt.Token) // by default token is stored under `user` key
 	if !ok {
 		return fmt.Errorf("missing token")
@@ -166,14 +206,66 @@ func (a *Authenticator) VerifyAuthJWT(c echo.Context, username string) error {
 		return fmt.Errorf("invalid claims")
 	}
 
-	sub := claims["sub"].(string)
-	if sub == "" {
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
 		return fmt.Errorf("missing subject")
 	}
 
-	if username != claims["sub"].(string) {
+	if username != sub {
 		return fmt.Errorf("wrong subject")
 	}
 
+	device, ok := claims["device"].(string)
+	if !ok || device == "" {
+		return fmt.Errorf("missing device")
+	}
+	// Devices are revoked when the user's key is rotated
+	_, err := controller.GetDevice(c.Request().Context(), username, device)
+	if err != nil {
+		return fmt.Errorf("controller.GetDevice: %w", err)
+	}
+
 	return nil
 }
+
+// AuthSubject returns the name of the user the JWT was issued to, for endpoints acting on behalf
+// of the caller on another user's resources. It must be checked with VerifyAuthJWT.
+func (a *Authenticator) AuthSubject(c echo.Context) (string, error) {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok {
+		return "", fmt.Errorf("missing token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", fmt.Errorf("invalid claims")
+	}
+
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
+		return "", fmt.Errorf("missing subject")
+	}
+
+	return sub, nil
+}
+
+// AuthDevice returns the ID of the device the JWT was issued to.
+// It must be called after VerifyAuthJWT.
+func (a *Authenticator) AuthDevice(c echo.Context) (string, error) {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok {
+		return "", fmt.Errorf("missing token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", fmt.Errorf("invalid claims")
+	}
+
+	device, ok := claims["device"].(string)
+	if !ok || device == "" {
+		return "", fmt.Errorf("missing device")
+	}
+
+	return device, nil
+}
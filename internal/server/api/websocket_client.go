@@ -7,11 +7,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/marc921/talk/internal/types"
 	"github.com/marc921/talk/internal/types/openapi"
 	"go.uber.org/zap"
 )
@@ -29,6 +32,9 @@ const (
 	// Maximum message size allowed from peer.
 	// TODO: ensure messages are sent by chunks and reconstructed on the client side or server database / file storage.
 	maxMessageSize = 1 << 20 // 1 MB
+
+	// Events queued for a client, beyond which messages are loaded from the database once it caught up.
+	sessionQueueSize = 256
 )
 
 // WebSocketClient is a middleman between the websocket connection and the hub.
@@ -37,10 +43,29 @@ type WebSocketClient struct {
 	hub    *WebSocketHub
 	// The websocket connection.
 	conn *websocket.Conn
-	// Buffered channel of outbound messages.
-	out chan *openapi.Message
+	// Buffered channel of outbound events.
+	out chan *types.WebSocketEvent
+	// Set by the hub when a message did not fit in out, for WritePump to load the missed messages
+	// from the database once out is drained.
+	overflow atomic.Bool
+	// When out became full, zero while it is not. Owned by the hub.
+	overflowSince time.Time
+	// Close frame sent once the hub closes out, if the hub set a code.
+	closeCode   int
+	closeReason string
+	// Whether the client negotiated types.WebSocketProtocol, otherwise it exchanges bare messages.
+	events bool
 	// The username of the client.
 	username openapi.Username
+	// The ID of the client's device.
+	device string
+	// Whether the device is the user's first device.
+	primary bool
+	// Messages stored while the device was not connected, sent before any other.
+	backlog []*openapi.Message
+	// Users who exchanged messages with the client's user, told when it comes online or goes offline.
+	// Owned by the hub once the client is registered.
+	correspondents map[openapi.Username]bool
 }
 
 // newWebSocketClient creates a new WebSocketClient.
@@ -49,27 +74,52 @@ func NewWebSocketClient(
 	hub *WebSocketHub,
 	conn *websocket.Conn,
 	username openapi.Username,
+	device string,
+	primary bool,
+	correspondents []openapi.Username,
 ) *WebSocketClient {
+	correspondentSet := make(map[openapi.Username]bool, len(correspondents))
+	for _, correspondent := range correspondents {
+		correspondentSet[correspondent] = true
+	}
 	return &WebSocketClient{
 		logger: logger.With(
 			zap.String("component", "websocket_client"),
 			zap.String("client_ip", conn.RemoteAddr().String()),
 		),
-		hub:      hub,
-		conn:     conn,
-		out:      make(chan *openapi.Message, 256),
-		username: username,
+		hub:            hub,
+		conn:           conn,
+		out:            make(chan *types.WebSocketEvent, sessionQueueSize),
+		events:         conn.Subprotocol() == types.WebSocketProtocol,
+		username:       username,
+		device:         device,
+		primary:        primary,
+		correspondents: correspondentSet,
+	}
+}
+
+// accepts reports whether a message is for the client's device: either sent to its user,
+// or sent by its user from another device. Envelopes without per-device keys can only be
+// read by the user's first device.
+func (c *WebSocketClient) accepts(message *openapi.Message) bool {
+	if message.Recipient == c.username && (message.Keys != nil || c.primary) {
+		return true
 	}
+	return message.Sender == c.username &&
+		message.Keys != nil &&
+		message.SenderDevice != nil &&
+		*message.SenderDevice != c.device
 }
 
-// ReadPump pumps messages from the websocket connection to the hub.
+// ReadPump pumps messages and typing events from the websocket connection to the hub.
+// Messages are stored before being broadcast, for recipients not connected to receive them later.
 //
 // The application runs ReadPump in a per-connection goroutine. The application
 // ensures that there is at most one reader on a connection by executing all
 // reads from this goroutine.
-func (c *WebSocketClient) ReadPump() {
+func (c *WebSocketClient) ReadPump(ctx context.Context) {
 	defer func() {
-		c.hub.unregister <- c
+		c.hub.requestUnregister(c)
 		c.conn.Close()
 	}()
 	c.conn.SetReadLimit(maxMessageSize)
@@ -90,52 +140,109 @@ func (c *WebSocketClient) ReadPump() {
 			break
 		}
 
-		var msg *openapi.Message
-		err = json.Unmarshal(message, &msg)
-		if err != nil {
-			c.logger.Error("json.Unmarshal", zap.Error(err))
+		if !c.events {
+			var msg *openapi.Message
+			err = json.Unmarshal(message, &msg)
+			if err != nil {
+				c.logger.Error("json.Unmarshal", zap.Error(err))
+				continue
+			}
+			c.addMessage(ctx, msg)
 			continue
 		}
 
-		if msg.Sender != c.username {
-			c.logger.Warn("message sender does not match client username", zap.String("sender", string(msg.Sender)), zap.String("client_username", string(c.username)))
+		var event *types.WebSocketEvent
+		err = json.Unmarshal(message, &event)
+		if err != nil {
+			c.logger.Error("json.Unmarshal", zap.Error(err))
 			continue
 		}
+		switch {
+		case (event.Type == types.WebSocketEventMessage || event.Type == types.WebSocketEventReceipt) && event.Message != nil:
+			c.addMessage(ctx, event.Message)
+		case event.Type == types.WebSocketEventTyping && event.Typing != nil:
+			event.Typing.From = c.username
+			c.hub.Typing(ctx, event.Typing)
+		default:
+			c.logger.Warn("unexpected event", zap.String("type", string(event.Type)))
+		}
+	}
+}
+
+// addMessage stores a message sent by the client, and broadcasts it unless it was already stored.
+func (c *WebSocketClient) addMessage(ctx context.Context, msg *openapi.Message) {
+	if msg.Sender != c.username {
+		c.logger.Warn("message sender does not match client username", zap.String("sender", string(msg.Sender)), zap.String("client_username", string(c.username)))
+		return
+	}
+	if msg.SenderDevice != nil && *msg.SenderDevice != c.device {
+		c.logger.Warn("message sender device does not match client device", zap.String("sender_device", *msg.SenderDevice), zap.String("client_device", c.device))
+		return
+	}
 
-		c.hub.in <- msg
+	stored, alreadyExists, err := c.hub.controller.AddMessage(ctx, msg)
+	if err != nil {
+		c.logger.Error("controller.AddMessage", zap.Error(err))
+		return
+	}
+	if alreadyExists {
+		return
 	}
+	c.hub.Broadcast(ctx, stored)
 }
 
 // WritePump pumps messages from the hub to the websocket connection.
+// The backlog of the client is sent first. Messages pushed live are leased to the device,
+// for them not to be fetched again before the device acknowledges them. Messages the hub
+// could not queue are loaded from the database once the queue is drained.
 //
 // A goroutine running WritePump is started for each connection. The
 // application ensures that there is at most one writer to a connection by
 // executing all writes from this goroutine.
-func (c *WebSocketClient) WritePump() error {
+func (c *WebSocketClient) WritePump(ctx context.Context) error {
 	pingTicker := time.NewTicker(pingPeriod)
 	defer func() {
 		pingTicker.Stop()
 		c.conn.Close()
 	}()
+
+	// Messages stored before the backlog was fetched may also have been broadcast
+	sent := make(map[string]bool, len(c.backlog))
+	err := c.writeMessages(c.backlog, sent)
+	if err != nil {
+		return fmt.Errorf("writeMessages: %w", err)
+	}
+	c.backlog = nil
+
 	for {
 		select {
-		case message, ok := <-c.out:
+		case event, ok := <-c.out:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// The hub closed the channel.
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMessage := []byte{}
+				if c.closeCode != 0 {
+					closeMessage = websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return nil
 			}
-
-			// Send the message to the client.
-			msgBytes, err := json.Marshal(message)
+			err := c.push(ctx, event, sent)
 			if err != nil {
-				return fmt.Errorf("json.Marshal: %w", err)
+				return fmt.Errorf("push: %w", err)
 			}
 
-			err = c.conn.WriteMessage(websocket.TextMessage, msgBytes)
-			if err != nil {
-				return fmt.Errorf("conn.WriteMessage: %w", err)
+			// Messages the hub could not queue are still undelivered, thus not leased
+			if len(c.out) == 0 && c.overflow.Swap(false) {
+				websocketMetrics.Add("resyncs", 1)
+				missed, err := c.hub.controller.GetMessages(ctx, c.username, c.device)
+				if err != nil {
+					return fmt.Errorf("controller.GetMessages: %w", err)
+				}
+				err = c.writeMessages(missed, sent)
+				if err != nil {
+					return fmt.Errorf("writeMessages: %w", err)
+				}
 			}
 		case <-pingTicker.C:
 			// Periodically send ping messages to the client to ensure they are still alive.
@@ -147,3 +254,51 @@ func (c *WebSocketClient) WritePump() error {
 		}
 	}
 }
+
+// push sends an event queued by the hub, leasing its message to the device.
+func (c *WebSocketClient) push(ctx context.Context, event *types.WebSocketEvent, sent map[string]bool) error {
+	if message := event.Message; message != nil && message.Id != nil {
+		if sent[*message.Id] {
+			return nil
+		}
+		err := c.hub.controller.LeaseMessage(ctx, c.device, *message.Id)
+		if err != nil {
+			// The message may be fetched again, which is better than not pushing it
+			c.logger.Error("controller.LeaseMessage", zap.Error(err))
+		}
+	}
+	return c.write(event)
+}
+
+// writeMessages sends messages loaded from the database, skipping and recording those already sent.
+func (c *WebSocketClient) writeMessages(messages []*openapi.Message, sent map[string]bool) error {
+	for _, message := range messages {
+		if sent[*message.Id] {
+			continue
+		}
+		err := c.write(types.NewMessageEvent(message))
+		if err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+		sent[*message.Id] = true
+	}
+	return nil
+}
+
+// write sends an event to the client, or only its message if the client does not understand events.
+func (c *WebSocketClient) write(event *types.WebSocketEvent) error {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	var payload any = event
+	if !c.events {
+		payload = event.Message
+	}
+	msgBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	err = c.conn.WriteMessage(websocket.TextMessage, msgBytes)
+	if err != nil {
+		return fmt.Errorf("conn.WriteMessage: %w", err)
+	}
+	return nil
+}
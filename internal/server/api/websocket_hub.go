@@ -8,86 +8,506 @@ package api
 
 import (
 	"context"
+	"errors"
+	"expvar"
 	"fmt"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
+	"github.com/marc921/talk/internal/server/controller"
+	"github.com/marc921/talk/internal/server/fanout"
+	"github.com/marc921/talk/internal/types"
 	"github.com/marc921/talk/internal/types/openapi"
 	"go.uber.org/zap"
 )
 
+const (
+	// Presence events waiting to be published, beyond which they are dropped rather than
+	// blocking the hub.
+	presenceBuffer = 256
+	// Time allowed to tell other replicas that the users of this one went offline, on shutdown.
+	shutdownPresenceWait = 5 * time.Second
+	// Time a session may keep its queue full before it is evicted.
+	overflowTimeout = time.Minute
+	// Interval between the heartbeats of a replica.
+	heartbeatInterval = 30 * time.Second
+	// Time without a heartbeat after which a replica is considered stopped, with its users offline.
+	replicaTimeout = 3 * heartbeatInterval
+)
+
+// websocketMetrics counts the websocket sessions, and those that do not keep up. Served by expvar.
+var websocketMetrics = expvar.NewMap("websocket")
+
 // WebSocketHub maintains the set of active clients and broadcasts messages to the clients.
+// A user may hold several sessions at once, from one or more devices, each with its own queue.
+// It tells users when their correspondents come online or go offline, and relays typing events.
+// Events go through the fan-out to the hubs of all server replicas, this one included, so that
+// they reach clients connected to any replica.
 type WebSocketHub struct {
 	logger *zap.Logger
+	// Stores inbound messages before they are broadcast, and holds the backlog of new clients.
+	controller *controller.ServerController
+	// Relays events between the hubs of all replicas.
+	fanout fanout.Fanout
+	// ID of this replica, telling its presence events apart.
+	replica string
 	// Registered clients.
 	clients map[*WebSocketClient]bool
-	// Inbound messages from the clients.
+	// Number of registered clients of each user connected to this replica.
+	local map[openapi.Username]int
+	// Replicas each online user is connected to.
+	online map[openapi.Username]map[string]bool
+	// When each replica was last heard from.
+	replicas map[string]time.Time
+	// Messages received from the fan-out.
 	in chan *openapi.Message
+	// Typing events received from the fan-out.
+	typing chan *types.TypingEvent
+	// Presence events, heartbeats and sync requests received from the fan-out.
+	presence chan *fanout.Event
+	// Batches of presence events and heartbeats of this replica to publish.
+	outbox chan []*fanout.Event
 	// Register requests from the clients.
 	register chan *WebSocketClient
 	// Unregister requests from clients.
 	unregister chan *WebSocketClient
+	// Users whose clients must be disconnected.
+	disconnect chan openapi.Username
+	// Closed once Run returns, for clients not to wait on register and unregister forever.
+	done chan struct{}
 	// Upgrader for the websocket connection.
 	upgrader websocket.Upgrader
 }
 
-func NewWebSocketHub(logger *zap.Logger) *WebSocketHub {
+func NewWebSocketHub(
+	logger *zap.Logger,
+	controller *controller.ServerController,
+	hubFanout fanout.Fanout,
+) *WebSocketHub {
 	return &WebSocketHub{
 		logger: logger.With(
 			zap.String("component", "websocket_hub"),
 		),
+		controller: controller,
+		fanout:     hubFanout,
+		replica:    uuid.NewString(),
 		in:         make(chan *openapi.Message),
+		typing:     make(chan *types.TypingEvent),
+		presence:   make(chan *fanout.Event),
+		outbox:     make(chan []*fanout.Event, presenceBuffer),
 		register:   make(chan *WebSocketClient),
 		unregister: make(chan *WebSocketClient),
+		disconnect: make(chan openapi.Username),
+		done:       make(chan struct{}),
 		clients:    make(map[*WebSocketClient]bool),
+		local:      make(map[openapi.Username]int),
+		online:     make(map[openapi.Username]map[string]bool),
+		replicas:   make(map[string]time.Time),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
+			Subprotocols:    []string{types.WebSocketProtocol},
 		},
 	}
 }
 
+// close disconnects the clients, and tells the other replicas that their users went offline.
 func (h *WebSocketHub) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownPresenceWait)
+	defer cancel()
+	for username := range h.local {
+		err := h.fanout.Publish(ctx, h.presenceEvent(username, false))
+		if err != nil {
+			h.logger.Error("fanout.Publish", zap.Error(err))
+			break
+		}
+	}
 	for client := range h.clients {
-		h.unregisterClient(client)
+		client.closeCode = websocket.CloseGoingAway
+		close(client.out)
+		delete(h.clients, client)
+		websocketMetrics.Add("sessions", -1)
+	}
+}
+
+func (h *WebSocketHub) registerClient(client *WebSocketClient) {
+	h.clients[client] = true
+	websocketMetrics.Add("sessions", 1)
+	h.local[client.username]++
+	if h.local[client.username] == 1 {
+		h.publishPresence(client.username, true)
+	}
+	// The new client learns which of its correspondents are already online
+	for username := range client.correspondents {
+		if len(h.online[username]) > 0 {
+			h.send(client, &types.WebSocketEvent{
+				Type:     types.WebSocketEventPresence,
+				Presence: &types.PresenceEvent{Username: username, Online: true},
+			})
+		}
 	}
 }
 
 func (h *WebSocketHub) unregisterClient(client *WebSocketClient) {
 	close(client.out)
 	delete(h.clients, client)
+	websocketMetrics.Add("sessions", -1)
+	h.local[client.username]--
+	if h.local[client.username] == 0 {
+		delete(h.local, client.username)
+		h.publishPresence(client.username, false)
+	}
+}
+
+func (h *WebSocketHub) presenceEvent(username openapi.Username, online bool) *fanout.Event {
+	return &fanout.Event{
+		Type:     fanout.EventPresence,
+		Replica:  h.replica,
+		Presence: &types.PresenceEvent{Username: username, Online: online},
+	}
+}
+
+// publishPresence queues a presence event of this replica, to be published by publishLoop
+// without blocking the hub.
+func (h *WebSocketHub) publishPresence(username openapi.Username, online bool) {
+	h.publish(h.presenceEvent(username, online))
+}
+
+// publish queues a batch of events of this replica, to be published in order by publishLoop
+// without blocking the hub.
+func (h *WebSocketHub) publish(events ...*fanout.Event) {
+	select {
+	case h.outbox <- events:
+	default:
+		h.logger.Warn("presence events dropped", zap.Int("count", len(events)))
+	}
+}
+
+// publishLoop publishes the presence events and heartbeats of this replica, in order.
+func (h *WebSocketHub) publishLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case events := <-h.outbox:
+			for _, event := range events {
+				err := h.fanout.Publish(ctx, event)
+				if err != nil {
+					h.logger.Error("fanout.Publish", zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+// receivePresence handles the presence events, heartbeats and sync requests of the replicas.
+func (h *WebSocketHub) receivePresence(event *fanout.Event) {
+	_, known := h.replicas[event.Replica]
+	h.replicas[event.Replica] = time.Now()
+	switch event.Type {
+	case fanout.EventPresence:
+		h.setPresence(event)
+	case fanout.EventHeartbeat:
+		if !known {
+			// The replica was considered stopped, or started before this one without being heard of:
+			// which users are online on it is unknown
+			h.publish(&fanout.Event{Type: fanout.EventSync, Replica: h.replica})
+		}
+	case fanout.EventSync:
+		// The heartbeat comes first, for this replica to be known even without online users
+		events := []*fanout.Event{{Type: fanout.EventHeartbeat, Replica: h.replica}}
+		for username := range h.local {
+			events = append(events, h.presenceEvent(username, true))
+		}
+		h.publish(events...)
+	}
+}
+
+// expireReplicas considers the users of the replicas not heard from for replicaTimeout offline.
+func (h *WebSocketHub) expireReplicas(now time.Time) {
+	for replica, heardAt := range h.replicas {
+		if replica == h.replica || now.Sub(heardAt) <= replicaTimeout {
+			continue
+		}
+		h.logger.Warn("replica timed out", zap.String("replica", replica))
+		delete(h.replicas, replica)
+		for username, replicas := range h.online {
+			if replicas[replica] {
+				h.setPresence(&fanout.Event{
+					Type:     fanout.EventPresence,
+					Replica:  replica,
+					Presence: &types.PresenceEvent{Username: username, Online: false},
+				})
+			}
+		}
+	}
+}
+
+// setPresence records on which replicas a user is online, and tells the user's correspondents
+// connected to this replica when the user came online on a first replica or went offline on the last.
+func (h *WebSocketHub) setPresence(event *fanout.Event) {
+	username := event.Presence.Username
+	wasOnline := len(h.online[username]) > 0
+	if event.Presence.Online {
+		if h.online[username] == nil {
+			h.online[username] = make(map[string]bool)
+		}
+		h.online[username][event.Replica] = true
+	} else {
+		delete(h.online[username], event.Replica)
+		if len(h.online[username]) == 0 {
+			delete(h.online, username)
+		}
+	}
+	if online := len(h.online[username]) > 0; online != wasOnline {
+		h.broadcastPresence(username, online)
+	}
+}
+
+// receiveLoop forwards the events received from the fan-out to the hub.
+// Messages published by other replicas are loaded from the database.
+func (h *WebSocketHub) receiveLoop(ctx context.Context, events <-chan *fanout.Event) {
+	for {
+		var event *fanout.Event
+		select {
+		case <-ctx.Done():
+			return
+		case event = <-events:
+		}
+
+		switch {
+		case event.Type == fanout.EventMessage:
+			message := event.Message
+			if message == nil {
+				var err error
+				message, err = h.controller.GetMessage(ctx, event.MessageID)
+				if err != nil {
+					h.logger.Error("controller.GetMessage", zap.Error(err))
+					continue
+				}
+			}
+			select {
+			case h.in <- message:
+			case <-ctx.Done():
+				return
+			}
+		case event.Type == fanout.EventTyping && event.Typing != nil:
+			select {
+			case h.typing <- event.Typing:
+			case <-ctx.Done():
+				return
+			}
+		case event.Type == fanout.EventPresence && event.Presence != nil,
+			event.Type == fanout.EventHeartbeat,
+			event.Type == fanout.EventSync:
+			select {
+			case h.presence <- event:
+			case <-ctx.Done():
+				return
+			}
+		case event.Type == fanout.EventDisconnect:
+			select {
+			case h.disconnect <- event.Username:
+			case <-ctx.Done():
+				return
+			}
+		default:
+			h.logger.Warn("unexpected fanout event", zap.String("type", string(event.Type)))
+		}
+	}
+}
+
+// send queues an event for a client. When the queue of the client is full, messages are left
+// in the database for the client to load them once it caught up, and other events are dropped.
+// Clients whose queue stays full are evicted, and told to resync.
+// Only messages are sent to clients that do not understand events.
+func (h *WebSocketHub) send(client *WebSocketClient, event *types.WebSocketEvent) {
+	if !client.events && event.Message == nil {
+		return
+	}
+	select {
+	case client.out <- event:
+		client.overflowSince = time.Time{}
+		return
+	default:
+	}
+
+	if client.overflowSince.IsZero() {
+		client.overflowSince = time.Now()
+		websocketMetrics.Add("overflows", 1)
+		h.logger.Warn(
+			"slow websocket client",
+			zap.String("username", client.username),
+			zap.String("device", client.device),
+		)
+	} else if time.Since(client.overflowSince) > overflowTimeout {
+		websocketMetrics.Add("evictions", 1)
+		h.evictClient(client, types.WebSocketCloseResync, "slow consumer")
+		return
+	}
+	if event.Message != nil {
+		client.overflow.Store(true)
+	} else {
+		websocketMetrics.Add("dropped_events", 1)
+	}
+}
+
+// evictClient unregisters a client, whose connection is closed with the given code.
+func (h *WebSocketHub) evictClient(client *WebSocketClient, code int, reason string) {
+	client.closeCode = code
+	client.closeReason = reason
+	h.unregisterClient(client)
+}
+
+// requestRegister asks Run to register a client, and reports false if the hub stopped.
+func (h *WebSocketHub) requestRegister(client *WebSocketClient) bool {
+	select {
+	case h.register <- client:
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+// requestUnregister asks Run to unregister a client, unless the hub stopped, which dropped it.
+func (h *WebSocketHub) requestUnregister(client *WebSocketClient) {
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
+}
+
+// broadcastPresence tells the correspondents of a user that the user came online or went offline.
+func (h *WebSocketHub) broadcastPresence(username openapi.Username, online bool) {
+	event := &types.WebSocketEvent{
+		Type:     types.WebSocketEventPresence,
+		Presence: &types.PresenceEvent{Username: username, Online: online},
+	}
+	for client := range h.clients {
+		if client.correspondents[username] {
+			h.send(client, event)
+		}
+	}
 }
 
 func (h *WebSocketHub) Run(ctx context.Context) error {
+	defer close(h.done)
+	events, err := h.fanout.Subscribe(ctx)
+	if err != nil {
+		return fmt.Errorf("fanout.Subscribe: %w", err)
+	}
+	go h.receiveLoop(ctx, events)
+	go h.publishLoop(ctx)
+	// The users online on the replicas already running are learnt from their answers
+	h.publish(&fanout.Event{Type: fanout.EventSync, Replica: h.replica})
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
 	defer h.close()
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case now := <-heartbeat.C:
+			h.publish(&fanout.Event{Type: fanout.EventHeartbeat, Replica: h.replica})
+			h.expireReplicas(now)
 		case client := <-h.register:
-			h.clients[client] = true
+			h.registerClient(client)
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
 				h.unregisterClient(client)
 			}
+		case username := <-h.disconnect:
+			for client := range h.clients {
+				if client.username == username {
+					h.evictClient(client, types.WebSocketCloseDisconnected, "devices revoked")
+				}
+			}
 		case message := <-h.in:
+			event := types.NewMessageEvent(message)
 			for client := range h.clients {
-				if client.username != message.Recipient {
-					continue
+				// Users who just exchanged their first message now see each other's presence
+				switch client.username {
+				case message.Sender:
+					client.correspondents[message.Recipient] = true
+				case message.Recipient:
+					client.correspondents[message.Sender] = true
 				}
-				// TODO: persist messages in case user is not connected, deduplicate?
-				select {
-				case client.out <- message:
-				default:
-					h.unregisterClient(client)
+				if client.accepts(message) {
+					h.send(client, event)
+				}
+			}
+		case typing := <-h.typing:
+			event := &types.WebSocketEvent{Type: types.WebSocketEventTyping, Typing: typing}
+			for client := range h.clients {
+				if client.username == typing.To {
+					h.send(client, event)
 				}
 			}
+		case event := <-h.presence:
+			h.receivePresence(event)
 		}
 	}
 }
 
+// Broadcast pushes a stored message to the connected devices it is for, on any replica.
+// Devices that miss it fetch it later, so failures are only logged.
+func (h *WebSocketHub) Broadcast(ctx context.Context, message *openapi.Message) {
+	if message.Id == nil {
+		h.logger.Error("cannot broadcast a message without ID")
+		return
+	}
+	err := h.fanout.Publish(ctx, &fanout.Event{
+		Type:      fanout.EventMessage,
+		Replica:   h.replica,
+		MessageID: *message.Id,
+		Message:   message,
+	})
+	if err != nil {
+		h.logger.Error("fanout.Publish", zap.Error(err))
+	}
+}
+
+// Typing relays a typing event to the connected devices of its recipient, on any replica.
+func (h *WebSocketHub) Typing(ctx context.Context, typing *types.TypingEvent) {
+	err := h.fanout.Publish(ctx, &fanout.Event{
+		Type:    fanout.EventTyping,
+		Replica: h.replica,
+		Typing:  typing,
+	})
+	if err != nil {
+		h.logger.Error("fanout.Publish", zap.Error(err))
+	}
+}
+
+// DisconnectUser closes the connections of all the user's clients, on all replicas.
+func (h *WebSocketHub) DisconnectUser(ctx context.Context, username openapi.Username) {
+	err := h.fanout.Publish(ctx, &fanout.Event{
+		Type:     fanout.EventDisconnect,
+		Replica:  h.replica,
+		Username: username,
+	})
+	if err != nil {
+		h.logger.Error("fanout.Publish", zap.Error(err))
+	}
+}
+
 // serveWs handles websocket requests from the peer.
-func (h *WebSocketHub) RegisterClient(c echo.Context, username openapi.Username) error {
+func (h *WebSocketHub) RegisterClient(
+	c echo.Context,
+	username openapi.Username,
+	device string,
+	primary bool,
+) error {
+	// The connection outlives the request, so must its context.
+	ctx := context.WithoutCancel(c.Request().Context())
+	correspondents, err := h.controller.ListCorrespondents(ctx, username)
+	if err != nil {
+		return fmt.Errorf("controller.ListCorrespondents: %w", err)
+	}
+
 	conn, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
 		return fmt.Errorf("upgrader.Upgrade: %w", err)
@@ -97,13 +517,34 @@ func (h *WebSocketHub) RegisterClient(c echo.Context, username openapi.Username)
 		h,
 		conn,
 		username,
+		device,
+		primary,
+		correspondents,
 	)
-	h.register <- client
+	if !h.requestRegister(client) {
+		conn.Close()
+		return errors.New("websocket hub stopped")
+	}
+
+	// Fetched once registered, for messages stored in the meantime to be either in the backlog
+	// or broadcast to the client. WritePump skips those broadcast that are also in the backlog.
+	backlog, err := h.controller.GetMessages(ctx, username, device)
+	if err != nil {
+		h.requestUnregister(client)
+		conn.Close()
+		return fmt.Errorf("controller.GetMessages: %w", err)
+	}
+	client.backlog = backlog
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
-	go client.WritePump()
-	go client.ReadPump()
+	go func() {
+		err := client.WritePump(ctx)
+		if err != nil {
+			client.logger.Error("client.WritePump", zap.Error(err))
+		}
+	}()
+	go client.ReadPump(ctx)
 
 	return nil
 }
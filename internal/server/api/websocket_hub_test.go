@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/marc921/talk/internal/server/fanout"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+	"go.uber.org/zap"
+)
+
+func newTestHub() *WebSocketHub {
+	return NewWebSocketHub(zap.NewNop(), nil, fanout.NewMemory())
+}
+
+// newTestClient returns a client speaking events, without connection.
+func newTestClient(username openapi.Username, correspondents ...openapi.Username) *WebSocketClient {
+	correspondentSet := make(map[openapi.Username]bool, len(correspondents))
+	for _, correspondent := range correspondents {
+		correspondentSet[correspondent] = true
+	}
+	return &WebSocketClient{
+		logger:         zap.NewNop(),
+		out:            make(chan *types.WebSocketEvent, sessionQueueSize),
+		events:         true,
+		username:       username,
+		device:         username + "-device",
+		primary:        true,
+		correspondents: correspondentSet,
+	}
+}
+
+// nextPresence returns the next event queued for a client, which must be a presence event.
+func nextPresence(t *testing.T, client *WebSocketClient) *types.PresenceEvent {
+	t.Helper()
+	select {
+	case event := <-client.out:
+		if event.Presence == nil {
+			t.Fatalf("got a %s event, want a presence event", event.Type)
+		}
+		return event.Presence
+	default:
+		t.Fatal("no event queued")
+		return nil
+	}
+}
+
+// nextOutbox returns the next batch of events queued for publication.
+func nextOutbox(t *testing.T, h *WebSocketHub) []*fanout.Event {
+	t.Helper()
+	select {
+	case events := <-h.outbox:
+		return events
+	default:
+		t.Fatal("no events queued for publication")
+		return nil
+	}
+}
+
+func TestHubReplicaPresence(t *testing.T) {
+	h := newTestHub()
+	alice := newTestClient("alice", "bob")
+	h.registerClient(alice)
+	if events := nextOutbox(t, h); len(events) != 1 || events[0].Presence == nil || !events[0].Presence.Online {
+		t.Fatalf("registering did not publish alice online: %+v", events)
+	}
+
+	// Bob comes online on another replica
+	h.receivePresence(&fanout.Event{
+		Type:     fanout.EventPresence,
+		Replica:  "other",
+		Presence: &types.PresenceEvent{Username: "bob", Online: true},
+	})
+	if presence := nextPresence(t, alice); presence.Username != "bob" || !presence.Online {
+		t.Fatalf("got %+v, want bob online", presence)
+	}
+
+	// Heartbeats keep the replica alive
+	h.receivePresence(&fanout.Event{Type: fanout.EventHeartbeat, Replica: "other"})
+	h.expireReplicas(time.Now().Add(replicaTimeout / 2))
+	if len(h.online["bob"]) != 1 {
+		t.Fatal("bob went offline while his replica sends heartbeats")
+	}
+
+	// The replica crashes: bob goes offline once it timed out
+	h.expireReplicas(time.Now().Add(replicaTimeout + time.Second))
+	if presence := nextPresence(t, alice); presence.Username != "bob" || presence.Online {
+		t.Fatalf("got %+v, want bob offline", presence)
+	}
+	if _, ok := h.replicas["other"]; ok {
+		t.Error("timed out replica still known")
+	}
+
+	// The replica heard from again is asked which users are online on it
+	h.receivePresence(&fanout.Event{Type: fanout.EventHeartbeat, Replica: "other"})
+	if events := nextOutbox(t, h); len(events) != 1 || events[0].Type != fanout.EventSync {
+		t.Fatalf("got %+v, want a sync request", events)
+	}
+
+	// A sync request is answered with a heartbeat and the users online on this replica
+	h.receivePresence(&fanout.Event{Type: fanout.EventSync, Replica: "other"})
+	events := nextOutbox(t, h)
+	if len(events) != 2 || events[0].Type != fanout.EventHeartbeat {
+		t.Fatalf("got %+v, want a heartbeat and alice online", events)
+	}
+	if events[1].Presence == nil || events[1].Presence.Username != "alice" || !events[1].Presence.Online {
+		t.Fatalf("got %+v, want alice online", events[1])
+	}
+}
+
+func TestHubSlowSession(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := newTestHub()
+	go h.Run(ctx)
+
+	// Two devices of the same user, only one of which reads its queue
+	slow := newTestClient("bob")
+	fast := newTestClient("bob")
+	for _, client := range []*WebSocketClient{slow, fast} {
+		if !h.requestRegister(client) {
+			t.Fatal("hub stopped")
+		}
+	}
+	const count = sessionQueueSize + 10
+	received := make(chan int)
+	go func() {
+		n := 0
+		for event := range fast.out {
+			if event.Message != nil {
+				n++
+				if n == count {
+					received <- n
+					return
+				}
+			}
+		}
+	}()
+
+	for i := range count {
+		id := fmt.Sprintf("message-%d", i)
+		h.Broadcast(ctx, &openapi.Message{Id: &id, Sender: "alice", Recipient: "bob"})
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the fast session did not receive every message")
+	}
+	// The messages that did not fit are left for the slow session to load once it caught up
+	deadline := time.Now().Add(5 * time.Second)
+	for !slow.overflow.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("the slow session was not flagged to load the messages it missed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(slow.out) != sessionQueueSize {
+		t.Errorf("slow session queue holds %d events, want %d", len(slow.out), sessionQueueSize)
+	}
+}
+
+func TestHubOverflowResync(t *testing.T) {
+	h := newTestHub()
+	clients := make(chan *WebSocketClient)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := h.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrader.Upgrade: %v", err)
+			return
+		}
+		clients <- NewWebSocketClient(zap.NewNop(), h, conn, "bob", "bob-device", true, nil)
+	}))
+	defer server.Close()
+	dialer := websocket.Dialer{Subprotocols: []string{types.WebSocketProtocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dialer.Dial: %v", err)
+	}
+	defer conn.Close()
+	client := <-clients
+	h.registerClient(client)
+	evictions := websocketMetrics.Get("evictions")
+	evictionsBefore := int64(0)
+	if evictions != nil {
+		evictionsBefore = evictions.(*expvar.Int).Value()
+	}
+
+	// The queue fills up, and stays full beyond overflowTimeout
+	typing := &types.WebSocketEvent{
+		Type:   types.WebSocketEventTyping,
+		Typing: &types.TypingEvent{From: "alice", To: "bob", Typing: true},
+	}
+	for range sessionQueueSize + 1 {
+		h.send(client, typing)
+	}
+	if client.overflowSince.IsZero() {
+		t.Fatal("overflow not recorded")
+	}
+	client.overflowSince = time.Now().Add(-overflowTimeout - time.Second)
+	h.send(client, typing)
+	if h.clients[client] {
+		t.Fatal("the slow session was not evicted")
+	}
+	if got := websocketMetrics.Get("evictions").(*expvar.Int).Value(); got != evictionsBefore+1 {
+		t.Errorf("evictions = %d, want %d", got, evictionsBefore+1)
+	}
+
+	// The queued events are written, then the connection is closed for the client to resync
+	go client.WritePump(context.Background())
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	events := 0
+	for {
+		_, _, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, types.WebSocketCloseResync) {
+				t.Fatalf("conn.ReadMessage: got %v, want close code %d", err, types.WebSocketCloseResync)
+			}
+			break
+		}
+		events++
+	}
+	if events != sessionQueueSize {
+		t.Errorf("received %d events before closing, want %d", events, sessionQueueSize)
+	}
+}
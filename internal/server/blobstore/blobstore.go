@@ -0,0 +1,16 @@
+// Package blobstore stores the encrypted blobs of file transfers outside of the database.
+package blobstore
+
+import (
+	"context"
+)
+
+// Store stores blobs by ID. IDs are chosen by the server, never by clients.
+type Store interface {
+	// Put stores a blob, replacing any blob with the same ID.
+	Put(ctx context.Context, id string, data []byte) error
+	// Get returns a blob, or types.ErrNotFound if there is none with this ID.
+	Get(ctx context.Context, id string) ([]byte, error)
+	// Delete deletes a blob. Deleting a blob that does not exist is not an error.
+	Delete(ctx context.Context, id string) error
+}
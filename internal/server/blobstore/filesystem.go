@@ -0,0 +1,86 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/marc921/talk/internal/types"
+)
+
+// FilesystemStore stores blobs as files in a local directory.
+type FilesystemStore struct {
+	dir string
+}
+
+func NewFilesystemStore(dir string) (*FilesystemStore, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	return &FilesystemStore{dir: dir}, nil
+}
+
+func (s *FilesystemStore) Put(ctx context.Context, id string, data []byte) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	// Written to a temporary file first, so that readers never see a partial blob
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("tmp.Write: %w", err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("tmp.Close: %w", err)
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+	return nil
+}
+
+func (s *FilesystemStore) Get(ctx context.Context, id string) ([]byte, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, types.ErrNotFound
+		}
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	return data, nil
+}
+
+func (s *FilesystemStore) Delete(ctx context.Context, id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("os.Remove: %w", err)
+	}
+	return nil
+}
+
+// path returns the file of a blob, refusing IDs that are not plain file names.
+func (s *FilesystemStore) path(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || id[0] == '.' {
+		return "", fmt.Errorf("invalid blob ID %q", id)
+	}
+	return filepath.Join(s.dir, id), nil
+}
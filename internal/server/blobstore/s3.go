@@ -0,0 +1,183 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/marc921/talk/internal/types"
+)
+
+type S3Config struct {
+	// Endpoint is the base URL of the S3-compatible service, such as https://s3.eu-west-3.amazonaws.com
+	// or http://localhost:9000 for a local MinIO.
+	Endpoint        string `env:"ENDPOINT"`
+	Region          string `env:"REGION, default=us-east-1"`
+	Bucket          string `env:"BUCKET"`
+	AccessKeyID     string `env:"ACCESS_KEY_ID"`
+	SecretAccessKey string `env:"SECRET_ACCESS_KEY"`
+}
+
+// S3Store stores blobs as objects of a bucket of an S3-compatible service.
+// Requests are addressed path-style and signed with AWS Signature Version 4,
+// which both AWS and MinIO accept.
+type S3Store struct {
+	httpClient *http.Client
+	endpoint   *url.URL
+	config     S3Config
+}
+
+func NewS3Store(config S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parse: %w", err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("missing S3 bucket")
+	}
+	return &S3Store{
+		httpClient: &http.Client{Timeout: time.Minute},
+		endpoint:   endpoint,
+		config:     config,
+	}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, id string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, id, data)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, id string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, id, nil)
+	if err != nil {
+		return nil, fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("io.ReadAll: %w", err)
+		}
+		return data, nil
+	case http.StatusNotFound:
+		return nil, types.ErrNotFound
+	default:
+		return nil, s3Error(resp)
+	}
+}
+
+func (s *S3Store) Delete(ctx context.Context, id string) error {
+	resp, err := s.do(ctx, http.MethodDelete, id, nil)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK, http.StatusNotFound:
+		return nil
+	default:
+		return s3Error(resp)
+	}
+}
+
+// do sends a signed request for an object of the bucket.
+func (s *S3Store) do(ctx context.Context, method string, id string, body []byte) (*http.Response, error) {
+	if id == "" || strings.Contains(id, "/") {
+		return nil, fmt.Errorf("invalid blob ID %q", id)
+	}
+	objectURL := s.endpoint.JoinPath(s.config.Bucket, id)
+	req, err := http.NewRequestWithContext(ctx, method, objectURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequestWithContext: %w", err)
+	}
+	s.sign(req, body, time.Now())
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("httpClient.Do: %w", err)
+	}
+	return resp, nil
+}
+
+// sign adds the headers of AWS Signature Version 4 to a request without query parameters.
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := signingKey(s.config.SecretAccessKey, date, s.config.Region, "s3")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID,
+		scope,
+		signedHeaders,
+		signature,
+	))
+}
+
+// signingKey derives the key signing the requests of a day to a service in a region.
+func signingKey(secretAccessKey, date, region, service string) []byte {
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return key
+}
+
+// s3Error reads the error returned by the service, whose body is XML.
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	return fmt.Errorf("received unexpected status code: %d: %s", resp.StatusCode, body)
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
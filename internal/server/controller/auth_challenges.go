@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/marc921/talk/internal/server/database/sqlcgen"
+	"github.com/marc921/talk/internal/types"
+)
+
+// UseAuthChallenge records that the challenge with this nonce was answered, and fails with
+// types.ErrInvalidToken if it already was. The nonce is kept until the challenge expires, after which
+// the challenge is rejected anyway.
+func (s *ServerController) UseAuthChallenge(ctx context.Context, nonce string, expiresAt time.Time) error {
+	queries := sqlcgen.New(s.db)
+	inserted, err := queries.InsertUsedAuthChallenge(ctx, sqlcgen.InsertUsedAuthChallengeParams{
+		Nonce:     nonce,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("queries.InsertUsedAuthChallenge: %w", err)
+	}
+	if inserted == 0 {
+		return fmt.Errorf("%w: challenge already used", types.ErrInvalidToken)
+	}
+	return nil
+}
+
+// PurgeAuthChallenges deletes the nonces of expired challenges, and returns how many were deleted.
+func (s *ServerController) PurgeAuthChallenges(ctx context.Context) (int64, error) {
+	queries := sqlcgen.New(s.db)
+	count, err := queries.DeleteExpiredAuthChallenges(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("queries.DeleteExpiredAuthChallenges: %w", err)
+	}
+	return count, nil
+}
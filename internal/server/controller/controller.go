@@ -5,29 +5,49 @@ import (
 	"context"
 	"crypto/rsa"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"go.uber.org/zap"
 
 	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/server/blobstore"
 	"github.com/marc921/talk/internal/server/database/sqlcgen"
 	"github.com/marc921/talk/internal/types"
 	"github.com/marc921/talk/internal/types/openapi"
 )
 
+// DB runs queries, and transactions for changes spanning several tables.
+type DB interface {
+	sqlcgen.DBTX
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
 type ServerController struct {
 	logger *zap.Logger
-	db     sqlcgen.DBTX
+	db     DB
+	// Stores the encrypted chunks of file transfers.
+	blobs blobstore.Store
+	// Delay after which messages fetched but not acknowledged by a device are delivered again.
+	messageLease time.Duration
 }
 
 func NewServerController(
 	logger *zap.Logger,
-	db sqlcgen.DBTX,
+	db DB,
+	blobs blobstore.Store,
+	messageLease time.Duration,
 ) *ServerController {
 	return &ServerController{
-		logger: logger.With(zap.String("component", "controller")),
-		db:     db,
+		logger:       logger.With(zap.String("component", "controller")),
+		db:           db,
+		blobs:        blobs,
+		messageLease: messageLease,
 	}
 }
 
@@ -39,13 +59,19 @@ func (s *ServerController) AddUser(
 	publicKeyBytes []byte,
 ) (bool, error) {
 	// Validate key format
-	_, err := cryptography.UnmarshalPublicKey(publicKeyBytes)
+	publicKey, err := cryptography.UnmarshalPublicKey(publicKeyBytes)
 	if err != nil {
 		return false, fmt.Errorf("cryptography.UnmarshalPublicKey: %w", err)
 	}
-	// Add user to the database
-	queries := sqlcgen.New(s.db)
-	user, err := queries.InsertUser(ctx, sqlcgen.InsertUserParams{
+	// Add user to the database, along with their first device: a user without it could never
+	// authenticate, nor be registered again
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("db.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	txQueries := sqlcgen.New(s.db).WithTx(tx)
+	user, err := txQueries.InsertUser(ctx, sqlcgen.InsertUserParams{
 		Name:      username,
 		PublicKey: publicKeyBytes,
 	})
@@ -58,9 +84,22 @@ func (s *ServerController) AddUser(
 			}
 			return true, nil
 		}
-		return false, fmt.Errorf("queries.InsertUser: %w", err)
+		return false, fmt.Errorf("txQueries.InsertUser: %w", err)
+	}
+	// The user's key is their first device, from which other devices are added
+	_, err = txQueries.InsertDevice(ctx, sqlcgen.InsertDeviceParams{
+		ID:        cryptography.Fingerprint(publicKey),
+		Username:  username,
+		PublicKey: publicKeyBytes,
+	})
+	if err != nil {
+		return false, fmt.Errorf("txQueries.InsertDevice: %w", err)
 	}
 
+	err = tx.Commit(ctx)
+	if err != nil {
+		return false, fmt.Errorf("tx.Commit: %w", err)
+	}
 	return false, nil
 }
 
@@ -83,50 +122,294 @@ func (s *ServerController) GetUserPublicKey(
 	return publicKey, nil
 }
 
+// AddMessage stores a message until it is delivered to the devices of its recipient,
+// and returns it as stored, with its ID.
+// If the sender already posted a message with the same client ID, it returns that message
+// and true as the second return value.
 func (s *ServerController) AddMessage(
 	ctx context.Context,
 	message *openapi.Message,
-) error {
+) (*openapi.Message, bool, error) {
+	var clientID pgtype.UUID
+	if message.ClientId != nil {
+		parsed, err := uuid.Parse(*message.ClientId)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: %w", types.ErrInvalidMessageID, err)
+		}
+		clientID = pgtype.UUID{Bytes: parsed, Valid: true}
+	}
+	var signature []byte
+	if message.Signature != nil {
+		signature = *message.Signature
+	}
+	var session []byte
+	if message.Session != nil {
+		var err error
+		session, err = json.Marshal(message.Session)
+		if err != nil {
+			return nil, false, fmt.Errorf("json.Marshal: %w", err)
+		}
+	}
+	var senderDevice pgtype.Text
+	if message.SenderDevice != nil {
+		senderDevice = pgtype.Text{String: *message.SenderDevice, Valid: true}
+	}
+	var kind pgtype.Text
+	if message.Kind != nil {
+		kind = pgtype.Text{String: string(*message.Kind), Valid: true}
+	}
+	var keys []byte
+	if message.Keys != nil {
+		var err error
+		keys, err = json.Marshal(message.Keys)
+		if err != nil {
+			return nil, false, fmt.Errorf("json.Marshal: %w", err)
+		}
+	}
 	queries := sqlcgen.New(s.db)
-	_, err := queries.InsertMessage(ctx, sqlcgen.InsertMessageParams{
+	dbMessage, err := queries.InsertMessage(ctx, sqlcgen.InsertMessageParams{
 		Sender:       message.Sender,
 		Recipient:    message.Recipient,
 		CipherSymKey: message.CipherSymKey,
 		Ciphertext:   message.Ciphertext,
+		Signature:    signature,
+		Version:      int32(types.MessageVersion(message)),
+		Session:      session,
+		SenderDevice: senderDevice,
+		Keys:         keys,
+		ClientID:     clientID,
+		Kind:         kind,
 	})
+	alreadyExists := false
 	if err != nil {
-		return fmt.Errorf("queries.InsertMessage: %w", err)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, false, fmt.Errorf("queries.InsertMessage: %w", err)
+		}
+		// Insert failed because of client ID conflict: the post was retried
+		dbMessage, err = queries.GetMessageByClientID(ctx, sqlcgen.GetMessageByClientIDParams{
+			Sender:   message.Sender,
+			ClientID: clientID,
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("queries.GetMessageByClientID: %w", err)
+		}
+		alreadyExists = true
 	}
 
-	return nil
+	stored, err := toOpenAPIMessage(dbMessage)
+	if err != nil {
+		return nil, false, fmt.Errorf("toOpenAPIMessage: %w", err)
+	}
+	return stored, alreadyExists, nil
 }
 
+// GetMessages returns the messages not yet delivered to one of the user's devices:
+// those sent to the user, and those the user sent from their other devices.
+// Messages are leased to the device until it acknowledges them with AckMessages,
+// and returned again once the lease expires.
 func (s *ServerController) GetMessages(
 	ctx context.Context,
 	username openapi.Username,
+	deviceID string,
 ) ([]*openapi.Message, error) {
 	queries := sqlcgen.New(s.db)
-	dbMessages, err := queries.GetUndeliveredMessages(ctx, username)
+	dbMessages, err := queries.GetUndeliveredMessages(ctx, sqlcgen.GetUndeliveredMessagesParams{
+		DeviceID: deviceID,
+		Username: username,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("queries.GetUndeliveredMessages: %w", err)
 	}
 	messages := make([]*openapi.Message, len(dbMessages))
 	for i, dbMessage := range dbMessages {
-		messages[i] = &openapi.Message{
-			Sender:       dbMessage.Sender,
-			Recipient:    dbMessage.Recipient,
-			CipherSymKey: dbMessage.CipherSymKey,
-			Ciphertext:   dbMessage.Ciphertext,
+		messages[i], err = toOpenAPIMessage(dbMessage)
+		if err != nil {
+			return nil, fmt.Errorf("toOpenAPIMessage: %w", err)
 		}
 	}
 
-	// Mark messages as delivered
 	for _, dbMessage := range dbMessages {
-		err := queries.SetMessageDelivered(ctx, dbMessage.ID)
+		err := s.leaseMessage(ctx, queries, dbMessage.ID, deviceID)
 		if err != nil {
-			return nil, fmt.Errorf("queries.SetMessageDelivered: %w", err)
+			return nil, fmt.Errorf("leaseMessage: %w", err)
 		}
 	}
 
 	return messages, nil
 }
+
+// GetMessage returns a stored message.
+func (s *ServerController) GetMessage(ctx context.Context, messageID string) (*openapi.Message, error) {
+	parsed, err := uuid.Parse(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", types.ErrInvalidMessageID, err)
+	}
+	queries := sqlcgen.New(s.db)
+	dbMessage, err := queries.GetMessage(ctx, pgtype.UUID{Bytes: parsed, Valid: true})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrNotFound
+		}
+		return nil, fmt.Errorf("queries.GetMessage: %w", err)
+	}
+	message, err := toOpenAPIMessage(dbMessage)
+	if err != nil {
+		return nil, fmt.Errorf("toOpenAPIMessage: %w", err)
+	}
+	return message, nil
+}
+
+// ListCorrespondents returns the users who exchanged messages with a user.
+func (s *ServerController) ListCorrespondents(
+	ctx context.Context,
+	username openapi.Username,
+) ([]openapi.Username, error) {
+	queries := sqlcgen.New(s.db)
+	correspondents, err := queries.ListCorrespondents(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("queries.ListCorrespondents: %w", err)
+	}
+	return correspondents, nil
+}
+
+// LeaseMessage leases a message pushed to one of the user's devices, for it not to be fetched again
+// until the device acknowledges it or the lease expires.
+func (s *ServerController) LeaseMessage(
+	ctx context.Context,
+	deviceID string,
+	messageID string,
+) error {
+	parsed, err := uuid.Parse(messageID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", types.ErrInvalidMessageID, err)
+	}
+	return s.leaseMessage(ctx, sqlcgen.New(s.db), pgtype.UUID{Bytes: parsed, Valid: true}, deviceID)
+}
+
+func (s *ServerController) leaseMessage(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	messageID pgtype.UUID,
+	deviceID string,
+) error {
+	err := queries.LeaseMessageDelivery(ctx, sqlcgen.LeaseMessageDeliveryParams{
+		MessageID:   messageID,
+		DeviceID:    deviceID,
+		LeasedUntil: pgtype.Timestamptz{Time: time.Now().Add(s.messageLease), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("queries.LeaseMessageDelivery: %w", err)
+	}
+	return nil
+}
+
+// AckMessages marks messages as delivered to one of the user's devices, once it has stored them.
+// Messages sent to the user are marked as delivered to the recipient on the first acknowledgement.
+// Unknown IDs and messages already acknowledged are ignored, so that acknowledgements can be retried.
+func (s *ServerController) AckMessages(
+	ctx context.Context,
+	username openapi.Username,
+	deviceID string,
+	ids []string,
+) error {
+	messageIDs := make([]pgtype.UUID, len(ids))
+	for i, id := range ids {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return fmt.Errorf("%w: %w", types.ErrInvalidMessageID, err)
+		}
+		messageIDs[i] = pgtype.UUID{Bytes: parsed, Valid: true}
+	}
+
+	// Start transaction to mark messages delivered to the device and to the recipient together
+	queries := sqlcgen.New(s.db)
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("db.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	txQueries := queries.WithTx(tx)
+
+	acked, err := txQueries.AckMessageDeliveries(ctx, sqlcgen.AckMessageDeliveriesParams{
+		DeviceID: deviceID,
+		Ids:      messageIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("txQueries.AckMessageDeliveries: %w", err)
+	}
+	err = txQueries.SetMessagesDelivered(ctx, sqlcgen.SetMessagesDeliveredParams{
+		Ids:       acked,
+		Recipient: username,
+	})
+	if err != nil {
+		return fmt.Errorf("txQueries.SetMessagesDelivered: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+	return nil
+}
+
+// PurgeMessages deletes the messages delivered before deliveredBefore, and those sent before
+// sentBefore but never delivered. It returns the number of messages of each deleted.
+func (s *ServerController) PurgeMessages(
+	ctx context.Context,
+	deliveredBefore time.Time,
+	sentBefore time.Time,
+) (int64, int64, error) {
+	queries := sqlcgen.New(s.db)
+	delivered, err := queries.DeleteDeliveredMessages(ctx, pgtype.Timestamptz{Time: deliveredBefore, Valid: true})
+	if err != nil {
+		return 0, 0, fmt.Errorf("queries.DeleteDeliveredMessages: %w", err)
+	}
+	undelivered, err := queries.DeleteUndeliveredMessages(ctx, pgtype.Timestamptz{Time: sentBefore, Valid: true})
+	if err != nil {
+		return delivered, 0, fmt.Errorf("queries.DeleteUndeliveredMessages: %w", err)
+	}
+	return delivered, undelivered, nil
+}
+
+func toOpenAPIMessage(dbMessage *sqlcgen.Message) (*openapi.Message, error) {
+	version := int(dbMessage.Version)
+	id := uuid.UUID(dbMessage.ID.Bytes).String()
+	message := &openapi.Message{
+		Id:           &id,
+		Version:      &version,
+		Sender:       dbMessage.Sender,
+		Recipient:    dbMessage.Recipient,
+		CipherSymKey: dbMessage.CipherSymKey,
+		Ciphertext:   dbMessage.Ciphertext,
+	}
+	if dbMessage.Signature != nil {
+		message.Signature = &dbMessage.Signature
+	}
+	if dbMessage.Session != nil {
+		err := json.Unmarshal(dbMessage.Session, &message.Session)
+		if err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+	}
+	if dbMessage.SenderDevice.Valid {
+		message.SenderDevice = &dbMessage.SenderDevice.String
+	}
+	if dbMessage.SentAt.Valid {
+		message.SentAt = &dbMessage.SentAt.Time
+	}
+	if dbMessage.Kind.Valid {
+		kind := openapi.MessageKind(dbMessage.Kind.String)
+		message.Kind = &kind
+	}
+	if dbMessage.ClientID.Valid {
+		clientID := uuid.UUID(dbMessage.ClientID.Bytes).String()
+		message.ClientId = &clientID
+	}
+	if dbMessage.Keys != nil {
+		err := json.Unmarshal(dbMessage.Keys, &message.Keys)
+		if err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+	}
+	return message, nil
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/server/database/sqlcgen"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// AddDevice adds a device to an existing user.
+// The device must be signed by one of the user's existing devices.
+// If the device already exists, it returns true as the first return value.
+func (s *ServerController) AddDevice(
+	ctx context.Context,
+	username openapi.Username,
+	device *openapi.Device,
+) (bool, error) {
+	publicKey, err := cryptography.UnmarshalPublicKey(device.PublicKey)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", types.ErrInvalidDevice, err)
+	}
+	if device.Id != cryptography.Fingerprint(publicKey) {
+		return false, fmt.Errorf("%w: id is not the key fingerprint", types.ErrInvalidDevice)
+	}
+	if device.SignedBy == nil || device.Signature == nil {
+		return false, fmt.Errorf("%w: missing signature", types.ErrInvalidDevice)
+	}
+
+	queries := sqlcgen.New(s.db)
+	_, err = queries.GetUser(ctx, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, types.ErrNotFound
+		}
+		return false, fmt.Errorf("queries.GetUser: %w", err)
+	}
+	signerKey, err := s.GetDevicePublicKey(ctx, username, *device.SignedBy)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return false, fmt.Errorf("%w: unknown signing device", types.ErrInvalidDevice)
+		}
+		return false, fmt.Errorf("GetDevicePublicKey: %w", err)
+	}
+	err = cryptography.Verify(signerKey, types.DeviceSignaturePayload(username, device.PublicKey), *device.Signature)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", types.ErrInvalidDevice, err)
+	}
+
+	_, err = queries.InsertDevice(ctx, sqlcgen.InsertDeviceParams{
+		ID:        device.Id,
+		Username:  username,
+		PublicKey: device.PublicKey,
+		SignedBy:  pgtype.Text{String: *device.SignedBy, Valid: true},
+		Signature: *device.Signature,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Insert failed because of id conflict: device already exists
+			existing, err := queries.GetDevice(ctx, device.Id)
+			if err != nil {
+				return false, fmt.Errorf("queries.GetDevice: %w", err)
+			}
+			if existing.Username != username {
+				return false, fmt.Errorf("%w: device belongs to another user", types.ErrInvalidDevice)
+			}
+			if existing.RevokedAt.Valid {
+				return false, fmt.Errorf("%w: device was revoked", types.ErrInvalidDevice)
+			}
+			return true, nil
+		}
+		return false, fmt.Errorf("queries.InsertDevice: %w", err)
+	}
+	return false, nil
+}
+
+// ListDevices returns the user's devices, the first one holding the user's public key.
+func (s *ServerController) ListDevices(
+	ctx context.Context,
+	username openapi.Username,
+) ([]openapi.Device, error) {
+	queries := sqlcgen.New(s.db)
+	dbDevices, err := queries.ListDevices(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("queries.ListDevices: %w", err)
+	}
+	devices := make([]openapi.Device, len(dbDevices))
+	for i, dbDevice := range dbDevices {
+		devices[i] = toOpenAPIDevice(dbDevice)
+	}
+	return devices, nil
+}
+
+// GetDevice returns one of the user's devices, or types.ErrNotFound if it was revoked.
+// The user's first device, the only one able to read envelopes without per-device keys,
+// is the one not signed by another device.
+func (s *ServerController) GetDevice(
+	ctx context.Context,
+	username openapi.Username,
+	deviceID string,
+) (*openapi.Device, error) {
+	queries := sqlcgen.New(s.db)
+	dbDevice, err := queries.GetDevice(ctx, deviceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrNotFound
+		}
+		return nil, fmt.Errorf("queries.GetDevice: %w", err)
+	}
+	if dbDevice.Username != username || dbDevice.RevokedAt.Valid {
+		return nil, types.ErrNotFound
+	}
+	device := toOpenAPIDevice(dbDevice)
+	return &device, nil
+}
+
+// GetDevicePublicKey returns the public key of one of the user's devices.
+func (s *ServerController) GetDevicePublicKey(
+	ctx context.Context,
+	username openapi.Username,
+	deviceID string,
+) (*rsa.PublicKey, error) {
+	device, err := s.GetDevice(ctx, username, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("GetDevice: %w", err)
+	}
+	publicKey, err := cryptography.UnmarshalPublicKey(device.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("cryptography.UnmarshalPublicKey: %w", err)
+	}
+	return publicKey, nil
+}
+
+func toOpenAPIDevice(dbDevice *sqlcgen.Device) openapi.Device {
+	device := openapi.Device{
+		Id:        dbDevice.ID,
+		PublicKey: dbDevice.PublicKey,
+	}
+	if dbDevice.SignedBy.Valid {
+		device.SignedBy = &dbDevice.SignedBy.String
+	}
+	if dbDevice.Signature != nil {
+		device.Signature = &dbDevice.Signature
+	}
+	return device
+}
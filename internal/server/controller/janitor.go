@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RetentionPolicy defines how long the server keeps what it stores on behalf of users.
+type RetentionPolicy struct {
+	// Delay after which messages delivered to their recipient are deleted.
+	DeliveredMessages time.Duration
+	// Delay after which messages never delivered to their recipient are deleted.
+	UndeliveredMessages time.Duration
+	// Delay after which transfers are deleted, even if not downloaded by all their recipient's devices.
+	Transfers time.Duration
+}
+
+// Janitor periodically deletes what the retention policy no longer keeps, and expired refresh tokens,
+// auth challenges and prekey claims.
+type Janitor struct {
+	logger     *zap.Logger
+	controller *ServerController
+	policy     RetentionPolicy
+	interval   time.Duration
+}
+
+func NewJanitor(
+	logger *zap.Logger,
+	controller *ServerController,
+	policy RetentionPolicy,
+	interval time.Duration,
+) *Janitor {
+	return &Janitor{
+		logger:     logger.With(zap.String("component", "janitor")),
+		controller: controller,
+		policy:     policy,
+		interval:   interval,
+	}
+}
+
+// Run enforces the retention policy once right away, then every interval, until ctx is done.
+// Failures are logged and retried on the next run.
+func (j *Janitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		j.clean(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *Janitor) clean(ctx context.Context) {
+	now := time.Now()
+	delivered, undelivered, err := j.controller.PurgeMessages(
+		ctx,
+		now.Add(-j.policy.DeliveredMessages),
+		now.Add(-j.policy.UndeliveredMessages),
+	)
+	if err != nil {
+		j.logger.Error("controller.PurgeMessages", zap.Error(err))
+	}
+	if delivered > 0 || undelivered > 0 {
+		j.logger.Info(
+			"messages purged",
+			zap.Int64("delivered", delivered),
+			zap.Int64("undelivered", undelivered),
+		)
+	}
+
+	transfers, err := j.controller.CollectTransfers(ctx, j.policy.Transfers)
+	if err != nil {
+		j.logger.Error("controller.CollectTransfers", zap.Error(err))
+	}
+	if transfers > 0 {
+		j.logger.Info("transfers collected", zap.Int("count", transfers))
+	}
+
+	refreshTokens, err := j.controller.PurgeRefreshTokens(ctx)
+	if err != nil {
+		j.logger.Error("controller.PurgeRefreshTokens", zap.Error(err))
+	}
+	if refreshTokens > 0 {
+		j.logger.Info("refresh tokens purged", zap.Int64("count", refreshTokens))
+	}
+
+	authChallenges, err := j.controller.PurgeAuthChallenges(ctx)
+	if err != nil {
+		j.logger.Error("controller.PurgeAuthChallenges", zap.Error(err))
+	}
+	if authChallenges > 0 {
+		j.logger.Info("auth challenges purged", zap.Int64("count", authChallenges))
+	}
+
+	prekeyClaims, err := j.controller.PurgePrekeyClaims(ctx)
+	if err != nil {
+		j.logger.Error("controller.PurgePrekeyClaims", zap.Error(err))
+	}
+	if prekeyClaims > 0 {
+		j.logger.Info("prekey claims purged", zap.Int64("count", prekeyClaims))
+	}
+}
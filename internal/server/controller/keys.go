@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/server/database/sqlcgen"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// RotateUserKey replaces the user's public key with a new key signed by the current one, and records
+// the rotation. All the user's devices are revoked, as they were trusted through the replaced key,
+// which invalidates their outstanding JWTs. The new key becomes the user's first device.
+func (s *ServerController) RotateUserKey(
+	ctx context.Context,
+	username openapi.Username,
+	rotation *openapi.KeyRotation,
+) error {
+	newPublicKey, err := cryptography.UnmarshalPublicKey(rotation.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %w", types.ErrInvalidKey, err)
+	}
+
+	queries := sqlcgen.New(s.db)
+	user, err := queries.GetUser(ctx, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.ErrNotFound
+		}
+		return fmt.Errorf("queries.GetUser: %w", err)
+	}
+	if !bytes.Equal(user.PublicKey, rotation.OldPublicKey) {
+		return types.ErrStaleKey
+	}
+	oldPublicKey, err := cryptography.UnmarshalPublicKey(user.PublicKey)
+	if err != nil {
+		return fmt.Errorf("cryptography.UnmarshalPublicKey: %w", err)
+	}
+	err = cryptography.Verify(oldPublicKey, types.KeyRotationPayload(username, rotation.PublicKey), rotation.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %w", types.ErrInvalidKey, err)
+	}
+	// Keys are never reused, so that a revoked device cannot come back
+	newDeviceID := cryptography.Fingerprint(newPublicKey)
+	_, err = queries.GetDevice(ctx, newDeviceID)
+	if err == nil {
+		return fmt.Errorf("%w: key already used", types.ErrInvalidKey)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("queries.GetDevice: %w", err)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("db.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	txQueries := queries.WithTx(tx)
+
+	_, err = txQueries.RotateUserKey(ctx, sqlcgen.RotateUserKeyParams{
+		NewPublicKey: rotation.PublicKey,
+		Name:         username,
+		OldPublicKey: rotation.OldPublicKey,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Rotated concurrently
+			return types.ErrStaleKey
+		}
+		return fmt.Errorf("txQueries.RotateUserKey: %w", err)
+	}
+	err = txQueries.InsertKeyRotation(ctx, sqlcgen.InsertKeyRotationParams{
+		Username:     username,
+		OldPublicKey: rotation.OldPublicKey,
+		NewPublicKey: rotation.PublicKey,
+		Signature:    rotation.Signature,
+	})
+	if err != nil {
+		return fmt.Errorf("txQueries.InsertKeyRotation: %w", err)
+	}
+	err = txQueries.RevokeDevices(ctx, username)
+	if err != nil {
+		return fmt.Errorf("txQueries.RevokeDevices: %w", err)
+	}
+	_, err = txQueries.InsertDevice(ctx, sqlcgen.InsertDeviceParams{
+		ID:        newDeviceID,
+		Username:  username,
+		PublicKey: rotation.PublicKey,
+	})
+	if err != nil {
+		return fmt.Errorf("txQueries.InsertDevice: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+	return nil
+}
+
+// ListKeyRotations returns the user's key rotations, oldest first.
+func (s *ServerController) ListKeyRotations(
+	ctx context.Context,
+	username openapi.Username,
+) ([]openapi.KeyRotation, error) {
+	queries := sqlcgen.New(s.db)
+	_, err := queries.GetUser(ctx, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrNotFound
+		}
+		return nil, fmt.Errorf("queries.GetUser: %w", err)
+	}
+	dbRotations, err := queries.ListKeyRotations(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("queries.ListKeyRotations: %w", err)
+	}
+	rotations := make([]openapi.KeyRotation, len(dbRotations))
+	for i, dbRotation := range dbRotations {
+		rotations[i] = openapi.KeyRotation{
+			OldPublicKey: dbRotation.OldPublicKey,
+			PublicKey:    dbRotation.NewPublicKey,
+			Signature:    dbRotation.Signature,
+			RotatedAt:    &dbRotation.RotatedAt.Time,
+		}
+	}
+	return rotations, nil
+}
@@ -0,0 +1,270 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+
+	"github.com/marc921/talk/internal/cryptography"
+	"github.com/marc921/talk/internal/server/database/sqlcgen"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+const (
+	// LowOneTimePrekeys is the pool size under which clients are asked to upload more one-time prekeys.
+	LowOneTimePrekeys = 10
+	// MaxOneTimePrekeysUpload bounds the number of one-time prekeys uploaded at once.
+	MaxOneTimePrekeysUpload = 100
+	// PrekeyClaimWindow is the period over which prekey claims are counted.
+	PrekeyClaimWindow = time.Hour
+	// MaxPrekeyClaims bounds the bundles a user claims per window, from all users together.
+	MaxPrekeyClaims = 60
+	// MaxOneTimePrekeyClaims bounds the one-time prekeys of a user handed out per window. Beyond it,
+	// bundles only hold the signed prekey, so that claimers cannot drain the pool.
+	MaxOneTimePrekeyClaims = 20
+	// OneTimePrekeyHold is how long a claimer is deemed to hold a one-time prekey of a user, and is not
+	// handed out another one. Claims are kept as long.
+	OneTimePrekeyHold = 7 * 24 * time.Hour
+)
+
+// UploadPrekeys stores the user's new signed prekey, replacing the previous one, and one-time prekeys.
+// The signed prekey must be signed by the user's private key.
+func (s *ServerController) UploadPrekeys(
+	ctx context.Context,
+	username openapi.Username,
+	upload *openapi.PrekeyUpload,
+) (*openapi.PrekeyStatus, error) {
+	publicKey, err := s.GetUserPublicKey(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("GetUserPublicKey: %w", err)
+	}
+
+	var oneTimePrekeys []openapi.Prekey
+	if upload.OneTimePrekeys != nil {
+		oneTimePrekeys = *upload.OneTimePrekeys
+	}
+	if len(oneTimePrekeys) > MaxOneTimePrekeysUpload {
+		return nil, fmt.Errorf("%w: more than %d one-time prekeys", types.ErrInvalidPrekey, MaxOneTimePrekeysUpload)
+	}
+	for _, prekey := range oneTimePrekeys {
+		err = cryptography.ValidateX25519PublicKey(prekey.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", types.ErrInvalidPrekey, err)
+		}
+	}
+	if upload.SignedPrekey != nil {
+		err = cryptography.ValidateX25519PublicKey(upload.SignedPrekey.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", types.ErrInvalidPrekey, err)
+		}
+		if upload.SignedPrekey.Signature == nil {
+			return nil, fmt.Errorf("%w: missing signature", types.ErrInvalidPrekey)
+		}
+		err = cryptography.Verify(publicKey, upload.SignedPrekey.PublicKey, *upload.SignedPrekey.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", types.ErrInvalidPrekey, err)
+		}
+	}
+
+	queries := sqlcgen.New(s.db)
+	for _, prekey := range oneTimePrekeys {
+		err = queries.InsertPrekey(ctx, sqlcgen.InsertPrekeyParams{
+			Username:  username,
+			KeyID:     int32(prekey.Id),
+			PublicKey: prekey.PublicKey,
+			OneTime:   true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.InsertPrekey: %w", err)
+		}
+	}
+	if upload.SignedPrekey != nil {
+		err = queries.InsertPrekey(ctx, sqlcgen.InsertPrekeyParams{
+			Username:  username,
+			KeyID:     int32(upload.SignedPrekey.Id),
+			PublicKey: upload.SignedPrekey.PublicKey,
+			Signature: *upload.SignedPrekey.Signature,
+			OneTime:   false,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.InsertPrekey: %w", err)
+		}
+		err = queries.DeleteOldSignedPrekeys(ctx, sqlcgen.DeleteOldSignedPrekeysParams{
+			Username: username,
+			KeyID:    int32(upload.SignedPrekey.Id),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.DeleteOldSignedPrekeys: %w", err)
+		}
+	}
+
+	return s.GetPrekeyStatus(ctx, username)
+}
+
+// GetPrekeyStatus reports how many one-time prekeys the user has left, and whether to upload more.
+func (s *ServerController) GetPrekeyStatus(
+	ctx context.Context,
+	username openapi.Username,
+) (*openapi.PrekeyStatus, error) {
+	queries := sqlcgen.New(s.db)
+	count, err := queries.CountOneTimePrekeys(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("queries.CountOneTimePrekeys: %w", err)
+	}
+	status := &openapi.PrekeyStatus{
+		OneTimePrekeys: int(count),
+		Low:            count < LowOneTimePrekeys,
+	}
+	signedPrekey, err := queries.GetSignedPrekey(ctx, username)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("queries.GetSignedPrekey: %w", err)
+		}
+	} else {
+		signedPrekeyID := int(signedPrekey.KeyID)
+		status.SignedPrekeyId = &signedPrekeyID
+	}
+	return status, nil
+}
+
+// ClaimPrekeyBundle returns the user's signed prekey along with one of their one-time prekeys, if any left.
+// The one-time prekey is deleted in the same transaction, so that it is never handed out twice.
+// Claims are recorded: a claimer beyond MaxPrekeyClaims per window gets types.ErrRateLimited, and
+// no one-time prekey is handed out to a claimer already holding one of the user's, nor beyond
+// MaxOneTimePrekeyClaims of the user's per window.
+func (s *ServerController) ClaimPrekeyBundle(
+	ctx context.Context,
+	claimer openapi.Username,
+	username openapi.Username,
+) (*openapi.PrekeyBundle, error) {
+	queries := sqlcgen.New(s.db)
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("db.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	txQueries := queries.WithTx(tx)
+
+	err = txQueries.LockPrekeyClaims(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("txQueries.LockPrekeyClaims: %w", err)
+	}
+	now := time.Now()
+	windowStart := pgtype.Timestamptz{Time: now.Add(-PrekeyClaimWindow), Valid: true}
+	claims, err := txQueries.CountPrekeyClaimsByClaimer(ctx, sqlcgen.CountPrekeyClaimsByClaimerParams{
+		Claimer:   claimer,
+		ClaimedAt: windowStart,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("txQueries.CountPrekeyClaimsByClaimer: %w", err)
+	}
+	if claims >= MaxPrekeyClaims {
+		return nil, fmt.Errorf("%w: %d prekey claims in %s", types.ErrRateLimited, claims, PrekeyClaimWindow)
+	}
+
+	signedPrekey, err := txQueries.GetSignedPrekey(ctx, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrNotFound
+		}
+		return nil, fmt.Errorf("txQueries.GetSignedPrekey: %w", err)
+	}
+	bundle := &openapi.PrekeyBundle{
+		SignedPrekey: toOpenAPIPrekey(signedPrekey),
+	}
+
+	oneTime, err := s.mayClaimOneTimePrekey(ctx, txQueries, claimer, username, now)
+	if err != nil {
+		return nil, fmt.Errorf("mayClaimOneTimePrekey: %w", err)
+	}
+	var oneTimeKeyID pgtype.Int4
+	if oneTime {
+		oneTimePrekey, err := txQueries.ClaimOneTimePrekey(ctx, username)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("txQueries.ClaimOneTimePrekey: %w", err)
+			}
+			// Pool exhausted, the session will rely on the signed prekey only
+			s.logger.Warn("no one-time prekey left", zap.String("username", username))
+		} else {
+			prekey := toOpenAPIPrekey(oneTimePrekey)
+			bundle.OneTimePrekey = &prekey
+			oneTimeKeyID = pgtype.Int4{Int32: oneTimePrekey.KeyID, Valid: true}
+		}
+	}
+
+	err = txQueries.InsertPrekeyClaim(ctx, sqlcgen.InsertPrekeyClaimParams{
+		Claimer:      claimer,
+		Username:     username,
+		OneTimeKeyID: oneTimeKeyID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("txQueries.InsertPrekeyClaim: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("tx.Commit: %w", err)
+	}
+	return bundle, nil
+}
+
+// mayClaimOneTimePrekey tells whether one of the user's one-time prekeys may be handed out to the claimer.
+func (s *ServerController) mayClaimOneTimePrekey(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	claimer openapi.Username,
+	username openapi.Username,
+	now time.Time,
+) (bool, error) {
+	holds, err := queries.HoldsOneTimePrekey(ctx, sqlcgen.HoldsOneTimePrekeyParams{
+		Claimer:   claimer,
+		Username:  username,
+		ClaimedAt: pgtype.Timestamptz{Time: now.Add(-OneTimePrekeyHold), Valid: true},
+	})
+	if err != nil {
+		return false, fmt.Errorf("queries.HoldsOneTimePrekey: %w", err)
+	}
+	if holds {
+		return false, nil
+	}
+	handedOut, err := queries.CountOneTimePrekeyClaims(ctx, sqlcgen.CountOneTimePrekeyClaimsParams{
+		Username:  username,
+		ClaimedAt: pgtype.Timestamptz{Time: now.Add(-PrekeyClaimWindow), Valid: true},
+	})
+	if err != nil {
+		return false, fmt.Errorf("queries.CountOneTimePrekeyClaims: %w", err)
+	}
+	if handedOut >= MaxOneTimePrekeyClaims {
+		s.logger.Warn("one-time prekey claims rate limited", zap.String("username", username))
+		return false, nil
+	}
+	return true, nil
+}
+
+// PurgePrekeyClaims deletes the claims older than OneTimePrekeyHold, and returns how many were deleted.
+func (s *ServerController) PurgePrekeyClaims(ctx context.Context) (int64, error) {
+	queries := sqlcgen.New(s.db)
+	count, err := queries.DeletePrekeyClaims(ctx, pgtype.Timestamptz{Time: time.Now().Add(-OneTimePrekeyHold), Valid: true})
+	if err != nil {
+		return 0, fmt.Errorf("queries.DeletePrekeyClaims: %w", err)
+	}
+	return count, nil
+}
+
+func toOpenAPIPrekey(dbPrekey *sqlcgen.Prekey) openapi.Prekey {
+	prekey := openapi.Prekey{
+		Id:        int(dbPrekey.KeyID),
+		PublicKey: dbPrekey.PublicKey,
+	}
+	if dbPrekey.Signature != nil {
+		prekey.Signature = &dbPrekey.Signature
+	}
+	return prekey
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/marc921/talk/internal/server/database/sqlcgen"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// AddRefreshToken stores a refresh token issued to one of the user's devices.
+// Only its hash is stored, for a leak of the database not to leak usable tokens.
+func (s *ServerController) AddRefreshToken(
+	ctx context.Context,
+	username openapi.Username,
+	deviceID string,
+	token string,
+	expiresAt time.Time,
+) error {
+	queries := sqlcgen.New(s.db)
+	err := queries.InsertRefreshToken(ctx, sqlcgen.InsertRefreshTokenParams{
+		TokenHash: hashRefreshToken(token),
+		Username:  username,
+		DeviceID:  deviceID,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("queries.InsertRefreshToken: %w", err)
+	}
+	return nil
+}
+
+// UseRefreshToken revokes a refresh token of the user, to be replaced by a new one, and returns
+// the device it was issued to. A token used twice may have been stolen: all the tokens of its device
+// are revoked, and the device must authenticate again with its key.
+func (s *ServerController) UseRefreshToken(
+	ctx context.Context,
+	username openapi.Username,
+	token string,
+) (string, error) {
+	queries := sqlcgen.New(s.db)
+	tokenHash := hashRefreshToken(token)
+	dbToken, err := queries.UseRefreshToken(ctx, sqlcgen.UseRefreshTokenParams{
+		TokenHash: tokenHash,
+		Username:  username,
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("queries.UseRefreshToken: %w", err)
+		}
+		return "", s.invalidRefreshToken(ctx, queries, username, tokenHash)
+	}
+
+	// Devices are revoked when the user's key is rotated
+	_, err = s.GetDevice(ctx, username, dbToken.DeviceID)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return "", fmt.Errorf("%w: device revoked", types.ErrInvalidToken)
+		}
+		return "", fmt.Errorf("GetDevice: %w", err)
+	}
+	return dbToken.DeviceID, nil
+}
+
+// invalidRefreshToken tells why a refresh token could not be used: it is unknown, expired or was
+// already used, in which case all the tokens of its device are revoked.
+func (s *ServerController) invalidRefreshToken(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	username openapi.Username,
+	tokenHash []byte,
+) error {
+	dbToken, err := queries.GetRefreshToken(ctx, sqlcgen.GetRefreshTokenParams{
+		TokenHash: tokenHash,
+		Username:  username,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.ErrInvalidToken
+		}
+		return fmt.Errorf("queries.GetRefreshToken: %w", err)
+	}
+	if time.Now().After(dbToken.ExpiresAt.Time) {
+		return fmt.Errorf("%w: expired", types.ErrInvalidToken)
+	}
+	if dbToken.RevokedAt.Valid {
+		err = queries.RevokeDeviceRefreshTokens(ctx, dbToken.DeviceID)
+		if err != nil {
+			return fmt.Errorf("queries.RevokeDeviceRefreshTokens: %w", err)
+		}
+		return fmt.Errorf("%w: already used", types.ErrInvalidToken)
+	}
+	return types.ErrInvalidToken
+}
+
+// RevokeRefreshToken revokes a refresh token of the user. Unknown tokens are ignored.
+func (s *ServerController) RevokeRefreshToken(
+	ctx context.Context,
+	username openapi.Username,
+	token string,
+) error {
+	queries := sqlcgen.New(s.db)
+	tokenHash := hashRefreshToken(token)
+	_, err := queries.GetRefreshToken(ctx, sqlcgen.GetRefreshTokenParams{
+		TokenHash: tokenHash,
+		Username:  username,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("queries.GetRefreshToken: %w", err)
+	}
+	_, err = queries.RevokeRefreshToken(ctx, tokenHash)
+	if err != nil {
+		return fmt.Errorf("queries.RevokeRefreshToken: %w", err)
+	}
+	return nil
+}
+
+// PurgeRefreshTokens deletes the expired refresh tokens, and returns how many were deleted.
+func (s *ServerController) PurgeRefreshTokens(ctx context.Context) (int64, error) {
+	queries := sqlcgen.New(s.db)
+	count, err := queries.DeleteExpiredRefreshTokens(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("queries.DeleteExpiredRefreshTokens: %w", err)
+	}
+	return count, nil
+}
+
+func hashRefreshToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
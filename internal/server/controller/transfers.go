@@ -0,0 +1,247 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/marc921/talk/internal/server/database/sqlcgen"
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+// CreateTransfer starts a transfer of a file, in chunkCount encrypted chunks, from sender to recipient.
+func (s *ServerController) CreateTransfer(
+	ctx context.Context,
+	sender openapi.Username,
+	recipient openapi.Username,
+	chunkCount int,
+) (*openapi.Transfer, error) {
+	if chunkCount < 1 || chunkCount > types.MaxTransferChunks {
+		return nil, fmt.Errorf("%w: chunk count must be between 1 and %d", types.ErrInvalidChunk, types.MaxTransferChunks)
+	}
+
+	queries := sqlcgen.New(s.db)
+	_, err := queries.GetUser(ctx, recipient)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrNotFound
+		}
+		return nil, fmt.Errorf("queries.GetUser: %w", err)
+	}
+	transfer, err := queries.InsertTransfer(ctx, sqlcgen.InsertTransferParams{
+		Sender:     sender,
+		Recipient:  recipient,
+		ChunkCount: int32(chunkCount),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("queries.InsertTransfer: %w", err)
+	}
+	return toOpenAPITransfer(transfer, nil), nil
+}
+
+// GetTransfer returns a transfer sent or received by the user, with the indexes of the chunks uploaded so far.
+func (s *ServerController) GetTransfer(
+	ctx context.Context,
+	username openapi.Username,
+	transferID string,
+) (*openapi.Transfer, error) {
+	queries := sqlcgen.New(s.db)
+	transfer, err := s.getTransfer(ctx, queries, username, transferID)
+	if err != nil {
+		return nil, fmt.Errorf("getTransfer: %w", err)
+	}
+	received, err := queries.ListTransferChunkIndexes(ctx, transfer.ID)
+	if err != nil {
+		return nil, fmt.Errorf("queries.ListTransferChunkIndexes: %w", err)
+	}
+	return toOpenAPITransfer(transfer, received), nil
+}
+
+// PutTransferChunk stores an encrypted chunk of a transfer sent by the user.
+func (s *ServerController) PutTransferChunk(
+	ctx context.Context,
+	username openapi.Username,
+	transferID string,
+	index int,
+	data []byte,
+) error {
+	queries := sqlcgen.New(s.db)
+	transfer, err := s.getTransfer(ctx, queries, username, transferID)
+	if err != nil {
+		return fmt.Errorf("getTransfer: %w", err)
+	}
+	// Only the sender uploads chunks
+	if transfer.Sender != username {
+		return types.ErrNotFound
+	}
+	if index < 0 || index >= int(transfer.ChunkCount) {
+		return fmt.Errorf("%w: index %d out of %d chunks", types.ErrInvalidChunk, index, transfer.ChunkCount)
+	}
+	if len(data) > types.MaxTransferChunkSize {
+		return fmt.Errorf("%w: more than %d bytes", types.ErrInvalidChunk, types.MaxTransferChunkSize)
+	}
+
+	// Chunks uploaded again replace the blob of the previous upload
+	newBlob := false
+	blobID, err := queries.GetTransferChunk(ctx, sqlcgen.GetTransferChunkParams{
+		TransferID: transfer.ID,
+		ChunkIndex: int32(index),
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("queries.GetTransferChunk: %w", err)
+		}
+		blobID = pgtype.UUID{Bytes: uuid.New(), Valid: true}
+		newBlob = true
+	}
+	err = s.blobs.Put(ctx, uuid.UUID(blobID.Bytes).String(), data)
+	if err != nil {
+		return fmt.Errorf("blobs.Put: %w", err)
+	}
+
+	inserted, err := queries.InsertTransferChunk(ctx, sqlcgen.InsertTransferChunkParams{
+		TransferID: transfer.ID,
+		ChunkIndex: int32(index),
+		BlobID:     blobID,
+	})
+	if err != nil {
+		return fmt.Errorf("queries.InsertTransferChunk: %w", err)
+	}
+	if newBlob && inserted == 0 {
+		// A concurrent upload of the same chunk stored its blob first, ours would never be collected
+		err = s.blobs.Delete(ctx, uuid.UUID(blobID.Bytes).String())
+		if err != nil {
+			return fmt.Errorf("blobs.Delete: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetTransferChunk returns an encrypted chunk of a transfer sent or received by the user.
+func (s *ServerController) GetTransferChunk(
+	ctx context.Context,
+	username openapi.Username,
+	transferID string,
+	index int,
+) ([]byte, error) {
+	queries := sqlcgen.New(s.db)
+	transfer, err := s.getTransfer(ctx, queries, username, transferID)
+	if err != nil {
+		return nil, fmt.Errorf("getTransfer: %w", err)
+	}
+	blobID, err := queries.GetTransferChunk(ctx, sqlcgen.GetTransferChunkParams{
+		TransferID: transfer.ID,
+		ChunkIndex: int32(index),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrNotFound
+		}
+		return nil, fmt.Errorf("queries.GetTransferChunk: %w", err)
+	}
+	data, err := s.blobs.Get(ctx, uuid.UUID(blobID.Bytes).String())
+	if err != nil {
+		return nil, fmt.Errorf("blobs.Get: %w", err)
+	}
+	return data, nil
+}
+
+// AckTransfer records that one of the user's devices downloaded a transfer, for its chunks
+// to be deleted once all the devices of its recipient have.
+func (s *ServerController) AckTransfer(
+	ctx context.Context,
+	username openapi.Username,
+	deviceID string,
+	transferID string,
+) error {
+	queries := sqlcgen.New(s.db)
+	transfer, err := s.getTransfer(ctx, queries, username, transferID)
+	if err != nil {
+		return fmt.Errorf("getTransfer: %w", err)
+	}
+	err = queries.InsertTransferAck(ctx, sqlcgen.InsertTransferAckParams{
+		TransferID: transfer.ID,
+		DeviceID:   deviceID,
+	})
+	if err != nil {
+		return fmt.Errorf("queries.InsertTransferAck: %w", err)
+	}
+	return nil
+}
+
+// CollectTransfers deletes the transfers downloaded by all the devices of their recipient,
+// and those older than the retention period, along with the blobs of their chunks.
+// It returns the number of transfers deleted.
+func (s *ServerController) CollectTransfers(ctx context.Context, retention time.Duration) (int, error) {
+	queries := sqlcgen.New(s.db)
+	transferIDs, err := queries.ListCollectableTransfers(
+		ctx,
+		pgtype.Timestamptz{Time: time.Now().Add(-retention), Valid: true},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("queries.ListCollectableTransfers: %w", err)
+	}
+	for _, transferID := range transferIDs {
+		blobIDs, err := queries.ListTransferBlobIDs(ctx, transferID)
+		if err != nil {
+			return 0, fmt.Errorf("queries.ListTransferBlobIDs: %w", err)
+		}
+		// Blobs are deleted first, so that none is left behind if deleting the transfer fails
+		for _, blobID := range blobIDs {
+			err := s.blobs.Delete(ctx, uuid.UUID(blobID.Bytes).String())
+			if err != nil {
+				return 0, fmt.Errorf("blobs.Delete: %w", err)
+			}
+		}
+		err = queries.DeleteTransfer(ctx, transferID)
+		if err != nil {
+			return 0, fmt.Errorf("queries.DeleteTransfer: %w", err)
+		}
+	}
+	return len(transferIDs), nil
+}
+
+// getTransfer returns a transfer if the user is its sender or recipient.
+// Other users are told that it does not exist.
+func (s *ServerController) getTransfer(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	username openapi.Username,
+	transferID string,
+) (*sqlcgen.Transfer, error) {
+	parsed, err := uuid.Parse(transferID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", types.ErrNotFound, err)
+	}
+	transfer, err := queries.GetTransfer(ctx, pgtype.UUID{Bytes: parsed, Valid: true})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.ErrNotFound
+		}
+		return nil, fmt.Errorf("queries.GetTransfer: %w", err)
+	}
+	if transfer.Sender != username && transfer.Recipient != username {
+		return nil, types.ErrNotFound
+	}
+	return transfer, nil
+}
+
+func toOpenAPITransfer(transfer *sqlcgen.Transfer, received []int32) *openapi.Transfer {
+	indexes := make([]int, 0, len(received))
+	for _, index := range received {
+		indexes = append(indexes, int(index))
+	}
+	return &openapi.Transfer{
+		Id:         uuid.UUID(transfer.ID.Bytes).String(),
+		Sender:     transfer.Sender,
+		Recipient:  transfer.Recipient,
+		ChunkCount: int(transfer.ChunkCount),
+		Received:   indexes,
+	}
+}
@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE messages ADD COLUMN signature BYTEA;
+
+-- migrate:down
+ALTER TABLE messages DROP COLUMN signature;
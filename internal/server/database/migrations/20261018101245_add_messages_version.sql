@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE messages ADD COLUMN version INTEGER DEFAULT 1 NOT NULL;
+
+-- migrate:down
+ALTER TABLE messages DROP COLUMN version;
@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE messages ADD COLUMN session JSONB;
+
+-- migrate:down
+ALTER TABLE messages DROP COLUMN session;
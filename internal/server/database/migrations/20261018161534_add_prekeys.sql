@@ -0,0 +1,14 @@
+-- migrate:up
+CREATE TABLE prekeys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    username TEXT references users(name) NOT NULL,
+    key_id INTEGER NOT NULL,
+    public_key BYTEA NOT NULL,
+    signature BYTEA,
+    one_time BOOLEAN NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (username, one_time, key_id)
+);
+
+-- migrate:down
+DROP TABLE prekeys;
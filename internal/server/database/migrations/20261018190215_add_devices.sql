@@ -0,0 +1,50 @@
+-- migrate:up
+CREATE TABLE devices (
+    id TEXT PRIMARY KEY,
+    username TEXT references users(name) NOT NULL,
+    public_key BYTEA NOT NULL,
+    signed_by TEXT references devices(id),
+    signature BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Every existing user gets their key as first device. Its ID is the fingerprint of the key in the
+-- PEM encoding the server marshals it to, which the stored key may differ from: the DER body is
+-- extracted from the stored PEM, dropping any header, and encoded again in 64 character lines.
+INSERT INTO devices (id, username, public_key, created_at)
+SELECT
+    encode(sha256(convert_to(
+        E'-----BEGIN RSA PUBLIC KEY-----\n'
+        || regexp_replace(
+            translate(encode(decode(regexp_replace(regexp_replace(
+                substring(convert_from(public_key, 'UTF8') FROM '-----BEGIN [^\n]*-----(.*)-----END '),
+                '^[^\n]*:[^\n]*$', '', 'gn'),
+                '\s', '', 'g'), 'base64'), 'base64'), E'\n', ''),
+            '(.{1,64})', E'\\1\n', 'g')
+        || E'-----END RSA PUBLIC KEY-----\n',
+        'UTF8'
+    )), 'hex'),
+    name, public_key, created_at
+FROM users;
+
+ALTER TABLE messages ADD COLUMN sender_device TEXT;
+ALTER TABLE messages ADD COLUMN keys JSONB;
+
+CREATE TABLE message_deliveries (
+    message_id UUID references messages(id) ON DELETE CASCADE NOT NULL,
+    device_id TEXT references devices(id) NOT NULL,
+    delivered_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (message_id, device_id)
+);
+
+-- Messages already delivered are not delivered again to the first device
+INSERT INTO message_deliveries (message_id, device_id, delivered_at)
+SELECT messages.id, devices.id, messages.delivered_at FROM messages
+JOIN devices ON devices.username = messages.recipient
+WHERE messages.delivered_at IS NOT NULL;
+
+-- migrate:down
+DROP TABLE message_deliveries;
+ALTER TABLE messages DROP COLUMN keys;
+ALTER TABLE messages DROP COLUMN sender_device;
+DROP TABLE devices;
@@ -0,0 +1,15 @@
+-- migrate:up
+ALTER TABLE devices ADD COLUMN revoked_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE key_rotations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    username TEXT references users(name) NOT NULL,
+    old_public_key BYTEA NOT NULL,
+    new_public_key BYTEA NOT NULL,
+    signature BYTEA NOT NULL,
+    rotated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- migrate:down
+DROP TABLE key_rotations;
+ALTER TABLE devices DROP COLUMN revoked_at;
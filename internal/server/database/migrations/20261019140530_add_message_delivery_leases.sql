@@ -0,0 +1,10 @@
+-- migrate:up
+-- Messages fetched by a device are leased until the device acknowledges them, and delivered again
+-- once the lease expires
+ALTER TABLE message_deliveries ADD COLUMN leased_until TIMESTAMP WITH TIME ZONE;
+ALTER TABLE message_deliveries ALTER COLUMN delivered_at DROP DEFAULT;
+
+-- migrate:down
+DELETE FROM message_deliveries WHERE delivered_at IS NULL;
+ALTER TABLE message_deliveries ALTER COLUMN delivered_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE message_deliveries DROP COLUMN leased_until;
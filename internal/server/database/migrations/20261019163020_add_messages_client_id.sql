@@ -0,0 +1,8 @@
+-- migrate:up
+-- Generated by the sender's client, for retried posts not to be stored twice
+ALTER TABLE messages ADD COLUMN client_id UUID;
+ALTER TABLE messages ADD CONSTRAINT messages_sender_client_id_key UNIQUE (sender, client_id);
+
+-- migrate:down
+ALTER TABLE messages DROP CONSTRAINT messages_sender_client_id_key;
+ALTER TABLE messages DROP COLUMN client_id;
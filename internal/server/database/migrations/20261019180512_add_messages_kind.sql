@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE messages ADD COLUMN kind TEXT;
+
+-- migrate:down
+ALTER TABLE messages DROP COLUMN kind;
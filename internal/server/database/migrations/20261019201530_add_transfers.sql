@@ -0,0 +1,20 @@
+-- migrate:up
+-- Files are uploaded in encrypted chunks, and described to their recipient by a message of kind file
+CREATE TABLE transfers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    sender TEXT references users(name) NOT NULL,
+    recipient TEXT references users(name) NOT NULL,
+    chunk_count INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE transfer_chunks (
+    transfer_id UUID references transfers(id) ON DELETE CASCADE NOT NULL,
+    chunk_index INTEGER NOT NULL,
+    data BYTEA NOT NULL,
+    PRIMARY KEY (transfer_id, chunk_index)
+);
+
+-- migrate:down
+DROP TABLE transfer_chunks;
+DROP TABLE transfers;
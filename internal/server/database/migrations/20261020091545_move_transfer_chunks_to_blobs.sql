@@ -0,0 +1,20 @@
+-- migrate:up
+-- Chunks are stored in the blob store, and only referenced here. Chunks already stored in the
+-- database are dropped: clients resume their uploads with the chunks the server is missing.
+DELETE FROM transfer_chunks;
+ALTER TABLE transfer_chunks DROP COLUMN data;
+ALTER TABLE transfer_chunks ADD COLUMN blob_id UUID NOT NULL;
+
+-- Devices acknowledge transfers once downloaded, for their blobs to be deleted once all have
+CREATE TABLE transfer_acks (
+    transfer_id UUID references transfers(id) ON DELETE CASCADE NOT NULL,
+    device_id TEXT references devices(id) NOT NULL,
+    acked_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (transfer_id, device_id)
+);
+
+-- migrate:down
+DROP TABLE transfer_acks;
+DELETE FROM transfer_chunks;
+ALTER TABLE transfer_chunks DROP COLUMN blob_id;
+ALTER TABLE transfer_chunks ADD COLUMN data BYTEA NOT NULL;
@@ -0,0 +1,14 @@
+-- migrate:up
+-- Refresh tokens are only stored hashed. Each is used once, being replaced by a new one:
+-- tokens used or revoked are kept until they expire, to detect them being used again.
+CREATE TABLE refresh_tokens (
+    token_hash BYTEA PRIMARY KEY,
+    username TEXT references users(name) NOT NULL,
+    device_id TEXT references devices(id) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+-- migrate:down
+DROP TABLE refresh_tokens;
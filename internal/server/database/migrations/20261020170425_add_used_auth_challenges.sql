@@ -0,0 +1,10 @@
+-- migrate:up
+-- Nonces of the auth challenges already answered, kept until the challenges expire so that
+-- signed challenges cannot be replayed.
+CREATE TABLE used_auth_challenges (
+    nonce TEXT PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- migrate:down
+DROP TABLE used_auth_challenges;
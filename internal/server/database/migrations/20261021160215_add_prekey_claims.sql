@@ -0,0 +1,14 @@
+-- migrate:up
+-- Prekey bundles handed out, to rate limit claims and not hand out several one-time prekeys
+-- of a user to the same claimer.
+CREATE TABLE prekey_claims (
+    claimer TEXT NOT NULL REFERENCES users(name),
+    username TEXT NOT NULL REFERENCES users(name),
+    one_time_key_id INTEGER,
+    claimed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX prekey_claims_claimer_claimed_at_idx ON prekey_claims (claimer, claimed_at);
+CREATE INDEX prekey_claims_username_claimed_at_idx ON prekey_claims (username, claimed_at);
+
+-- migrate:down
+DROP TABLE prekey_claims;
@@ -0,0 +1,8 @@
+-- name: InsertUsedAuthChallenge :execrows
+-- No row is inserted when the challenge was already used.
+INSERT INTO used_auth_challenges (nonce, expires_at)
+VALUES ($1, $2)
+ON CONFLICT (nonce) DO NOTHING;
+
+-- name: DeleteExpiredAuthChallenges :execrows
+DELETE FROM used_auth_challenges WHERE expires_at < CURRENT_TIMESTAMP;
@@ -0,0 +1,17 @@
+-- name: InsertDevice :one
+INSERT INTO devices (id, username, public_key, signed_by, signature)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (id) DO NOTHING
+RETURNING *;
+
+-- name: GetDevice :one
+SELECT * FROM devices WHERE id = $1;
+
+-- name: ListDevices :many
+SELECT * FROM devices
+WHERE username = $1 AND revoked_at IS NULL
+ORDER BY created_at;
+
+-- name: RevokeDevices :exec
+UPDATE devices SET revoked_at = CURRENT_TIMESTAMP
+WHERE username = $1 AND revoked_at IS NULL;
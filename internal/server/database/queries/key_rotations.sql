@@ -0,0 +1,8 @@
+-- name: InsertKeyRotation :exec
+INSERT INTO key_rotations (username, old_public_key, new_public_key, signature)
+VALUES ($1, $2, $3, $4);
+
+-- name: ListKeyRotations :many
+SELECT * FROM key_rotations
+WHERE username = $1
+ORDER BY rotated_at;
\ No newline at end of file
@@ -1,19 +1,118 @@
 -- name: InsertMessage :one
-INSERT INTO messages (sender, recipient, cipher_sym_key, ciphertext, sent_at)
-VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+-- Messages already stored with the same client ID are not stored again.
+INSERT INTO messages (sender, recipient, cipher_sym_key, ciphertext, signature, version, session, sender_device, keys, client_id, kind, sent_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, CURRENT_TIMESTAMP)
+ON CONFLICT (sender, client_id) DO NOTHING
 RETURNING *;
 
+-- name: GetMessage :one
+SELECT * FROM messages WHERE id = $1;
+
+-- name: GetMessageByClientID :one
+SELECT * FROM messages WHERE sender = $1 AND client_id = $2;
+
 -- name: GetUndeliveredMessages :many
-SELECT * FROM messages
+-- Messages for a device: those sent to its user, and those sent from its user's other devices.
+-- Envelopes without per-device keys can only be read by the user's first device.
+SELECT messages.* FROM messages
+JOIN devices ON
+	devices.id = sqlc.arg(device_id) AND
+	devices.username = sqlc.arg(username)
 WHERE
-	recipient = $1 AND
-	delivered_at IS NULL;
+	messages.sent_at >= devices.created_at AND
+	(
+		(messages.recipient = sqlc.arg(username) AND (messages.keys IS NOT NULL OR devices.signed_by IS NULL)) OR
+		(messages.sender = sqlc.arg(username) AND messages.keys IS NOT NULL AND messages.sender_device <> devices.id)
+	) AND
+	NOT EXISTS (
+		SELECT 1 FROM message_deliveries
+		WHERE
+			message_deliveries.message_id = messages.id AND
+			message_deliveries.device_id = devices.id AND
+			(message_deliveries.delivered_at IS NOT NULL OR message_deliveries.leased_until > CURRENT_TIMESTAMP)
+	)
+ORDER BY messages.sent_at;
+
+-- name: LeaseMessageDelivery :exec
+-- Messages leased to a device are not fetched again until the lease expires or is renewed.
+-- Messages already acknowledged by the device are never leased again.
+INSERT INTO message_deliveries (message_id, device_id, leased_until)
+VALUES ($1, $2, $3)
+ON CONFLICT (message_id, device_id) DO UPDATE SET leased_until = EXCLUDED.leased_until
+WHERE message_deliveries.delivered_at IS NULL;
+
+-- name: ListCorrespondents :many
+-- Users who sent messages to, or received messages from, a user.
+SELECT recipient FROM messages WHERE sender = sqlc.arg(username)
+UNION
+SELECT sender FROM messages WHERE recipient = sqlc.arg(username);
+
+-- name: AckMessageDeliveries :many
+-- Marks messages leased to a device as delivered to it, returning those not acknowledged before.
+UPDATE message_deliveries SET delivered_at = CURRENT_TIMESTAMP, leased_until = NULL
+WHERE
+	device_id = sqlc.arg(device_id) AND
+	message_id = ANY(sqlc.arg(ids)::uuid[]) AND
+	delivered_at IS NULL
+RETURNING message_id;
 
 -- name: SetMessageSent :exec
 UPDATE messages SET sent_at = CURRENT_TIMESTAMP WHERE id = $1;
 
--- name: SetMessageDelivered :exec
-UPDATE messages SET delivered_at = CURRENT_TIMESTAMP WHERE id = $1;
+-- name: SetMessagesDelivered :exec
+UPDATE messages SET delivered_at = CURRENT_TIMESTAMP
+WHERE
+	id = ANY(sqlc.arg(ids)::uuid[]) AND
+	recipient = sqlc.arg(recipient) AND
+	delivered_at IS NULL;
 
 -- name: SetMessageRead :exec
 UPDATE messages SET read_at = CURRENT_TIMESTAMP WHERE id = $1;
+
+-- name: DeleteDeliveredMessages :execrows
+-- Messages delivered to their recipient before the retention period, and acknowledged by every
+-- device they are for (see GetUndeliveredMessages). The recipient is told delivered on the first
+-- acknowledgement, so the other devices may not have fetched the message yet.
+DELETE FROM messages
+WHERE
+	delivered_at < sqlc.arg(delivered_before) AND
+	NOT EXISTS (
+		SELECT 1 FROM devices
+		WHERE
+			devices.revoked_at IS NULL AND
+			messages.sent_at >= devices.created_at AND
+			(
+				(devices.username = messages.recipient AND (messages.keys IS NOT NULL OR devices.signed_by IS NULL)) OR
+				(devices.username = messages.sender AND messages.keys IS NOT NULL AND messages.sender_device <> devices.id)
+			) AND
+			NOT EXISTS (
+				SELECT 1 FROM message_deliveries
+				WHERE
+					message_deliveries.message_id = messages.id AND
+					message_deliveries.device_id = devices.id AND
+					message_deliveries.delivered_at IS NOT NULL
+			)
+	);
+
+-- name: DeleteUndeliveredMessages :execrows
+-- Messages not acknowledged by every device they are for, sent before the retention period.
+DELETE FROM messages
+WHERE
+	sent_at < sqlc.arg(sent_before) AND
+	(delivered_at IS NULL OR EXISTS (
+		SELECT 1 FROM devices
+		WHERE
+			devices.revoked_at IS NULL AND
+			messages.sent_at >= devices.created_at AND
+			(
+				(devices.username = messages.recipient AND (messages.keys IS NOT NULL OR devices.signed_by IS NULL)) OR
+				(devices.username = messages.sender AND messages.keys IS NOT NULL AND messages.sender_device <> devices.id)
+			) AND
+			NOT EXISTS (
+				SELECT 1 FROM message_deliveries
+				WHERE
+					message_deliveries.message_id = messages.id AND
+					message_deliveries.device_id = devices.id AND
+					message_deliveries.delivered_at IS NOT NULL
+			)
+	));
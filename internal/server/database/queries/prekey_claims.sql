@@ -0,0 +1,36 @@
+-- name: InsertPrekeyClaim :exec
+INSERT INTO prekey_claims (claimer, username, one_time_key_id)
+VALUES ($1, $2, $3);
+
+-- name: CountPrekeyClaimsByClaimer :one
+-- Bundles of any user claimed by a claimer since a given time.
+SELECT count(*) FROM prekey_claims
+WHERE
+	claimer = $1 AND
+	claimed_at > $2;
+
+-- name: CountOneTimePrekeyClaims :one
+-- One-time prekeys of a user handed out since a given time.
+SELECT count(*) FROM prekey_claims
+WHERE
+	username = $1 AND
+	one_time_key_id IS NOT NULL AND
+	claimed_at > $2;
+
+-- name: HoldsOneTimePrekey :one
+-- Whether a claimer was handed out one of the user's one-time prekeys since a given time.
+SELECT EXISTS (
+	SELECT 1 FROM prekey_claims
+	WHERE
+		claimer = $1 AND
+		username = $2 AND
+		one_time_key_id IS NOT NULL AND
+		claimed_at > $3
+);
+
+-- name: DeletePrekeyClaims :execrows
+DELETE FROM prekey_claims WHERE claimed_at < $1;
+
+-- name: LockPrekeyClaims :exec
+-- Serializes the claims of a user's prekeys until the end of the transaction.
+SELECT pg_advisory_xact_lock(hashtext($1));
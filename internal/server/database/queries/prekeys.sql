@@ -0,0 +1,38 @@
+-- name: InsertPrekey :exec
+INSERT INTO prekeys (username, key_id, public_key, signature, one_time)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (username, one_time, key_id) DO NOTHING;
+
+-- name: GetSignedPrekey :one
+SELECT * FROM prekeys
+WHERE
+	username = $1 AND
+	NOT one_time
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: DeleteOldSignedPrekeys :exec
+DELETE FROM prekeys
+WHERE
+	username = $1 AND
+	NOT one_time AND
+	key_id <> $2;
+
+-- name: ClaimOneTimePrekey :one
+DELETE FROM prekeys
+WHERE id = (
+	SELECT id FROM prekeys
+	WHERE
+		username = $1 AND
+		one_time
+	ORDER BY created_at, key_id
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CountOneTimePrekeys :one
+SELECT count(*) FROM prekeys
+WHERE
+	username = $1 AND
+	one_time;
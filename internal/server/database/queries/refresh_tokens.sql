@@ -0,0 +1,25 @@
+-- name: InsertRefreshToken :exec
+INSERT INTO refresh_tokens (token_hash, username, device_id, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetRefreshToken :one
+SELECT * FROM refresh_tokens WHERE token_hash = $1 AND username = $2;
+
+-- name: UseRefreshToken :one
+-- Revokes a valid token and returns it, in a single statement for concurrent requests using the same
+-- token not to both succeed.
+UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+WHERE token_hash = $1 AND username = $2 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: RevokeRefreshToken :execrows
+-- Only one of concurrent requests using the same token revokes it.
+UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+WHERE token_hash = $1 AND revoked_at IS NULL;
+
+-- name: RevokeDeviceRefreshTokens :exec
+UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+WHERE device_id = $1 AND revoked_at IS NULL;
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens WHERE expires_at < CURRENT_TIMESTAMP;
@@ -0,0 +1,54 @@
+-- name: InsertTransfer :one
+INSERT INTO transfers (sender, recipient, chunk_count)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetTransfer :one
+SELECT * FROM transfers WHERE id = $1;
+
+-- name: ListTransferChunkIndexes :many
+SELECT chunk_index FROM transfer_chunks WHERE transfer_id = $1 ORDER BY chunk_index;
+
+-- name: InsertTransferChunk :execrows
+-- Chunks uploaded again, when resuming an interrupted upload, replace the blob of the previous ones.
+INSERT INTO transfer_chunks (transfer_id, chunk_index, blob_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (transfer_id, chunk_index) DO NOTHING;
+
+-- name: GetTransferChunk :one
+SELECT blob_id FROM transfer_chunks WHERE transfer_id = $1 AND chunk_index = $2;
+
+-- name: InsertTransferAck :exec
+INSERT INTO transfer_acks (transfer_id, device_id)
+VALUES ($1, $2)
+ON CONFLICT (transfer_id, device_id) DO NOTHING;
+
+-- name: ListCollectableTransfers :many
+-- Transfers created before the retention period, and those downloaded by all the devices their
+-- recipient had when they were created.
+SELECT transfers.id FROM transfers
+WHERE
+	transfers.created_at < sqlc.arg(created_before) OR
+	(
+		EXISTS (SELECT 1 FROM transfer_acks WHERE transfer_acks.transfer_id = transfers.id) AND
+		NOT EXISTS (
+			SELECT 1 FROM devices
+			WHERE
+				devices.username = transfers.recipient AND
+				devices.created_at <= transfers.created_at AND
+				devices.revoked_at IS NULL AND
+				NOT EXISTS (
+					SELECT 1 FROM transfer_acks
+					WHERE
+						transfer_acks.transfer_id = transfers.id AND
+						transfer_acks.device_id = devices.id
+				)
+		)
+	);
+
+-- name: ListTransferBlobIDs :many
+SELECT blob_id FROM transfer_chunks WHERE transfer_id = $1;
+
+-- name: DeleteTransfer :exec
+-- Chunks and acknowledgements are deleted along with the transfer.
+DELETE FROM transfers WHERE id = $1;
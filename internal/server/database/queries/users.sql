@@ -8,4 +8,12 @@ RETURNING *;
 SELECT * FROM users WHERE name = $1;
 
 -- name: ListUsers :many
-SELECT * FROM users;
\ No newline at end of file
+SELECT * FROM users;
+
+-- name: RotateUserKey :one
+-- Only rotates from the expected current key, so that concurrent rotations cannot both succeed.
+UPDATE users SET
+    public_key = sqlc.arg(new_public_key),
+    updated_at = CURRENT_TIMESTAMP
+WHERE name = sqlc.arg(name) AND public_key = sqlc.arg(old_public_key)
+RETURNING *;
\ No newline at end of file
@@ -28,6 +28,47 @@ SET default_tablespace = '';
 
 SET default_table_access_method = heap;
 
+--
+-- Name: devices; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.devices (
+    id text NOT NULL,
+    username text NOT NULL,
+    public_key bytea NOT NULL,
+    signed_by text,
+    signature bytea,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    revoked_at timestamp with time zone
+);
+
+
+--
+-- Name: key_rotations; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.key_rotations (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    username text NOT NULL,
+    old_public_key bytea NOT NULL,
+    new_public_key bytea NOT NULL,
+    signature bytea NOT NULL,
+    rotated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: message_deliveries; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.message_deliveries (
+    message_id uuid NOT NULL,
+    device_id text NOT NULL,
+    delivered_at timestamp with time zone,
+    leased_until timestamp with time zone
+);
+
+
 --
 -- Name: messages; Type: TABLE; Schema: public; Owner: -
 --
@@ -40,7 +81,55 @@ CREATE TABLE public.messages (
     ciphertext bytea NOT NULL,
     sent_at timestamp with time zone,
     delivered_at timestamp with time zone,
-    read_at timestamp with time zone
+    read_at timestamp with time zone,
+    signature bytea,
+    version integer DEFAULT 1 NOT NULL,
+    session jsonb,
+    sender_device text,
+    keys jsonb,
+    client_id uuid,
+    kind text
+);
+
+
+--
+-- Name: prekey_claims; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.prekey_claims (
+    claimer text NOT NULL,
+    username text NOT NULL,
+    one_time_key_id integer,
+    claimed_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+--
+-- Name: prekeys; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.prekeys (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    username text NOT NULL,
+    key_id integer NOT NULL,
+    public_key bytea NOT NULL,
+    signature bytea,
+    one_time boolean NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: refresh_tokens; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.refresh_tokens (
+    token_hash bytea NOT NULL,
+    username text NOT NULL,
+    device_id text NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    expires_at timestamp with time zone NOT NULL,
+    revoked_at timestamp with time zone
 );
 
 
@@ -53,6 +142,51 @@ CREATE TABLE public.schema_migrations (
 );
 
 
+--
+-- Name: transfer_acks; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.transfer_acks (
+    transfer_id uuid NOT NULL,
+    device_id text NOT NULL,
+    acked_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: transfer_chunks; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.transfer_chunks (
+    transfer_id uuid NOT NULL,
+    chunk_index integer NOT NULL,
+    blob_id uuid NOT NULL
+);
+
+
+--
+-- Name: transfers; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.transfers (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    sender text NOT NULL,
+    recipient text NOT NULL,
+    chunk_count integer NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: used_auth_challenges; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.used_auth_challenges (
+    nonce text NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+
 --
 -- Name: users; Type: TABLE; Schema: public; Owner: -
 --
@@ -66,6 +200,30 @@ CREATE TABLE public.users (
 );
 
 
+--
+-- Name: devices devices_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.devices
+    ADD CONSTRAINT devices_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: key_rotations key_rotations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.key_rotations
+    ADD CONSTRAINT key_rotations_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: message_deliveries message_deliveries_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.message_deliveries
+    ADD CONSTRAINT message_deliveries_pkey PRIMARY KEY (message_id, device_id);
+
+
 --
 -- Name: messages messages_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -74,6 +232,38 @@ ALTER TABLE ONLY public.messages
     ADD CONSTRAINT messages_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: messages messages_sender_client_id_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.messages
+    ADD CONSTRAINT messages_sender_client_id_key UNIQUE (sender, client_id);
+
+
+--
+-- Name: prekeys prekeys_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.prekeys
+    ADD CONSTRAINT prekeys_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: prekeys prekeys_username_one_time_key_id_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.prekeys
+    ADD CONSTRAINT prekeys_username_one_time_key_id_key UNIQUE (username, one_time, key_id);
+
+
+--
+-- Name: refresh_tokens refresh_tokens_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.refresh_tokens
+    ADD CONSTRAINT refresh_tokens_pkey PRIMARY KEY (token_hash);
+
+
 --
 -- Name: schema_migrations schema_migrations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -82,6 +272,38 @@ ALTER TABLE ONLY public.schema_migrations
     ADD CONSTRAINT schema_migrations_pkey PRIMARY KEY (version);
 
 
+--
+-- Name: transfer_acks transfer_acks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.transfer_acks
+    ADD CONSTRAINT transfer_acks_pkey PRIMARY KEY (transfer_id, device_id);
+
+
+--
+-- Name: transfer_chunks transfer_chunks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.transfer_chunks
+    ADD CONSTRAINT transfer_chunks_pkey PRIMARY KEY (transfer_id, chunk_index);
+
+
+--
+-- Name: transfers transfers_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.transfers
+    ADD CONSTRAINT transfers_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: used_auth_challenges used_auth_challenges_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.used_auth_challenges
+    ADD CONSTRAINT used_auth_challenges_pkey PRIMARY KEY (nonce);
+
+
 --
 -- Name: users users_name_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -98,6 +320,60 @@ ALTER TABLE ONLY public.users
     ADD CONSTRAINT users_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: prekey_claims_claimer_claimed_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX prekey_claims_claimer_claimed_at_idx ON public.prekey_claims USING btree (claimer, claimed_at);
+
+
+--
+-- Name: prekey_claims_username_claimed_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX prekey_claims_username_claimed_at_idx ON public.prekey_claims USING btree (username, claimed_at);
+
+
+--
+-- Name: devices devices_signed_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.devices
+    ADD CONSTRAINT devices_signed_by_fkey FOREIGN KEY (signed_by) REFERENCES public.devices(id);
+
+
+--
+-- Name: devices devices_username_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.devices
+    ADD CONSTRAINT devices_username_fkey FOREIGN KEY (username) REFERENCES public.users(name);
+
+
+--
+-- Name: key_rotations key_rotations_username_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.key_rotations
+    ADD CONSTRAINT key_rotations_username_fkey FOREIGN KEY (username) REFERENCES public.users(name);
+
+
+--
+-- Name: message_deliveries message_deliveries_device_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.message_deliveries
+    ADD CONSTRAINT message_deliveries_device_id_fkey FOREIGN KEY (device_id) REFERENCES public.devices(id);
+
+
+--
+-- Name: message_deliveries message_deliveries_message_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.message_deliveries
+    ADD CONSTRAINT message_deliveries_message_id_fkey FOREIGN KEY (message_id) REFERENCES public.messages(id) ON DELETE CASCADE;
+
+
 --
 -- Name: messages messages_recipient_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -114,6 +390,86 @@ ALTER TABLE ONLY public.messages
     ADD CONSTRAINT messages_sender_fkey FOREIGN KEY (sender) REFERENCES public.users(name);
 
 
+--
+-- Name: prekey_claims prekey_claims_claimer_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.prekey_claims
+    ADD CONSTRAINT prekey_claims_claimer_fkey FOREIGN KEY (claimer) REFERENCES public.users(name);
+
+
+--
+-- Name: prekey_claims prekey_claims_username_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.prekey_claims
+    ADD CONSTRAINT prekey_claims_username_fkey FOREIGN KEY (username) REFERENCES public.users(name);
+
+
+--
+-- Name: prekeys prekeys_username_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.prekeys
+    ADD CONSTRAINT prekeys_username_fkey FOREIGN KEY (username) REFERENCES public.users(name);
+
+
+--
+-- Name: refresh_tokens refresh_tokens_device_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.refresh_tokens
+    ADD CONSTRAINT refresh_tokens_device_id_fkey FOREIGN KEY (device_id) REFERENCES public.devices(id);
+
+
+--
+-- Name: refresh_tokens refresh_tokens_username_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.refresh_tokens
+    ADD CONSTRAINT refresh_tokens_username_fkey FOREIGN KEY (username) REFERENCES public.users(name);
+
+
+--
+-- Name: transfer_acks transfer_acks_device_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.transfer_acks
+    ADD CONSTRAINT transfer_acks_device_id_fkey FOREIGN KEY (device_id) REFERENCES public.devices(id);
+
+
+--
+-- Name: transfer_acks transfer_acks_transfer_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.transfer_acks
+    ADD CONSTRAINT transfer_acks_transfer_id_fkey FOREIGN KEY (transfer_id) REFERENCES public.transfers(id) ON DELETE CASCADE;
+
+
+--
+-- Name: transfer_chunks transfer_chunks_transfer_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.transfer_chunks
+    ADD CONSTRAINT transfer_chunks_transfer_id_fkey FOREIGN KEY (transfer_id) REFERENCES public.transfers(id) ON DELETE CASCADE;
+
+
+--
+-- Name: transfers transfers_recipient_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.transfers
+    ADD CONSTRAINT transfers_recipient_fkey FOREIGN KEY (recipient) REFERENCES public.users(name);
+
+
+--
+-- Name: transfers transfers_sender_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.transfers
+    ADD CONSTRAINT transfers_sender_fkey FOREIGN KEY (sender) REFERENCES public.users(name);
+
+
 --
 -- PostgreSQL database dump complete
 --
@@ -124,4 +480,18 @@ ALTER TABLE ONLY public.messages
 --
 
 INSERT INTO public.schema_migrations (version) VALUES
-    ('20250315125335');
+    ('20250315125335'),
+    ('20261018093512'),
+    ('20261018101245'),
+    ('20261018143027'),
+    ('20261018161534'),
+    ('20261018190215'),
+    ('20261018203045'),
+    ('20261019140530'),
+    ('20261019163020'),
+    ('20261019180512'),
+    ('20261019201530'),
+    ('20261020091545'),
+    ('20261020143210'),
+    ('20261020170425'),
+    ('20261021160215');
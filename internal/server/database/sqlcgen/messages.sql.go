@@ -11,15 +11,196 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const getUndeliveredMessages = `-- name: GetUndeliveredMessages :many
-SELECT id, sender, recipient, cipher_sym_key, ciphertext, sent_at, delivered_at, read_at FROM messages
+const ackMessageDeliveries = `-- name: AckMessageDeliveries :many
+UPDATE message_deliveries SET delivered_at = CURRENT_TIMESTAMP, leased_until = NULL
 WHERE
-	recipient = $1 AND
+	device_id = $1 AND
+	message_id = ANY($2::uuid[]) AND
 	delivered_at IS NULL
+RETURNING message_id
+`
+
+type AckMessageDeliveriesParams struct {
+	DeviceID string
+	Ids      []pgtype.UUID
+}
+
+// Marks messages leased to a device as delivered to it, returning those not acknowledged before.
+func (q *Queries) AckMessageDeliveries(ctx context.Context, arg AckMessageDeliveriesParams) ([]pgtype.UUID, error) {
+	rows, err := q.db.Query(ctx, ackMessageDeliveries, arg.DeviceID, arg.Ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []pgtype.UUID
+	for rows.Next() {
+		var message_id pgtype.UUID
+		if err := rows.Scan(&message_id); err != nil {
+			return nil, err
+		}
+		items = append(items, message_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteDeliveredMessages = `-- name: DeleteDeliveredMessages :execrows
+DELETE FROM messages
+WHERE
+	delivered_at < $1 AND
+	NOT EXISTS (
+		SELECT 1 FROM devices
+		WHERE
+			devices.revoked_at IS NULL AND
+			messages.sent_at >= devices.created_at AND
+			(
+				(devices.username = messages.recipient AND (messages.keys IS NOT NULL OR devices.signed_by IS NULL)) OR
+				(devices.username = messages.sender AND messages.keys IS NOT NULL AND messages.sender_device <> devices.id)
+			) AND
+			NOT EXISTS (
+				SELECT 1 FROM message_deliveries
+				WHERE
+					message_deliveries.message_id = messages.id AND
+					message_deliveries.device_id = devices.id AND
+					message_deliveries.delivered_at IS NOT NULL
+			)
+	)
+`
+
+// Messages delivered to their recipient before the retention period, and acknowledged by every
+// device they are for (see GetUndeliveredMessages). The recipient is told delivered on the first
+// acknowledgement, so the other devices may not have fetched the message yet.
+func (q *Queries) DeleteDeliveredMessages(ctx context.Context, deliveredBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteDeliveredMessages, deliveredBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteUndeliveredMessages = `-- name: DeleteUndeliveredMessages :execrows
+DELETE FROM messages
+WHERE
+	sent_at < $1 AND
+	(delivered_at IS NULL OR EXISTS (
+		SELECT 1 FROM devices
+		WHERE
+			devices.revoked_at IS NULL AND
+			messages.sent_at >= devices.created_at AND
+			(
+				(devices.username = messages.recipient AND (messages.keys IS NOT NULL OR devices.signed_by IS NULL)) OR
+				(devices.username = messages.sender AND messages.keys IS NOT NULL AND messages.sender_device <> devices.id)
+			) AND
+			NOT EXISTS (
+				SELECT 1 FROM message_deliveries
+				WHERE
+					message_deliveries.message_id = messages.id AND
+					message_deliveries.device_id = devices.id AND
+					message_deliveries.delivered_at IS NOT NULL
+			)
+	))
+`
+
+// Messages not acknowledged by every device they are for, sent before the retention period.
+func (q *Queries) DeleteUndeliveredMessages(ctx context.Context, sentBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteUndeliveredMessages, sentBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getMessage = `-- name: GetMessage :one
+SELECT id, sender, recipient, cipher_sym_key, ciphertext, sent_at, delivered_at, read_at, signature, version, session, sender_device, keys, client_id, kind FROM messages WHERE id = $1
+`
+
+func (q *Queries) GetMessage(ctx context.Context, id pgtype.UUID) (*Message, error) {
+	row := q.db.QueryRow(ctx, getMessage, id)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.Sender,
+		&i.Recipient,
+		&i.CipherSymKey,
+		&i.Ciphertext,
+		&i.SentAt,
+		&i.DeliveredAt,
+		&i.ReadAt,
+		&i.Signature,
+		&i.Version,
+		&i.Session,
+		&i.SenderDevice,
+		&i.Keys,
+		&i.ClientID,
+		&i.Kind,
+	)
+	return &i, err
+}
+
+const getMessageByClientID = `-- name: GetMessageByClientID :one
+SELECT id, sender, recipient, cipher_sym_key, ciphertext, sent_at, delivered_at, read_at, signature, version, session, sender_device, keys, client_id, kind FROM messages WHERE sender = $1 AND client_id = $2
 `
 
-func (q *Queries) GetUndeliveredMessages(ctx context.Context, recipient string) ([]*Message, error) {
-	rows, err := q.db.Query(ctx, getUndeliveredMessages, recipient)
+type GetMessageByClientIDParams struct {
+	Sender   string
+	ClientID pgtype.UUID
+}
+
+func (q *Queries) GetMessageByClientID(ctx context.Context, arg GetMessageByClientIDParams) (*Message, error) {
+	row := q.db.QueryRow(ctx, getMessageByClientID, arg.Sender, arg.ClientID)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.Sender,
+		&i.Recipient,
+		&i.CipherSymKey,
+		&i.Ciphertext,
+		&i.SentAt,
+		&i.DeliveredAt,
+		&i.ReadAt,
+		&i.Signature,
+		&i.Version,
+		&i.Session,
+		&i.SenderDevice,
+		&i.Keys,
+		&i.ClientID,
+		&i.Kind,
+	)
+	return &i, err
+}
+
+const getUndeliveredMessages = `-- name: GetUndeliveredMessages :many
+SELECT messages.id, messages.sender, messages.recipient, messages.cipher_sym_key, messages.ciphertext, messages.sent_at, messages.delivered_at, messages.read_at, messages.signature, messages.version, messages.session, messages.sender_device, messages.keys, messages.client_id, messages.kind FROM messages
+JOIN devices ON
+	devices.id = $1 AND
+	devices.username = $2
+WHERE
+	messages.sent_at >= devices.created_at AND
+	(
+		(messages.recipient = $2 AND (messages.keys IS NOT NULL OR devices.signed_by IS NULL)) OR
+		(messages.sender = $2 AND messages.keys IS NOT NULL AND messages.sender_device <> devices.id)
+	) AND
+	NOT EXISTS (
+		SELECT 1 FROM message_deliveries
+		WHERE
+			message_deliveries.message_id = messages.id AND
+			message_deliveries.device_id = devices.id AND
+			(message_deliveries.delivered_at IS NOT NULL OR message_deliveries.leased_until > CURRENT_TIMESTAMP)
+	)
+ORDER BY messages.sent_at
+`
+
+type GetUndeliveredMessagesParams struct {
+	DeviceID string
+	Username string
+}
+
+// Messages for a device: those sent to its user, and those sent from its user's other devices.
+// Envelopes without per-device keys can only be read by the user's first device.
+func (q *Queries) GetUndeliveredMessages(ctx context.Context, arg GetUndeliveredMessagesParams) ([]*Message, error) {
+	rows, err := q.db.Query(ctx, getUndeliveredMessages, arg.DeviceID, arg.Username)
 	if err != nil {
 		return nil, err
 	}
@@ -36,6 +217,13 @@ func (q *Queries) GetUndeliveredMessages(ctx context.Context, recipient string)
 			&i.SentAt,
 			&i.DeliveredAt,
 			&i.ReadAt,
+			&i.Signature,
+			&i.Version,
+			&i.Session,
+			&i.SenderDevice,
+			&i.Keys,
+			&i.ClientID,
+			&i.Kind,
 		); err != nil {
 			return nil, err
 		}
@@ -48,9 +236,10 @@ func (q *Queries) GetUndeliveredMessages(ctx context.Context, recipient string)
 }
 
 const insertMessage = `-- name: InsertMessage :one
-INSERT INTO messages (sender, recipient, cipher_sym_key, ciphertext, sent_at)
-VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
-RETURNING id, sender, recipient, cipher_sym_key, ciphertext, sent_at, delivered_at, read_at
+INSERT INTO messages (sender, recipient, cipher_sym_key, ciphertext, signature, version, session, sender_device, keys, client_id, kind, sent_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, CURRENT_TIMESTAMP)
+ON CONFLICT (sender, client_id) DO NOTHING
+RETURNING id, sender, recipient, cipher_sym_key, ciphertext, sent_at, delivered_at, read_at, signature, version, session, sender_device, keys, client_id, kind
 `
 
 type InsertMessageParams struct {
@@ -58,14 +247,29 @@ type InsertMessageParams struct {
 	Recipient    string
 	CipherSymKey []byte
 	Ciphertext   []byte
+	Signature    []byte
+	Version      int32
+	Session      []byte
+	SenderDevice pgtype.Text
+	Keys         []byte
+	ClientID     pgtype.UUID
+	Kind         pgtype.Text
 }
 
+// Messages already stored with the same client ID are not stored again.
 func (q *Queries) InsertMessage(ctx context.Context, arg InsertMessageParams) (*Message, error) {
 	row := q.db.QueryRow(ctx, insertMessage,
 		arg.Sender,
 		arg.Recipient,
 		arg.CipherSymKey,
 		arg.Ciphertext,
+		arg.Signature,
+		arg.Version,
+		arg.Session,
+		arg.SenderDevice,
+		arg.Keys,
+		arg.ClientID,
+		arg.Kind,
 	)
 	var i Message
 	err := row.Scan(
@@ -77,19 +281,64 @@ func (q *Queries) InsertMessage(ctx context.Context, arg InsertMessageParams) (*
 		&i.SentAt,
 		&i.DeliveredAt,
 		&i.ReadAt,
+		&i.Signature,
+		&i.Version,
+		&i.Session,
+		&i.SenderDevice,
+		&i.Keys,
+		&i.ClientID,
+		&i.Kind,
 	)
 	return &i, err
 }
 
-const setMessageDelivered = `-- name: SetMessageDelivered :exec
-UPDATE messages SET delivered_at = CURRENT_TIMESTAMP WHERE id = $1
+const leaseMessageDelivery = `-- name: LeaseMessageDelivery :exec
+INSERT INTO message_deliveries (message_id, device_id, leased_until)
+VALUES ($1, $2, $3)
+ON CONFLICT (message_id, device_id) DO UPDATE SET leased_until = EXCLUDED.leased_until
+WHERE message_deliveries.delivered_at IS NULL
 `
 
-func (q *Queries) SetMessageDelivered(ctx context.Context, id pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, setMessageDelivered, id)
+type LeaseMessageDeliveryParams struct {
+	MessageID   pgtype.UUID
+	DeviceID    string
+	LeasedUntil pgtype.Timestamptz
+}
+
+// Messages leased to a device are not fetched again until the lease expires or is renewed.
+// Messages already acknowledged by the device are never leased again.
+func (q *Queries) LeaseMessageDelivery(ctx context.Context, arg LeaseMessageDeliveryParams) error {
+	_, err := q.db.Exec(ctx, leaseMessageDelivery, arg.MessageID, arg.DeviceID, arg.LeasedUntil)
 	return err
 }
 
+const listCorrespondents = `-- name: ListCorrespondents :many
+SELECT recipient FROM messages WHERE sender = $1
+UNION
+SELECT sender FROM messages WHERE recipient = $1
+`
+
+// Users who sent messages to, or received messages from, a user.
+func (q *Queries) ListCorrespondents(ctx context.Context, username string) ([]string, error) {
+	rows, err := q.db.Query(ctx, listCorrespondents, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var recipient string
+		if err := rows.Scan(&recipient); err != nil {
+			return nil, err
+		}
+		items = append(items, recipient)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const setMessageRead = `-- name: SetMessageRead :exec
 UPDATE messages SET read_at = CURRENT_TIMESTAMP WHERE id = $1
 `
@@ -107,3 +356,21 @@ func (q *Queries) SetMessageSent(ctx context.Context, id pgtype.UUID) error {
 	_, err := q.db.Exec(ctx, setMessageSent, id)
 	return err
 }
+
+const setMessagesDelivered = `-- name: SetMessagesDelivered :exec
+UPDATE messages SET delivered_at = CURRENT_TIMESTAMP
+WHERE
+	id = ANY($1::uuid[]) AND
+	recipient = $2 AND
+	delivered_at IS NULL
+`
+
+type SetMessagesDeliveredParams struct {
+	Ids       []pgtype.UUID
+	Recipient string
+}
+
+func (q *Queries) SetMessagesDelivered(ctx context.Context, arg SetMessagesDeliveredParams) error {
+	_, err := q.db.Exec(ctx, setMessagesDelivered, arg.Ids, arg.Recipient)
+	return err
+}
@@ -8,6 +8,25 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type Device struct {
+	ID        string
+	Username  string
+	PublicKey []byte
+	SignedBy  pgtype.Text
+	Signature []byte
+	CreatedAt pgtype.Timestamptz
+	RevokedAt pgtype.Timestamptz
+}
+
+type KeyRotation struct {
+	ID           pgtype.UUID
+	Username     string
+	OldPublicKey []byte
+	NewPublicKey []byte
+	Signature    []byte
+	RotatedAt    pgtype.Timestamptz
+}
+
 type Message struct {
 	ID           pgtype.UUID
 	Sender       string
@@ -17,12 +36,72 @@ type Message struct {
 	SentAt       pgtype.Timestamptz
 	DeliveredAt  pgtype.Timestamptz
 	ReadAt       pgtype.Timestamptz
+	Signature    []byte
+	Version      int32
+	Session      []byte
+	SenderDevice pgtype.Text
+	Keys         []byte
+	ClientID     pgtype.UUID
+	Kind         pgtype.Text
+}
+
+type MessageDelivery struct {
+	MessageID   pgtype.UUID
+	DeviceID    string
+	DeliveredAt pgtype.Timestamptz
+	LeasedUntil pgtype.Timestamptz
+}
+
+type PrekeyClaim struct {
+	Claimer      string
+	Username     string
+	OneTimeKeyID pgtype.Int4
+	ClaimedAt    pgtype.Timestamptz
+}
+
+type Prekey struct {
+	ID        pgtype.UUID
+	Username  string
+	KeyID     int32
+	PublicKey []byte
+	Signature []byte
+	OneTime   bool
+	CreatedAt pgtype.Timestamptz
+}
+
+type RefreshToken struct {
+	TokenHash []byte
+	Username  string
+	DeviceID  string
+	CreatedAt pgtype.Timestamptz
+	ExpiresAt pgtype.Timestamptz
+	RevokedAt pgtype.Timestamptz
 }
 
 type SchemaMigration struct {
 	Version string
 }
 
+type Transfer struct {
+	ID         pgtype.UUID
+	Sender     string
+	Recipient  string
+	ChunkCount int32
+	CreatedAt  pgtype.Timestamptz
+}
+
+type TransferAck struct {
+	TransferID pgtype.UUID
+	DeviceID   string
+	AckedAt    pgtype.Timestamptz
+}
+
+type TransferChunk struct {
+	TransferID pgtype.UUID
+	ChunkIndex int32
+	BlobID     pgtype.UUID
+}
+
 type User struct {
 	ID        pgtype.UUID
 	Name      string
@@ -30,3 +109,8 @@ type User struct {
 	CreatedAt pgtype.Timestamptz
 	UpdatedAt pgtype.Timestamptz
 }
+
+type UsedAuthChallenge struct {
+	Nonce     string
+	ExpiresAt pgtype.Timestamptz
+}
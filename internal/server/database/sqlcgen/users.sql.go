@@ -80,3 +80,35 @@ func (q *Queries) ListUsers(ctx context.Context) ([]*User, error) {
 	}
 	return items, nil
 }
+
+const rotateUserKey = `-- name: RotateUserKey :one
+UPDATE users SET
+    public_key = $1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE name = $2 AND public_key = $3
+RETURNING id, name, public_key, created_at, updated_at
+`
+
+type RotateUserKeyParams struct {
+	NewPublicKey []byte
+	Name         string
+	OldPublicKey []byte
+}
+
+// Only rotates from the expected current key, so that concurrent rotations cannot both succeed.
+func (q *Queries) RotateUserKey(ctx context.Context, arg RotateUserKeyParams) (*User, error) {
+	row := q.db.QueryRow(ctx, rotateUserKey,
+		arg.NewPublicKey,
+		arg.Name,
+		arg.OldPublicKey,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.PublicKey,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return &i, err
+}
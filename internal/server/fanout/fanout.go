@@ -0,0 +1,52 @@
+// Package fanout relays the events of the websocket hub between the server replicas, for users
+// connected to any replica to receive them.
+package fanout
+
+import (
+	"context"
+
+	"github.com/marc921/talk/internal/types"
+	"github.com/marc921/talk/internal/types/openapi"
+)
+
+type EventType string
+
+const (
+	// EventMessage tells that a message was stored, for its connected recipients to receive it.
+	EventMessage EventType = "message"
+	// EventTyping relays a typing event to the connected devices of its recipient.
+	EventTyping EventType = "typing"
+	// EventPresence tells that a user came online or went offline on a replica.
+	EventPresence EventType = "presence"
+	// EventDisconnect asks the replicas to close the connections of a user.
+	EventDisconnect EventType = "disconnect"
+	// EventHeartbeat tells that a replica is alive. The users online on a replica that stopped sending
+	// heartbeats, as it crashed, are considered offline.
+	EventHeartbeat EventType = "heartbeat"
+	// EventSync asks the replicas to publish which users are online on them, for a replica that just
+	// started or lost track of another one.
+	EventSync EventType = "sync"
+)
+
+// Event is an event of the websocket hub, published to all replicas.
+type Event struct {
+	Type EventType `json:"type"`
+	// Replica is the ID of the replica that published the event.
+	Replica   string `json:"replica"`
+	MessageID string `json:"message_id,omitempty"`
+	// Message is only carried within a process: other replicas load the message from its ID,
+	// as messages may not fit in a notification.
+	Message  *openapi.Message     `json:"-"`
+	Typing   *types.TypingEvent   `json:"typing,omitempty"`
+	Presence *types.PresenceEvent `json:"presence,omitempty"`
+	// Username is the user to disconnect.
+	Username openapi.Username `json:"username,omitempty"`
+}
+
+// Fanout publishes events to the hubs of all replicas, including the publisher's.
+// Events are delivered at most once: those published while a subscriber reconnects are lost.
+type Fanout interface {
+	Publish(ctx context.Context, event *Event) error
+	// Subscribe returns the events published by all replicas, until ctx is done.
+	Subscribe(ctx context.Context) (<-chan *Event, error)
+}
@@ -0,0 +1,54 @@
+package fanout
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory relays events within a single process, for a single replica or tests.
+type Memory struct {
+	mu sync.Mutex
+	// Channel of each subscriber, with the done channel of its context.
+	subscribers map[chan *Event]<-chan struct{}
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		subscribers: make(map[chan *Event]<-chan struct{}),
+	}
+}
+
+// Publish blocks until all subscribers received the event, or ctx is done.
+func (m *Memory) Publish(ctx context.Context, event *Event) error {
+	m.mu.Lock()
+	subscribers := make(map[chan *Event]<-chan struct{}, len(m.subscribers))
+	for events, done := range m.subscribers {
+		subscribers[events] = done
+	}
+	m.mu.Unlock()
+
+	for events, done := range subscribers {
+		select {
+		case events <- event:
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context) (<-chan *Event, error) {
+	events := make(chan *Event)
+	m.mu.Lock()
+	m.subscribers[events] = ctx.Done()
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		delete(m.subscribers, events)
+		m.mu.Unlock()
+	}()
+	return events, nil
+}
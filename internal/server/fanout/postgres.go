@@ -0,0 +1,115 @@
+package fanout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	// Channel of the notifications carrying the events.
+	postgresChannel = "talk_hub"
+	// Delay before listening again after the connection was lost.
+	postgresRetryDelay = 5 * time.Second
+)
+
+// Postgres relays events between replicas sharing a database, with LISTEN/NOTIFY.
+type Postgres struct {
+	logger *zap.Logger
+	pool   *pgxpool.Pool
+}
+
+func NewPostgres(logger *zap.Logger, pool *pgxpool.Pool) *Postgres {
+	return &Postgres{
+		logger: logger.With(zap.String("component", "fanout")),
+		pool:   pool,
+	}
+}
+
+func (p *Postgres) Publish(ctx context.Context, event *Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	_, err = p.pool.Exec(ctx, "SELECT pg_notify($1, $2)", postgresChannel, string(payload))
+	if err != nil {
+		return fmt.Errorf("pool.Exec: %w", err)
+	}
+	return nil
+}
+
+// Subscribe listens on a connection of its own, which it acquires again if it is lost.
+func (p *Postgres) Subscribe(ctx context.Context) (<-chan *Event, error) {
+	// The first connection is acquired right away, for configuration errors to surface
+	conn, err := p.listen(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	events := make(chan *Event)
+	go func() {
+		for {
+			err := p.receive(ctx, conn, events)
+			conn.Close(context.Background())
+			if ctx.Err() != nil {
+				return
+			}
+			p.logger.Error("receive", zap.Error(err))
+
+			for conn == nil || err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(postgresRetryDelay):
+				}
+				conn, err = p.listen(ctx)
+				if err != nil {
+					p.logger.Error("listen", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// listen takes a connection out of the pool, for it not to be reused while listening,
+// and listens to the channel of the events on it.
+func (p *Postgres) listen(ctx context.Context) (*pgx.Conn, error) {
+	pooled, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pool.Acquire: %w", err)
+	}
+	conn := pooled.Hijack()
+	_, err = conn.Exec(ctx, "LISTEN "+postgresChannel)
+	if err != nil {
+		conn.Close(context.Background())
+		return nil, fmt.Errorf("conn.Exec: %w", err)
+	}
+	return conn, nil
+}
+
+// receive forwards the events notified on a connection until it fails or ctx is done.
+func (p *Postgres) receive(ctx context.Context, conn *pgx.Conn, events chan<- *Event) error {
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("WaitForNotification: %w", err)
+		}
+		var event *Event
+		err = json.Unmarshal([]byte(notification.Payload), &event)
+		if err != nil {
+			p.logger.Error("json.Unmarshal", zap.Error(err))
+			continue
+		}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
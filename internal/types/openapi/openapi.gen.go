@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/oapi-codegen/runtime"
 )
@@ -20,6 +21,13 @@ const (
 	BearerAuthScopes = "bearerAuth.Scopes"
 )
 
+// Defines values for MessageKind.
+const (
+	MessageKindFile    MessageKind = "file"
+	MessageKindMessage MessageKind = "message"
+	MessageKindReceipt MessageKind = "receipt"
+)
+
 // AuthChallenge defines model for AuthChallenge.
 type AuthChallenge struct {
 	Nonce string `json:"nonce"`
@@ -35,6 +43,29 @@ type AuthChallengeSigned struct {
 // CipherText defines model for CipherText.
 type CipherText = []byte
 
+// Device defines model for Device.
+type Device struct {
+	// Id Hex encoded SHA-256 fingerprint of the device's public key
+	Id string `json:"id"`
+
+	// PublicKey The public key of the device, PEM encoded
+	PublicKey []byte `json:"public_key"`
+
+	// Signature Signature of the device's username and public key by the existing device
+	Signature *CipherText `json:"signature,omitempty"`
+
+	// SignedBy ID of the existing device that added this device
+	SignedBy *string `json:"signed_by,omitempty"`
+}
+
+// DeviceKey defines model for DeviceKey.
+type DeviceKey struct {
+	CipherSymKey CipherText `json:"cipher_sym_key"`
+
+	// Device ID of the device the key is wrapped for
+	Device string `json:"device"`
+}
+
 // ErrorResponse defines model for ErrorResponse.
 type ErrorResponse struct {
 	// Error Error message
@@ -43,37 +74,202 @@ type ErrorResponse struct {
 
 // JWT defines model for JWT.
 type JWT struct {
-	Token string `json:"token"`
+	// RefreshToken Set when the server issues refresh tokens, to renew the token once expired
+	RefreshToken *string `json:"refresh_token,omitempty"`
+	Token        string  `json:"token"`
+}
+
+// KeyRotation defines model for KeyRotation.
+type KeyRotation struct {
+	// OldPublicKey The replaced public key, PEM encoded
+	OldPublicKey []byte `json:"old_public_key"`
+
+	// PublicKey The new public key, PEM encoded
+	PublicKey []byte     `json:"public_key"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+
+	// Signature Signature of the username and new public key by the replaced key
+	Signature CipherText `json:"signature"`
 }
 
 // Message defines model for Message.
 type Message struct {
 	CipherSymKey CipherText `json:"cipher_sym_key"`
 	Ciphertext   CipherText `json:"ciphertext"`
-	Recipient    Username   `json:"recipient"`
-	Sender       Username   `json:"sender"`
+
+	// ClientId UUID generated by the sender's client, for the server to deduplicate retried posts
+	ClientId *string `json:"client_id,omitempty"`
+
+	// Id Server ID of the message, set by the server, to acknowledge it once stored
+	Id *string `json:"id,omitempty"`
+
+	// Keys Symmetric key wrapped for each device of the recipient and the sender, cipher_sym_key being wrapped for the recipient's public key
+	Keys *[]DeviceKey `json:"keys,omitempty"`
+
+	// Kind Kind of the encrypted content, message when omitted
+	Kind      *MessageKind `json:"kind,omitempty"`
+	Recipient Username     `json:"recipient"`
+	Sender    Username     `json:"sender"`
+
+	// SenderDevice ID of the sender's device whose key signed the envelope
+	SenderDevice *string `json:"sender_device,omitempty"`
+
+	// SentAt Time the server stored the message, set by the server
+	SentAt *time.Time `json:"sent_at,omitempty"`
+
+	// Session Forward-secret session data sent in clear along with a message
+	Session *SessionHeader `json:"session,omitempty"`
+
+	// Signature Detached signature of the envelope by the sender's private key
+	Signature *CipherText `json:"signature,omitempty"`
+
+	// Version Envelope format version, 1 (RSA PKCS#1 v1.5) when omitted
+	Version *int `json:"version,omitempty"`
+}
+
+// MessageKind Kind of the encrypted content, message when omitted
+type MessageKind string
+
+// MessageAck defines model for MessageAck.
+type MessageAck struct {
+	// Ids Server IDs of the messages stored by the device
+	Ids []string `json:"ids"`
+}
+
+// Prekey defines model for Prekey.
+type Prekey struct {
+	Id int `json:"id"`
+
+	// PublicKey X25519 public key
+	PublicKey []byte `json:"public_key"`
+
+	// Signature Signature of the public key by the owner's private key, for signed prekeys
+	Signature *CipherText `json:"signature,omitempty"`
+}
+
+// PrekeyBundle defines model for PrekeyBundle.
+type PrekeyBundle struct {
+	OneTimePrekey *Prekey `json:"one_time_prekey,omitempty"`
+	SignedPrekey  Prekey  `json:"signed_prekey"`
+}
+
+// PrekeyStatus defines model for PrekeyStatus.
+type PrekeyStatus struct {
+	// Low Whether the client should upload more one-time prekeys
+	Low bool `json:"low"`
+
+	// OneTimePrekeys Number of one-time prekeys left on the server
+	OneTimePrekeys int `json:"one_time_prekeys"`
+
+	// SignedPrekeyId ID of the current signed prekey, if any
+	SignedPrekeyId *int `json:"signed_prekey_id,omitempty"`
+}
+
+// PrekeyUpload defines model for PrekeyUpload.
+type PrekeyUpload struct {
+	OneTimePrekeys *[]Prekey `json:"one_time_prekeys,omitempty"`
+	SignedPrekey   *Prekey   `json:"signed_prekey,omitempty"`
 }
 
 // PublicUser defines model for PublicUser.
 type PublicUser struct {
-	Name Username `json:"name"`
+	// Devices The user's devices, the first one holding the user's public key
+	Devices *[]Device `json:"devices,omitempty"`
+	Name    Username  `json:"name"`
 
 	// PublicKey The public key of the user, PEM encoded
 	PublicKey []byte `json:"public_key"`
 }
 
+// RefreshRequest defines model for RefreshRequest.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// SessionHeader Forward-secret session data sent in clear along with a message
+type SessionHeader struct {
+	// EphemeralKey X25519 public key the sender started the session with from the recipient's prekeys
+	EphemeralKey *[]byte `json:"ephemeral_key,omitempty"`
+
+	// MessageNumber Index of the message in the sender's current sending chain
+	MessageNumber *int `json:"message_number,omitempty"`
+
+	// OfferKey X25519 public key the sender offers to start a session with
+	OfferKey *[]byte `json:"offer_key,omitempty"`
+
+	// OneTimePrekeyId ID of the recipient's one-time prekey the session was started with, if any
+	OneTimePrekeyId *int `json:"one_time_prekey_id,omitempty"`
+
+	// PreviousChainLength Number of messages in the sender's previous sending chain
+	PreviousChainLength *int `json:"previous_chain_length,omitempty"`
+
+	// RatchetKey The sender's current ratchet public key
+	RatchetKey *[]byte `json:"ratchet_key,omitempty"`
+
+	// SignedPrekeyId ID of the recipient's signed prekey the session was started with
+	SignedPrekeyId *int `json:"signed_prekey_id,omitempty"`
+}
+
+// Transfer defines model for Transfer.
+type Transfer struct {
+	// ChunkCount Number of encrypted chunks the file is split into
+	ChunkCount int    `json:"chunk_count"`
+	Id         string `json:"id"`
+
+	// Received Indexes of the chunks uploaded so far
+	Received  []int    `json:"received"`
+	Recipient Username `json:"recipient"`
+	Sender    Username `json:"sender"`
+}
+
+// TransferChunk defines model for TransferChunk.
+type TransferChunk struct {
+	Data CipherText `json:"data"`
+}
+
+// TransferRequest defines model for TransferRequest.
+type TransferRequest struct {
+	// ChunkCount Number of encrypted chunks the file is split into
+	ChunkCount int      `json:"chunk_count"`
+	Recipient  Username `json:"recipient"`
+}
+
 // Username defines model for Username.
 type Username = string
 
 // PostAuthUsernameJSONRequestBody defines body for PostAuthUsername for application/json ContentType.
 type PostAuthUsernameJSONRequestBody = AuthChallengeSigned
 
+// PostAuthUsernameRefreshJSONRequestBody defines body for PostAuthUsernameRefresh for application/json ContentType.
+type PostAuthUsernameRefreshJSONRequestBody = RefreshRequest
+
+// PostAuthUsernameRevokeJSONRequestBody defines body for PostAuthUsernameRevoke for application/json ContentType.
+type PostAuthUsernameRevokeJSONRequestBody = RefreshRequest
+
 // PostMessagesUsernameJSONRequestBody defines body for PostMessagesUsername for application/json ContentType.
 type PostMessagesUsernameJSONRequestBody = Message
 
+// PostMessagesUsernameAckJSONRequestBody defines body for PostMessagesUsernameAck for application/json ContentType.
+type PostMessagesUsernameAckJSONRequestBody = MessageAck
+
+// PutPrekeysUsernameJSONRequestBody defines body for PutPrekeysUsername for application/json ContentType.
+type PutPrekeysUsernameJSONRequestBody = PrekeyUpload
+
+// PostTransfersUsernameJSONRequestBody defines body for PostTransfersUsername for application/json ContentType.
+type PostTransfersUsernameJSONRequestBody = TransferRequest
+
+// PutTransfersUsernameTransferIdChunksIndexJSONRequestBody defines body for PutTransfersUsernameTransferIdChunksIndex for application/json ContentType.
+type PutTransfersUsernameTransferIdChunksIndexJSONRequestBody = TransferChunk
+
 // PostUsersJSONRequestBody defines body for PostUsers for application/json ContentType.
 type PostUsersJSONRequestBody = PublicUser
 
+// PostUsersUsernameDevicesJSONRequestBody defines body for PostUsersUsernameDevices for application/json ContentType.
+type PostUsersUsernameDevicesJSONRequestBody = Device
+
+// PutUsersUsernameKeyJSONRequestBody defines body for PutUsersUsernameKey for application/json ContentType.
+type PutUsersUsernameKeyJSONRequestBody = KeyRotation
+
 // RequestEditorFn  is the function signature for the RequestEditor callback function
 type RequestEditorFn func(ctx context.Context, req *http.Request) error
 
@@ -155,6 +351,16 @@ type ClientInterface interface {
 
 	PostAuthUsername(ctx context.Context, username Username, body PostAuthUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
 
+	// PostAuthUsernameRefreshWithBody request with any body
+	PostAuthUsernameRefreshWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	PostAuthUsernameRefresh(ctx context.Context, username Username, body PostAuthUsernameRefreshJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// PostAuthUsernameRevokeWithBody request with any body
+	PostAuthUsernameRevokeWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	PostAuthUsernameRevoke(ctx context.Context, username Username, body PostAuthUsernameRevokeJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
+
 	// GetMessagesUsername request
 	GetMessagesUsername(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error)
 
@@ -163,6 +369,41 @@ type ClientInterface interface {
 
 	PostMessagesUsername(ctx context.Context, username Username, body PostMessagesUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
 
+	// PostMessagesUsernameAckWithBody request with any body
+	PostMessagesUsernameAckWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	PostMessagesUsernameAck(ctx context.Context, username Username, body PostMessagesUsernameAckJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetPrekeysUsername request
+	GetPrekeysUsername(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// PutPrekeysUsernameWithBody request with any body
+	PutPrekeysUsernameWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	PutPrekeysUsername(ctx context.Context, username Username, body PutPrekeysUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// PostPrekeysUsernameClaim request
+	PostPrekeysUsernameClaim(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// PostTransfersUsernameWithBody request with any body
+	PostTransfersUsernameWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	PostTransfersUsername(ctx context.Context, username Username, body PostTransfersUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetTransfersUsernameTransferId request
+	GetTransfersUsernameTransferId(ctx context.Context, username Username, transferId string, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// PostTransfersUsernameTransferIdAck request
+	PostTransfersUsernameTransferIdAck(ctx context.Context, username Username, transferId string, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetTransfersUsernameTransferIdChunksIndex request
+	GetTransfersUsernameTransferIdChunksIndex(ctx context.Context, username Username, transferId string, index int, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// PutTransfersUsernameTransferIdChunksIndexWithBody request with any body
+	PutTransfersUsernameTransferIdChunksIndexWithBody(ctx context.Context, username Username, transferId string, index int, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	PutTransfersUsernameTransferIdChunksIndex(ctx context.Context, username Username, transferId string, index int, body PutTransfersUsernameTransferIdChunksIndexJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
+
 	// PostUsersWithBody request with any body
 	PostUsersWithBody(ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
 
@@ -170,6 +411,19 @@ type ClientInterface interface {
 
 	// GetUsersUsername request
 	GetUsersUsername(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// PostUsersUsernameDevicesWithBody request with any body
+	PostUsersUsernameDevicesWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	PostUsersUsernameDevices(ctx context.Context, username Username, body PostUsersUsernameDevicesJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetUsersUsernameKey request
+	GetUsersUsernameKey(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// PutUsersUsernameKeyWithBody request with any body
+	PutUsersUsernameKeyWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	PutUsersUsernameKey(ctx context.Context, username Username, body PutUsersUsernameKeyJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error)
 }
 
 func (c *Client) GetAuthUsername(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error) {
@@ -208,8 +462,8 @@ func (c *Client) PostAuthUsername(ctx context.Context, username Username, body P
 	return c.Client.Do(req)
 }
 
-func (c *Client) GetMessagesUsername(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error) {
-	req, err := NewGetMessagesUsernameRequest(c.Server, username)
+func (c *Client) PostAuthUsernameRefreshWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostAuthUsernameRefreshRequestWithBody(c.Server, username, contentType, body)
 	if err != nil {
 		return nil, err
 	}
@@ -220,8 +474,8 @@ func (c *Client) GetMessagesUsername(ctx context.Context, username Username, req
 	return c.Client.Do(req)
 }
 
-func (c *Client) PostMessagesUsernameWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
-	req, err := NewPostMessagesUsernameRequestWithBody(c.Server, username, contentType, body)
+func (c *Client) PostAuthUsernameRefresh(ctx context.Context, username Username, body PostAuthUsernameRefreshJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostAuthUsernameRefreshRequest(c.Server, username, body)
 	if err != nil {
 		return nil, err
 	}
@@ -232,8 +486,8 @@ func (c *Client) PostMessagesUsernameWithBody(ctx context.Context, username User
 	return c.Client.Do(req)
 }
 
-func (c *Client) PostMessagesUsername(ctx context.Context, username Username, body PostMessagesUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
-	req, err := NewPostMessagesUsernameRequest(c.Server, username, body)
+func (c *Client) PostAuthUsernameRevokeWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostAuthUsernameRevokeRequestWithBody(c.Server, username, contentType, body)
 	if err != nil {
 		return nil, err
 	}
@@ -244,8 +498,8 @@ func (c *Client) PostMessagesUsername(ctx context.Context, username Username, bo
 	return c.Client.Do(req)
 }
 
-func (c *Client) PostUsersWithBody(ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
-	req, err := NewPostUsersRequestWithBody(c.Server, contentType, body)
+func (c *Client) PostAuthUsernameRevoke(ctx context.Context, username Username, body PostAuthUsernameRevokeJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostAuthUsernameRevokeRequest(c.Server, username, body)
 	if err != nil {
 		return nil, err
 	}
@@ -256,8 +510,8 @@ func (c *Client) PostUsersWithBody(ctx context.Context, contentType string, body
 	return c.Client.Do(req)
 }
 
-func (c *Client) PostUsers(ctx context.Context, body PostUsersJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
-	req, err := NewPostUsersRequest(c.Server, body)
+func (c *Client) GetMessagesUsername(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetMessagesUsernameRequest(c.Server, username)
 	if err != nil {
 		return nil, err
 	}
@@ -268,8 +522,8 @@ func (c *Client) PostUsers(ctx context.Context, body PostUsersJSONRequestBody, r
 	return c.Client.Do(req)
 }
 
-func (c *Client) GetUsersUsername(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error) {
-	req, err := NewGetUsersUsernameRequest(c.Server, username)
+func (c *Client) PostMessagesUsernameWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostMessagesUsernameRequestWithBody(c.Server, username, contentType, body)
 	if err != nil {
 		return nil, err
 	}
@@ -280,210 +534,272 @@ func (c *Client) GetUsersUsername(ctx context.Context, username Username, reqEdi
 	return c.Client.Do(req)
 }
 
-// NewGetAuthUsernameRequest generates requests for GetAuthUsername
-func NewGetAuthUsernameRequest(server string, username Username) (*http.Request, error) {
-	var err error
-
-	var pathParam0 string
-
-	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+func (c *Client) PostMessagesUsername(ctx context.Context, username Username, body PostMessagesUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostMessagesUsernameRequest(c.Server, username, body)
 	if err != nil {
 		return nil, err
 	}
-
-	serverURL, err := url.Parse(server)
-	if err != nil {
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
 		return nil, err
 	}
+	return c.Client.Do(req)
+}
 
-	operationPath := fmt.Sprintf("/auth/%s", pathParam0)
-	if operationPath[0] == '/' {
-		operationPath = "." + operationPath
-	}
-
-	queryURL, err := serverURL.Parse(operationPath)
+func (c *Client) PostMessagesUsernameAckWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostMessagesUsernameAckRequestWithBody(c.Server, username, contentType, body)
 	if err != nil {
 		return nil, err
 	}
-
-	req, err := http.NewRequest("GET", queryURL.String(), nil)
-	if err != nil {
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
 		return nil, err
 	}
-
-	return req, nil
+	return c.Client.Do(req)
 }
 
-// NewPostAuthUsernameRequest calls the generic PostAuthUsername builder with application/json body
-func NewPostAuthUsernameRequest(server string, username Username, body PostAuthUsernameJSONRequestBody) (*http.Request, error) {
-	var bodyReader io.Reader
-	buf, err := json.Marshal(body)
+func (c *Client) PostMessagesUsernameAck(ctx context.Context, username Username, body PostMessagesUsernameAckJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostMessagesUsernameAckRequest(c.Server, username, body)
 	if err != nil {
 		return nil, err
 	}
-	bodyReader = bytes.NewReader(buf)
-	return NewPostAuthUsernameRequestWithBody(server, username, "application/json", bodyReader)
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
 }
 
-// NewPostAuthUsernameRequestWithBody generates requests for PostAuthUsername with any type of body
-func NewPostAuthUsernameRequestWithBody(server string, username Username, contentType string, body io.Reader) (*http.Request, error) {
-	var err error
-
-	var pathParam0 string
-
-	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+func (c *Client) GetPrekeysUsername(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetPrekeysUsernameRequest(c.Server, username)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
 
-	serverURL, err := url.Parse(server)
+func (c *Client) PutPrekeysUsernameWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPutPrekeysUsernameRequestWithBody(c.Server, username, contentType, body)
 	if err != nil {
 		return nil, err
 	}
-
-	operationPath := fmt.Sprintf("/auth/%s", pathParam0)
-	if operationPath[0] == '/' {
-		operationPath = "." + operationPath
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
 	}
+	return c.Client.Do(req)
+}
 
-	queryURL, err := serverURL.Parse(operationPath)
+func (c *Client) PutPrekeysUsername(ctx context.Context, username Username, body PutPrekeysUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPutPrekeysUsernameRequest(c.Server, username, body)
 	if err != nil {
 		return nil, err
 	}
-
-	req, err := http.NewRequest("POST", queryURL.String(), body)
-	if err != nil {
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
 		return nil, err
 	}
-
-	req.Header.Add("Content-Type", contentType)
-
-	return req, nil
+	return c.Client.Do(req)
 }
 
-// NewGetMessagesUsernameRequest generates requests for GetMessagesUsername
-func NewGetMessagesUsernameRequest(server string, username Username) (*http.Request, error) {
-	var err error
-
-	var pathParam0 string
-
-	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+func (c *Client) PostPrekeysUsernameClaim(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostPrekeysUsernameClaimRequest(c.Server, username)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
 
-	serverURL, err := url.Parse(server)
+func (c *Client) PostTransfersUsernameWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostTransfersUsernameRequestWithBody(c.Server, username, contentType, body)
 	if err != nil {
 		return nil, err
 	}
-
-	operationPath := fmt.Sprintf("/messages/%s", pathParam0)
-	if operationPath[0] == '/' {
-		operationPath = "." + operationPath
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
 	}
+	return c.Client.Do(req)
+}
 
-	queryURL, err := serverURL.Parse(operationPath)
+func (c *Client) PostTransfersUsername(ctx context.Context, username Username, body PostTransfersUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostTransfersUsernameRequest(c.Server, username, body)
 	if err != nil {
 		return nil, err
 	}
-
-	req, err := http.NewRequest("GET", queryURL.String(), nil)
-	if err != nil {
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
 		return nil, err
 	}
-
-	return req, nil
+	return c.Client.Do(req)
 }
 
-// NewPostMessagesUsernameRequest calls the generic PostMessagesUsername builder with application/json body
-func NewPostMessagesUsernameRequest(server string, username Username, body PostMessagesUsernameJSONRequestBody) (*http.Request, error) {
-	var bodyReader io.Reader
-	buf, err := json.Marshal(body)
+func (c *Client) GetTransfersUsernameTransferId(ctx context.Context, username Username, transferId string, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetTransfersUsernameTransferIdRequest(c.Server, username, transferId)
 	if err != nil {
 		return nil, err
 	}
-	bodyReader = bytes.NewReader(buf)
-	return NewPostMessagesUsernameRequestWithBody(server, username, "application/json", bodyReader)
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
 }
 
-// NewPostMessagesUsernameRequestWithBody generates requests for PostMessagesUsername with any type of body
-func NewPostMessagesUsernameRequestWithBody(server string, username Username, contentType string, body io.Reader) (*http.Request, error) {
-	var err error
-
-	var pathParam0 string
-
-	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+func (c *Client) PostTransfersUsernameTransferIdAck(ctx context.Context, username Username, transferId string, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostTransfersUsernameTransferIdAckRequest(c.Server, username, transferId)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
 
-	serverURL, err := url.Parse(server)
+func (c *Client) GetTransfersUsernameTransferIdChunksIndex(ctx context.Context, username Username, transferId string, index int, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetTransfersUsernameTransferIdChunksIndexRequest(c.Server, username, transferId, index)
 	if err != nil {
 		return nil, err
 	}
-
-	operationPath := fmt.Sprintf("/messages/%s", pathParam0)
-	if operationPath[0] == '/' {
-		operationPath = "." + operationPath
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
 	}
+	return c.Client.Do(req)
+}
 
-	queryURL, err := serverURL.Parse(operationPath)
+func (c *Client) PutTransfersUsernameTransferIdChunksIndexWithBody(ctx context.Context, username Username, transferId string, index int, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPutTransfersUsernameTransferIdChunksIndexRequestWithBody(c.Server, username, transferId, index, contentType, body)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
 
-	req, err := http.NewRequest("POST", queryURL.String(), body)
+func (c *Client) PutTransfersUsernameTransferIdChunksIndex(ctx context.Context, username Username, transferId string, index int, body PutTransfersUsernameTransferIdChunksIndexJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPutTransfersUsernameTransferIdChunksIndexRequest(c.Server, username, transferId, index, body)
 	if err != nil {
 		return nil, err
 	}
-
-	req.Header.Add("Content-Type", contentType)
-
-	return req, nil
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
 }
 
-// NewPostUsersRequest calls the generic PostUsers builder with application/json body
-func NewPostUsersRequest(server string, body PostUsersJSONRequestBody) (*http.Request, error) {
-	var bodyReader io.Reader
-	buf, err := json.Marshal(body)
+func (c *Client) PostUsersWithBody(ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostUsersRequestWithBody(c.Server, contentType, body)
 	if err != nil {
 		return nil, err
 	}
-	bodyReader = bytes.NewReader(buf)
-	return NewPostUsersRequestWithBody(server, "application/json", bodyReader)
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
 }
 
-// NewPostUsersRequestWithBody generates requests for PostUsers with any type of body
-func NewPostUsersRequestWithBody(server string, contentType string, body io.Reader) (*http.Request, error) {
-	var err error
+func (c *Client) PostUsers(ctx context.Context, body PostUsersJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostUsersRequest(c.Server, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
 
-	serverURL, err := url.Parse(server)
+func (c *Client) GetUsersUsername(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetUsersUsernameRequest(c.Server, username)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
 
-	operationPath := fmt.Sprintf("/users")
-	if operationPath[0] == '/' {
-		operationPath = "." + operationPath
+func (c *Client) PostUsersUsernameDevicesWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostUsersUsernameDevicesRequestWithBody(c.Server, username, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
 	}
+	return c.Client.Do(req)
+}
 
-	queryURL, err := serverURL.Parse(operationPath)
+func (c *Client) PostUsersUsernameDevices(ctx context.Context, username Username, body PostUsersUsernameDevicesJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPostUsersUsernameDevicesRequest(c.Server, username, body)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
 
-	req, err := http.NewRequest("POST", queryURL.String(), body)
+func (c *Client) GetUsersUsernameKey(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetUsersUsernameKeyRequest(c.Server, username)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
 
-	req.Header.Add("Content-Type", contentType)
+func (c *Client) PutUsersUsernameKeyWithBody(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPutUsersUsernameKeyRequestWithBody(c.Server, username, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
 
-	return req, nil
+func (c *Client) PutUsersUsernameKey(ctx context.Context, username Username, body PutUsersUsernameKeyJSONRequestBody, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewPutUsersUsernameKeyRequest(c.Server, username, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
 }
 
-// NewGetUsersUsernameRequest generates requests for GetUsersUsername
-func NewGetUsersUsernameRequest(server string, username Username) (*http.Request, error) {
+// NewGetAuthUsernameRequest generates requests for GetAuthUsername
+func NewGetAuthUsernameRequest(server string, username Username) (*http.Request, error) {
 	var err error
 
 	var pathParam0 string
@@ -498,7 +814,7 @@ func NewGetUsersUsernameRequest(server string, username Username) (*http.Request
 		return nil, err
 	}
 
-	operationPath := fmt.Sprintf("/users/%s", pathParam0)
+	operationPath := fmt.Sprintf("/auth/%s", pathParam0)
 	if operationPath[0] == '/' {
 		operationPath = "." + operationPath
 	}
@@ -516,311 +832,2134 @@ func NewGetUsersUsernameRequest(server string, username Username) (*http.Request
 	return req, nil
 }
 
-func (c *Client) applyEditors(ctx context.Context, req *http.Request, additionalEditors []RequestEditorFn) error {
-	for _, r := range c.RequestEditors {
-		if err := r(ctx, req); err != nil {
-			return err
-		}
-	}
-	for _, r := range additionalEditors {
-		if err := r(ctx, req); err != nil {
-			return err
-		}
+// NewPostAuthUsernameRequest calls the generic PostAuthUsername builder with application/json body
+func NewPostAuthUsernameRequest(server string, username Username, body PostAuthUsernameJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	bodyReader = bytes.NewReader(buf)
+	return NewPostAuthUsernameRequestWithBody(server, username, "application/json", bodyReader)
 }
 
-// ClientWithResponses builds on ClientInterface to offer response payloads
-type ClientWithResponses struct {
-	ClientInterface
-}
+// NewPostAuthUsernameRequestWithBody generates requests for PostAuthUsername with any type of body
+func NewPostAuthUsernameRequestWithBody(server string, username Username, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
 
-// NewClientWithResponses creates a new ClientWithResponses, which wraps
-// Client with return type handling
-func NewClientWithResponses(server string, opts ...ClientOption) (*ClientWithResponses, error) {
-	client, err := NewClient(server, opts...)
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
 	if err != nil {
 		return nil, err
 	}
-	return &ClientWithResponses{client}, nil
-}
 
-// WithBaseURL overrides the baseURL.
-func WithBaseURL(baseURL string) ClientOption {
-	return func(c *Client) error {
-		newBaseURL, err := url.Parse(baseURL)
-		if err != nil {
-			return err
-		}
-		c.Server = newBaseURL.String()
-		return nil
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
 	}
-}
-
-// ClientWithResponsesInterface is the interface specification for the client with responses above.
-type ClientWithResponsesInterface interface {
-	// GetAuthUsernameWithResponse request
-	GetAuthUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetAuthUsernameResponse, error)
-
-	// PostAuthUsernameWithBodyWithResponse request with any body
-	PostAuthUsernameWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostAuthUsernameResponse, error)
-
-	PostAuthUsernameWithResponse(ctx context.Context, username Username, body PostAuthUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*PostAuthUsernameResponse, error)
 
-	// GetMessagesUsernameWithResponse request
-	GetMessagesUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetMessagesUsernameResponse, error)
-
-	// PostMessagesUsernameWithBodyWithResponse request with any body
-	PostMessagesUsernameWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostMessagesUsernameResponse, error)
+	operationPath := fmt.Sprintf("/auth/%s", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
 
-	PostMessagesUsernameWithResponse(ctx context.Context, username Username, body PostMessagesUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*PostMessagesUsernameResponse, error)
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
 
-	// PostUsersWithBodyWithResponse request with any body
-	PostUsersWithBodyWithResponse(ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostUsersResponse, error)
+	req, err := http.NewRequest("POST", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
 
-	PostUsersWithResponse(ctx context.Context, body PostUsersJSONRequestBody, reqEditors ...RequestEditorFn) (*PostUsersResponse, error)
+	req.Header.Add("Content-Type", contentType)
 
-	// GetUsersUsernameWithResponse request
-	GetUsersUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetUsersUsernameResponse, error)
+	return req, nil
 }
 
-type GetAuthUsernameResponse struct {
-	Body         []byte
-	HTTPResponse *http.Response
-	JSON200      *AuthChallenge
-	JSON404      *ErrorResponse
+// NewPostAuthUsernameRefreshRequest calls the generic PostAuthUsernameRefresh builder with application/json body
+func NewPostAuthUsernameRefreshRequest(server string, username Username, body PostAuthUsernameRefreshJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewPostAuthUsernameRefreshRequestWithBody(server, username, "application/json", bodyReader)
 }
 
-// Status returns HTTPResponse.Status
-func (r GetAuthUsernameResponse) Status() string {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.Status
+// NewPostAuthUsernameRefreshRequestWithBody generates requests for PostAuthUsernameRefresh with any type of body
+func NewPostAuthUsernameRefreshRequestWithBody(server string, username Username, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
 	}
-	return http.StatusText(0)
-}
 
-// StatusCode returns HTTPResponse.StatusCode
-func (r GetAuthUsernameResponse) StatusCode() int {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.StatusCode
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
 	}
-	return 0
-}
 
-type PostAuthUsernameResponse struct {
-	Body         []byte
-	HTTPResponse *http.Response
-	JSON200      *JWT
-	JSON401      *ErrorResponse
-}
+	operationPath := fmt.Sprintf("/auth/%s/refresh", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
 
-// Status returns HTTPResponse.Status
-func (r PostAuthUsernameResponse) Status() string {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.Status
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
 	}
-	return http.StatusText(0)
-}
 
-// StatusCode returns HTTPResponse.StatusCode
-func (r PostAuthUsernameResponse) StatusCode() int {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.StatusCode
+	req, err := http.NewRequest("POST", queryURL.String(), body)
+	if err != nil {
+		return nil, err
 	}
-	return 0
-}
 
-type GetMessagesUsernameResponse struct {
-	Body         []byte
-	HTTPResponse *http.Response
-	JSON200      *[]Message
-	JSON401      *ErrorResponse
-	JSON404      *ErrorResponse
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
 }
 
-// Status returns HTTPResponse.Status
-func (r GetMessagesUsernameResponse) Status() string {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.Status
+// NewPostAuthUsernameRevokeRequest calls the generic PostAuthUsernameRevoke builder with application/json body
+func NewPostAuthUsernameRevokeRequest(server string, username Username, body PostAuthUsernameRevokeJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
 	}
-	return http.StatusText(0)
+	bodyReader = bytes.NewReader(buf)
+	return NewPostAuthUsernameRevokeRequestWithBody(server, username, "application/json", bodyReader)
 }
 
-// StatusCode returns HTTPResponse.StatusCode
-func (r GetMessagesUsernameResponse) StatusCode() int {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.StatusCode
+// NewPostAuthUsernameRevokeRequestWithBody generates requests for PostAuthUsernameRevoke with any type of body
+func NewPostAuthUsernameRevokeRequestWithBody(server string, username Username, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
 	}
-	return 0
-}
 
-type PostMessagesUsernameResponse struct {
-	Body         []byte
-	HTTPResponse *http.Response
-	JSON401      *ErrorResponse
-	JSON404      *ErrorResponse
-}
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
 
-// Status returns HTTPResponse.Status
-func (r PostMessagesUsernameResponse) Status() string {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.Status
+	operationPath := fmt.Sprintf("/auth/%s/revoke", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
 	}
-	return http.StatusText(0)
-}
 
-// StatusCode returns HTTPResponse.StatusCode
-func (r PostMessagesUsernameResponse) StatusCode() int {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.StatusCode
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
 	}
-	return 0
+
+	req, err := http.NewRequest("POST", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
 }
 
-type PostUsersResponse struct {
-	Body         []byte
-	HTTPResponse *http.Response
-	JSON409      *ErrorResponse
+// NewGetMessagesUsernameRequest generates requests for GetMessagesUsername
+func NewGetMessagesUsernameRequest(server string, username Username) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/messages/%s", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
 }
 
-// Status returns HTTPResponse.Status
-func (r PostUsersResponse) Status() string {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.Status
+// NewPostMessagesUsernameRequest calls the generic PostMessagesUsername builder with application/json body
+func NewPostMessagesUsernameRequest(server string, username Username, body PostMessagesUsernameJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
 	}
-	return http.StatusText(0)
+	bodyReader = bytes.NewReader(buf)
+	return NewPostMessagesUsernameRequestWithBody(server, username, "application/json", bodyReader)
 }
 
-// StatusCode returns HTTPResponse.StatusCode
-func (r PostUsersResponse) StatusCode() int {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.StatusCode
+// NewPostMessagesUsernameRequestWithBody generates requests for PostMessagesUsername with any type of body
+func NewPostMessagesUsernameRequestWithBody(server string, username Username, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
 	}
-	return 0
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/messages/%s", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
 }
 
-type GetUsersUsernameResponse struct {
-	Body         []byte
-	HTTPResponse *http.Response
-	JSON200      *PublicUser
-	JSON404      *ErrorResponse
+// NewPostMessagesUsernameAckRequest calls the generic PostMessagesUsernameAck builder with application/json body
+func NewPostMessagesUsernameAckRequest(server string, username Username, body PostMessagesUsernameAckJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewPostMessagesUsernameAckRequestWithBody(server, username, "application/json", bodyReader)
 }
 
-// Status returns HTTPResponse.Status
-func (r GetUsersUsernameResponse) Status() string {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.Status
+// NewPostMessagesUsernameAckRequestWithBody generates requests for PostMessagesUsernameAck with any type of body
+func NewPostMessagesUsernameAckRequestWithBody(server string, username Username, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
 	}
-	return http.StatusText(0)
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/messages/%s/ack", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
 }
 
-// StatusCode returns HTTPResponse.StatusCode
-func (r GetUsersUsernameResponse) StatusCode() int {
-	if r.HTTPResponse != nil {
-		return r.HTTPResponse.StatusCode
+// NewGetPrekeysUsernameRequest generates requests for GetPrekeysUsername
+func NewGetPrekeysUsernameRequest(server string, username Username) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
 	}
-	return 0
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/prekeys/%s", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
 }
 
-// GetAuthUsernameWithResponse request returning *GetAuthUsernameResponse
-func (c *ClientWithResponses) GetAuthUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetAuthUsernameResponse, error) {
-	rsp, err := c.GetAuthUsername(ctx, username, reqEditors...)
+// NewPutPrekeysUsernameRequest calls the generic PutPrekeysUsername builder with application/json body
+func NewPutPrekeysUsernameRequest(server string, username Username, body PutPrekeysUsernameJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
 	}
-	return ParseGetAuthUsernameResponse(rsp)
+	bodyReader = bytes.NewReader(buf)
+	return NewPutPrekeysUsernameRequestWithBody(server, username, "application/json", bodyReader)
 }
 
-// PostAuthUsernameWithBodyWithResponse request with arbitrary body returning *PostAuthUsernameResponse
-func (c *ClientWithResponses) PostAuthUsernameWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostAuthUsernameResponse, error) {
-	rsp, err := c.PostAuthUsernameWithBody(ctx, username, contentType, body, reqEditors...)
+// NewPutPrekeysUsernameRequestWithBody generates requests for PutPrekeysUsername with any type of body
+func NewPutPrekeysUsernameRequestWithBody(server string, username Username, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
 	if err != nil {
 		return nil, err
 	}
-	return ParsePostAuthUsernameResponse(rsp)
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/prekeys/%s", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("PUT", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
+}
+
+// NewPostPrekeysUsernameClaimRequest generates requests for PostPrekeysUsernameClaim
+func NewPostPrekeysUsernameClaimRequest(server string, username Username) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/prekeys/%s/claim", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewPostTransfersUsernameRequest calls the generic PostTransfersUsername builder with application/json body
+func NewPostTransfersUsernameRequest(server string, username Username, body PostTransfersUsernameJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewPostTransfersUsernameRequestWithBody(server, username, "application/json", bodyReader)
+}
+
+// NewPostTransfersUsernameRequestWithBody generates requests for PostTransfersUsername with any type of body
+func NewPostTransfersUsernameRequestWithBody(server string, username Username, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/transfers/%s", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
+}
+
+// NewGetTransfersUsernameTransferIdRequest generates requests for GetTransfersUsernameTransferId
+func NewGetTransfersUsernameTransferIdRequest(server string, username Username, transferId string) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathParam1 string
+
+	pathParam1, err = runtime.StyleParamWithLocation("simple", false, "transfer_id", runtime.ParamLocationPath, transferId)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/transfers/%s/%s", pathParam0, pathParam1)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewPostTransfersUsernameTransferIdAckRequest generates requests for PostTransfersUsernameTransferIdAck
+func NewPostTransfersUsernameTransferIdAckRequest(server string, username Username, transferId string) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathParam1 string
+
+	pathParam1, err = runtime.StyleParamWithLocation("simple", false, "transfer_id", runtime.ParamLocationPath, transferId)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/transfers/%s/%s/ack", pathParam0, pathParam1)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewGetTransfersUsernameTransferIdChunksIndexRequest generates requests for GetTransfersUsernameTransferIdChunksIndex
+func NewGetTransfersUsernameTransferIdChunksIndexRequest(server string, username Username, transferId string, index int) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathParam1 string
+
+	pathParam1, err = runtime.StyleParamWithLocation("simple", false, "transfer_id", runtime.ParamLocationPath, transferId)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathParam2 string
+
+	pathParam2, err = runtime.StyleParamWithLocation("simple", false, "index", runtime.ParamLocationPath, index)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/transfers/%s/%s/chunks/%s", pathParam0, pathParam1, pathParam2)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewPutTransfersUsernameTransferIdChunksIndexRequest calls the generic PutTransfersUsernameTransferIdChunksIndex builder with application/json body
+func NewPutTransfersUsernameTransferIdChunksIndexRequest(server string, username Username, transferId string, index int, body PutTransfersUsernameTransferIdChunksIndexJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewPutTransfersUsernameTransferIdChunksIndexRequestWithBody(server, username, transferId, index, "application/json", bodyReader)
+}
+
+// NewPutTransfersUsernameTransferIdChunksIndexRequestWithBody generates requests for PutTransfersUsernameTransferIdChunksIndex with any type of body
+func NewPutTransfersUsernameTransferIdChunksIndexRequestWithBody(server string, username Username, transferId string, index int, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathParam1 string
+
+	pathParam1, err = runtime.StyleParamWithLocation("simple", false, "transfer_id", runtime.ParamLocationPath, transferId)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathParam2 string
+
+	pathParam2, err = runtime.StyleParamWithLocation("simple", false, "index", runtime.ParamLocationPath, index)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/transfers/%s/%s/chunks/%s", pathParam0, pathParam1, pathParam2)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("PUT", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
+}
+
+// NewPostUsersRequest calls the generic PostUsers builder with application/json body
+func NewPostUsersRequest(server string, body PostUsersJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewPostUsersRequestWithBody(server, "application/json", bodyReader)
+}
+
+// NewPostUsersRequestWithBody generates requests for PostUsers with any type of body
+func NewPostUsersRequestWithBody(server string, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/users")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
+}
+
+// NewGetUsersUsernameRequest generates requests for GetUsersUsername
+func NewGetUsersUsernameRequest(server string, username Username) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/users/%s", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewPostUsersUsernameDevicesRequest calls the generic PostUsersUsernameDevices builder with application/json body
+func NewPostUsersUsernameDevicesRequest(server string, username Username, body PostUsersUsernameDevicesJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewPostUsersUsernameDevicesRequestWithBody(server, username, "application/json", bodyReader)
+}
+
+// NewPostUsersUsernameDevicesRequestWithBody generates requests for PostUsersUsernameDevices with any type of body
+func NewPostUsersUsernameDevicesRequestWithBody(server string, username Username, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/users/%s/devices", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
+}
+
+// NewGetUsersUsernameKeyRequest generates requests for GetUsersUsernameKey
+func NewGetUsersUsernameKeyRequest(server string, username Username) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/users/%s/key", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewPutUsersUsernameKeyRequest calls the generic PutUsersUsernameKey builder with application/json body
+func NewPutUsersUsernameKeyRequest(server string, username Username, body PutUsersUsernameKeyJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewPutUsersUsernameKeyRequestWithBody(server, username, "application/json", bodyReader)
+}
+
+// NewPutUsersUsernameKeyRequestWithBody generates requests for PutUsersUsernameKey with any type of body
+func NewPutUsersUsernameKeyRequestWithBody(server string, username Username, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	var pathParam0 string
+
+	pathParam0, err = runtime.StyleParamWithLocation("simple", false, "username", runtime.ParamLocationPath, username)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/users/%s/key", pathParam0)
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("PUT", queryURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+
+	return req, nil
+}
+
+func (c *Client) applyEditors(ctx context.Context, req *http.Request, additionalEditors []RequestEditorFn) error {
+	for _, r := range c.RequestEditors {
+		if err := r(ctx, req); err != nil {
+			return err
+		}
+	}
+	for _, r := range additionalEditors {
+		if err := r(ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClientWithResponses builds on ClientInterface to offer response payloads
+type ClientWithResponses struct {
+	ClientInterface
+}
+
+// NewClientWithResponses creates a new ClientWithResponses, which wraps
+// Client with return type handling
+func NewClientWithResponses(server string, opts ...ClientOption) (*ClientWithResponses, error) {
+	client, err := NewClient(server, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientWithResponses{client}, nil
+}
+
+// WithBaseURL overrides the baseURL.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) error {
+		newBaseURL, err := url.Parse(baseURL)
+		if err != nil {
+			return err
+		}
+		c.Server = newBaseURL.String()
+		return nil
+	}
+}
+
+// ClientWithResponsesInterface is the interface specification for the client with responses above.
+type ClientWithResponsesInterface interface {
+	// GetAuthUsernameWithResponse request
+	GetAuthUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetAuthUsernameResponse, error)
+
+	// PostAuthUsernameWithBodyWithResponse request with any body
+	PostAuthUsernameWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostAuthUsernameResponse, error)
+
+	PostAuthUsernameWithResponse(ctx context.Context, username Username, body PostAuthUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*PostAuthUsernameResponse, error)
+
+	// PostAuthUsernameRefreshWithBodyWithResponse request with any body
+	PostAuthUsernameRefreshWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostAuthUsernameRefreshResponse, error)
+
+	PostAuthUsernameRefreshWithResponse(ctx context.Context, username Username, body PostAuthUsernameRefreshJSONRequestBody, reqEditors ...RequestEditorFn) (*PostAuthUsernameRefreshResponse, error)
+
+	// PostAuthUsernameRevokeWithBodyWithResponse request with any body
+	PostAuthUsernameRevokeWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostAuthUsernameRevokeResponse, error)
+
+	PostAuthUsernameRevokeWithResponse(ctx context.Context, username Username, body PostAuthUsernameRevokeJSONRequestBody, reqEditors ...RequestEditorFn) (*PostAuthUsernameRevokeResponse, error)
+
+	// GetMessagesUsernameWithResponse request
+	GetMessagesUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetMessagesUsernameResponse, error)
+
+	// PostMessagesUsernameWithBodyWithResponse request with any body
+	PostMessagesUsernameWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostMessagesUsernameResponse, error)
+
+	PostMessagesUsernameWithResponse(ctx context.Context, username Username, body PostMessagesUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*PostMessagesUsernameResponse, error)
+
+	// PostMessagesUsernameAckWithBodyWithResponse request with any body
+	PostMessagesUsernameAckWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostMessagesUsernameAckResponse, error)
+
+	PostMessagesUsernameAckWithResponse(ctx context.Context, username Username, body PostMessagesUsernameAckJSONRequestBody, reqEditors ...RequestEditorFn) (*PostMessagesUsernameAckResponse, error)
+
+	// GetPrekeysUsernameWithResponse request
+	GetPrekeysUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetPrekeysUsernameResponse, error)
+
+	// PutPrekeysUsernameWithBodyWithResponse request with any body
+	PutPrekeysUsernameWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PutPrekeysUsernameResponse, error)
+
+	PutPrekeysUsernameWithResponse(ctx context.Context, username Username, body PutPrekeysUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*PutPrekeysUsernameResponse, error)
+
+	// PostPrekeysUsernameClaimWithResponse request
+	PostPrekeysUsernameClaimWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*PostPrekeysUsernameClaimResponse, error)
+
+	// PostTransfersUsernameWithBodyWithResponse request with any body
+	PostTransfersUsernameWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostTransfersUsernameResponse, error)
+
+	PostTransfersUsernameWithResponse(ctx context.Context, username Username, body PostTransfersUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*PostTransfersUsernameResponse, error)
+
+	// GetTransfersUsernameTransferIdWithResponse request
+	GetTransfersUsernameTransferIdWithResponse(ctx context.Context, username Username, transferId string, reqEditors ...RequestEditorFn) (*GetTransfersUsernameTransferIdResponse, error)
+
+	// PostTransfersUsernameTransferIdAckWithResponse request
+	PostTransfersUsernameTransferIdAckWithResponse(ctx context.Context, username Username, transferId string, reqEditors ...RequestEditorFn) (*PostTransfersUsernameTransferIdAckResponse, error)
+
+	// GetTransfersUsernameTransferIdChunksIndexWithResponse request
+	GetTransfersUsernameTransferIdChunksIndexWithResponse(ctx context.Context, username Username, transferId string, index int, reqEditors ...RequestEditorFn) (*GetTransfersUsernameTransferIdChunksIndexResponse, error)
+
+	// PutTransfersUsernameTransferIdChunksIndexWithBodyWithResponse request with any body
+	PutTransfersUsernameTransferIdChunksIndexWithBodyWithResponse(ctx context.Context, username Username, transferId string, index int, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PutTransfersUsernameTransferIdChunksIndexResponse, error)
+
+	PutTransfersUsernameTransferIdChunksIndexWithResponse(ctx context.Context, username Username, transferId string, index int, body PutTransfersUsernameTransferIdChunksIndexJSONRequestBody, reqEditors ...RequestEditorFn) (*PutTransfersUsernameTransferIdChunksIndexResponse, error)
+
+	// PostUsersWithBodyWithResponse request with any body
+	PostUsersWithBodyWithResponse(ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostUsersResponse, error)
+
+	PostUsersWithResponse(ctx context.Context, body PostUsersJSONRequestBody, reqEditors ...RequestEditorFn) (*PostUsersResponse, error)
+
+	// GetUsersUsernameWithResponse request
+	GetUsersUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetUsersUsernameResponse, error)
+
+	// PostUsersUsernameDevicesWithBodyWithResponse request with any body
+	PostUsersUsernameDevicesWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostUsersUsernameDevicesResponse, error)
+
+	PostUsersUsernameDevicesWithResponse(ctx context.Context, username Username, body PostUsersUsernameDevicesJSONRequestBody, reqEditors ...RequestEditorFn) (*PostUsersUsernameDevicesResponse, error)
+
+	// GetUsersUsernameKeyWithResponse request
+	GetUsersUsernameKeyWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetUsersUsernameKeyResponse, error)
+
+	// PutUsersUsernameKeyWithBodyWithResponse request with any body
+	PutUsersUsernameKeyWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PutUsersUsernameKeyResponse, error)
+
+	PutUsersUsernameKeyWithResponse(ctx context.Context, username Username, body PutUsersUsernameKeyJSONRequestBody, reqEditors ...RequestEditorFn) (*PutUsersUsernameKeyResponse, error)
+}
+
+type GetAuthUsernameResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *AuthChallenge
+	JSON404      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r GetAuthUsernameResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetAuthUsernameResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PostAuthUsernameResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *JWT
+	JSON401      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r PostAuthUsernameResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PostAuthUsernameResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PostAuthUsernameRefreshResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *JWT
+	JSON401      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r PostAuthUsernameRefreshResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PostAuthUsernameRefreshResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PostAuthUsernameRevokeResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+}
+
+// Status returns HTTPResponse.Status
+func (r PostAuthUsernameRevokeResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PostAuthUsernameRevokeResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetMessagesUsernameResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *[]Message
+	JSON401      *ErrorResponse
+	JSON404      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r GetMessagesUsernameResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetMessagesUsernameResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PostMessagesUsernameResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *Message
+	JSON201      *Message
+	JSON400      *ErrorResponse
+	JSON401      *ErrorResponse
+	JSON404      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r PostMessagesUsernameResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PostMessagesUsernameResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PostMessagesUsernameAckResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON400      *ErrorResponse
+	JSON401      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r PostMessagesUsernameAckResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PostMessagesUsernameAckResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetPrekeysUsernameResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *PrekeyStatus
+	JSON401      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r GetPrekeysUsernameResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetPrekeysUsernameResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PutPrekeysUsernameResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *PrekeyStatus
+	JSON400      *ErrorResponse
+	JSON401      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r PutPrekeysUsernameResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PutPrekeysUsernameResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PostPrekeysUsernameClaimResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *PrekeyBundle
+	JSON401      *ErrorResponse
+	JSON404      *ErrorResponse
+	JSON429      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r PostPrekeysUsernameClaimResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PostPrekeysUsernameClaimResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PostTransfersUsernameResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON201      *Transfer
+	JSON400      *ErrorResponse
+	JSON401      *ErrorResponse
+	JSON404      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r PostTransfersUsernameResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PostTransfersUsernameResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetTransfersUsernameTransferIdResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *Transfer
+	JSON401      *ErrorResponse
+	JSON404      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r GetTransfersUsernameTransferIdResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetTransfersUsernameTransferIdResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PostTransfersUsernameTransferIdAckResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON401      *ErrorResponse
+	JSON404      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r PostTransfersUsernameTransferIdAckResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PostTransfersUsernameTransferIdAckResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetTransfersUsernameTransferIdChunksIndexResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *TransferChunk
+	JSON401      *ErrorResponse
+	JSON404      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r GetTransfersUsernameTransferIdChunksIndexResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetTransfersUsernameTransferIdChunksIndexResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PutTransfersUsernameTransferIdChunksIndexResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON400      *ErrorResponse
+	JSON401      *ErrorResponse
+	JSON404      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r PutTransfersUsernameTransferIdChunksIndexResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PutTransfersUsernameTransferIdChunksIndexResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PostUsersResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON409      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r PostUsersResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PostUsersResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetUsersUsernameResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *PublicUser
+	JSON404      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r GetUsersUsernameResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetUsersUsernameResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PostUsersUsernameDevicesResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON400      *ErrorResponse
+	JSON404      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r PostUsersUsernameDevicesResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PostUsersUsernameDevicesResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetUsersUsernameKeyResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *[]KeyRotation
+	JSON404      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r GetUsersUsernameKeyResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetUsersUsernameKeyResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type PutUsersUsernameKeyResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON400      *ErrorResponse
+	JSON404      *ErrorResponse
+	JSON409      *ErrorResponse
+}
+
+// Status returns HTTPResponse.Status
+func (r PutUsersUsernameKeyResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r PutUsersUsernameKeyResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+// GetAuthUsernameWithResponse request returning *GetAuthUsernameResponse
+func (c *ClientWithResponses) GetAuthUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetAuthUsernameResponse, error) {
+	rsp, err := c.GetAuthUsername(ctx, username, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetAuthUsernameResponse(rsp)
+}
+
+// PostAuthUsernameWithBodyWithResponse request with arbitrary body returning *PostAuthUsernameResponse
+func (c *ClientWithResponses) PostAuthUsernameWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostAuthUsernameResponse, error) {
+	rsp, err := c.PostAuthUsernameWithBody(ctx, username, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostAuthUsernameResponse(rsp)
+}
+
+func (c *ClientWithResponses) PostAuthUsernameWithResponse(ctx context.Context, username Username, body PostAuthUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*PostAuthUsernameResponse, error) {
+	rsp, err := c.PostAuthUsername(ctx, username, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostAuthUsernameResponse(rsp)
+}
+
+// PostAuthUsernameRefreshWithBodyWithResponse request with arbitrary body returning *PostAuthUsernameRefreshResponse
+func (c *ClientWithResponses) PostAuthUsernameRefreshWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostAuthUsernameRefreshResponse, error) {
+	rsp, err := c.PostAuthUsernameRefreshWithBody(ctx, username, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostAuthUsernameRefreshResponse(rsp)
+}
+
+func (c *ClientWithResponses) PostAuthUsernameRefreshWithResponse(ctx context.Context, username Username, body PostAuthUsernameRefreshJSONRequestBody, reqEditors ...RequestEditorFn) (*PostAuthUsernameRefreshResponse, error) {
+	rsp, err := c.PostAuthUsernameRefresh(ctx, username, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostAuthUsernameRefreshResponse(rsp)
+}
+
+// PostAuthUsernameRevokeWithBodyWithResponse request with arbitrary body returning *PostAuthUsernameRevokeResponse
+func (c *ClientWithResponses) PostAuthUsernameRevokeWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostAuthUsernameRevokeResponse, error) {
+	rsp, err := c.PostAuthUsernameRevokeWithBody(ctx, username, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostAuthUsernameRevokeResponse(rsp)
+}
+
+func (c *ClientWithResponses) PostAuthUsernameRevokeWithResponse(ctx context.Context, username Username, body PostAuthUsernameRevokeJSONRequestBody, reqEditors ...RequestEditorFn) (*PostAuthUsernameRevokeResponse, error) {
+	rsp, err := c.PostAuthUsernameRevoke(ctx, username, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostAuthUsernameRevokeResponse(rsp)
+}
+
+// GetMessagesUsernameWithResponse request returning *GetMessagesUsernameResponse
+func (c *ClientWithResponses) GetMessagesUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetMessagesUsernameResponse, error) {
+	rsp, err := c.GetMessagesUsername(ctx, username, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetMessagesUsernameResponse(rsp)
+}
+
+// PostMessagesUsernameWithBodyWithResponse request with arbitrary body returning *PostMessagesUsernameResponse
+func (c *ClientWithResponses) PostMessagesUsernameWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostMessagesUsernameResponse, error) {
+	rsp, err := c.PostMessagesUsernameWithBody(ctx, username, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostMessagesUsernameResponse(rsp)
+}
+
+func (c *ClientWithResponses) PostMessagesUsernameWithResponse(ctx context.Context, username Username, body PostMessagesUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*PostMessagesUsernameResponse, error) {
+	rsp, err := c.PostMessagesUsername(ctx, username, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostMessagesUsernameResponse(rsp)
+}
+
+// PostMessagesUsernameAckWithBodyWithResponse request with arbitrary body returning *PostMessagesUsernameAckResponse
+func (c *ClientWithResponses) PostMessagesUsernameAckWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostMessagesUsernameAckResponse, error) {
+	rsp, err := c.PostMessagesUsernameAckWithBody(ctx, username, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostMessagesUsernameAckResponse(rsp)
+}
+
+func (c *ClientWithResponses) PostMessagesUsernameAckWithResponse(ctx context.Context, username Username, body PostMessagesUsernameAckJSONRequestBody, reqEditors ...RequestEditorFn) (*PostMessagesUsernameAckResponse, error) {
+	rsp, err := c.PostMessagesUsernameAck(ctx, username, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostMessagesUsernameAckResponse(rsp)
+}
+
+// GetPrekeysUsernameWithResponse request returning *GetPrekeysUsernameResponse
+func (c *ClientWithResponses) GetPrekeysUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetPrekeysUsernameResponse, error) {
+	rsp, err := c.GetPrekeysUsername(ctx, username, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetPrekeysUsernameResponse(rsp)
+}
+
+// PutPrekeysUsernameWithBodyWithResponse request with arbitrary body returning *PutPrekeysUsernameResponse
+func (c *ClientWithResponses) PutPrekeysUsernameWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PutPrekeysUsernameResponse, error) {
+	rsp, err := c.PutPrekeysUsernameWithBody(ctx, username, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePutPrekeysUsernameResponse(rsp)
+}
+
+func (c *ClientWithResponses) PutPrekeysUsernameWithResponse(ctx context.Context, username Username, body PutPrekeysUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*PutPrekeysUsernameResponse, error) {
+	rsp, err := c.PutPrekeysUsername(ctx, username, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePutPrekeysUsernameResponse(rsp)
+}
+
+// PostPrekeysUsernameClaimWithResponse request returning *PostPrekeysUsernameClaimResponse
+func (c *ClientWithResponses) PostPrekeysUsernameClaimWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*PostPrekeysUsernameClaimResponse, error) {
+	rsp, err := c.PostPrekeysUsernameClaim(ctx, username, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostPrekeysUsernameClaimResponse(rsp)
+}
+
+// PostTransfersUsernameWithBodyWithResponse request with arbitrary body returning *PostTransfersUsernameResponse
+func (c *ClientWithResponses) PostTransfersUsernameWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostTransfersUsernameResponse, error) {
+	rsp, err := c.PostTransfersUsernameWithBody(ctx, username, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostTransfersUsernameResponse(rsp)
+}
+
+func (c *ClientWithResponses) PostTransfersUsernameWithResponse(ctx context.Context, username Username, body PostTransfersUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*PostTransfersUsernameResponse, error) {
+	rsp, err := c.PostTransfersUsername(ctx, username, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostTransfersUsernameResponse(rsp)
+}
+
+// GetTransfersUsernameTransferIdWithResponse request returning *GetTransfersUsernameTransferIdResponse
+func (c *ClientWithResponses) GetTransfersUsernameTransferIdWithResponse(ctx context.Context, username Username, transferId string, reqEditors ...RequestEditorFn) (*GetTransfersUsernameTransferIdResponse, error) {
+	rsp, err := c.GetTransfersUsernameTransferId(ctx, username, transferId, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetTransfersUsernameTransferIdResponse(rsp)
+}
+
+// PostTransfersUsernameTransferIdAckWithResponse request returning *PostTransfersUsernameTransferIdAckResponse
+func (c *ClientWithResponses) PostTransfersUsernameTransferIdAckWithResponse(ctx context.Context, username Username, transferId string, reqEditors ...RequestEditorFn) (*PostTransfersUsernameTransferIdAckResponse, error) {
+	rsp, err := c.PostTransfersUsernameTransferIdAck(ctx, username, transferId, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostTransfersUsernameTransferIdAckResponse(rsp)
+}
+
+// GetTransfersUsernameTransferIdChunksIndexWithResponse request returning *GetTransfersUsernameTransferIdChunksIndexResponse
+func (c *ClientWithResponses) GetTransfersUsernameTransferIdChunksIndexWithResponse(ctx context.Context, username Username, transferId string, index int, reqEditors ...RequestEditorFn) (*GetTransfersUsernameTransferIdChunksIndexResponse, error) {
+	rsp, err := c.GetTransfersUsernameTransferIdChunksIndex(ctx, username, transferId, index, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetTransfersUsernameTransferIdChunksIndexResponse(rsp)
+}
+
+// PutTransfersUsernameTransferIdChunksIndexWithBodyWithResponse request with arbitrary body returning *PutTransfersUsernameTransferIdChunksIndexResponse
+func (c *ClientWithResponses) PutTransfersUsernameTransferIdChunksIndexWithBodyWithResponse(ctx context.Context, username Username, transferId string, index int, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PutTransfersUsernameTransferIdChunksIndexResponse, error) {
+	rsp, err := c.PutTransfersUsernameTransferIdChunksIndexWithBody(ctx, username, transferId, index, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePutTransfersUsernameTransferIdChunksIndexResponse(rsp)
+}
+
+func (c *ClientWithResponses) PutTransfersUsernameTransferIdChunksIndexWithResponse(ctx context.Context, username Username, transferId string, index int, body PutTransfersUsernameTransferIdChunksIndexJSONRequestBody, reqEditors ...RequestEditorFn) (*PutTransfersUsernameTransferIdChunksIndexResponse, error) {
+	rsp, err := c.PutTransfersUsernameTransferIdChunksIndex(ctx, username, transferId, index, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePutTransfersUsernameTransferIdChunksIndexResponse(rsp)
+}
+
+// PostUsersWithBodyWithResponse request with arbitrary body returning *PostUsersResponse
+func (c *ClientWithResponses) PostUsersWithBodyWithResponse(ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostUsersResponse, error) {
+	rsp, err := c.PostUsersWithBody(ctx, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostUsersResponse(rsp)
+}
+
+func (c *ClientWithResponses) PostUsersWithResponse(ctx context.Context, body PostUsersJSONRequestBody, reqEditors ...RequestEditorFn) (*PostUsersResponse, error) {
+	rsp, err := c.PostUsers(ctx, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostUsersResponse(rsp)
+}
+
+// GetUsersUsernameWithResponse request returning *GetUsersUsernameResponse
+func (c *ClientWithResponses) GetUsersUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetUsersUsernameResponse, error) {
+	rsp, err := c.GetUsersUsername(ctx, username, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetUsersUsernameResponse(rsp)
+}
+
+// PostUsersUsernameDevicesWithBodyWithResponse request with arbitrary body returning *PostUsersUsernameDevicesResponse
+func (c *ClientWithResponses) PostUsersUsernameDevicesWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostUsersUsernameDevicesResponse, error) {
+	rsp, err := c.PostUsersUsernameDevicesWithBody(ctx, username, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostUsersUsernameDevicesResponse(rsp)
+}
+
+func (c *ClientWithResponses) PostUsersUsernameDevicesWithResponse(ctx context.Context, username Username, body PostUsersUsernameDevicesJSONRequestBody, reqEditors ...RequestEditorFn) (*PostUsersUsernameDevicesResponse, error) {
+	rsp, err := c.PostUsersUsernameDevices(ctx, username, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostUsersUsernameDevicesResponse(rsp)
+}
+
+// GetUsersUsernameKeyWithResponse request returning *GetUsersUsernameKeyResponse
+func (c *ClientWithResponses) GetUsersUsernameKeyWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetUsersUsernameKeyResponse, error) {
+	rsp, err := c.GetUsersUsernameKey(ctx, username, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetUsersUsernameKeyResponse(rsp)
+}
+
+// PutUsersUsernameKeyWithBodyWithResponse request with arbitrary body returning *PutUsersUsernameKeyResponse
+func (c *ClientWithResponses) PutUsersUsernameKeyWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PutUsersUsernameKeyResponse, error) {
+	rsp, err := c.PutUsersUsernameKeyWithBody(ctx, username, contentType, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePutUsersUsernameKeyResponse(rsp)
+}
+
+func (c *ClientWithResponses) PutUsersUsernameKeyWithResponse(ctx context.Context, username Username, body PutUsersUsernameKeyJSONRequestBody, reqEditors ...RequestEditorFn) (*PutUsersUsernameKeyResponse, error) {
+	rsp, err := c.PutUsersUsernameKey(ctx, username, body, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePutUsersUsernameKeyResponse(rsp)
+}
+
+// ParseGetAuthUsernameResponse parses an HTTP response from a GetAuthUsernameWithResponse call
+func ParseGetAuthUsernameResponse(rsp *http.Response) (*GetAuthUsernameResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetAuthUsernameResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest AuthChallenge
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 404:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON404 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParsePostAuthUsernameResponse parses an HTTP response from a PostAuthUsernameWithResponse call
+func ParsePostAuthUsernameResponse(rsp *http.Response) (*PostAuthUsernameResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &PostAuthUsernameResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest JWT
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParsePostAuthUsernameRefreshResponse parses an HTTP response from a PostAuthUsernameRefreshWithResponse call
+func ParsePostAuthUsernameRefreshResponse(rsp *http.Response) (*PostAuthUsernameRefreshResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &PostAuthUsernameRefreshResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest JWT
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParsePostAuthUsernameRevokeResponse parses an HTTP response from a PostAuthUsernameRevokeWithResponse call
+func ParsePostAuthUsernameRevokeResponse(rsp *http.Response) (*PostAuthUsernameRevokeResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &PostAuthUsernameRevokeResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	}
+
+	return response, nil
+}
+
+// ParseGetMessagesUsernameResponse parses an HTTP response from a GetMessagesUsernameWithResponse call
+func ParseGetMessagesUsernameResponse(rsp *http.Response) (*GetMessagesUsernameResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetMessagesUsernameResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest []Message
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 404:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON404 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParsePostMessagesUsernameResponse parses an HTTP response from a PostMessagesUsernameWithResponse call
+func ParsePostMessagesUsernameResponse(rsp *http.Response) (*PostMessagesUsernameResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &PostMessagesUsernameResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest Message
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 201:
+		var dest Message
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON201 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 404:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON404 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParsePostMessagesUsernameAckResponse parses an HTTP response from a PostMessagesUsernameAckWithResponse call
+func ParsePostMessagesUsernameAckResponse(rsp *http.Response) (*PostMessagesUsernameAckResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &PostMessagesUsernameAckResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParseGetPrekeysUsernameResponse parses an HTTP response from a GetPrekeysUsernameWithResponse call
+func ParseGetPrekeysUsernameResponse(rsp *http.Response) (*GetPrekeysUsernameResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetPrekeysUsernameResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest PrekeyStatus
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	}
+
+	return response, nil
 }
 
-func (c *ClientWithResponses) PostAuthUsernameWithResponse(ctx context.Context, username Username, body PostAuthUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*PostAuthUsernameResponse, error) {
-	rsp, err := c.PostAuthUsername(ctx, username, body, reqEditors...)
+// ParsePutPrekeysUsernameResponse parses an HTTP response from a PutPrekeysUsernameWithResponse call
+func ParsePutPrekeysUsernameResponse(rsp *http.Response) (*PutPrekeysUsernameResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
 	if err != nil {
 		return nil, err
 	}
-	return ParsePostAuthUsernameResponse(rsp)
-}
 
-// GetMessagesUsernameWithResponse request returning *GetMessagesUsernameResponse
-func (c *ClientWithResponses) GetMessagesUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetMessagesUsernameResponse, error) {
-	rsp, err := c.GetMessagesUsername(ctx, username, reqEditors...)
-	if err != nil {
-		return nil, err
+	response := &PutPrekeysUsernameResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
 	}
-	return ParseGetMessagesUsernameResponse(rsp)
-}
 
-// PostMessagesUsernameWithBodyWithResponse request with arbitrary body returning *PostMessagesUsernameResponse
-func (c *ClientWithResponses) PostMessagesUsernameWithBodyWithResponse(ctx context.Context, username Username, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostMessagesUsernameResponse, error) {
-	rsp, err := c.PostMessagesUsernameWithBody(ctx, username, contentType, body, reqEditors...)
-	if err != nil {
-		return nil, err
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest PrekeyStatus
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
 	}
-	return ParsePostMessagesUsernameResponse(rsp)
+
+	return response, nil
 }
 
-func (c *ClientWithResponses) PostMessagesUsernameWithResponse(ctx context.Context, username Username, body PostMessagesUsernameJSONRequestBody, reqEditors ...RequestEditorFn) (*PostMessagesUsernameResponse, error) {
-	rsp, err := c.PostMessagesUsername(ctx, username, body, reqEditors...)
+// ParsePostPrekeysUsernameClaimResponse parses an HTTP response from a PostPrekeysUsernameClaimWithResponse call
+func ParsePostPrekeysUsernameClaimResponse(rsp *http.Response) (*PostPrekeysUsernameClaimResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
 	if err != nil {
 		return nil, err
 	}
-	return ParsePostMessagesUsernameResponse(rsp)
-}
 
-// PostUsersWithBodyWithResponse request with arbitrary body returning *PostUsersResponse
-func (c *ClientWithResponses) PostUsersWithBodyWithResponse(ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn) (*PostUsersResponse, error) {
-	rsp, err := c.PostUsersWithBody(ctx, contentType, body, reqEditors...)
-	if err != nil {
-		return nil, err
+	response := &PostPrekeysUsernameClaimResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
 	}
-	return ParsePostUsersResponse(rsp)
-}
 
-func (c *ClientWithResponses) PostUsersWithResponse(ctx context.Context, body PostUsersJSONRequestBody, reqEditors ...RequestEditorFn) (*PostUsersResponse, error) {
-	rsp, err := c.PostUsers(ctx, body, reqEditors...)
-	if err != nil {
-		return nil, err
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest PrekeyBundle
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 404:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON404 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 429:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON429 = &dest
+
 	}
-	return ParsePostUsersResponse(rsp)
+
+	return response, nil
 }
 
-// GetUsersUsernameWithResponse request returning *GetUsersUsernameResponse
-func (c *ClientWithResponses) GetUsersUsernameWithResponse(ctx context.Context, username Username, reqEditors ...RequestEditorFn) (*GetUsersUsernameResponse, error) {
-	rsp, err := c.GetUsersUsername(ctx, username, reqEditors...)
+// ParsePostTransfersUsernameResponse parses an HTTP response from a PostTransfersUsernameWithResponse call
+func ParsePostTransfersUsernameResponse(rsp *http.Response) (*PostTransfersUsernameResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
 	if err != nil {
 		return nil, err
 	}
-	return ParseGetUsersUsernameResponse(rsp)
+
+	response := &PostTransfersUsernameResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 201:
+		var dest Transfer
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON201 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 404:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON404 = &dest
+
+	}
+
+	return response, nil
 }
 
-// ParseGetAuthUsernameResponse parses an HTTP response from a GetAuthUsernameWithResponse call
-func ParseGetAuthUsernameResponse(rsp *http.Response) (*GetAuthUsernameResponse, error) {
+// ParseGetTransfersUsernameTransferIdResponse parses an HTTP response from a GetTransfersUsernameTransferIdWithResponse call
+func ParseGetTransfersUsernameTransferIdResponse(rsp *http.Response) (*GetTransfersUsernameTransferIdResponse, error) {
 	bodyBytes, err := io.ReadAll(rsp.Body)
 	defer func() { _ = rsp.Body.Close() }()
 	if err != nil {
 		return nil, err
 	}
 
-	response := &GetAuthUsernameResponse{
+	response := &GetTransfersUsernameTransferIdResponse{
 		Body:         bodyBytes,
 		HTTPResponse: rsp,
 	}
 
 	switch {
 	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
-		var dest AuthChallenge
+		var dest Transfer
 		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
 			return nil, err
 		}
 		response.JSON200 = &dest
 
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
 	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 404:
 		var dest ErrorResponse
 		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
@@ -833,55 +2972,55 @@ func ParseGetAuthUsernameResponse(rsp *http.Response) (*GetAuthUsernameResponse,
 	return response, nil
 }
 
-// ParsePostAuthUsernameResponse parses an HTTP response from a PostAuthUsernameWithResponse call
-func ParsePostAuthUsernameResponse(rsp *http.Response) (*PostAuthUsernameResponse, error) {
+// ParsePostTransfersUsernameTransferIdAckResponse parses an HTTP response from a PostTransfersUsernameTransferIdAckWithResponse call
+func ParsePostTransfersUsernameTransferIdAckResponse(rsp *http.Response) (*PostTransfersUsernameTransferIdAckResponse, error) {
 	bodyBytes, err := io.ReadAll(rsp.Body)
 	defer func() { _ = rsp.Body.Close() }()
 	if err != nil {
 		return nil, err
 	}
 
-	response := &PostAuthUsernameResponse{
+	response := &PostTransfersUsernameTransferIdAckResponse{
 		Body:         bodyBytes,
 		HTTPResponse: rsp,
 	}
 
 	switch {
-	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
-		var dest JWT
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest ErrorResponse
 		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
 			return nil, err
 		}
-		response.JSON200 = &dest
+		response.JSON401 = &dest
 
-	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 404:
 		var dest ErrorResponse
 		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
 			return nil, err
 		}
-		response.JSON401 = &dest
+		response.JSON404 = &dest
 
 	}
 
 	return response, nil
 }
 
-// ParseGetMessagesUsernameResponse parses an HTTP response from a GetMessagesUsernameWithResponse call
-func ParseGetMessagesUsernameResponse(rsp *http.Response) (*GetMessagesUsernameResponse, error) {
+// ParseGetTransfersUsernameTransferIdChunksIndexResponse parses an HTTP response from a GetTransfersUsernameTransferIdChunksIndexWithResponse call
+func ParseGetTransfersUsernameTransferIdChunksIndexResponse(rsp *http.Response) (*GetTransfersUsernameTransferIdChunksIndexResponse, error) {
 	bodyBytes, err := io.ReadAll(rsp.Body)
 	defer func() { _ = rsp.Body.Close() }()
 	if err != nil {
 		return nil, err
 	}
 
-	response := &GetMessagesUsernameResponse{
+	response := &GetTransfersUsernameTransferIdChunksIndexResponse{
 		Body:         bodyBytes,
 		HTTPResponse: rsp,
 	}
 
 	switch {
 	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
-		var dest []Message
+		var dest TransferChunk
 		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
 			return nil, err
 		}
@@ -906,20 +3045,27 @@ func ParseGetMessagesUsernameResponse(rsp *http.Response) (*GetMessagesUsernameR
 	return response, nil
 }
 
-// ParsePostMessagesUsernameResponse parses an HTTP response from a PostMessagesUsernameWithResponse call
-func ParsePostMessagesUsernameResponse(rsp *http.Response) (*PostMessagesUsernameResponse, error) {
+// ParsePutTransfersUsernameTransferIdChunksIndexResponse parses an HTTP response from a PutTransfersUsernameTransferIdChunksIndexWithResponse call
+func ParsePutTransfersUsernameTransferIdChunksIndexResponse(rsp *http.Response) (*PutTransfersUsernameTransferIdChunksIndexResponse, error) {
 	bodyBytes, err := io.ReadAll(rsp.Body)
 	defer func() { _ = rsp.Body.Close() }()
 	if err != nil {
 		return nil, err
 	}
 
-	response := &PostMessagesUsernameResponse{
+	response := &PutTransfersUsernameTransferIdChunksIndexResponse{
 		Body:         bodyBytes,
 		HTTPResponse: rsp,
 	}
 
 	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
 	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
 		var dest ErrorResponse
 		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
@@ -997,3 +3143,109 @@ func ParseGetUsersUsernameResponse(rsp *http.Response) (*GetUsersUsernameRespons
 
 	return response, nil
 }
+
+// ParsePostUsersUsernameDevicesResponse parses an HTTP response from a PostUsersUsernameDevicesWithResponse call
+func ParsePostUsersUsernameDevicesResponse(rsp *http.Response) (*PostUsersUsernameDevicesResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &PostUsersUsernameDevicesResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 404:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON404 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParseGetUsersUsernameKeyResponse parses an HTTP response from a GetUsersUsernameKeyWithResponse call
+func ParseGetUsersUsernameKeyResponse(rsp *http.Response) (*GetUsersUsernameKeyResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetUsersUsernameKeyResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest []KeyRotation
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 404:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON404 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParsePutUsersUsernameKeyResponse parses an HTTP response from a PutUsersUsernameKeyWithResponse call
+func ParsePutUsersUsernameKeyResponse(rsp *http.Response) (*PutUsersUsernameKeyResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &PutUsersUsernameKeyResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 404:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON404 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 409:
+		var dest ErrorResponse
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON409 = &dest
+
+	}
+
+	return response, nil
+}
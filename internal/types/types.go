@@ -3,6 +3,7 @@ package types
 import (
 	"crypto/rsa"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/marc921/talk/internal/types/openapi"
@@ -12,12 +13,156 @@ type PlainText = []byte
 
 var ErrUserAlreadyExists = errors.New("user already exists")
 var ErrNotFound = errors.New("not found")
+var ErrInvalidPrekey = errors.New("invalid prekey")
+var ErrInvalidDevice = errors.New("invalid device")
+var ErrInvalidKey = errors.New("invalid key")
+var ErrStaleKey = errors.New("not the current key")
+var ErrInvalidMessageID = errors.New("invalid message id")
+var ErrInvalidChunk = errors.New("invalid chunk")
+var ErrInvalidToken = errors.New("invalid token")
+var ErrRateLimited = errors.New("rate limited")
+
+// Message envelope versions, see openapi.Message.Version.
+const (
+	// MessageVersionPKCS1v15 wraps the symmetric key with RSA PKCS#1 v1.5 and signs with PKCS#1 v1.5.
+	MessageVersionPKCS1v15 = 1
+	// MessageVersionOAEP wraps the symmetric key with RSA-OAEP and signs with RSA-PSS.
+	MessageVersionOAEP = 2
+	// MessageVersionSession encrypts with a forward-secret session key and signs with RSA-PSS.
+	// The RSA-OAEP wrapped key, if any, is the session's shared secret.
+	MessageVersionSession = 3
+)
+
+// MessageVersion returns the envelope version of a message, defaulting to the legacy format.
+func MessageVersion(message *openapi.Message) int {
+	if message.Version == nil {
+		return MessageVersionPKCS1v15
+	}
+	return *message.Version
+}
+
+// File transfers, see openapi.Transfer.
+const (
+	// TransferChunkSize is the size of the plaintext chunks files are split into.
+	TransferChunkSize = 256 << 10
+	// MaxTransferChunkSize bounds the size of the encrypted chunks stored by the server,
+	// leaving room for the nonce and tag added by encryption.
+	MaxTransferChunkSize = TransferChunkSize + 1<<10
+	// MaxTransferChunks bounds the number of chunks of a transfer, hence the size of the files sent.
+	MaxTransferChunks = 1 << 16
+)
 
 type PublicUser struct {
 	Name      openapi.Username
 	PublicKey *rsa.PublicKey
 }
 
+// Device is one of a user's devices, each holding its own private key.
+type Device struct {
+	// ID is the fingerprint of the device's public key, see cryptography.Fingerprint.
+	ID        string
+	PublicKey *rsa.PublicKey
+}
+
+// DeviceSignaturePayload returns the bytes an existing device signs to add a new device to a user.
+func DeviceSignaturePayload(username openapi.Username, publicKey []byte) []byte {
+	payload := []byte(fmt.Sprintf("talk device\n%s\n", username))
+	return append(payload, publicKey...)
+}
+
+// KeyRotationPayload returns the bytes a user's current key signs to replace it with a new key.
+func KeyRotationPayload(username openapi.Username, publicKey []byte) []byte {
+	payload := []byte(fmt.Sprintf("talk key rotation\n%s\n", username))
+	return append(payload, publicKey...)
+}
+
+// ReceiptType tells whether a receipt reports messages delivered to, or read by, their recipient.
+type ReceiptType string
+
+const (
+	ReceiptDelivered ReceiptType = "delivered"
+	ReceiptRead      ReceiptType = "read"
+)
+
+// Receipt is the encrypted content of messages of kind openapi.MessageKindReceipt,
+// sent back to the sender of messages, identified by their server IDs.
+type Receipt struct {
+	Type       ReceiptType `json:"type"`
+	MessageIDs []string    `json:"message_ids"`
+}
+
+// WebSocketProtocol is the websocket subprotocol in which frames are WebSocketEvent envelopes.
+// Clients that do not request it receive bare messages, and no typing or presence events.
+const WebSocketProtocol = "talk.events.v1"
+
+// Close codes sent by the server when it closes a websocket, in the range reserved for applications.
+const (
+	// WebSocketCloseResync closes a session that fell too far behind to be pushed events.
+	// Messages it missed are still stored: the client fetches them before reconnecting.
+	WebSocketCloseResync = 4000
+	// WebSocketCloseDisconnected closes the sessions of a user whose devices were revoked.
+	WebSocketCloseDisconnected = 4001
+)
+
+// WebSocketEventType tells which field of a WebSocketEvent is set.
+type WebSocketEventType string
+
+const (
+	WebSocketEventMessage WebSocketEventType = "message"
+	// WebSocketEventReceipt carries a message of kind openapi.MessageKindReceipt.
+	WebSocketEventReceipt  WebSocketEventType = "receipt"
+	WebSocketEventTyping   WebSocketEventType = "typing"
+	WebSocketEventPresence WebSocketEventType = "presence"
+)
+
+// WebSocketEvent is the envelope of the frames exchanged over the websocket.
+type WebSocketEvent struct {
+	Type     WebSocketEventType `json:"type"`
+	Message  *openapi.Message   `json:"message,omitempty"`
+	Typing   *TypingEvent       `json:"typing,omitempty"`
+	Presence *PresenceEvent     `json:"presence,omitempty"`
+}
+
+// NewMessageEvent wraps a message in an event, of type receipt for messages of kind receipt.
+func NewMessageEvent(message *openapi.Message) *WebSocketEvent {
+	if message.Kind != nil && *message.Kind == openapi.MessageKindReceipt {
+		return &WebSocketEvent{Type: WebSocketEventReceipt, Message: message}
+	}
+	return &WebSocketEvent{Type: WebSocketEventMessage, Message: message}
+}
+
+// TypingEvent tells a user that another user started or stopped typing to them.
+// The sender is set by the server.
+type TypingEvent struct {
+	From   openapi.Username `json:"from"`
+	To     openapi.Username `json:"to"`
+	Typing bool             `json:"typing"`
+}
+
+// PresenceEvent tells a user that one of their correspondents came online or went offline.
+type PresenceEvent struct {
+	Username openapi.Username `json:"username"`
+	Online   bool             `json:"online"`
+}
+
+// FileManifest is the encrypted content of messages of kind openapi.MessageKindFile, whereas
+// messages of kind openapi.MessageKindMessage hold plain text. It describes a file uploaded in
+// encrypted chunks to a transfer, and holds the key to decrypt them.
+type FileManifest struct {
+	TransferID string `json:"transfer_id"`
+	// Name is the base name of the file sent, which recipients must not trust as a path.
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type,omitempty"`
+	Size     int64  `json:"size"`
+	// Hash is the SHA-256 of the file's content, checked once the chunks are reassembled.
+	Hash       []byte `json:"hash"`
+	ChunkSize  int    `json:"chunk_size"`
+	ChunkCount int    `json:"chunk_count"`
+	// Key is the AES key of the chunks, each encrypted with the transfer ID and its index
+	// as additional data so that chunks cannot be swapped.
+	Key []byte `json:"key"`
+}
+
 type PlainMessage struct {
 	From        openapi.Username
 	To          openapi.Username